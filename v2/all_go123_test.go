@@ -0,0 +1,28 @@
+//go:build go1.23
+
+package congomap_test
+
+import (
+	"fmt"
+	"log"
+
+	congomap "github.com/karrick/congomap/v2"
+)
+
+func ExampleCongomap_all() {
+	cgm, err := congomap.NewSyncMutexMap()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("answer", 42)
+	for key, value := range cgm.All() {
+		cgm.Delete(key) // the loop body may use the Congomap
+		fmt.Println(key, value)
+	}
+	fmt.Println(cgm.Len())
+	// Output:
+	// answer 42
+	// 0
+}
@@ -0,0 +1,134 @@
+package congomap
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// badLookups memoizes the errors returned by the lookup callback function, so that a failing
+// backend is not queried again for a key until its memoized error goes stale or expires.
+type badLookups struct {
+	lock sync.Mutex
+	db   map[string]*badLookup
+}
+
+type badLookup struct {
+	err        error
+	stale      time.Time // zero time means the error never goes stale
+	expiry     time.Time
+	refreshing bool // true while a go routine refreshes a stale error
+}
+
+// fetch returns the value for key from the lookup callback function, or the error it returned.
+// When bad lookups are memoized, a memoized error that has not yet expired is returned without
+// invoking the lookup callback function. Once that error goes stale, a single go routine invokes
+// the lookup callback function in the background, and passes a value it obtains to store. The
+// lookup function is passed ctx, except in the background, where no caller is waiting.
+func (c *config) fetch(ctx context.Context, lookup lookupFunc, key string, store func(string, interface{})) (interface{}, error) {
+	if c.badExpiry == 0 {
+		value, err := c.retryLookup(ctx, lookup, key)
+		if err != nil {
+			return nil, lookupError(key, err)
+		}
+		return value, nil
+	}
+
+	now := c.now()
+
+	c.bad.lock.Lock()
+	if bl, ok := c.bad.db[key]; ok && now.Before(bl.expiry) {
+		if !bl.stale.IsZero() && !now.Before(bl.stale) && !bl.refreshing {
+			bl.refreshing = true
+			c.goBackground(func() {
+				if c.admit() != nil {
+					return // no new lookups while draining
+				}
+				defer c.release()
+				if value, err := c.lookupBad(context.Background(), lookup, key); err == nil {
+					store(key, c.lookupValue(value))
+				}
+			})
+		}
+		c.bad.lock.Unlock()
+		return nil, bl.err
+	}
+	c.bad.lock.Unlock()
+
+	return c.lookupBad(ctx, lookup, key)
+}
+
+// lookupBad invokes the lookup callback function, memoizing the error it returns, or forgetting
+// any error previously memoized for key when it succeeds.
+func (c *config) lookupBad(ctx context.Context, lookup lookupFunc, key string) (interface{}, error) {
+	value, err := c.retryLookup(ctx, lookup, key)
+	if err != nil {
+		err = lookupError(key, err)
+	}
+
+	now := c.now()
+
+	c.bad.lock.Lock()
+	if err == nil {
+		delete(c.bad.db, key)
+	} else {
+		bl := &badLookup{err: err, expiry: now.Add(c.badExpiry)}
+		if c.badStale > 0 {
+			bl.stale = now.Add(c.badStale)
+		}
+		if c.bad.db == nil {
+			c.bad.db = make(map[string]*badLookup)
+		}
+		c.bad.db[key] = bl
+	}
+	c.bad.lock.Unlock()
+
+	return value, err
+}
+
+// gcBadLookups forgets memoized errors that have expired.
+func (c *config) gcBadLookups(now time.Time) {
+	c.bad.lock.Lock()
+	for key, bl := range c.bad.db {
+		if !now.Before(bl.expiry) {
+			delete(c.bad.db, key)
+		}
+	}
+	c.bad.lock.Unlock()
+}
+
+// BadExpiryDuration is used to specify how long an error returned by the lookup callback function
+// is memoized for its key. Until that error expires, LoadStore returns it for that key without
+// invoking the lookup callback function again, sparing a failing backend from a flood of repeated
+// requests. When not specified, lookup errors are not memoized.
+func BadExpiryDuration(duration time.Duration) Setter {
+	return configure(func(c *config) error {
+		if duration <= 0 {
+			return ErrInvalidDuration(duration)
+		}
+		c.badExpiry = duration
+		return nil
+	})
+}
+
+// ErrorTTL is used to specify how long an error returned by the lookup callback function is
+// memoized for its key, exactly as BadExpiryDuration does, for those who know negative caching by
+// that name.
+func ErrorTTL(duration time.Duration) Setter {
+	return BadExpiryDuration(duration)
+}
+
+// BadStaleDuration is used to specify how long after a lookup error is memoized it goes stale. When
+// LoadStore finds a stale memoized error for a key, it still returns that error, but also invokes
+// the lookup callback function in the background, storing the value it returns should it succeed.
+// This option only takes effect when lookup errors are memoized by BadExpiryDuration, and ought to
+// be shorter than that duration.
+func BadStaleDuration(duration time.Duration) Setter {
+	return configure(func(c *config) error {
+		if duration <= 0 {
+			return ErrInvalidDuration(duration)
+		}
+		c.badStale = duration
+		return nil
+	})
+}
@@ -0,0 +1,43 @@
+package congomap
+
+// canonicalKeys returns the canonical form of each of the keys, without modifying the given slice.
+func (c *config) canonicalKeys(keys []string) []string {
+	canonical := make([]string, len(keys))
+	for i, key := range keys {
+		canonical[i] = c.canonical(key)
+	}
+	return canonical
+}
+
+// storable returns the entry to store for each of the values written by StoreMany, keyed by
+// the canonical form of its key, omitting keys rejected by a KeyValidator.
+func (c *config) storable(pairs map[string]interface{}) map[string]*entry {
+	evs := make(map[string]*entry, len(pairs))
+	for key, value := range pairs {
+		key = c.canonical(key)
+		if c.checkKey(key) == nil {
+			evs[key] = c.storeValue(key, value)
+		}
+	}
+	return evs
+}
+
+// loadMany is the common implementation of the LoadMany method. It invokes fetch with the canonical
+// form of the keys, which returns the value stored for each of them, or nil when absent, in a single
+// pass over the data store, then returns the live values keyed by the keys as given.
+func (c *config) loadMany(mutate func(string, mutator), keys []string, fetch func([]string) []*entry) map[string]interface{} {
+	canonical := c.canonicalKeys(keys)
+	evs := fetch(canonical)
+	now := c.now()
+	values := make(map[string]interface{}, len(keys))
+	for i, ev := range evs {
+		hit := ev != nil && ev.live(now)
+		c.loaded(canonical[i], hit)
+		if hit {
+			c.use(&ev.used)
+			c.accessed(mutate, canonical[i])
+			values[keys[i]] = c.copied(ev.Value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,126 @@
+package congomap
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// defaultEvictionSamples is how many entries are sampled to choose each one to evict, when
+// MaxEntries is specified without EvictionSamples.
+const defaultEvictionSamples = 5
+
+// ErrInvalidCount is returned when an option that requires a positive count is given a count of
+// less than or equal to zero.
+type ErrInvalidCount struct {
+	Option string
+	Count  int
+}
+
+func (e ErrInvalidCount) Error() string {
+	return fmt.Sprintf("congomap: %s must be greater than 0: %d", e.Option, e.Count)
+}
+
+// MaxEntries is used to specify the most keys the Congomap holds. Whenever a new key would exceed
+// the limit, another key is evicted, and its value is sent to the reaper. Rather than maintain the
+// bookkeeping needed to find the best key to evict, a few keys are sampled at random, as specified
+// by EvictionSamples, and the sampled key whose value expires soonest is evicted. Values that never
+// expire are only evicted when every sampled value never expires. Specify EvictLRU to evict the
+// sampled key least recently used instead.
+func MaxEntries(n int) Setter {
+	return configure(func(c *config) error {
+		if n <= 0 {
+			return ErrInvalidCount{Option: "MaxEntries", Count: n}
+		}
+		c.maxEntries = n
+		return nil
+	})
+}
+
+// EvictionSamples is used to specify how many keys are sampled to choose each key evicted when the
+// Congomap holds MaxEntries keys, or values costing MaxCost. More samples make better choices at a
+// higher cost. The default is 5.
+func EvictionSamples(k int) Setter {
+	return configure(func(c *config) error {
+		if k <= 0 {
+			return ErrInvalidCount{Option: "EvictionSamples", Count: k}
+		}
+		c.evictionSamples = k
+		return nil
+	})
+}
+
+// EvictLRU is used to specify that the key evicted when the Congomap holds MaxEntries keys, or
+// values costing MaxCost, is the sampled key least recently used, rather than the one whose value
+// expires soonest. A key is used when its value is written, or read by Load, LoadMany, or
+// LoadStore. Like Redis, the Congomap approximates LRU by sampling rather than maintaining a list of
+// every key, so a larger number of EvictionSamples makes it more accurate.
+func EvictLRU() Setter {
+	return configure(func(c *config) error {
+		c.lru = true
+		return nil
+	})
+}
+
+// use records that a value was used now, in the counter of nanoseconds since the epoch consulted by
+// EvictLRU. It does nothing unless EvictLRU was specified.
+func (c *config) use(used *int64) {
+	if c.lru {
+		atomic.StoreInt64(used, c.now().UnixNano())
+	}
+}
+
+// victim returns the key whose value expires soonest, or that was least recently used when EvictLRU
+// was specified, among the keys sampled from those visited by each, other than the key that was just
+// written, and false when there is no other key. The zero expiry means the value never expires.
+func (c *config) victim(written string, each func(func(key string, expiry time.Time, used int64) bool)) (string, bool) {
+	samples := c.evictionSamples
+	if samples == 0 {
+		samples = defaultEvictionSamples
+	}
+	var victim string
+	var soonest time.Time
+	var oldest int64
+	var found bool
+	each(func(key string, expiry time.Time, used int64) bool {
+		if key == written {
+			return true
+		}
+		if !found || (c.lru && used < oldest) || (!c.lru && !expiry.IsZero() && (soonest.IsZero() || expiry.Before(soonest))) {
+			victim, soonest, oldest, found = key, expiry, used, true
+		}
+		samples--
+		return samples > 0
+	})
+	return victim, found
+}
+
+// eachOf returns a function that visits the key, expiry, and time of most recent use of each value
+// in the data store, for choosing keys to evict.
+func eachOf(db map[string]*entry) func(func(string, time.Time, int64) bool) {
+	return func(fn func(string, time.Time, int64) bool) {
+		for k, ev := range db { // map iteration order is randomized
+			if !fn(k, ev.Expiry, atomic.LoadInt64(&ev.used)) {
+				return
+			}
+		}
+	}
+}
+
+// evict removes sampled keys from the data store while it holds more than MaxEntries keys, or values
+// costing more than MaxCost, and returns their pairs to be reaped. The caller must serialize access
+// to the data store.
+func (c *config) evict(db map[string]*entry, written string) []Pair {
+	var evicted []Pair
+	for c.full(len(db)) {
+		key, ok := c.victim(written, eachOf(db))
+		if !ok {
+			break
+		}
+		if c.reaping() {
+			evicted = append(evicted, Pair{key, db[key].Value})
+		}
+		c.remove(db, key)
+	}
+	return evicted
+}
@@ -1,19 +1,19 @@
 package congomap
 
 import (
+	"context"
 	"sync"
 	"time"
 )
 
 type channelMap struct {
-	db    map[string]*ExpiringValue
+	db    map[string]*entry
 	queue chan func()
 
-	halt   chan struct{}
-	lookup func(string) (interface{}, error)
-	reaper func(interface{})
+	halt chan struct{}
+	done chan struct{} // closed when run returns
 
-	ttl time.Duration
+	config
 }
 
 // NewChannelMap returns a map that uses channels to serialize access.
@@ -28,17 +28,16 @@ type channelMap struct {
 //	defer func() { _ = cgm.Close() }()
 func NewChannelMap(setters ...Setter) (Congomap, error) {
 	cgm := &channelMap{
-		db:    make(map[string]*ExpiringValue),
+		db:    make(map[string]*entry),
 		halt:  make(chan struct{}),
+		done:  make(chan struct{}),
 		queue: make(chan func()),
 	}
-	for _, setter := range setters {
-		if err := setter(cgm); err != nil {
-			return nil, err
-		}
+	if err := applySetters(cgm, setters); err != nil {
+		return nil, err
 	}
 	if cgm.lookup == nil {
-		cgm.lookup = func(_ string) (interface{}, error) {
+		cgm.lookup = func(context.Context, string) (interface{}, error) {
 			return nil, ErrNoLookupDefined{}
 		}
 	}
@@ -46,188 +45,661 @@ func NewChannelMap(setters ...Setter) (Congomap, error) {
 	return cgm, nil
 }
 
-func (cgm *channelMap) Lookup(lookup func(string) (interface{}, error)) error {
-	cgm.lookup = lookup
-	return nil
+func (cgm *channelMap) All() func(yield func(string, interface{}) bool) {
+	return all(cgm)
 }
 
-func (cgm *channelMap) Reaper(reaper func(interface{})) error {
-	cgm.reaper = reaper
-	return nil
+func (cgm *channelMap) Clear() {
+	var db map[string]*entry
+	var wg sync.WaitGroup
+	wg.Add(1)
+	if !cgm.enqueue(func() {
+		db = cgm.db
+		cgm.db = make(map[string]*entry)
+		cgm.resetCost()
+		wg.Done()
+	}) {
+		return
+	}
+	wg.Wait()
+	cgm.reapAll(pairsOf(db), ReasonCleared)
 }
 
-func (cgm *channelMap) TTL(duration time.Duration) error {
-	if duration <= 0 {
-		return ErrInvalidDuration(duration)
+func (cgm *channelMap) Prune(n int) int {
+	var pruned []Pair
+	var wg sync.WaitGroup
+	wg.Add(1)
+	if !cgm.enqueue(func() {
+		pruned = cgm.pruneLocked(cgm.db, n)
+		wg.Done()
+	}) {
+		return 0
 	}
-	cgm.ttl = duration
-	return nil
+	wg.Wait()
+	cgm.reapAll(pruned, ReasonEvicted)
+	return len(pruned)
 }
 
 func (cgm *channelMap) Delete(key string) {
-	cgm.queue <- func() {
+	key = cgm.canonical(key)
+	cgm.enqueue(func() {
 		ev, ok := cgm.db[key]
-		if ok && cgm.reaper != nil {
-			cgm.reaper(ev.Value)
+		if ok && cgm.reaping() {
+			cgm.reap(key, ev.Value, ReasonDeleted)
+		}
+		cgm.remove(cgm.db, key)
+	})
+}
+
+func (cgm *channelMap) DeleteIf(key string, fn func(interface{}) bool) bool {
+	return deleteIf(cgm.mutate, cgm.canonical(key), fn)
+}
+
+func (cgm *channelMap) DeleteMany(keys []string) {
+	keys = cgm.canonicalKeys(keys)
+	var removed []Pair
+	var wg sync.WaitGroup
+	wg.Add(1)
+	if !cgm.enqueue(func() {
+		for _, key := range keys {
+			if ev, ok := cgm.db[key]; ok {
+				if cgm.reaping() {
+					removed = append(removed, Pair{key, ev.Value})
+				}
+				cgm.remove(cgm.db, key)
+			}
 		}
-		delete(cgm.db, key)
+		wg.Done()
+	}) {
+		return
 	}
+	wg.Wait()
+	cgm.reapAll(removed, ReasonDeleted)
 }
 
-func (cgm *channelMap) GC() {
+func (cgm *channelMap) Do(key string, fn func(interface{}, bool) (interface{}, bool)) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return
+	}
+	defer cgm.release()
+	cgm.mutate(key, doMutator(fn, cgm.storeFunc(key)))
+}
+
+func (cgm *channelMap) Update(key string, fn func(interface{}, bool) (interface{}, bool)) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return
+	}
+	defer cgm.release()
+	cgm.mutate(key, updateMutator(fn, cgm.storeFunc(key)))
+}
+
+// mutate invokes fn with the live value for key from the queue go routine, and replaces that value
+// with what fn returns.
+func (cgm *channelMap) mutate(key string, fn mutator) {
 	var wg sync.WaitGroup
+	wg.Add(1)
+	if !cgm.enqueue(func() {
+		stored, ok := cgm.db[key]
+		ev := stored
+		if ok && !stored.live(cgm.now()) {
+			ev = nil // expired values are never shown to fn
+		}
 
-	cgm.queue <- func() {
-		now := time.Now()
-		for key, ev := range cgm.db {
-			if !ev.Expiry.IsZero() && now.After(ev.Expiry) {
-				delete(cgm.db, key)
-				if cgm.reaper != nil {
-					wg.Add(1)
-					go func(value interface{}) {
-						cgm.reaper(value)
-						wg.Done()
-					}(ev.Value)
-				}
+		next, reap := fn(ev)
+		if next != ev {
+			if next == nil {
+				cgm.remove(cgm.db, key)
+			} else {
+				cgm.put(cgm.db, key, next)
+				cgm.evictAsync(key, &wg)
+			}
+			// expired values being discarded are always reaped
+			if ok && cgm.reaping() && (reap || ev == nil) {
+				wg.Add(1)
+				go func(value interface{}, reason EvictionReason) {
+					cgm.reap(key, value, reason)
+					wg.Done()
+				}(stored.Value, mutated(ev, next))
+			}
+		}
+		wg.Done()
+	}) {
+		return
+	}
+	wg.Wait()
+}
+
+// evictAsync evicts sampled keys while the data store holds more than MaxEntries keys, and reaps
+// their values from another goroutine, marking wg done when finished. It must be invoked by the run
+// goroutine.
+func (cgm *channelMap) evictAsync(key string, wg *sync.WaitGroup) {
+	if evicted := cgm.evict(cgm.db, key); len(evicted) > 0 {
+		wg.Add(1)
+		go func() {
+			cgm.reapAll(evicted, ReasonEvicted)
+			wg.Done()
+		}()
+	}
+}
+
+func (cgm *channelMap) each(fn func(string, *entry) bool) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	if !cgm.enqueue(func() {
+		defer wg.Done()
+		for k, ev := range cgm.db {
+			if !fn(k, ev) {
+				return
 			}
 		}
+	}) {
+		return
 	}
 	wg.Wait()
 }
 
+func (cgm *channelMap) Expire(key string, duration time.Duration) bool {
+	key = cgm.canonical(key)
+	if cgm.admit() != nil {
+		return false
+	}
+	defer cgm.release()
+	return cgm.setExpiry(cgm.mutate, key, cgm.now().Add(duration))
+}
+
+func (cgm *channelMap) Persist(key string) bool {
+	key = cgm.canonical(key)
+	if cgm.admit() != nil {
+		return false
+	}
+	defer cgm.release()
+	return cgm.setExpiry(cgm.mutate, key, time.Time{})
+}
+
+func (cgm *channelMap) TTLRemaining(key string) (time.Duration, bool) {
+	key = cgm.canonical(key)
+	rq := make(chan *entry)
+	if !cgm.enqueue(func() {
+		rq <- cgm.db[key]
+	}) {
+		return 0, false
+	}
+	return remaining(<-rq, cgm.now())
+}
+
+func (cgm *channelMap) Touch(key string) bool {
+	key = cgm.canonical(key)
+	if cgm.admit() != nil {
+		return false
+	}
+	defer cgm.release()
+	return cgm.touch(cgm.mutate, key, cgm.touchDuration())
+}
+
+func (cgm *channelMap) GC() {
+	var reaped []Pair
+	done := make(chan struct{})
+	if !cgm.enqueue(func() {
+		reaped = cgm.gc()
+		close(done)
+	}) {
+		return
+	}
+	<-done
+	cgm.reapAll(reaped, ReasonExpired)
+}
+
+func (cgm *channelMap) MaintainOnce() {
+	cgm.GC()
+}
+
+// gc removes evictable values from the data store and returns their pairs to be reaped. It must be
+// invoked by the run goroutine.
+func (cgm *channelMap) gc() []Pair {
+	var reaped []Pair
+	now := cgm.now()
+	cgm.gcBadLookups(now)
+	if cgm.expiries != nil {
+		evicted, reaped := cgm.sweepExpired(cgm.db, now)
+		cgm.recordSweep(now, evicted, len(cgm.db))
+		return reaped
+	}
+	sampler := newTTLSampler(now)
+	for key, ev := range cgm.db {
+		if cgm.evictable(ev, now) {
+			cgm.remove(cgm.db, key)
+			sampler.evict()
+			reaped = append(reaped, Pair{key, ev.Value})
+		} else {
+			sampler.add(ev.Expiry)
+		}
+	}
+	cgm.recordTTLs(sampler)
+	return reaped
+}
+
 func (cgm *channelMap) Load(key string) (interface{}, bool) {
+	key = cgm.canonical(key)
+	if cgm.isClosed() {
+		return nil, false
+	}
 	rq := make(chan result)
-	cgm.queue <- func() {
+	if !cgm.enqueue(func() {
 		ev, ok := cgm.db[key]
-		if ok && (ev.Expiry.IsZero() || ev.Expiry.After(time.Now())) {
+		if ok && (ev.Expiry.IsZero() || ev.Expiry.After(cgm.now())) {
+			cgm.use(&ev.used)
 			rq <- result{value: ev.Value, ok: true}
 			return
 		}
 		rq <- result{value: nil, ok: false}
+	}) {
+		return nil, false
 	}
 	res := <-rq
-	return res.value, res.ok
+	cgm.loaded(key, res.ok)
+	if !res.ok {
+		return nil, false
+	}
+	cgm.accessed(cgm.mutate, key)
+	return cgm.copied(res.value), true
+}
+
+func (cgm *channelMap) LoadMany(keys []string) map[string]interface{} {
+	return cgm.loadMany(cgm.mutate, keys, func(keys []string) []*entry {
+		evs := make([]*entry, len(keys))
+		var wg sync.WaitGroup
+		wg.Add(1)
+		if !cgm.enqueue(func() {
+			for i, key := range keys {
+				evs[i] = cgm.db[key]
+			}
+			wg.Done()
+		}) {
+			return evs
+		}
+		wg.Wait()
+		return evs
+	})
 }
 
 func (cgm *channelMap) LoadStore(key string) (interface{}, error) {
+	value, _, err := cgm.LoadStoreInfo(key)
+	return value, err
+}
+
+func (cgm *channelMap) LoadStoreInfo(key string) (interface{}, bool, error) {
+	return cgm.loadStoreWith(context.Background(), key, cgm.lookup)
+}
+
+func (cgm *channelMap) LoadStoreContext(ctx context.Context, key string) (interface{}, error) {
+	value, _, err := cgm.loadStoreWith(ctx, key, cgm.lookup)
+	return value, err
+}
+
+func (cgm *channelMap) LoadStoreWith(key string, lookup func(string) (interface{}, error)) (interface{}, error) {
+	value, _, err := cgm.loadStoreWith(context.Background(), key, withoutContext(lookup))
+	return value, err
+}
+
+// loadStoreWith does the work of LoadStoreInfo, LoadStoreContext, and LoadStoreWith, passing ctx to
+// the lookup function.
+func (cgm *channelMap) loadStoreWith(ctx context.Context, key string, lookup lookupFunc) (interface{}, bool, error) {
+	ctx, end := cgm.trace(ctx, "LoadStore", key)
+	value, looked, err := cgm.loadStoreInfo(ctx, key, lookup)
+	end(err)
+	key = cgm.canonical(key)
+	cgm.loadStored(key, looked, err)
+	if err != nil {
+		return nil, false, err
+	}
+	if !looked {
+		cgm.accessed(cgm.mutate, key)
+	}
+	return cgm.copied(value), looked, nil
+}
+
+// loadStoreInfo does the work of loadStoreWith, returning the value held by the Congomap.
+func (cgm *channelMap) loadStoreInfo(ctx context.Context, key string, lookup lookupFunc) (interface{}, bool, error) {
+	key = cgm.canonical(key)
+	if err := cgm.checkKey(key); err != nil {
+		return nil, false, err
+	}
+	if err := cgm.admit(); err != nil {
+		return nil, false, err
+	}
+	defer cgm.release()
 	var wg sync.WaitGroup
 	rq := make(chan result)
-	cgm.queue <- func() {
+	if !cgm.enqueue(func() {
 		ev, ok := cgm.db[key]
-		if ok && (ev.Expiry.IsZero() || ev.Expiry.After(time.Now())) {
+		if ok && (ev.Expiry.IsZero() || ev.Expiry.After(cgm.now())) {
+			cgm.use(&ev.used)
+			cgm.refreshAhead(key, ev, lookup, cgm.mutate)
 			rq <- result{value: ev.Value, ok: true}
 			return
 		}
 		// key not there or expired
-		value, err := cgm.lookup(key)
+		value, err := cgm.fetch(ctx, lookup, key, cgm.Store)
 		if err != nil {
+			if ok && cgm.servesStale(ev, cgm.now()) {
+				rq <- result{value: ev.Value, ok: true}
+				return
+			}
 			rq <- result{value: nil, ok: false, err: err}
 			return
 		}
 
-		if ok && cgm.reaper != nil {
+		if ok && cgm.reaping() {
 			wg.Add(1)
 			go func(value interface{}) {
-				cgm.reaper(value)
+				cgm.reap(key, value, ReasonExpired)
 				wg.Done()
 			}(ev.Value)
 		}
 
-		cgm.db[key] = newExpiringValue(value, cgm.ttl)
-		rq <- result{value: value, ok: true}
+		cgm.put(cgm.db, key, cgm.lookupValue(value))
+		cgm.evictAsync(key, &wg)
+		rq <- result{value: value, ok: true, looked: true}
+	}) {
+		return nil, false, ErrClosed{}
 	}
 	res := <-rq
 	wg.Wait() // must be after receive from rq to ensure Add had a chance to run
-	return res.value, res.err
+	return res.value, res.looked, res.err
+}
+
+func (cgm *channelMap) LoadStoreAll(keys []string) map[string]LoadStoreResult {
+	return loadStoreAll(cgm, keys)
+}
+
+func (cgm *channelMap) NextExpiry() (time.Time, bool) {
+	var wg sync.WaitGroup
+	var next time.Time
+	wg.Add(1)
+	if !cgm.enqueue(func() {
+		for _, ev := range cgm.db {
+			next = earliest(next, ev)
+		}
+		wg.Done()
+	}) {
+		return time.Time{}, false
+	}
+	wg.Wait()
+	return next, !next.IsZero()
 }
 
 func (cgm *channelMap) Store(key string, value interface{}) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return
+	}
+	defer cgm.release()
 	var wg sync.WaitGroup
 	wg.Add(1)
-	cgm.queue <- func() {
+	if !cgm.enqueue(func() {
 		ev, ok := cgm.db[key]
 
-		if ok && cgm.reaper != nil {
+		if ok && cgm.reaping() {
 			wg.Add(1)
 			go func(value interface{}) {
-				cgm.reaper(value)
+				cgm.reap(key, value, ReasonReplaced)
 				wg.Done()
 			}(ev.Value)
 		}
 
-		cgm.db[key] = newExpiringValue(value, cgm.ttl)
+		cgm.put(cgm.db, key, cgm.storeValue(key, value))
+		cgm.evictAsync(key, &wg)
 		wg.Done()
+	}) {
+		return
 	}
 	wg.Wait()
 }
 
-func (cgm channelMap) Keys() []string {
+func (cgm *channelMap) StoreMany(pairs map[string]interface{}) {
+	if cgm.admit() != nil {
+		return
+	}
+	defer cgm.release()
+	evs := cgm.storable(pairs)
+	var replaced []Pair
 	var wg sync.WaitGroup
-	keys := make([]string, 0, len(cgm.db))
 	wg.Add(1)
-	cgm.queue <- func() {
+	if !cgm.enqueue(func() {
+		for key, ev := range evs {
+			if prev, ok := cgm.db[key]; ok && cgm.reaping() {
+				replaced = append(replaced, Pair{key, prev.Value})
+			}
+			cgm.put(cgm.db, key, ev)
+			cgm.evictAsync(key, &wg)
+		}
+		wg.Done()
+	}) {
+		return
+	}
+	wg.Wait()
+	cgm.reapAll(replaced, ReasonReplaced)
+}
+
+func (cgm *channelMap) Snapshot() map[string]interface{} {
+	return cgm.snapshot(cgm)
+}
+
+func (cgm *channelMap) Len() int {
+	return liveCount(cgm)
+}
+
+func (cgm *channelMap) LoadAndDelete(key string) (interface{}, bool) {
+	return loadAndDelete(cgm.mutate, cgm.canonical(key))
+}
+
+func (cgm *channelMap) LoadOrStore(key string, value interface{}) (interface{}, bool) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return nil, false
+	}
+	defer cgm.release()
+	return loadOrStore(cgm.mutate, key, cgm.storeValue(key, value))
+}
+
+func (cgm *channelMap) StoreWithTTL(key string, value interface{}, ttl time.Duration) {
+	cgm.Store(key, cgm.withTTL(value, ttl))
+}
+
+func (cgm *channelMap) StoreReturning(key string, value interface{}) (interface{}, bool) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return nil, false
+	}
+	defer cgm.release()
+	return storeReturning(cgm.mutate, key, cgm.storeValue(key, value))
+}
+
+func (cgm *channelMap) Swap(key string, value interface{}) (interface{}, bool) {
+	return cgm.StoreReturning(key, value)
+}
+
+func (cgm *channelMap) Keys() []string {
+	var wg sync.WaitGroup
+	var keys []string
+	wg.Add(1)
+	if !cgm.enqueue(func() {
+		keys = make([]string, 0, len(cgm.db))
 		for k := range cgm.db {
 			keys = append(keys, k)
 		}
 		wg.Done()
+	}) {
+		return nil
+	}
+	wg.Wait()
+	return keys
+}
+
+func (cgm *channelMap) AppendKeys(buf []string) []string {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	if !cgm.enqueue(func() {
+		for k := range cgm.db {
+			buf = append(buf, k)
+		}
+		wg.Done()
+	}) {
+		return buf
+	}
+	wg.Wait()
+	return buf
+}
+
+func (cgm *channelMap) KeysMatching(match func(string) bool) []string {
+	var wg sync.WaitGroup
+	var keys []string
+	wg.Add(1)
+	if !cgm.enqueue(func() {
+		for k := range cgm.db {
+			if match(k) {
+				keys = append(keys, k)
+			}
+		}
+		wg.Done()
+	}) {
+		return nil
 	}
 	wg.Wait()
 	return keys
 }
 
-func (cgm *channelMap) Pairs() <-chan *Pair {
-	pairs := make(chan *Pair)
-	cgm.queue <- func() {
-		now := time.Now()
+func (cgm *channelMap) KeysWithPrefix(prefix string) []string {
+	return cgm.KeysMatching(hasPrefix(prefix))
+}
+
+func (cgm *channelMap) KeysPage(cursor string, limit int) ([]string, string) {
+	p := newKeyPager(cursor, limit)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	if !cgm.enqueue(func() {
+		for k := range cgm.db {
+			p.add(k)
+		}
+		wg.Done()
+	}) {
+		return nil, ""
+	}
+	wg.Wait()
+	return p.page()
+}
+
+func (cgm *channelMap) Pairs() <-chan Pair {
+	if cgm.snapshotPairs {
+		return snapshotPairs(cgm)
+	}
+	var wg sync.WaitGroup
+	var pairs chan Pair
+	wg.Add(1)
+	if !cgm.enqueue(func() {
+		pairs = newPairs(len(cgm.db))
+		wg.Done()
+		now := cgm.now()
 		for key, ev := range cgm.db {
 			if ev.Expiry.IsZero() || (ev.Expiry.After(now)) {
-				pairs <- &Pair{key, ev.Value}
+				pairs <- Pair{key, ev.Value}
 			}
 		}
 		close(pairs)
+	}) {
+		pairs = make(chan Pair)
+		close(pairs)
+		return pairs
 	}
+	wg.Wait()
 	return pairs
 }
 
+func (cgm *channelMap) PairsByExpiry() <-chan Pair {
+	var wg sync.WaitGroup
+	var eps []expiringPair
+	wg.Add(1)
+	if !cgm.enqueue(func() {
+		eps = make([]expiringPair, 0, len(cgm.db))
+		for key, ev := range cgm.db {
+			eps = append(eps, expiringPair{key, ev})
+		}
+		wg.Done()
+	}) {
+		return pairsByExpiry(nil, cgm.now())
+	}
+	wg.Wait()
+	return pairsByExpiry(eps, cgm.now())
+}
+
 func (cgm *channelMap) Close() error {
-	close(cgm.halt)
-	return nil
+	if cgm.closing() {
+		close(cgm.halt)
+	}
+	<-cgm.done
+	cgm.closeEvents()
+	return cgm.closeErr()
+}
+
+// enqueue sends fn to be invoked by the run goroutine, and returns true, or returns false without
+// invoking fn once the run goroutine has returned, so methods invoked after Close do not wait
+// forever.
+func (cgm *channelMap) enqueue(fn func()) bool {
+	select {
+	case cgm.queue <- fn:
+		return true
+	case <-cgm.done:
+		return false
+	}
 }
 
 type result struct {
-	value interface{}
-	ok    bool
-	err   error
+	value  interface{}
+	ok     bool
+	looked bool // value was obtained by invoking the lookup callback function
+	err    error
 }
 
-func (cgm *channelMap) run() {
-	gcPeriodicity := 15 * time.Minute
-	if cgm.ttl > 0 && cgm.ttl <= time.Second {
-		gcPeriodicity = time.Minute
+func (cgm *channelMap) running() bool {
+	select {
+	case <-cgm.done:
+		return false
+	default:
+		return true
 	}
+}
+
+func (cgm *channelMap) run() {
+	defer close(cgm.done)
+
+	ticks, stop := cgm.gcTicker()
+	defer stop()
 
 	active := true
 	for active {
 		select {
 		case fn := <-cgm.queue:
 			fn()
-		case <-time.After(gcPeriodicity):
-			cgm.GC()
+		case <-ticks:
+			if !cgm.gcDue() {
+				continue
+			}
+			// GC would deadlock sending to the queue this goroutine reads
+			reaped := cgm.gc()
+			cgm.goBackground(func() { cgm.reapAll(reaped, ReasonExpired) })
 		case <-cgm.halt:
 			active = false
 		}
 	}
 
-	if cgm.reaper != nil {
-		var wg sync.WaitGroup
-		wg.Add(len(cgm.db))
+	if cgm.reaping() {
+		reaped := make([]Pair, 0, len(cgm.db))
 		for key, ev := range cgm.db {
-			delete(cgm.db, key)
-			go func(value interface{}) {
-				cgm.reaper(value)
-				wg.Done()
-			}(ev.Value)
+			cgm.remove(cgm.db, key)
+			reaped = append(reaped, Pair{key, ev.Value})
 		}
-		wg.Wait()
+		cgm.reapAll(reaped, ReasonClosed)
 	}
 }
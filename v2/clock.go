@@ -0,0 +1,39 @@
+package congomap
+
+import "time"
+
+// TimeSource is the interface of a clock, which a Congomap consults for the current time when
+// deciding whether values have expired, and for scheduling its periodic GC.
+type TimeSource interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// Clock is used to specify the TimeSource a Congomap consults instead of the system clock, so tests
+// and simulations can control the passage of time, and exercise expiry without sleeping. The
+// durations of operations, such as those passed to an Observer, are still measured by the system
+// clock.
+func Clock(clock TimeSource) Setter {
+	return configure(func(c *config) error {
+		c.clock = clock
+		return nil
+	})
+}
+
+// now returns the current time according to the TimeSource, or the system clock when Clock was not
+// specified.
+func (c *config) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
+
+// Now returns the current time according to the TimeSource of the Congomap, by which it decides
+// whether its values have expired, or the system clock for a Congomap not provided by this library.
+func Now(cgm Congomap) time.Time {
+	if c, ok := cgm.(configurable); ok {
+		return c.getConfig().now()
+	}
+	return time.Now()
+}
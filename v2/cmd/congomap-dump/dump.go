@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/karrick/congomap/v2/persist"
+)
+
+// entry describes one key-value pair of a dumped Congomap.
+type entry struct {
+	Key     string     `json:"key"`
+	Expiry  *time.Time `json:"expiry,omitempty"`
+	Type    string     `json:"type"`
+	Summary string     `json:"summary"`
+}
+
+// source invokes its argument with each key-value pair of a Congomap.
+type source func(func(key string, value interface{}, expiry time.Time) error) error
+
+// snapshotSource returns a source that reads the snapshot file at the specified path.
+func snapshotSource(path string) source {
+	return func(fn func(string, interface{}, time.Time) error) error {
+		fh, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = fh.Close() }()
+		return persist.ReadSnapshot(fh, func(rec *persist.Record) error {
+			return fn(rec.Key, rec.Value, rec.Expiry)
+		})
+	}
+}
+
+// serverSource returns a source that requests every key, then the value of each key, from the
+// congomapd server at the specified base URL. Keys deleted between the requests are skipped.
+func serverSource(base string) source {
+	base = strings.TrimSuffix(base, "/")
+	return func(fn func(string, interface{}, time.Time) error) error {
+		var keys []string
+		if _, err := get(base+"/keys", &keys); err != nil {
+			return err
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			var value json.RawMessage
+			found, err := get(base+"/keys/"+url.PathEscape(key), &value)
+			if err != nil {
+				return err
+			}
+			if !found {
+				continue
+			}
+			if err = fn(key, value, time.Time{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// get decodes the JSON response body of the URL into v, returning false when the server responds
+// 404 Not Found.
+func get(u string, v interface{}) (bool, error) {
+	resp, err := http.Get(u)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, json.NewDecoder(resp.Body).Decode(v)
+	case http.StatusNotFound:
+		return false, nil
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	return false, fmt.Errorf("GET %s: %s: %s", u, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// writer writes entries in an output format.
+type writer interface {
+	write(*entry) error
+	flush() error
+}
+
+func newWriter(w io.Writer, format string) (writer, error) {
+	switch format {
+	case "jsonl":
+		return jsonWriter{json.NewEncoder(w)}, nil
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"key", "expiry", "type", "summary"}); err != nil {
+			return nil, err
+		}
+		return csvWriter{cw}, nil
+	}
+	return nil, fmt.Errorf("unknown format: %q", format)
+}
+
+type jsonWriter struct{ e *json.Encoder }
+
+func (jw jsonWriter) write(e *entry) error { return jw.e.Encode(e) }
+func (jw jsonWriter) flush() error         { return nil }
+
+type csvWriter struct{ w *csv.Writer }
+
+func (cw csvWriter) write(e *entry) error {
+	var expiry string
+	if e.Expiry != nil {
+		expiry = e.Expiry.Format(time.RFC3339Nano)
+	}
+	return cw.w.Write([]string{e.Key, expiry, e.Type, e.Summary})
+}
+
+func (cw csvWriter) flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// dump writes an entry for each key-value pair of the source, summarizing each value in at most
+// width bytes.
+func dump(out writer, src source, width int) error {
+	err := src(func(key string, value interface{}, expiry time.Time) error {
+		e := &entry{Key: key, Type: fmt.Sprintf("%T", value), Summary: summarize(value, width)}
+		if raw, ok := value.(json.RawMessage); ok {
+			e.Type = "json"
+			e.Summary = summarize(string(raw), width)
+		}
+		if !expiry.IsZero() {
+			e.Expiry = &expiry
+		}
+		return out.write(e)
+	})
+	if ferr := out.flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// summarize formats the value, truncated to at most width bytes including a trailing ellipsis.
+func summarize(value interface{}, width int) string {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = fmt.Sprintf("%q", v)
+	default:
+		s = fmt.Sprintf("%v", v)
+	}
+	if width > 3 && len(s) > width {
+		s = s[:width-3] + "..."
+	}
+	return s
+}
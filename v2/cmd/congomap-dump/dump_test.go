@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+	"github.com/karrick/congomap/v2/persist"
+)
+
+func TestDumpSnapshot(t *testing.T) {
+	cgm, err := congomap.NewSyncMutexMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+	cgm.Store("abc", "a rather long string value")
+	cgm.Store("def", 42)
+
+	path := filepath.Join(t.TempDir(), "snapshot")
+	fh, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = persist.WriteSnapshot(fh, cgm); err != nil {
+		t.Fatal(err)
+	}
+	if err = fh.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	out, err := newWriter(&buf, "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dump(out, sortedSource(snapshotSource(path)), 10); err != nil {
+		t.Fatal(err)
+	}
+	expected := "key,expiry,type,summary\nabc,,string,a rathe...\ndef,,int,42\n"
+	if actual := buf.String(); actual != expected {
+		t.Errorf("Actual: %q; Expected: %q", actual, expected)
+	}
+}
+
+func TestDumpServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/keys":
+			_, _ = w.Write([]byte(`["def","abc","gone"]`))
+		case "/keys/abc":
+			_, _ = w.Write([]byte(`{"answer":42}`))
+		case "/keys/def":
+			_, _ = w.Write([]byte(`[1,2,3]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	out, err := newWriter(&buf, "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dump(out, serverSource(server.URL), 80); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"key":"abc","type":"json","summary":"{\"answer\":42}"}` + "\n" +
+		`{"key":"def","type":"json","summary":"[1,2,3]"}` + "\n"
+	if actual := buf.String(); actual != expected {
+		t.Errorf("Actual: %q; Expected: %q", actual, expected)
+	}
+}
+
+func TestDumpExpiry(t *testing.T) {
+	expiry := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	src := source(func(fn func(string, interface{}, time.Time) error) error {
+		return fn("abc", []byte("xyz"), expiry)
+	})
+
+	var buf bytes.Buffer
+	out, err := newWriter(&buf, "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dump(out, src, 80); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"key":"abc","expiry":"2020-01-02T03:04:05Z","type":"[]uint8","summary":"\"xyz\""}` + "\n"
+	if actual := buf.String(); actual != expected {
+		t.Errorf("Actual: %q; Expected: %q", actual, expected)
+	}
+}
+
+// sortedSource returns a source that provides the pairs of src sorted by key, because snapshots
+// record pairs in the order Pairs provided them.
+func sortedSource(src source) source {
+	return func(fn func(string, interface{}, time.Time) error) error {
+		type pair struct {
+			key    string
+			value  interface{}
+			expiry time.Time
+		}
+		var pairs []pair
+		if err := src(func(key string, value interface{}, expiry time.Time) error {
+			pairs = append(pairs, pair{key, value, expiry})
+			return nil
+		}); err != nil {
+			return err
+		}
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i].key < pairs[j].key })
+		for _, p := range pairs {
+			if err := fn(p.key, p.value, p.expiry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestImportSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot")
+	input := `{"key":"def","value":[1,2,3]}` + "\n" + `{"key":"abc","value":{"answer":42}}` + "\n"
+
+	var log bytes.Buffer
+	if err := importSnapshot(strings.NewReader(input), path, 2, &log); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	out, err := newWriter(&buf, "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dump(out, sortedSource(snapshotSource(path)), 80); err != nil {
+		t.Fatal(err)
+	}
+	expected := "key,expiry,type,summary\nabc,,json,\"{\"\"answer\"\":42}\"\ndef,,json,\"[1,2,3]\"\n"
+	if actual := buf.String(); actual != expected {
+		t.Errorf("Actual: %q; Expected: %q", actual, expected)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	congomap "github.com/karrick/congomap/v2"
+	"github.com/karrick/congomap/v2/persist"
+)
+
+func init() {
+	gob.Register(json.RawMessage{}) // type of imported values
+}
+
+// importSnapshot imports the JSON-lines records read from input into a new snapshot file at the
+// specified path, reporting progress to the log writer.
+func importSnapshot(input io.Reader, path string, concurrency int, log io.Writer) error {
+	cgm, err := congomap.NewSyncMutexMap()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = cgm.Close() }()
+
+	n, err := persist.ImportJSONLines(input, cgm,
+		persist.ImportConcurrency(concurrency),
+		persist.ImportProgress(10000, func(n int) { fmt.Fprintf(log, "imported %d records\n", n) }))
+	if err != nil {
+		return err
+	}
+
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = persist.WriteSnapshot(fh, cgm); err != nil {
+		_ = fh.Close()
+		return err
+	}
+	if err = fh.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(log, "wrote %d records to %s\n", n, path)
+	return nil
+}
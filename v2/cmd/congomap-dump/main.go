@@ -0,0 +1,82 @@
+// Command congomap-dump writes the contents of a Congomap snapshot file, or of the Congomap served
+// by congomapd, as JSON lines or CSV, for offline analysis of cache contents.
+//
+//	congomap-dump -snapshot /var/lib/app/snapshot
+//	congomap-dump -server http://localhost:8080 -format csv
+//
+// Each entry has the key, the expiry of its value when known, the type of its value, and a summary
+// of its value, truncated to the -width flag. Snapshot values are decoded with encoding/gob, so
+// only values of types gob knows without registration, such as strings, numbers, and byte slices,
+// can be dumped from a snapshot. Expiries are not available from congomapd.
+//
+// With the -import flag, it instead creates a snapshot file from JSON-lines records, each an object
+// with a "key" string, a "value" of any JSON type, and an optional RFC 3339 "expiry", for seeding
+// caches from offline jobs. Values are stored as raw JSON. Records that have already expired are
+// not written, and the snapshot does not record the expiries of the others.
+//
+//	congomap-dump -import records.jsonl -snapshot /var/lib/app/snapshot
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	snapshot := flag.String("snapshot", "", "path of snapshot file to dump")
+	server := flag.String("server", "", "base URL of congomapd server to dump")
+	format := flag.String("format", "jsonl", "output format: jsonl or csv")
+	width := flag.Int("width", 80, "maximum length of value summaries")
+	input := flag.String("import", "", "path of JSON-lines records to import into a new -snapshot file, or - for standard input")
+	concurrency := flag.Int("concurrency", 4, "number of records imported concurrently")
+	flag.Parse()
+
+	var err error
+	if *input != "" {
+		err = runImport(*input, *snapshot, *concurrency)
+	} else {
+		err = run(*snapshot, *server, *format, *width)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], err)
+		os.Exit(1)
+	}
+}
+
+func run(snapshot, server, format string, width int) error {
+	var src source
+	switch {
+	case snapshot != "" && server != "":
+		return fmt.Errorf("cannot specify both -snapshot and -server")
+	case snapshot != "":
+		src = snapshotSource(snapshot)
+	case server != "":
+		src = serverSource(server)
+	default:
+		return fmt.Errorf("must specify either -snapshot or -server")
+	}
+
+	out, err := newWriter(os.Stdout, format)
+	if err != nil {
+		return err
+	}
+	return dump(out, src, width)
+}
+
+func runImport(input, snapshot string, concurrency int) error {
+	if snapshot == "" {
+		return fmt.Errorf("must specify -snapshot with -import")
+	}
+	var r io.Reader = os.Stdin
+	if input != "-" {
+		fh, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = fh.Close() }()
+		r = fh
+	}
+	return importSnapshot(r, snapshot, concurrency, os.Stderr)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+)
+
+// handler exposes a Congomap over HTTP, storing values as raw JSON documents.
+type handler struct {
+	cgm   congomap.Congomap
+	which string
+}
+
+func newHandler(cgm congomap.Congomap, which string) http.Handler {
+	h := &handler{cgm: cgm, which: which}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/keys", h.keys)
+	mux.HandleFunc("/keys/", h.key)
+	mux.HandleFunc("/stats", h.stats)
+	return mux
+}
+
+func (h *handler) keys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	keys := h.cgm.Keys()
+	sort.Strings(keys)
+	writeJSON(w, keys)
+}
+
+func (h *handler) key(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/keys/")
+	if key == "" {
+		http.Error(w, "key required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		value, ok := h.cgm.Load(key)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(value.(json.RawMessage))
+	case http.MethodPut:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !json.Valid(body) {
+			http.Error(w, "value must be a JSON document", http.StatusBadRequest)
+			return
+		}
+		var value interface{} = json.RawMessage(body)
+		if arg := r.URL.Query().Get("ttl"); arg != "" {
+			ttl, err := time.ParseDuration(arg)
+			if err != nil || ttl <= 0 {
+				http.Error(w, "ttl must be a positive duration", http.StatusBadRequest)
+				return
+			}
+			value = &congomap.ExpiringValue{Value: value, Expiry: time.Now().Add(ttl)}
+		}
+		h.cgm.Store(key, value)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		h.cgm.Delete(key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *handler) stats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, struct {
+		Type string `json:"type"`
+		Keys int    `json:"keys"`
+	}{
+		Type: h.which,
+		Keys: len(h.cgm.Keys()),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	congomap "github.com/karrick/congomap/v2"
+)
+
+func request(t *testing.T, h http.Handler, method, target, body string) (int, string) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	response, err := ioutil.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, strings.TrimSpace(string(response))
+}
+
+func TestHandler(t *testing.T) {
+	cgm, err := congomap.NewTwoLevelMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	h := newHandler(cgm, "twoLevel")
+
+	tests := []struct {
+		method, target, body string
+		code                 int
+		response             string
+	}{
+		{"GET", "/keys/abc", "", http.StatusNotFound, "404 page not found"},
+		{"PUT", "/keys/abc", `{"answer":42}`, http.StatusNoContent, ""},
+		{"PUT", "/keys/def", `[1,2,3]`, http.StatusNoContent, ""},
+		{"PUT", "/keys/ghi", `not json`, http.StatusBadRequest, "value must be a JSON document"},
+		{"PUT", "/keys/ghi?ttl=-1s", `13`, http.StatusBadRequest, "ttl must be a positive duration"},
+		{"GET", "/keys/abc", "", http.StatusOK, `{"answer":42}`},
+		{"GET", "/keys", "", http.StatusOK, `["abc","def"]`},
+		{"GET", "/stats", "", http.StatusOK, `{"type":"twoLevel","keys":2}`},
+		{"DELETE", "/keys/abc", "", http.StatusNoContent, ""},
+		{"GET", "/keys/abc", "", http.StatusNotFound, "404 page not found"},
+		{"POST", "/keys/abc", "", http.StatusMethodNotAllowed, "Method Not Allowed"},
+	}
+
+	for _, test := range tests {
+		code, response := request(t, h, test.method, test.target, test.body)
+		if code != test.code || response != test.response {
+			t.Errorf("%s %s; Actual: %d %q; Expected: %d %q", test.method, test.target, code, response, test.code, test.response)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Command congomapd serves a Congomap over HTTP, so programs not written in Go may share a cache
+// with the same semantics.
+//
+//	congomapd -addr :8080 -type twoLevel -ttl 5m
+//
+// Values are arbitrary JSON documents.
+//
+//	GET    /keys          JSON array of keys
+//	GET    /keys/{key}    value of key, or 404 Not Found
+//	PUT    /keys/{key}    stores request body as value of key; optional ttl query parameter,
+//	                      e.g., ?ttl=30s, overrides the default TTL for this value
+//	DELETE /keys/{key}    removes key
+//	GET    /stats         JSON object describing the Congomap
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+)
+
+var constructors = map[string]func(...congomap.Setter) (congomap.Congomap, error){
+	"channel":    congomap.NewChannelMap,
+	"syncAtomic": congomap.NewSyncAtomicMap,
+	"syncMutex":  congomap.NewSyncMutexMap,
+	"twoLevel":   congomap.NewTwoLevelMap,
+}
+
+func main() {
+	addr := flag.String("addr", ":8080", "address on which to listen")
+	which := flag.String("type", "twoLevel", "type of Congomap: channel, syncAtomic, syncMutex, or twoLevel")
+	ttl := flag.Duration("ttl", 0, "default time-to-live for values; zero means values never expire")
+	flag.Parse()
+
+	if err := run(*addr, *which, *ttl); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], err)
+		os.Exit(1)
+	}
+}
+
+func run(addr, which string, ttl time.Duration) error {
+	newCongomap, ok := constructors[which]
+	if !ok {
+		return fmt.Errorf("unknown Congomap type: %q", which)
+	}
+
+	var setters []congomap.Setter
+	if ttl > 0 {
+		setters = append(setters, congomap.TTL(ttl))
+	}
+
+	cgm, err := newCongomap(setters...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = cgm.Close() }()
+
+	log.Printf("serving %s Congomap on %s", which, addr)
+	return http.ListenAndServe(addr, newHandler(cgm, which))
+}
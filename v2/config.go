@@ -0,0 +1,733 @@
+package congomap
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// reapBatchSize is the most values passed to a batch reaper at once.
+const reapBatchSize = 1024
+
+// config holds the options common to every Congomap implementation provided by this library. Each
+// implementation embeds a config, which is modified by the Setter functions provided when the
+// Congomap is created.
+type config struct {
+	lookup      lookupFunc
+	reaper      func(string, interface{})
+	batchReaper func([]interface{}) // when not nil, reaper sends each value to it alone
+	reaperPanic func(string, interface{}, interface{})
+	events      events
+	ttl         time.Duration
+	storeTTL    time.Duration // when not zero, overrides ttl for values written by Store and Do
+	lookupTTL   time.Duration // when not zero, overrides ttl for values obtained by lookup
+	minTTL      time.Duration // when not zero, floor of every expiry
+	maxTTL      time.Duration // when not zero, cap of every expiry
+	maxStale    time.Duration
+	stale       time.Duration // when not zero, age at which looked up values are refreshed
+	accessTTL   time.Duration // when not zero, each read postpones expiry by this much
+	jitter      float64       // when not zero, fraction by which default time-to-live is randomized
+
+	lookupAttempts int // when greater than 1, failed lookups are retried
+	lookupBackoff  func(int) time.Duration
+	lookupTimeout  time.Duration // when not zero, longest each lookup is awaited
+
+	badStale  time.Duration
+	badExpiry time.Duration
+	bad       badLookups
+
+	refreshing refreshes
+
+	reraisePanics bool
+	manual        bool          // when true, GC is only invoked by the application
+	gcEvery       time.Duration // when not zero, overrides the default interval between GCs
+	noGoroutine   bool          // when true, no go routine runs GC, and Close reaps
+	gcPaused      int32         // set to 1 by PauseGC
+	validators    []func(string) error
+	canonicalize  func(string) string // nil means keys are used as given
+	holds         *lockHolds // not nil when debugging lock holds
+
+	maxEntries      int  // when not zero, most keys held before sampled keys are evicted
+	evictionSamples int  // when not zero, overrides defaultEvictionSamples
+	lru             bool // when true, least recently used sampled keys are evicted
+
+	maxCost int64                                     // when not zero, greatest total cost of values held
+	cost    func(key string, value interface{}) int64 // nil means every value costs 1
+	spent   int64                                     // total cost of values held, when maxCost is not zero
+
+	copier func(interface{}) interface{} // nil means values are returned as stored
+	sizer  func(interface{}) int64       // nil means MemoryUsage measures values by their type
+
+	snapshotPairs bool // when true, Pairs sends pairs gathered before any is sent
+
+	stats      *counters // not nil when collecting statistics
+	expvarName string    // when not empty, name under which statistics are published
+	tracer     Tracer    // nil means operations are not traced
+
+	logger   LeveledLogger // nil means nothing is logged
+	observer Observer      // nil means operations are not observed
+	clock    TimeSource    // nil means the system clock is consulted
+
+	ttlLock  sync.Mutex
+	ttls     TTLHistogram // sampled by most recent GC
+	gcReport GCReport     // of most recent GC
+
+	expiries *expiries // set by ExpiryHeap
+
+	draining int32 // set to 1 by Drain
+	closed   int32 // set to 1 by Close
+	inflight int32 // number of admitted operations that have not yet returned
+
+	drainLock sync.Mutex
+	drained   chan struct{} // made by Drain, and closed by release once no operations remain in flight
+
+	background sync.WaitGroup // goroutines started by goBackground that have not yet returned
+
+	reapLock     sync.Mutex
+	reapFailures int
+	reapErr      error // first error returned by a fallible reaper
+}
+
+func (c *config) getConfig() *config { return c }
+
+func (c *config) Lookup(lookup func(string) (interface{}, error)) error {
+	if lookup == nil {
+		c.lookup = nil // the Congomap is created with the default lookup
+		return nil
+	}
+	c.lookup = withoutContext(lookup)
+	return nil
+}
+
+func (c *config) Reaper(reaper func(interface{})) error {
+	c.reaper = nil
+	c.batchReaper = nil
+	if reaper != nil {
+		c.reaper = func(_ string, value interface{}) {
+			reaper(value)
+		}
+	}
+	return nil
+}
+
+// protect invokes fn, which invokes the named callback function, and returns ErrCallbackPanic
+// when the callback panics, unless panics are to be re-raised.
+func (c *config) protect(callback string, fn func()) (err error) {
+	if !c.reraisePanics {
+		defer func() {
+			if r := recover(); r != nil {
+				err = ErrCallbackPanic{Callback: callback, Value: r}
+			}
+		}()
+	}
+	fn()
+	return nil
+}
+
+// callLookup invokes the lookup function, converting a panic into an error.
+func (c *config) callLookup(ctx context.Context, lookup lookupFunc, key string) (value interface{}, err error) {
+	ctx, end := c.trace(ctx, "Lookup", key)
+	defer func() { end(err) }()
+	c.stats.add(countLookups, 1)
+	started := time.Now()
+	defer func() {
+		if c.observer != nil {
+			c.observer.OnLookup(key, time.Since(started), err)
+		}
+		if err != nil {
+			c.stats.add(countLookupErrors, 1)
+			c.errorf("congomap: lookup %q: %v", key, err)
+		}
+	}()
+	if c.lookupTimeout > 0 {
+		return c.callLookupTimeout(ctx, lookup, key)
+	}
+	if perr := c.protect("lookup", func() { value, err = lookup(ctx, key) }); perr != nil {
+		return nil, perr
+	}
+	return value, err
+}
+
+// reap sends an eviction event for the value removed for the specified reason, and invokes the
+// reaper callback function.
+func (c *config) reap(key string, value interface{}, reason EvictionReason) {
+	c.notify(reason, Pair{key, value})
+	if c.reaper != nil {
+		c.callReaper(key, value)
+	}
+}
+
+// callReaper invokes the reaper callback function, recording a panic as a reaper failure.
+func (c *config) callReaper(key string, value interface{}) {
+	if err := c.protect("reaper", func() { c.reaper(key, value) }); err != nil {
+		c.reapPanicked(err, Pair{key, value})
+	}
+}
+
+// reapPanicked records a panic in the reaper callback function as a reaper failure, and passes it
+// to the handler specified by OnReaperError with each of the pairs being reaped.
+func (c *config) reapPanicked(err error, pairs ...Pair) {
+	c.reapFailed(err)
+	if c.reaperPanic == nil {
+		return
+	}
+	recovered := err.(ErrCallbackPanic).Value
+	for _, p := range pairs {
+		// a panic in the handler is ignored, because it has no handler of its own
+		_ = c.protect("reaper error handler", func() { c.reaperPanic(p.Key, p.Value, recovered) })
+	}
+}
+
+// canonical returns the canonical form of the key.
+func (c *config) canonical(key string) string {
+	if c.canonicalize == nil {
+		return key
+	}
+	return c.canonicalize(key)
+}
+
+// reapFailed records an error returned by a fallible reaper.
+func (c *config) reapFailed(err error) {
+	c.errorf("congomap: reaper: %v", err)
+	c.reapLock.Lock()
+	if c.reapFailures == 0 {
+		c.reapErr = err
+	}
+	c.reapFailures++
+	c.reapLock.Unlock()
+}
+
+// closeErr returns the error to be returned by Close, which is nil unless a fallible reaper failed.
+func (c *config) closeErr() error {
+	c.reapLock.Lock()
+	defer c.reapLock.Unlock()
+	if c.reapFailures == 0 {
+		return nil
+	}
+	return ErrReaperFailed{Count: c.reapFailures, Err: c.reapErr}
+}
+
+// reapAll sends eviction events for the pairs removed for the specified reason, and invokes the
+// reaper with each of them, returning after all have been reaped. The values are passed to the
+// batch reaper when one was specified, and otherwise reaped concurrently.
+func (c *config) reapAll(pairs []Pair, reason EvictionReason) {
+	c.notify(reason, pairs...)
+	if c.reaper == nil || len(pairs) == 0 {
+		return
+	}
+	if c.batchReaper != nil {
+		for len(pairs) > 0 {
+			n := len(pairs)
+			if n > reapBatchSize {
+				n = reapBatchSize
+			}
+			batch := make([]interface{}, n)
+			for i, p := range pairs[:n] {
+				batch[i] = p.Value
+			}
+			if err := c.protect("reaper", func() { c.batchReaper(batch) }); err != nil {
+				c.reapPanicked(err, pairs[:n]...)
+			}
+			pairs = pairs[n:]
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	wg.Add(len(pairs))
+	for _, p := range pairs {
+		go func(p Pair) {
+			c.callReaper(p.Key, p.Value)
+			wg.Done()
+		}(p)
+	}
+	wg.Wait()
+}
+
+func (c *config) TTL(duration time.Duration) error {
+	if duration <= 0 {
+		return ErrInvalidDuration(duration)
+	}
+	c.ttl = duration
+	return nil
+}
+
+// storeDuration returns the time-to-live of values written by Store and Do.
+func (c *config) storeDuration() time.Duration {
+	if c.storeTTL > 0 {
+		return c.storeTTL
+	}
+	if c.ttl == 0 {
+		return c.accessTTL
+	}
+	return c.ttl
+}
+
+// lookupDuration returns the time-to-live of values obtained by the lookup callback function.
+func (c *config) lookupDuration() time.Duration {
+	if c.lookupTTL > 0 {
+		return c.lookupTTL
+	}
+	if c.ttl == 0 {
+		return c.accessTTL
+	}
+	return c.ttl
+}
+
+// touchDuration returns how far the Touch method postpones the expiry of a value.
+func (c *config) touchDuration() time.Duration {
+	if c.accessTTL > 0 {
+		return c.accessTTL
+	}
+	return c.storeDuration()
+}
+
+// accessed postpones the expiry of the live value of key after it is read, when AccessTTL was
+// specified.
+func (c *config) accessed(mutate func(string, mutator), key string) {
+	if c.accessTTL > 0 {
+		c.touch(mutate, key, c.accessTTL)
+	}
+}
+
+// storeValue returns the entry to store for a value written by Store or Do.
+func (c *config) storeValue(key string, value interface{}) *entry {
+	ev := c.clamp(c.newEntry(value, c.storeDuration()))
+	c.use(&ev.used)
+	c.stored(key)
+	return ev
+}
+
+// storeFunc returns a function that returns the entry to store for a value written to the
+// key by Do or Update.
+func (c *config) storeFunc(key string) func(interface{}) *entry {
+	return func(value interface{}) *entry {
+		return c.storeValue(key, value)
+	}
+}
+
+// lookupValue returns the entry to store for a value obtained by the lookup callback
+// function.
+func (c *config) lookupValue(value interface{}) *entry {
+	ev := c.clamp(c.newEntry(value, c.lookupDuration()))
+	if c.stale > 0 {
+		ev.stale = c.now().Add(c.stale)
+	}
+	c.use(&ev.used)
+	return ev
+}
+
+// clamp returns the entry with its expiry moved within the bounds specified by MinTTL and MaxTTL.
+// The entry is copied rather than modified, because it may be read without holding any lock.
+func (c *config) clamp(ev *entry) *entry {
+	if c.minTTL == 0 && c.maxTTL == 0 {
+		return ev
+	}
+	now := c.now()
+	expiry := ev.Expiry
+	if c.maxTTL > 0 {
+		if max := now.Add(c.maxTTL); expiry.IsZero() || expiry.After(max) {
+			expiry = max
+		}
+	}
+	if c.minTTL > 0 && !expiry.IsZero() {
+		if min := now.Add(c.minTTL); expiry.Before(min) {
+			expiry = min
+		}
+	}
+	if expiry.Equal(ev.Expiry) {
+		return ev
+	}
+	return ev.withExpiry(expiry)
+}
+
+// shortestDuration returns the shortest time-to-live of any value, or zero when no value expires by
+// default.
+func (c *config) shortestDuration() time.Duration {
+	store, lookup := c.storeDuration(), c.lookupDuration()
+	if store == 0 || (lookup > 0 && lookup < store) {
+		return lookup
+	}
+	return store
+}
+
+// gcInterval returns how often GC is invoked, unless ManualMaintenance was specified.
+func (c *config) gcInterval() time.Duration {
+	if c.gcEvery > 0 {
+		return c.gcEvery
+	}
+	if ttl := c.shortestDuration(); ttl > 0 && ttl <= time.Second {
+		return time.Minute
+	}
+	return 15 * time.Minute
+}
+
+// evictable returns true when the value ought to be removed from the data store as of the
+// specified time, because it has expired and may no longer be served stale.
+func (c *config) evictable(ev *entry, now time.Time) bool {
+	return c.evictableAt(ev.Expiry, now)
+}
+
+// evictableAt returns true when a value with the specified expiry ought to be removed from the data
+// store as of the specified time.
+func (c *config) evictableAt(expiry, now time.Time) bool {
+	return !expiry.IsZero() && now.After(expiry.Add(c.maxStale))
+}
+
+// servesStale returns true when the expired value may still be returned by LoadStore as of the
+// specified time, because the lookup callback function failed to provide a fresh value.
+func (c *config) servesStale(ev *entry, now time.Time) bool {
+	return c.maxStale > 0 && !c.evictable(ev, now)
+}
+
+// applySetters invokes every setter with the Congomap being created, then validates the resulting
+// combination of options. When there is a single problem, its error is returned; when there are
+// several, ErrInvalidOptions lists them all.
+func applySetters(cgm Congomap, setters []Setter) error {
+	var errs []error
+	for _, setter := range setters {
+		if err := setter(cgm); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c, ok := cgm.(configurable); ok {
+		errs = append(errs, c.getConfig().validate()...)
+	}
+	switch len(errs) {
+	case 0:
+		if c, ok := cgm.(configurable); ok {
+			c.getConfig().publish() // only once nothing can fail, because it cannot be undone
+		}
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return ErrInvalidOptions(errs)
+}
+
+// validate returns an error for each combination of options that conflicts or has no effect.
+func (c *config) validate() []error {
+	var errs []error
+	if c.lookup == nil {
+		if c.maxStale > 0 {
+			errs = append(errs, ErrOptionConflict("MaxStale requires Lookup"))
+		}
+		if c.badExpiry > 0 {
+			errs = append(errs, ErrOptionConflict("BadExpiryDuration requires Lookup"))
+		}
+		if c.stale > 0 {
+			errs = append(errs, ErrOptionConflict("Stale requires Lookup"))
+		}
+	}
+	if ttl := c.lookupDuration(); c.stale > 0 && ttl > 0 && c.stale >= ttl {
+		errs = append(errs, ErrOptionConflict("Stale must be shorter than the time-to-live of looked up values"))
+	}
+	if c.badStale > 0 {
+		if c.badExpiry == 0 {
+			errs = append(errs, ErrOptionConflict("BadStaleDuration requires BadExpiryDuration"))
+		} else if c.badStale >= c.badExpiry {
+			errs = append(errs, ErrOptionConflict("BadStaleDuration must be shorter than BadExpiryDuration"))
+		}
+	}
+	if c.jitter > 0 && c.ttl == 0 && c.storeTTL == 0 && c.lookupTTL == 0 {
+		errs = append(errs, ErrOptionConflict("TTLJitter requires TTL, StoreTTL, or LookupTTL"))
+	}
+	if c.minTTL > 0 && c.maxTTL > 0 && c.minTTL > c.maxTTL {
+		errs = append(errs, ErrOptionConflict("MinTTL must not be longer than MaxTTL"))
+	}
+	if c.lru && c.maxEntries == 0 && c.maxCost == 0 {
+		errs = append(errs, ErrOptionConflict("EvictLRU requires MaxEntries or MaxCost"))
+	}
+	if c.evictionSamples > 0 && c.maxEntries == 0 && c.maxCost == 0 {
+		errs = append(errs, ErrOptionConflict("EvictionSamples requires MaxEntries or MaxCost"))
+	}
+	if c.gcEvery > 0 && c.manual {
+		errs = append(errs, ErrOptionConflict("GCInterval conflicts with ManualMaintenance"))
+	}
+	if c.cost != nil && c.maxCost == 0 {
+		errs = append(errs, ErrOptionConflict("Cost requires MaxCost"))
+	}
+	if err := c.publishConflict(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// configurable is implemented by the Congomap types provided by this library, all of which embed a
+// config.
+type configurable interface {
+	getConfig() *config
+}
+
+// configure returns a Setter that invokes the specified function with the config of the Congomap
+// being created.
+func configure(fn func(*config) error) Setter {
+	return func(cgm Congomap) error {
+		c, ok := cgm.(configurable)
+		if !ok {
+			return ErrUnsupportedOption{}
+		}
+		return fn(c.getConfig())
+	}
+}
+
+// StoreTTL is used to specify the time-to-live of values written by the Store and Do methods,
+// overriding the default time-to-live specified by TTL.
+func StoreTTL(duration time.Duration) Setter {
+	return configure(func(c *config) error {
+		if duration <= 0 {
+			return ErrInvalidDuration(duration)
+		}
+		c.storeTTL = duration
+		return nil
+	})
+}
+
+// LookupTTL is used to specify the time-to-live of values obtained by the lookup callback function,
+// overriding the default time-to-live specified by TTL. Values looked up in the background, after a
+// memoized lookup error goes stale, also use this time-to-live.
+func LookupTTL(duration time.Duration) Setter {
+	return configure(func(c *config) error {
+		if duration <= 0 {
+			return ErrInvalidDuration(duration)
+		}
+		c.lookupTTL = duration
+		return nil
+	})
+}
+
+// AccessTTL is used to specify sliding expiration: each time a value is read by the Load,
+// LoadStore, LoadStoreInfo, or LoadStoreAll methods, or touched by the Touch method, its expiry is
+// postponed to the specified duration from then, so values are kept alive for as long as they are
+// read, such as in a session cache. An expiry is never brought forward, nor given to a value that
+// never expires. When no other time-to-live is specified, values expire after the specified
+// duration unless read. Because each read then also writes, reads of a Congomap created by
+// NewSyncAtomicMap become as expensive as writes.
+func AccessTTL(duration time.Duration) Setter {
+	return configure(func(c *config) error {
+		if duration <= 0 {
+			return ErrInvalidDuration(duration)
+		}
+		c.accessTTL = duration
+		return nil
+	})
+}
+
+// SnapshotPairs is used to specify that the Pairs method copies the live pairs before sending the
+// first one, and sends them from the copy, so that a slow consumer of the channel does not hold the
+// Congomap locked and stall every other accessor. The copy costs memory proportional to the number
+// of pairs, and pairs stored or deleted while the channel is read are not reflected.
+func SnapshotPairs() Setter {
+	return configure(func(c *config) error {
+		c.snapshotPairs = true
+		return nil
+	})
+}
+
+// ManualMaintenance is used to specify that the Congomap does not periodically perform maintenance
+// on its own, so that an application with its own scheduler, or a test, may invoke MaintainOnce
+// whenever maintenance is due.
+func ManualMaintenance() Setter {
+	return configure(func(c *config) error {
+		c.manual = true
+		return nil
+	})
+}
+
+// NoGoroutine is used to specify that the Congomap starts no go routine of its own, for
+// applications that create a Congomap per request or per connection, and cannot afford a go
+// routine and timer for each. It implies ManualMaintenance, so the application invokes GC or
+// MaintainOnce whenever maintenance is due, and Close reaps the remaining values itself. Go routines
+// are still started on demand for work such as reaping evicted values. NewChannelMap returns
+// ErrUnsupportedOption when it is specified, because its go routine performs every operation.
+func NoGoroutine() Setter {
+	return func(cgm Congomap) error {
+		if _, ok := cgm.(*channelMap); ok {
+			return ErrUnsupportedOption{}
+		}
+		return configure(func(c *config) error {
+			c.manual = true
+			c.noGoroutine = true
+			return nil
+		})(cgm)
+	}
+}
+
+// GCInterval is used to specify how often the Congomap performs GC, overriding the default of every
+// 15 minutes, or every minute when a time-to-live of a second or less was specified, so operators
+// may tune how promptly expired values are removed to their time-to-live.
+func GCInterval(duration time.Duration) Setter {
+	return configure(func(c *config) error {
+		if duration <= 0 {
+			return ErrInvalidDuration(duration)
+		}
+		c.gcEvery = duration
+		return nil
+	})
+}
+
+// CanonicalKey is used to specify a function that converts every key passed to the Delete, Do,
+// Load, LoadStore, LoadStoreAll, and Store methods to a canonical form, such as strings.ToLower, so
+// that keys differing only in ways the application does not care about refer to the same value.
+// Keys are validated and passed to the lookup callback function in their canonical form, and Keys
+// and Pairs return keys in their canonical form.
+func CanonicalKey(canonicalize func(string) string) Setter {
+	return configure(func(c *config) error {
+		c.canonicalize = canonicalize
+		return nil
+	})
+}
+
+// Copier is used to specify a function that returns a copy of a value. When specified, the Load,
+// LoadStore, LoadStoreInfo, and LoadStoreAll methods return a copy of each value rather than the
+// value held by the Congomap, so that a caller modifying what it was given, such as by appending to
+// a slice, cannot modify what other goroutines are given. The function is not invoked while the
+// Congomap is locked.
+//
+//	cgm, err := congomap.NewSyncMutexMap(congomap.Copier(func(value interface{}) interface{} {
+//	    return append([]string(nil), value.([]string)...)
+//	}))
+func Copier(copier func(interface{}) interface{}) Setter {
+	return configure(func(c *config) error {
+		c.copier = copier
+		return nil
+	})
+}
+
+// copied returns the value as it ought to be returned to the caller.
+func (c *config) copied(value interface{}) interface{} {
+	if c.copier == nil {
+		return value
+	}
+	return c.copier(value)
+}
+
+// MinTTL is used to specify the shortest time-to-live of any value. An expiry sooner than the
+// specified duration from when the value is stored, including one already in the past, such as may
+// be supplied in an ExpiringValue by a faulty backend, is postponed to that time.
+func MinTTL(duration time.Duration) Setter {
+	return configure(func(c *config) error {
+		if duration <= 0 {
+			return ErrInvalidDuration(duration)
+		}
+		c.minTTL = duration
+		return nil
+	})
+}
+
+// MaxTTL is used to specify the longest time-to-live of any value. An expiry later than the
+// specified duration from when the value is stored, such as one a year away supplied in an
+// ExpiringValue by a faulty backend, is brought forward to that time. Values that would otherwise
+// never expire also expire after the specified duration.
+func MaxTTL(duration time.Duration) Setter {
+	return configure(func(c *config) error {
+		if duration <= 0 {
+			return ErrInvalidDuration(duration)
+		}
+		c.maxTTL = duration
+		return nil
+	})
+}
+
+// MaxStale is used to specify how long after a value expires it may still be returned by the
+// LoadStore method, when the lookup callback function fails to provide a fresh value for its key.
+// Rather than returning the lookup error, LoadStore returns the stale value with a nil error, until
+// the value is older than its expiry plus the specified duration, after which lookup errors are
+// returned unconditionally. Stale values are not returned by the Load method, but are retained by
+// the garbage collector until they may no longer be served.
+func MaxStale(duration time.Duration) Setter {
+	return configure(func(c *config) error {
+		if duration <= 0 {
+			return ErrInvalidDuration(duration)
+		}
+		c.maxStale = duration
+		return nil
+	})
+}
+
+// StaleOnError is used to specify that an expired value is returned by the LoadStore method, with a
+// nil error, whenever the lookup callback function fails to provide a fresh value for its key,
+// however long ago the value expired. It is MaxStale without a limit, so the garbage collector
+// retains expired values until they are replaced or deleted; specify MaxEntries to bound how many
+// are retained.
+func StaleOnError() Setter {
+	return MaxStale(time.Duration(math.MaxInt64))
+}
+
+// BatchReaper is used to specify a reaper callback function that is invoked with slices of values,
+// as an alternative to Reaper. When the garbage collector evicts many values at once, or the
+// Congomap is closed, the values are passed in batches of at most 1024 values, which avoids the
+// overhead of invoking a callback function per value. Values removed individually, by Delete or by
+// being replaced, are passed in a slice of one value. Specifying BatchReaper replaces any reaper
+// specified by Reaper or Reaper2, and vice versa.
+func BatchReaper(reaper func([]interface{})) Setter {
+	return configure(func(c *config) error {
+		c.batchReaper = reaper
+		c.reaper = nil
+		if reaper != nil {
+			c.reaper = func(_ string, value interface{}) {
+				reaper([]interface{}{value})
+			}
+		}
+		return nil
+	})
+}
+
+// RecoverPanics is used to specify whether panics in the lookup and reaper callback functions are
+// recovered, which is the default. A recovered panic in the lookup callback function is returned by
+// LoadStore as ErrLookupFailed wrapping ErrCallbackPanic, and a recovered panic in the reaper
+// callback function is counted as a reaper failure, so that Close returns ErrReaperFailed. When
+// panics are not recovered, they propagate from the callback function, but no lock of the Congomap
+// is left held. Callback functions invoked by a background go routine, including every callback
+// function invoked by a Congomap created by NewChannelMap, terminate the program when they panic
+// and panics are not recovered.
+func RecoverPanics(enabled bool) Setter {
+	return configure(func(c *config) error {
+		c.reraisePanics = !enabled
+		return nil
+	})
+}
+
+// FallibleReaper is used to specify a reaper callback function that may fail, as an alternative to
+// Reaper. The Congomap counts the errors it returns, and its Close method returns ErrReaperFailed
+// when any were returned. Specifying FallibleReaper replaces any reaper specified by Reaper,
+// Reaper2, or BatchReaper, and vice versa.
+func FallibleReaper(reaper func(interface{}) error) Setter {
+	return configure(func(c *config) error {
+		c.batchReaper = nil
+		c.reaper = nil
+		if reaper != nil {
+			c.reaper = func(_ string, value interface{}) {
+				if err := reaper(value); err != nil {
+					c.reapFailed(err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// OnReaperError is used to specify a function that is invoked with the key and value being reaped,
+// and the value recovered, when the reaper callback function panics, so an application may log or
+// retry the cleanup that did not happen. The panic is still counted as a reaper failure, so that
+// Close returns ErrReaperFailed. When a batch reaper specified by BatchReaper panics, the function
+// is invoked once for each value in the batch. It is not invoked when RecoverPanics(false) is
+// specified, because panics are then not recovered.
+func OnReaperError(handler func(key string, value interface{}, recovered interface{})) Setter {
+	return configure(func(c *config) error {
+		c.reaperPanic = handler
+		return nil
+	})
+}
+
+// Reaper2 is used to specify a reaper callback function that is invoked with the key as well as the
+// value being removed, as an alternative to Reaper, for cleanup that releases resources belonging
+// to the key, such as files named after it. Specifying Reaper2 replaces any reaper specified by
+// Reaper, BatchReaper, or FallibleReaper, and vice versa.
+func Reaper2(reaper func(string, interface{})) Setter {
+	return configure(func(c *config) error {
+		c.batchReaper = nil
+		c.reaper = reaper
+		return nil
+	})
+}
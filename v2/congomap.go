@@ -1,42 +1,235 @@
 package congomap
 
-import "time"
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
 
 // Congomap is the interface implemented by an object that acts as a concurrent go map to store data
 // in a key-value data store.
 type Congomap interface {
-	// Close releases resources used by the Congomap.
+	// All returns an iterator over the keys and values that have not expired, the same ones
+	// Pairs sends, which stops early when yield returns false. Its type is that of
+	// iter.Seq2[string, interface{}], so with Go 1.23 or later it may be ranged over:
+	//
+	//	for key, value := range cgm.All() {
+	//	    fmt.Println(key, value)
+	//	}
+	//
+	// The pairs are gathered before the first one is yielded, so the loop body may invoke
+	// methods on the same Congomap.
+	All() func(yield func(string, interface{}) bool)
+
+	// Clear removes every key, sending each value to the reaper, without closing the Congomap.
+	Clear()
+
+	// Close releases resources used by the Congomap, after reaping its remaining values. It
+	// returns ErrReaperFailed when a reaper specified by FallibleReaper returned any errors.
+	// Close may be invoked more than once, and returns the same result each time. Once closed,
+	// LoadStore returns ErrClosed, Load reports every key absent, and Store and the other methods
+	// that store values do nothing.
 	Close() error
 
 	// Delete removes a key value pair from a Congomap.
 	Delete(string)
 
+	// DeleteIf removes the given key, exactly as Delete would, only when the specified function
+	// returns true for its live value, and returns whether the key was removed. The function is
+	// invoked while holding the key's serialization, as Do does, and when the key is absent or
+	// expired, or the function returns false, nothing changes. The function must not invoke any
+	// methods on the same Congomap.
+	DeleteIf(string, func(interface{}) bool) bool
+
+	// DeleteMany removes each of the given keys as Delete would, in a single pass over the
+	// Congomap.
+	DeleteMany([]string)
+
+	// Do invokes the specified function with the value associated with the given key and whether
+	// the key is in the map, while holding that key's serialization, so no other mutation of the
+	// key can interleave with it. When the function returns true, the value it returns replaces
+	// the value associated with the key, exactly as Store would. The function must not invoke any
+	// methods on the same Congomap.
+	Do(string, func(interface{}, bool) (interface{}, bool))
+
+	// Update invokes the specified function with the value associated with the given key and
+	// whether the key is in the map, while holding that key's serialization, as Do does. When the
+	// function returns true, the value it returns replaces the value associated with the key,
+	// exactly as Store would. Unlike Do, when the function returns false the key is removed,
+	// exactly as Delete would, so counters, appends, and removals can all be computed
+	// atomically. The function must not invoke any methods on the same Congomap.
+	Update(string, func(interface{}, bool) (interface{}, bool))
+
+	// Expire sets the expiry of the live value associated with the given key to the specified
+	// duration from now, and returns true, or returns false when the key is absent or expired. A
+	// duration that is not positive expires the value at once. Like every expiry, the new one is
+	// kept within the bounds specified by MinTTL and MaxTTL.
+	Expire(string, time.Duration) bool
+
+	// Persist clears the expiry of the live value associated with the given key, so it never
+	// expires, and returns true, or returns false when the key is absent or expired. When MaxTTL
+	// was specified, the value instead expires after that duration.
+	Persist(string) bool
+
+	// TTLRemaining returns how long the live value associated with the given key has before it
+	// expires, and true, or zero and false when the key is absent or expired. A live value that
+	// never expires has zero duration remaining.
+	TTLRemaining(string) (time.Duration, bool)
+
+	// Touch postpones the expiry of the live value associated with the given key as reading it
+	// would when AccessTTL was specified, and returns true, or returns false when the key is absent
+	// or expired. When AccessTTL was not specified, the expiry is postponed by the time-to-live of
+	// values written by Store.
+	Touch(string) bool
+
 	// GC forces elimination of keys in Congomap with values that have expired.
 	GC()
 
+	// PauseGC defers the periodic GC of the Congomap until ResumeGC is invoked, so latency
+	// sensitive phases of a program, such as serving a burst of requests, are not interrupted by
+	// sweeps. The GC method may still be invoked while periodic GC is paused.
+	PauseGC()
+
+	// ResumeGC resumes the periodic GC of the Congomap deferred by PauseGC, starting with the next
+	// period.
+	ResumeGC()
+
+	// Prune evicts up to the specified number of keys on demand, such as in response to a signal of
+	// memory pressure, sending their values to the reaper, and returns how many keys it evicted.
+	// Keys are chosen as they are when the Congomap holds MaxEntries keys, but from every key
+	// rather than a sample: those whose values expire soonest, or those least recently used when
+	// EvictLRU was specified. It evicts nothing when the number is not positive.
+	Prune(int) int
+
 	// Keys returns an array of key-values stored in the map.
 	Keys() []string
 
+	// AppendKeys appends the keys stored in the map to the given slice and returns the extended
+	// slice, so callers that list keys repeatedly can reuse a buffer rather than allocate a new
+	// one each time.
+	AppendKeys([]string) []string
+
+	// KeysMatching returns the keys stored in the map for which the specified function returns
+	// true, without first gathering every key. Like Keys, it may return keys whose values have
+	// expired but not yet been collected. Because the function is invoked while the Congomap is
+	// locked, it must not invoke any methods on the same Congomap.
+	KeysMatching(func(string) bool) []string
+
+	// KeysWithPrefix returns the keys stored in the map that begin with the specified prefix, as
+	// KeysMatching would. Keys are compared with the prefix in their canonical form.
+	KeysWithPrefix(string) []string
+
+	// KeysPage returns up to limit keys, in sorted order, that sort after the cursor, along with
+	// the cursor of the next page, which is empty after the last page. The empty cursor requests
+	// the first page. Only one page of keys is held at a time, and no lock is held between
+	// pages, so keys stored or deleted between pages may or may not be returned.
+	KeysPage(cursor string, limit int) ([]string, string)
+
+	// Len returns the number of keys with values that have not expired. It counts them while
+	// holding the Congomap's serialization rather than maintaining a count, because values expire
+	// without any write to account for them, but it neither allocates nor copies any keys.
+	Len() int
+
 	// Load gets the value associated with the given key. When the key is in the map, it returns
 	// the value associated with the key and true. Otherwise it returns nil for the value and
 	// false.
 	Load(string) (interface{}, bool)
 
+	// LoadAndDelete removes the given key, and returns its live value and true, or nil and false
+	// when the key was absent or expired. The removed value is returned to the caller rather than
+	// sent to the reaper. Expired values are left for GC to reap.
+	LoadAndDelete(string) (interface{}, bool)
+
+	// LoadOrStore returns the live value associated with the given key and true when there is
+	// one. Otherwise it stores the given value, exactly as Store would, and returns that value
+	// and false. The check and the store are atomic, so of several goroutines racing to store a
+	// value for the same key, exactly one stores its value, and the others load it.
+	LoadOrStore(string, interface{}) (interface{}, bool)
+
 	// LoadStore gets the value associated with the given key if it's in the map. If it's not in
 	// the map, it calls the lookup function, and sets the value in the map to that returned by
 	// the lookup function.
 	LoadStore(string) (interface{}, error)
 
+	// LoadStoreInfo behaves like LoadStore, and also returns true when the value was obtained by
+	// invoking the lookup callback function rather than from the map, so callers can tell cache
+	// hits from misses.
+	LoadStoreInfo(string) (interface{}, bool, error)
+
+	// LoadStoreContext behaves like LoadStore, and passes ctx to the lookup callback function
+	// specified by LookupContext, so lookups may honor the deadline of the caller and carry its
+	// tracing values. LoadStore passes context.Background() instead.
+	LoadStoreContext(context.Context, string) (interface{}, error)
+
+	// LoadStoreWith behaves like LoadStore, but invokes the specified lookup function rather than
+	// the lookup callback function of the Congomap, so different callers may fill the same
+	// Congomap in different ways, while concurrent lookups for a key are serialized exactly as
+	// LoadStore serializes them. An error memoized by BadExpiryDuration is returned regardless of
+	// which lookup function returned it.
+	LoadStoreWith(string, func(string) (interface{}, error)) (interface{}, error)
+
+	// LoadMany returns the live values associated with the given keys, keyed by the keys as
+	// given, in a single pass over the Congomap. Keys that are absent or expired are omitted. Like
+	// Load, it does not invoke the lookup callback function.
+	LoadMany([]string) map[string]interface{}
+
+	// LoadStoreAll resolves each of the given keys as LoadStore would, concurrently, and returns
+	// a map of each key to its result. At most 64 keys are resolved at a time, so a long list of
+	// keys does not start a go routine for each.
+	LoadStoreAll([]string) map[string]LoadStoreResult
+
+	// MaintainOnce performs exactly one cycle of the maintenance the Congomap otherwise performs
+	// periodically: garbage collection of expired values and memoized lookup errors, and sampling
+	// of the TTL distribution. Combined with NextExpiry, it lets an application schedule
+	// maintenance precisely.
+	MaintainOnce()
+
+	// NextExpiry returns the earliest expiry of the values stored in the Congomap and true, or the
+	// zero time and false when no stored value expires. An expiry in the past means expired values
+	// are awaiting GC.
+	NextExpiry() (time.Time, bool)
+
 	// Pairs returns a channel through which key value pairs are read. Pairs will lock the
-	// Congomap so that no other accessors can be used until the returned channel is closed.
-	//
-	// TODO: In next version, should return a channel of Pair structures, rather than channel of
-	// pointers to Pair structures.
-	Pairs() <-chan *Pair
+	// Congomap so that no other accessors can be used until the returned channel is closed,
+	// unless SnapshotPairs was specified. Pairs are sent by value, so a receiver cannot modify a
+	// pair another receiver is given.
+	Pairs() <-chan Pair
+
+	// PairsByExpiry returns a channel through which key value pairs are read, soonest to expire
+	// first, followed by the pairs that never expire. Unlike Pairs, it does not lock the Congomap
+	// while the channel is read, because it gathers the pairs before sending the first one.
+	PairsByExpiry() <-chan Pair
+
+	// Snapshot returns a new map of every key to its value, for the values that have not expired,
+	// so callers can serialize or inspect the contents without reading the Pairs channel. The
+	// values are copied by the function specified by Copier, when one was specified.
+	Snapshot() map[string]interface{}
 
 	// Store sets the value associated with the given key.
 	Store(string, interface{})
 
+	// StoreMany stores each of the given values with its key as Store would, in a single pass over
+	// the Congomap, invoking the reaper with each value it replaces.
+	StoreMany(map[string]interface{})
+
+	// StoreWithTTL sets the value associated with the given key, exactly as Store would, except
+	// that the value expires after the specified time-to-live rather than the default one. A
+	// time-to-live that is not positive means the default applies.
+	StoreWithTTL(string, interface{}, time.Duration)
+
+	// StoreReturning sets the value associated with the given key, exactly as Store would, and
+	// returns the value it replaced and true, or nil and false when the key was absent or
+	// expired. The replaced value is returned to the caller rather than sent to the reaper.
+	StoreReturning(string, interface{}) (interface{}, bool)
+
+	// Swap is StoreReturning by the name sync.Map gives it: it sets the value associated with the
+	// given key, which may be an *ExpiringValue, and returns the previous live value and true, or
+	// nil and false.
+	Swap(string, interface{}) (interface{}, bool)
+
 	Lookup(func(string) (interface{}, error)) error
 	Reaper(func(interface{})) error
 	TTL(time.Duration) error
@@ -49,6 +242,13 @@ type Pair struct {
 	Value interface{}
 }
 
+// LoadStoreResult objects hold the outcome of resolving a single key, and are returned by the
+// LoadStoreAll() method.
+type LoadStoreResult struct {
+	Value interface{}
+	Err   error
+}
+
 // Setter declares the type of function used when creating a Congomap to change the instance's
 // behavior.
 type Setter func(Congomap) error
@@ -66,6 +266,30 @@ func Lookup(lookup func(string) (interface{}, error)) Setter {
 	}
 }
 
+// lookupFunc is the type of the lookup callback function each Congomap invokes, which is passed a
+// context.
+type lookupFunc func(context.Context, string) (interface{}, error)
+
+// withoutContext adapts a lookup callback function that is not passed a context to a lookupFunc.
+func withoutContext(lookup func(string) (interface{}, error)) lookupFunc {
+	return func(_ context.Context, key string) (interface{}, error) {
+		return lookup(key)
+	}
+}
+
+// LookupContext is used to specify a lookup callback function that is also passed a context, as an
+// alternative to Lookup, for lookups that query databases or other services, so they may honor
+// deadlines and carry tracing values. The context is the one passed to LoadStoreContext, or
+// context.Background() when the lookup is invoked by LoadStore, or in the background to refresh a
+// stale lookup error. Specifying LookupContext replaces any lookup specified by Lookup, and vice
+// versa.
+func LookupContext(lookup func(context.Context, string) (interface{}, error)) Setter {
+	return configure(func(c *config) error {
+		c.lookup = lookup
+		return nil
+	})
+}
+
 // Reaper is used to specify what function is to be called when garbage collecting item from the
 // Congomap.
 func Reaper(reaper func(interface{})) Setter {
@@ -91,19 +315,383 @@ type ExpiringValue struct {
 	Expiry time.Time
 }
 
-// helper function to wrap non ExpiringValue items as ExpiringValue items.
-func newExpiringValue(value interface{}, defaultDuration time.Duration) *ExpiringValue {
+// entry is a value held by a Congomap, along with the bookkeeping the Congomap keeps for it, which
+// is not part of ExpiringValue so that callers may create and copy ExpiringValues freely.
+type entry struct {
+	used int64 // UnixNano of most recent use, when EvictLRU was specified; first for alignment
+	ExpiringValue
+	stale time.Time // when not zero, when LoadStore begins refreshing a looked up value
+}
+
+// withExpiry returns a copy of the entry with the specified expiry. The entry is copied rather than
+// modified, because it may be read without holding any lock.
+func (ev *entry) withExpiry(expiry time.Time) *entry {
+	return &entry{
+		used:          atomic.LoadInt64(&ev.used),
+		ExpiringValue: ExpiringValue{Value: ev.Value, Expiry: expiry},
+		stale:         ev.stale,
+	}
+}
+
+// newEntry returns the entry to hold the value, which expires after the default duration unless it
+// is an ExpiringValue. An ExpiringValue is copied, because it belongs to the caller, but an entry,
+// such as one looked up in the background and then written by Store, is held as is.
+func (c *config) newEntry(value interface{}, defaultDuration time.Duration) *entry {
 	switch val := value.(type) {
-	case *ExpiringValue:
+	case *entry:
 		return val
+	case *ExpiringValue:
+		return &entry{ExpiringValue: ExpiringValue{Value: val.Value, Expiry: val.Expiry}}
 	default:
 		if defaultDuration > 0 {
-			return &ExpiringValue{Value: value, Expiry: time.Now().Add(defaultDuration)}
+			return &entry{ExpiringValue: ExpiringValue{Value: value, Expiry: c.now().Add(c.jittered(defaultDuration))}}
+		}
+		return &entry{ExpiringValue: ExpiringValue{Value: value}}
+	}
+}
+
+// maxLoadStoreAllConcurrency is the most keys LoadStoreAll resolves at a time, so that a long list
+// of keys neither starts a go routine per key nor floods the lookup callback function.
+const maxLoadStoreAllConcurrency = 64
+
+// loadStoreAll is the common implementation of the LoadStoreAll method, which invokes the
+// Congomap's LoadStore method for each unique key, from at most maxLoadStoreAllConcurrency go
+// routines at a time.
+func loadStoreAll(cgm Congomap, keys []string) map[string]LoadStoreResult {
+	seen := make(map[string]struct{}, len(keys))
+	unique := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			unique = append(unique, key)
+		}
+	}
+
+	values := make([]LoadStoreResult, len(unique))
+	concurrently(len(unique), maxLoadStoreAllConcurrency, func(i int) {
+		value, err := cgm.LoadStore(unique[i])
+		values[i] = LoadStoreResult{Value: value, Err: err}
+	})
+
+	results := make(map[string]LoadStoreResult, len(unique))
+	for i, key := range unique {
+		results[key] = values[i]
+	}
+	return results
+}
+
+// live returns true when the value has not expired as of the specified time.
+func (ev *entry) live(now time.Time) bool {
+	return ev.Expiry.IsZero() || ev.Expiry.After(now)
+}
+
+// remaining returns how long the stored value has before it expires as of the specified time, and
+// whether it is live.
+func remaining(ev *entry, now time.Time) (time.Duration, bool) {
+	if ev == nil {
+		return 0, false
+	}
+	if ev.Expiry.IsZero() {
+		return 0, true
+	}
+	if left := ev.Expiry.Sub(now); left > 0 {
+		return left, true
+	}
+	return 0, false
+}
+
+// earliest returns the earlier of next and the expiry of the value, ignoring zero times.
+func earliest(next time.Time, ev *entry) time.Time {
+	if next.IsZero() || (!ev.Expiry.IsZero() && ev.Expiry.Before(next)) {
+		return ev.Expiry
+	}
+	return next
+}
+
+// expiringPair is a key and its associated entry.
+type expiringPair struct {
+	key string
+	ev  *entry
+}
+
+// maxPairsBuffer is the largest buffer of a channel returned by Pairs or PairsByExpiry.
+const maxPairsBuffer = 1024
+
+// newPairs returns a channel for sending the specified number of pairs, buffered to hold as many of
+// them as reasonable, so the sender seldom waits for the receiver.
+func newPairs(n int) chan Pair {
+	if n > maxPairsBuffer {
+		n = maxPairsBuffer
+	}
+	return make(chan Pair, n)
+}
+
+// pairsByExpiry returns a channel through which the live pairs are sent, soonest to expire first,
+// followed by the pairs that never expire.
+func pairsByExpiry(eps []expiringPair, now time.Time) <-chan Pair {
+	live := eps[:0]
+	for _, ep := range eps {
+		if ep.ev.live(now) {
+			live = append(live, ep)
+		}
+	}
+	sort.Slice(live, func(i, j int) bool {
+		ei, ej := live[i].ev.Expiry, live[j].ev.Expiry
+		if ei.IsZero() {
+			return false
+		}
+		return ej.IsZero() || ei.Before(ej)
+	})
+
+	pairs := newPairs(len(live))
+	go func(pairs chan<- Pair) {
+		for _, ep := range live {
+			pairs <- Pair{ep.key, ep.ev.Value}
+		}
+		close(pairs)
+	}(pairs)
+	return pairs
+}
+
+// snapshotPairs is the implementation of the Pairs method when SnapshotPairs was specified, which
+// gathers the live pairs visited by the Congomap's each method, then sends them after its lock is
+// released.
+func snapshotPairs(it iterable) <-chan Pair {
+	var live []Pair
+	now := it.now()
+	it.each(func(key string, ev *entry) bool {
+		if ev.live(now) {
+			live = append(live, Pair{key, ev.Value})
+		}
+		return true
+	})
+
+	pairs := newPairs(len(live))
+	go func(pairs chan<- Pair) {
+		for _, p := range live {
+			pairs <- p
+		}
+		close(pairs)
+	}(pairs)
+	return pairs
+}
+
+// mutator is the type of function each Congomap invokes with a key's live value, or nil when the
+// key is absent or expired, while holding that key's serialization. It returns the value to store
+// in its place, or nil to remove the key, along with whether the value it replaces ought to be sent
+// to the reaper. Returning its argument leaves the key unchanged.
+type mutator func(*entry) (*entry, bool)
+
+// mutated returns why the value stored for a key is reaped after a mutator, shown live, or nil when
+// the stored value had expired, returned next.
+func mutated(live, next *entry) EvictionReason {
+	switch {
+	case live == nil:
+		return ReasonExpired
+	case next == nil:
+		return ReasonDeleted
+	}
+	return ReasonReplaced
+}
+
+// doMutator adapts the function provided to the Do method to a mutator.
+func doMutator(fn func(interface{}, bool) (interface{}, bool), newValue func(interface{}) *entry) mutator {
+	return func(ev *entry) (*entry, bool) {
+		var value interface{}
+		if ev != nil {
+			value = ev.Value
 		}
-		return &ExpiringValue{Value: value}
+		replacement, ok := fn(value, ev != nil)
+		if !ok {
+			return ev, false
+		}
+		return newValue(replacement), true
+	}
+}
+
+// updateMutator adapts the function provided to the Update method to a mutator.
+func updateMutator(fn func(interface{}, bool) (interface{}, bool), newValue func(interface{}) *entry) mutator {
+	return func(ev *entry) (*entry, bool) {
+		var value interface{}
+		if ev != nil {
+			value = ev.Value
+		}
+		replacement, keep := fn(value, ev != nil)
+		if !keep {
+			return nil, true
+		}
+		return newValue(replacement), true
 	}
 }
 
+// withTTL returns the value to pass to Store so that it expires after the specified time-to-live.
+func (c *config) withTTL(value interface{}, ttl time.Duration) interface{} {
+	if ev, ok := value.(*ExpiringValue); ok {
+		value = ev.Value
+	}
+	if ttl <= 0 {
+		return value
+	}
+	return &ExpiringValue{Value: value, Expiry: c.now().Add(ttl)}
+}
+
+// setExpiry is the common implementation of the Expire and Persist methods, which replaces the live
+// value of key with one having the specified expiry using the Congomap's mutate method. The value is
+// copied rather than modified, because it may belong to the caller.
+func (c *config) setExpiry(mutate func(string, mutator), key string, expiry time.Time) bool {
+	var found bool
+	mutate(key, func(stored *entry) (*entry, bool) {
+		if stored == nil {
+			return nil, false
+		}
+		found = true
+		return c.clamp(stored.withExpiry(expiry)), false
+	})
+	return found
+}
+
+// touch is the common implementation of the Touch method, and of sliding expiration, which postpones
+// the expiry of the live value of key to the specified duration from now using the Congomap's
+// mutate method. An expiry is never brought forward, nor given to a value that never expires.
+func (c *config) touch(mutate func(string, mutator), key string, duration time.Duration) bool {
+	var found bool
+	mutate(key, func(stored *entry) (*entry, bool) {
+		if stored == nil {
+			return nil, false
+		}
+		found = true
+		if duration <= 0 || stored.Expiry.IsZero() {
+			return stored, false
+		}
+		expiry := c.now().Add(duration)
+		if !expiry.After(stored.Expiry) {
+			return stored, false
+		}
+		return c.clamp(stored.withExpiry(expiry)), false
+	})
+	return found
+}
+
+// storeReturning is the common implementation of the StoreReturning method, which replaces the
+// value of key with ev using the Congomap's mutate method.
+func storeReturning(mutate func(string, mutator), key string, ev *entry) (interface{}, bool) {
+	var prev interface{}
+	var existed bool
+	mutate(key, func(stored *entry) (*entry, bool) {
+		if stored != nil {
+			prev, existed = stored.Value, true
+		}
+		return ev, false
+	})
+	return prev, existed
+}
+
+// all is the common implementation of the All method, which gathers the live values visited by the
+// Congomap's each method each time the iterator is invoked.
+func all(it iterable) func(yield func(string, interface{}) bool) {
+	return func(yield func(string, interface{}) bool) {
+		var live []Pair
+		now := it.now()
+		it.each(func(key string, ev *entry) bool {
+			if ev.live(now) {
+				live = append(live, Pair{key, ev.Value})
+			}
+			return true
+		})
+		for _, p := range live {
+			if !yield(p.Key, p.Value) {
+				return
+			}
+		}
+	}
+}
+
+// pairsOf returns the keys and values of the data store, such as to reap them all.
+func pairsOf(db map[string]*entry) []Pair {
+	ps := make([]Pair, 0, len(db))
+	for key, ev := range db {
+		ps = append(ps, Pair{key, ev.Value})
+	}
+	return ps
+}
+
+// liveCount is the common implementation of the Len method, which counts the live values visited by
+// the Congomap's each method.
+func liveCount(it iterable) int {
+	now := it.now()
+	var n int
+	it.each(func(_ string, ev *entry) bool {
+		if ev.live(now) {
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+// snapshot is the common implementation of the Snapshot method, which gathers the live values
+// visited by the Congomap's each method, then copies them after its lock is released.
+func (c *config) snapshot(it iterable) map[string]interface{} {
+	m := make(map[string]interface{})
+	now := c.now()
+	it.each(func(key string, ev *entry) bool {
+		if ev.live(now) {
+			m[key] = ev.Value
+		}
+		return true
+	})
+	if c.copier != nil {
+		for key, value := range m {
+			m[key] = c.copier(value)
+		}
+	}
+	return m
+}
+
+// loadAndDelete is the common implementation of the LoadAndDelete method, which removes key using
+// the Congomap's mutate method when key has a live value.
+func loadAndDelete(mutate func(string, mutator), key string) (interface{}, bool) {
+	var value interface{}
+	var loaded bool
+	mutate(key, func(stored *entry) (*entry, bool) {
+		if stored != nil {
+			value, loaded = stored.Value, true
+		}
+		return nil, false
+	})
+	return value, loaded
+}
+
+// deleteIf is the common implementation of the DeleteIf method, which removes key using the
+// Congomap's mutate method when fn returns true for its live value.
+func deleteIf(mutate func(string, mutator), key string, fn func(interface{}) bool) bool {
+	var deleted bool
+	mutate(key, func(stored *entry) (*entry, bool) {
+		if stored == nil || !fn(stored.Value) {
+			return stored, false
+		}
+		deleted = true
+		return nil, true
+	})
+	return deleted
+}
+
+// loadOrStore is the common implementation of the LoadOrStore method, which stores ev as the value
+// of key using the Congomap's mutate method, unless key already has a live value.
+func loadOrStore(mutate func(string, mutator), key string, ev *entry) (interface{}, bool) {
+	var actual interface{}
+	var loaded bool
+	mutate(key, func(stored *entry) (*entry, bool) {
+		if stored != nil {
+			actual, loaded = stored.Value, true
+			return stored, false
+		}
+		actual = ev.Value
+		return ev, false
+	})
+	return actual, loaded
+}
+
 // ErrNoLookupDefined is returned by LoadStore method when a key is not found in a Congomap for
 // which there has been no lookup function declared.
 type ErrNoLookupDefined struct{}
@@ -112,6 +700,61 @@ func (e ErrNoLookupDefined) Error() string {
 	return "congomap: no lookup callback function set"
 }
 
+// ErrLookupFailed is returned by LoadStore method when the lookup callback function returns an
+// error for a key. It records which key failed, and wraps the error returned by the lookup
+// callback function.
+type ErrLookupFailed struct {
+	Key string
+	Err error
+}
+
+func (e ErrLookupFailed) Error() string {
+	return fmt.Sprintf("congomap: lookup %q: %s", e.Key, e.Err)
+}
+
+// Unwrap returns the error returned by the lookup callback function.
+func (e ErrLookupFailed) Unwrap() error {
+	return e.Err
+}
+
+// lookupError wraps an error returned by the lookup callback function for the specified key. The
+// errors returned when no lookup callback function was set, or when it timed out, are passed
+// through unchanged.
+func lookupError(key string, err error) error {
+	switch err.(type) {
+	case ErrNoLookupDefined, ErrLookupTimeout:
+		return err
+	}
+	return ErrLookupFailed{Key: key, Err: err}
+}
+
+// ErrCallbackPanic records a panic recovered from the named callback function.
+type ErrCallbackPanic struct {
+	Callback string
+	Value    interface{}
+}
+
+func (e ErrCallbackPanic) Error() string {
+	return fmt.Sprintf("congomap: %s callback function panicked: %v", e.Callback, e.Value)
+}
+
+// ErrReaperFailed is returned by the Close method when the reaper callback function specified by
+// FallibleReaper returned errors, or any reaper panicked, during the life of the Congomap. It
+// records how many times the reaper failed, and wraps the first error it returned.
+type ErrReaperFailed struct {
+	Count int
+	Err   error
+}
+
+func (e ErrReaperFailed) Error() string {
+	return fmt.Sprintf("congomap: reaper failed %d times; first error: %s", e.Count, e.Err)
+}
+
+// Unwrap returns the first error returned by the reaper callback function.
+func (e ErrReaperFailed) Unwrap() error {
+	return e.Err
+}
+
 // ErrInvalidDuration is returned by TTL function when a time-to-live of less than or equal to zero
 // is specified.
 type ErrInvalidDuration time.Duration
@@ -119,3 +762,32 @@ type ErrInvalidDuration time.Duration
 func (e ErrInvalidDuration) Error() string {
 	return "congomap: duration must be greater than 0: " + time.Duration(e).String()
 }
+
+// ErrOptionConflict is returned when creating a Congomap with a combination of options that
+// conflict, or where one option has no effect without another. It is also returned by
+// TTLDistribution for a Congomap created with ExpiryHeap.
+type ErrOptionConflict string
+
+func (e ErrOptionConflict) Error() string {
+	return "congomap: option conflict: " + string(e)
+}
+
+// ErrInvalidOptions is returned when creating a Congomap with more than one invalid option or
+// conflicting combination of options, and lists every problem found.
+type ErrInvalidOptions []error
+
+func (e ErrInvalidOptions) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("congomap: %d invalid options: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// ErrUnsupportedOption is returned when a Setter is used to create a Congomap that is not provided
+// by this library, and does not support that option.
+type ErrUnsupportedOption struct{}
+
+func (e ErrUnsupportedOption) Error() string {
+	return "congomap: option not supported"
+}
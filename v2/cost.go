@@ -0,0 +1,76 @@
+package congomap
+
+import "sync/atomic"
+
+// MaxCost is used to specify the greatest total cost of the values the Congomap holds, as measured
+// by the function specified by Cost, such as their size in bytes. Whenever a write makes the total
+// exceed the limit, other keys are evicted, sampled as for MaxEntries, until it no longer does, and
+// their values are sent to the reaper. A value costing more than the limit by itself is still
+// stored, but evicts every other key. Without Cost, every value costs 1.
+func MaxCost(total int64) Setter {
+	return configure(func(c *config) error {
+		if total <= 0 {
+			return ErrInvalidCount{Option: "MaxCost", Count: int(total)}
+		}
+		c.maxCost = total
+		return nil
+	})
+}
+
+// Cost is used to specify the function that measures the cost of each value held by the Congomap,
+// whose total is limited by MaxCost. It is invoked when a value is written, and again when it is
+// removed, so it must return the same cost each time it is invoked with the same key and value.
+func Cost(cost func(key string, value interface{}) int64) Setter {
+	return configure(func(c *config) error {
+		c.cost = cost
+		return nil
+	})
+}
+
+// costOf returns the cost of the value for key, or zero when there is no value.
+func (c *config) costOf(key string, ev *entry) int64 {
+	switch {
+	case ev == nil:
+		return 0
+	case c.cost == nil:
+		return 1
+	}
+	return c.cost(key, ev.Value)
+}
+
+// charge accounts for the value of key being replaced by next, either of which may be nil, in the
+// total cost of the values held. It does nothing unless MaxCost was specified.
+func (c *config) charge(key string, prev, next *entry) {
+	if c.maxCost > 0 {
+		atomic.AddInt64(&c.spent, c.costOf(key, next)-c.costOf(key, prev))
+	}
+}
+
+// put stores the value of key in the data store, accounting for its cost and indexing its expiry.
+// The caller must serialize access to the data store.
+func (c *config) put(db map[string]*entry, key string, ev *entry) {
+	c.charge(key, db[key], ev)
+	db[key] = ev
+	c.indexExpiry(key, ev)
+}
+
+// remove deletes key from the data store, accounting for the cost of its value. The caller must
+// serialize access to the data store.
+func (c *config) remove(db map[string]*entry, key string) {
+	c.charge(key, db[key], nil)
+	delete(db, key)
+}
+
+// full returns true while the data store holds more than MaxEntries keys, or values whose total cost
+// exceeds MaxCost, given how many keys it holds.
+func (c *config) full(keys int) bool {
+	return (c.maxEntries > 0 && keys > c.maxEntries) || (c.maxCost > 0 && atomic.LoadInt64(&c.spent) > c.maxCost)
+}
+
+// resetCost forgets the cost of every value held, when the data store is replaced by an empty one.
+// The caller must serialize access to the data store.
+func (c *config) resetCost() {
+	if c.maxCost > 0 {
+		atomic.StoreInt64(&c.spent, 0)
+	}
+}
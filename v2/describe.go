@@ -0,0 +1,164 @@
+package congomap
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Description is the effective configuration of a Congomap, as returned by Describe.
+type Description struct {
+	// Implementation is the name of the Congomap's type: "channel", "syncAtomic", "syncMutex", or
+	// "twoLevel".
+	Implementation string
+
+	// GCInterval is how often the Congomap is garbage collected, or zero when ManualMaintenance
+	// was specified.
+	GCInterval time.Duration
+
+	TTL               time.Duration
+	StoreTTL          time.Duration
+	LookupTTL         time.Duration
+	MinTTL            time.Duration
+	MaxTTL            time.Duration
+	MaxStale          time.Duration
+	Stale             time.Duration
+	AccessTTL         time.Duration
+	BadExpiryDuration time.Duration
+	BadStaleDuration  time.Duration
+
+	// TTLJitter is the fraction by which the default time-to-live of each value is randomized.
+	TTLJitter float64
+
+	// MaxEntries is the most keys held before sampled keys are evicted, or zero when unbounded.
+	// EvictionSamples is how many keys are sampled to choose each key evicted. EvictLRU is true
+	// when the least recently used sampled key is evicted, rather than the one expiring soonest.
+	MaxEntries      int
+	EvictionSamples int
+	EvictLRU        bool
+
+	// MaxCost is the greatest total cost of the values held, or zero when unbounded.
+	MaxCost int64
+
+	Reaper       bool // true when a reaper callback function was specified
+	CollectStats bool // true when operations are counted for Stats
+	ExpiryHeap   bool // true when GC consults a heap of expiries rather than examining every value
+
+	// ShardCount is the number of shards of the index of keys of a TwoLevelMap, or zero for any
+	// other Congomap.
+	ShardCount int
+
+	// WriteBuffer is how many stored keys a SyncAtomicMap buffers before merging them into its
+	// data store, and WriteBufferInterval how often it merges them regardless, or zero when
+	// WriteBuffer was not specified.
+	WriteBuffer         int
+	WriteBufferInterval time.Duration
+}
+
+// String returns the implementation name followed by the options that differ from their defaults,
+// such as "syncMutex{GCInterval: 15m0s, TTL: 1m0s, Reaper: true}", for logs and admin endpoints.
+func (d Description) String() string {
+	var fields []string
+	for _, f := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"GCInterval", d.GCInterval},
+		{"TTL", d.TTL},
+		{"StoreTTL", d.StoreTTL},
+		{"LookupTTL", d.LookupTTL},
+		{"MinTTL", d.MinTTL},
+		{"MaxTTL", d.MaxTTL},
+		{"MaxStale", d.MaxStale},
+		{"Stale", d.Stale},
+		{"AccessTTL", d.AccessTTL},
+		{"BadExpiryDuration", d.BadExpiryDuration},
+		{"BadStaleDuration", d.BadStaleDuration},
+	} {
+		if f.value != 0 {
+			fields = append(fields, fmt.Sprintf("%s: %s", f.name, f.value))
+		}
+	}
+	if d.TTLJitter != 0 {
+		fields = append(fields, fmt.Sprintf("TTLJitter: %v", d.TTLJitter))
+	}
+	if d.MaxEntries != 0 {
+		fields = append(fields, fmt.Sprintf("MaxEntries: %d", d.MaxEntries))
+	}
+	if d.MaxCost != 0 {
+		fields = append(fields, fmt.Sprintf("MaxCost: %d", d.MaxCost))
+	}
+	if d.EvictionSamples != 0 {
+		fields = append(fields, fmt.Sprintf("EvictionSamples: %d", d.EvictionSamples))
+	}
+	if d.EvictLRU {
+		fields = append(fields, "EvictLRU: true")
+	}
+	if d.Reaper {
+		fields = append(fields, "Reaper: true")
+	}
+	if d.CollectStats {
+		fields = append(fields, "CollectStats: true")
+	}
+	if d.ExpiryHeap {
+		fields = append(fields, "ExpiryHeap: true")
+	}
+	if d.ShardCount > 1 {
+		fields = append(fields, fmt.Sprintf("ShardCount: %d", d.ShardCount))
+	}
+	if d.WriteBuffer != 0 {
+		fields = append(fields, fmt.Sprintf("WriteBuffer: %d, WriteBufferInterval: %s", d.WriteBuffer, d.WriteBufferInterval))
+	}
+	return d.Implementation + "{" + strings.Join(fields, ", ") + "}"
+}
+
+// Describe returns the effective configuration of the Congomap, so logs and admin endpoints can
+// show exactly how each instance is configured at runtime. It returns ErrUnsupportedOption for a
+// Congomap not provided by this library.
+func Describe(cgm Congomap) (Description, error) {
+	var d Description
+	switch t := cgm.(type) {
+	case *channelMap:
+		d.Implementation = "channel"
+	case *syncAtomicMap:
+		d.Implementation = "syncAtomic"
+		d.WriteBuffer = t.buffer.limit
+		d.WriteBufferInterval = t.buffer.every
+	case *syncMutexMap:
+		d.Implementation = "syncMutex"
+	case *twoLevelMap:
+		d.Implementation = "twoLevel"
+		d.ShardCount = len(t.shards)
+	default:
+		return d, ErrUnsupportedOption{}
+	}
+
+	c := cgm.(configurable).getConfig()
+	if !c.manual {
+		d.GCInterval = c.gcInterval()
+	}
+	d.TTL = c.ttl
+	d.StoreTTL = c.storeTTL
+	d.LookupTTL = c.lookupTTL
+	d.MinTTL = c.minTTL
+	d.MaxTTL = c.maxTTL
+	d.MaxStale = c.maxStale
+	d.Stale = c.stale
+	d.AccessTTL = c.accessTTL
+	d.BadExpiryDuration = c.badExpiry
+	d.BadStaleDuration = c.badStale
+	d.TTLJitter = c.jitter
+	d.MaxEntries = c.maxEntries
+	d.MaxCost = c.maxCost
+	if c.maxEntries > 0 || c.maxCost > 0 {
+		d.EvictionSamples = c.evictionSamples
+		if d.EvictionSamples == 0 {
+			d.EvictionSamples = defaultEvictionSamples
+		}
+		d.EvictLRU = c.lru
+	}
+	d.Reaper = c.reaper != nil
+	d.CollectStats = c.stats != nil
+	d.ExpiryHeap = c.expiries != nil
+	return d, nil
+}
@@ -0,0 +1,61 @@
+package congomap
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Difference reports how the live contents of one Congomap differ from those of another, as
+// returned by Diff. Each slice of keys is sorted.
+type Difference struct {
+	Added   []string // keys only present in the second Congomap
+	Removed []string // keys only present in the first Congomap
+	Changed []string // keys present in both, whose values are not equal
+}
+
+// Empty returns true when the Difference reports no keys.
+func (d Difference) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares the live contents of two Congomaps, which need not be of the same type, and reports
+// the keys added, removed, and changed going from the first to the second. Values are compared with
+// the equal function, or with reflect.DeepEqual when it is nil. Because each Congomap is read with
+// its Pairs method, the comparison is only meaningful when neither Congomap is being modified.
+func Diff(from, to Congomap, equal func(interface{}, interface{}) bool) Difference {
+	if equal == nil {
+		equal = reflect.DeepEqual
+	}
+
+	values := make(map[string]interface{})
+	for pair := range from.Pairs() {
+		values[pair.Key] = pair.Value
+	}
+
+	var d Difference
+	for pair := range to.Pairs() {
+		value, ok := values[pair.Key]
+		if !ok {
+			d.Added = append(d.Added, pair.Key)
+			continue
+		}
+		delete(values, pair.Key)
+		if !equal(value, pair.Value) {
+			d.Changed = append(d.Changed, pair.Key)
+		}
+	}
+	for key := range values {
+		d.Removed = append(d.Removed, key)
+	}
+
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+	return d
+}
+
+// Equal returns true when two Congomaps, which need not be of the same type, have the same live
+// keys, and equal values for each key, compared as described by Diff.
+func Equal(a, b Congomap, equal func(interface{}, interface{}) bool) bool {
+	return Diff(a, b, equal).Empty()
+}
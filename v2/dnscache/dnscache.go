@@ -0,0 +1,139 @@
+// Package dnscache provides a cache of host name resolutions backed by a Congomap, in which each
+// resolution expires after the TTL of the DNS answer it came from.
+//
+//	r, err := dnscache.New(congomap.NewTwoLevelMap, nil, congomap.MaxTTL(time.Hour))
+//	if err != nil {
+//	    panic(err)
+//	}
+//	defer func() { _ = r.Close() }()
+//
+//	addrs, err := r.LookupHost(ctx, "example.com")
+package dnscache
+
+import (
+	"context"
+	"net"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+)
+
+// DefaultTTL is how long resolutions obtained from a net.Resolver are cached, because net.Resolver
+// does not report the TTLs of the DNS answers it receives.
+const DefaultTTL = time.Minute
+
+// Upstream resolves a host name to its addresses, and reports the TTL of the DNS answer that
+// provided them.
+type Upstream func(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error)
+
+// NetResolver returns an Upstream that resolves host names with the specified net.Resolver, and
+// reports the given TTL for every answer.
+func NetResolver(r *net.Resolver, ttl time.Duration) Upstream {
+	return func(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+		addrs, err := r.LookupIPAddr(ctx, host)
+		return addrs, ttl, err
+	}
+}
+
+// Setter declares the type of function used when creating a Resolver to change the instance's
+// behavior.
+type Setter func(*Resolver) error
+
+// WithUpstream is used to specify how host names absent from the cache are resolved. The default
+// is NetResolver(net.DefaultResolver, DefaultTTL).
+func WithUpstream(upstream Upstream) Setter {
+	return func(r *Resolver) error {
+		r.upstream = upstream
+		return nil
+	}
+}
+
+// Resolver resolves host names like net.Resolver, but caches each resolution until the TTL of its
+// DNS answer elapses. Failed resolutions are not cached.
+type Resolver struct {
+	cgm      congomap.Congomap
+	upstream Upstream
+}
+
+// New returns a Resolver that caches resolutions in a Congomap created by newCongomap, such as
+// congomap.NewTwoLevelMap, with the given Congomap setters. Setters such as congomap.MinTTL and
+// congomap.MaxTTL bound the TTLs of the cached answers.
+func New(newCongomap func(...congomap.Setter) (congomap.Congomap, error), setters []Setter, cgmSetters ...congomap.Setter) (*Resolver, error) {
+	r := &Resolver{upstream: NetResolver(net.DefaultResolver, DefaultTTL)}
+	for _, setter := range setters {
+		if err := setter(r); err != nil {
+			return nil, err
+		}
+	}
+	cgm, err := newCongomap(cgmSetters...)
+	if err != nil {
+		return nil, err
+	}
+	r.cgm = cgm
+	return r, nil
+}
+
+// Close releases the resources of the Congomap holding the cached resolutions.
+func (r *Resolver) Close() error {
+	return r.cgm.Close()
+}
+
+// LookupIPAddr returns the addresses of the host, from the cache when a resolution of the host has
+// not yet expired, and otherwise from the Upstream.
+func (r *Resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if value, ok := r.cgm.Load(host); ok {
+		return value.([]net.IPAddr), nil
+	}
+	addrs, ttl, err := r.upstream(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if ttl > 0 {
+		r.cgm.Store(host, &congomap.ExpiringValue{Value: addrs, Expiry: time.Now().Add(ttl)})
+	}
+	return addrs, nil
+}
+
+// LookupIP returns the addresses of the host for the network, which must be "ip", "ip4", or "ip6",
+// as LookupIPAddr would.
+func (r *Resolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	var keep func(net.IP) bool
+	switch network {
+	case "ip":
+		keep = func(net.IP) bool { return true }
+	case "ip4":
+		keep = func(ip net.IP) bool { return ip.To4() != nil }
+	case "ip6":
+		keep = func(ip net.IP) bool { return ip.To4() == nil }
+	default:
+		return nil, net.UnknownNetworkError(network)
+	}
+
+	addrs, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		if keep(addr.IP) {
+			ips = append(ips, addr.IP)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return ips, nil
+}
+
+// LookupHost returns the addresses of the host as strings, as LookupIPAddr would.
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	hosts := make([]string, len(addrs))
+	for i, addr := range addrs {
+		hosts[i] = addr.String()
+	}
+	return hosts, nil
+}
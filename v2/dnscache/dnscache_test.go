@@ -0,0 +1,103 @@
+package dnscache_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+	"github.com/karrick/congomap/v2/dnscache"
+)
+
+var errNoSuchHost = errors.New("no such host")
+
+// fakeUpstream answers for two hosts with different TTLs, and counts how often it is asked.
+type fakeUpstream struct{ calls int32 }
+
+func (u *fakeUpstream) lookup(_ context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	atomic.AddInt32(&u.calls, 1)
+	switch host {
+	case "long.example":
+		return []net.IPAddr{{IP: net.ParseIP("192.0.2.1")}, {IP: net.ParseIP("2001:db8::1")}}, time.Hour, nil
+	case "short.example":
+		return []net.IPAddr{{IP: net.ParseIP("192.0.2.2")}}, 10 * time.Millisecond, nil
+	}
+	return nil, 0, errNoSuchHost
+}
+
+func newTestResolver(t *testing.T, u *fakeUpstream) *dnscache.Resolver {
+	r, err := dnscache.New(congomap.NewSyncMutexMap, []dnscache.Setter{dnscache.WithUpstream(u.lookup)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+	return r
+}
+
+func TestLookupHostCaches(t *testing.T) {
+	u := &fakeUpstream{}
+	r := newTestResolver(t, u)
+
+	for i := 0; i < 3; i++ {
+		hosts, err := r.LookupHost(context.Background(), "long.example")
+		if expected := []string{"192.0.2.1", "2001:db8::1"}; !reflect.DeepEqual(hosts, expected) || err != nil {
+			t.Errorf("Actual: %v, %#v; Expected: %v, %#v", hosts, err, expected, nil)
+		}
+	}
+	if calls := atomic.LoadInt32(&u.calls); calls != 1 {
+		t.Errorf("Actual: %d; Expected: %d", calls, 1)
+	}
+}
+
+func TestAnswerTTL(t *testing.T) {
+	u := &fakeUpstream{}
+	r := newTestResolver(t, u)
+
+	if _, err := r.LookupHost(context.Background(), "short.example"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := r.LookupHost(context.Background(), "short.example"); err != nil {
+		t.Fatal(err)
+	}
+	if calls := atomic.LoadInt32(&u.calls); calls != 2 {
+		t.Errorf("Actual: %d; Expected: %d", calls, 2)
+	}
+}
+
+func TestErrorsNotCached(t *testing.T) {
+	u := &fakeUpstream{}
+	r := newTestResolver(t, u)
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.LookupHost(context.Background(), "missing.example"); err != errNoSuchHost {
+			t.Errorf("Actual: %#v; Expected: %#v", err, errNoSuchHost)
+		}
+	}
+	if calls := atomic.LoadInt32(&u.calls); calls != 2 {
+		t.Errorf("Actual: %d; Expected: %d", calls, 2)
+	}
+}
+
+func TestLookupIPNetwork(t *testing.T) {
+	r := newTestResolver(t, &fakeUpstream{})
+
+	ips, err := r.LookupIP(context.Background(), "ip6", "long.example")
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("2001:db8::1")) || err != nil {
+		t.Errorf("Actual: %v, %#v; Expected: %v, %#v", ips, err, "[2001:db8::1]", nil)
+	}
+
+	_, err = r.LookupIP(context.Background(), "ip6", "short.example")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("Actual: %#v; Expected: %T", err, dnsErr)
+	}
+
+	if _, err = r.LookupIP(context.Background(), "tcp", "long.example"); err == nil {
+		t.Errorf("Actual: %#v; Expected: error", err)
+	}
+}
@@ -0,0 +1,122 @@
+package congomap
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ErrDraining is returned by LoadStore when the Congomap is being drained by Drain.
+type ErrDraining struct{}
+
+func (e ErrDraining) Error() string {
+	return "congomap: draining"
+}
+
+// ErrClosed is returned by LoadStore when the Congomap has been closed.
+type ErrClosed struct{}
+
+func (e ErrClosed) Error() string {
+	return "congomap: closed"
+}
+
+// admit returns ErrClosed when the Congomap is closed, or ErrDraining when it is draining, and
+// otherwise counts an operation in flight, which must be followed by a call to release.
+func (c *config) admit() error {
+	if c.isClosed() {
+		return ErrClosed{}
+	}
+	atomic.AddInt32(&c.inflight, 1)
+	if atomic.LoadInt32(&c.draining) != 0 {
+		c.release()
+		return ErrDraining{}
+	}
+	return nil
+}
+
+// closing marks the Congomap closed, and returns true the first time it is invoked, so that Close
+// only stops the Congomap once however many times it is invoked.
+func (c *config) closing() bool {
+	return atomic.CompareAndSwapInt32(&c.closed, 0, 1)
+}
+
+// isClosed returns true once Close has been invoked.
+func (c *config) isClosed() bool {
+	return atomic.LoadInt32(&c.closed) != 0
+}
+
+// goBackground invokes fn from a new goroutine that Shutdown waits for, for work that outlives the
+// operation that started it.
+func (c *config) goBackground(fn func()) {
+	c.background.Add(1)
+	go func() {
+		defer c.background.Done()
+		fn()
+	}()
+}
+
+// release counts the end of an operation admitted by admit, and wakes Drain when it was the last
+// operation in flight while draining.
+func (c *config) release() {
+	if atomic.AddInt32(&c.inflight, -1) != 0 || atomic.LoadInt32(&c.draining) == 0 {
+		return
+	}
+	c.drainLock.Lock()
+	select {
+	case <-c.drained:
+	default:
+		close(c.drained)
+	}
+	c.drainLock.Unlock()
+}
+
+// Drain shuts down the Congomap in an orderly fashion, for processes that stop serving requests
+// before they exit. Once Drain is invoked, LoadStore and LoadStoreAll return ErrDraining, and Store,
+// Do, and StoreReturning do nothing, while Load and Delete continue to work. Drain waits for the
+// operations already in progress, including invocations of the lookup callback function, to
+// finish, then closes the Congomap, reaping its remaining values, and returns what Close returns.
+//
+// A Congomap not provided by this library is simply closed.
+func Drain(cgm Congomap) error {
+	c, ok := cgm.(configurable)
+	if !ok {
+		return cgm.Close()
+	}
+	cfg := c.getConfig()
+	cfg.drainLock.Lock()
+	if cfg.drained == nil {
+		cfg.drained = make(chan struct{})
+	}
+	drained := cfg.drained
+	cfg.drainLock.Unlock()
+
+	// Either release observes draining after the last operation in flight returns, and closes
+	// drained, or the load below observes no operations in flight.
+	atomic.StoreInt32(&cfg.draining, 1)
+	if atomic.LoadInt32(&cfg.inflight) != 0 {
+		<-drained
+	}
+	return cgm.Close()
+}
+
+// Shutdown drains the Congomap as Drain does, then also waits for the goroutines the Congomap
+// started in the background, such as those reaping values evicted or collected as garbage, and
+// those refreshing memoized lookup errors, to finish. It returns what Close returns, or ctx.Err()
+// when ctx is done first, in which case the Congomap continues shutting down in the background.
+//
+// A Congomap not provided by this library is simply closed, unless ctx is done first.
+func Shutdown(ctx context.Context, cgm Congomap) error {
+	done := make(chan error, 1)
+	go func() {
+		err := Drain(cgm)
+		if c, ok := cgm.(configurable); ok {
+			c.getConfig().background.Wait()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,136 @@
+package congomap
+
+import (
+	"sync"
+	"time"
+)
+
+// EvictionReason is why a value was removed from a Congomap.
+type EvictionReason int
+
+const (
+	// ReasonExpired is for a value removed after it expired, by GC, or by being replaced.
+	ReasonExpired EvictionReason = iota
+
+	// ReasonDeleted is for a value removed by Delete, DeleteMany, or a function that removed its
+	// key.
+	ReasonDeleted
+
+	// ReasonReplaced is for a value replaced by another value for the same key.
+	ReasonReplaced
+
+	// ReasonEvicted is for a value evicted because the Congomap held more than MaxEntries keys.
+	ReasonEvicted
+
+	// ReasonCleared is for a value removed by Clear.
+	ReasonCleared
+
+	// ReasonClosed is for a value removed when the Congomap was closed.
+	ReasonClosed
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case ReasonExpired:
+		return "expired"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonReplaced:
+		return "replaced"
+	case ReasonEvicted:
+		return "evicted"
+	case ReasonCleared:
+		return "cleared"
+	case ReasonClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// EvictionEvent describes a value removed from a Congomap, as sent through the channel returned by
+// Events.
+type EvictionEvent struct {
+	Key    string
+	Value  interface{}
+	Reason EvictionReason
+	Time   time.Time
+}
+
+// events holds the channel through which eviction events are sent, as enabled by EvictionEvents.
+type events struct {
+	lock   sync.Mutex
+	ch     chan EvictionEvent
+	closed bool
+}
+
+// EvictionEvents is used to specify that an EvictionEvent is sent through the channel returned by
+// Events for every value removed from the Congomap, as an alternative to Reaper for applications
+// that forward removals through channels, such as to invalidate the caches of other nodes. The
+// channel buffers the specified number of events, and an event is dropped rather than sent when the
+// buffer is full, so a slow reader never blocks the Congomap. The channel is closed when the
+// Congomap is closed.
+func EvictionEvents(buffer int) Setter {
+	return configure(func(c *config) error {
+		if buffer <= 0 {
+			return ErrInvalidCount{Option: "EvictionEvents", Count: buffer}
+		}
+		c.events.ch = make(chan EvictionEvent, buffer)
+		return nil
+	})
+}
+
+// Events returns the channel through which eviction events are sent, or nil when EvictionEvents was
+// not specified. It returns ErrUnsupportedOption for a Congomap not provided by this library.
+func Events(cgm Congomap) (<-chan EvictionEvent, error) {
+	c, ok := cgm.(configurable)
+	if !ok {
+		return nil, ErrUnsupportedOption{}
+	}
+	return c.getConfig().events.ch, nil
+}
+
+// reaping returns true when values removed from the data store are sent to a reaper or an events
+// channel, or are counted, logged, or observed.
+func (c *config) reaping() bool {
+	if c.reaper != nil || c.events.ch != nil {
+		return true
+	}
+	return c.stats != nil || c.logger != nil || c.observer != nil
+}
+
+// notify sends an event for each of the removed pairs through the events channel, dropping those
+// for which the buffer has no room.
+func (c *config) notify(reason EvictionReason, pairs ...Pair) {
+	c.removed(reason, pairs)
+	if reason == ReasonEvicted {
+		c.debugf("congomap: evicted %d values", len(pairs))
+	}
+	if c.events.ch == nil {
+		return
+	}
+	now := c.now()
+	c.events.lock.Lock()
+	defer c.events.lock.Unlock()
+	if c.events.closed {
+		return
+	}
+	for _, p := range pairs {
+		select {
+		case c.events.ch <- EvictionEvent{Key: p.Key, Value: p.Value, Reason: reason, Time: now}:
+		default:
+		}
+	}
+}
+
+// closeEvents closes the events channel, after which no more events are sent.
+func (c *config) closeEvents() {
+	if c.events.ch == nil {
+		return
+	}
+	c.events.lock.Lock()
+	if !c.events.closed {
+		c.events.closed = true
+		close(c.events.ch)
+	}
+	c.events.lock.Unlock()
+}
@@ -0,0 +1,128 @@
+package congomap
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// ExpiryHeap is used to specify that the Congomap maintains a min-heap of the expiry of the values
+// it holds, so GC removes exactly the values that have expired, in O(k log n) time for k expired
+// values among n, rather than examining every value, and so the go routine that invokes GC sleeps
+// until the next value expires, or the interval specified by GCInterval elapses, whichever is
+// sooner. It suits a Congomap holding many values that expire at varied times. Because GC no longer
+// examines every value, TTLDistribution returns ErrOptionConflict. Each value written with an expiry
+// adds an entry to the heap, which is removed when that expiry passes, so the heap holds an entry
+// for every write whose value has not yet expired, even when the value has since been replaced or
+// deleted.
+func ExpiryHeap() Setter {
+	return configure(func(c *config) error {
+		c.expiries = &expiries{wake: make(chan struct{}, 1)}
+		return nil
+	})
+}
+
+// expiryEntry records that the value written to the key expires at the specified time.
+type expiryEntry struct {
+	expiry time.Time
+	key    string
+}
+
+// expiryEntries implements heap.Interface, ordering entries soonest to expire first.
+type expiryEntries []expiryEntry
+
+func (h expiryEntries) Len() int            { return len(h) }
+func (h expiryEntries) Less(i, j int) bool  { return h[i].expiry.Before(h[j].expiry) }
+func (h expiryEntries) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *expiryEntries) Push(x interface{}) { *h = append(*h, x.(expiryEntry)) }
+
+func (h *expiryEntries) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// expiries is the heap maintained when ExpiryHeap was specified. Entries are not removed when their
+// values are replaced or deleted, so GC confirms each key it pops still holds an evictable value.
+type expiries struct {
+	lock    sync.Mutex
+	entries expiryEntries
+	wake    chan struct{} // receives when the soonest expiry becomes sooner
+}
+
+// indexExpiry adds the expiry of the value written to the key to the heap. It does nothing unless
+// ExpiryHeap was specified.
+func (c *config) indexExpiry(key string, ev *entry) {
+	if c.expiries == nil || ev == nil || ev.Expiry.IsZero() {
+		return
+	}
+	x := c.expiries
+	x.lock.Lock()
+	heap.Push(&x.entries, expiryEntry{ev.Expiry, key})
+	soonest := x.entries[0].expiry.Equal(ev.Expiry)
+	x.lock.Unlock()
+	if soonest {
+		select {
+		case x.wake <- struct{}{}:
+		default: // already awakened
+		}
+	}
+}
+
+// popExpired removes the entries whose values are evictable as of the specified time from the heap,
+// and returns their keys, each at most once.
+func (c *config) popExpired(now time.Time) []string {
+	x := c.expiries
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	var keys []string
+	seen := make(map[string]struct{})
+	for len(x.entries) > 0 && c.evictableAt(x.entries[0].expiry, now) {
+		e := heap.Pop(&x.entries).(expiryEntry)
+		if _, ok := seen[e.key]; !ok {
+			seen[e.key] = struct{}{}
+			keys = append(keys, e.key)
+		}
+	}
+	return keys
+}
+
+// untilExpiry returns how long until GC ought to be invoked to remove the value that expires
+// soonest, no longer than the interval between periodic GCs.
+func (c *config) untilExpiry() time.Duration {
+	d := c.gcInterval()
+	x := c.expiries
+	x.lock.Lock()
+	if len(x.entries) > 0 {
+		// a value is only evictable once its expiry and the time it may be served stale have passed
+		if until := x.entries[0].expiry.Add(c.maxStale).Sub(c.now()) + time.Nanosecond; until < d {
+			d = until
+		}
+	}
+	x.lock.Unlock()
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+// sweepExpired removes the values the heap reports to have expired from the data store, and
+// returns how many it removed, and their pairs when reaping. The caller must serialize access to
+// the data store.
+func (c *config) sweepExpired(db map[string]*entry, now time.Time) (int, []Pair) {
+	var evicted int
+	var reaped []Pair
+	for _, key := range c.popExpired(now) {
+		ev, ok := db[key]
+		if !ok || !c.evictable(ev, now) {
+			continue // deleted, or replaced by a value with a later expiry
+		}
+		c.remove(db, key)
+		evicted++
+		if c.reaping() {
+			reaped = append(reaped, Pair{key, ev.Value})
+		}
+	}
+	return evicted, reaped
+}
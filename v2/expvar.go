@@ -0,0 +1,40 @@
+package congomap
+
+import (
+	"expvar"
+	"fmt"
+)
+
+// PublishExpvar is used to specify that the counts returned by Stats are published as an expvar
+// variable with the specified name, so they are served by /debug/vars alongside the runtime's
+// memstats. It implies CollectStats. Because expvar variables cannot be removed, the name may be
+// used by only one Congomap during the life of the process, and the variable continues to report
+// the final counts after the Congomap is closed.
+func PublishExpvar(name string) Setter {
+	return configure(func(c *config) error {
+		if name == "" {
+			return ErrOptionConflict("PublishExpvar requires a name")
+		}
+		c.expvarName = name
+		if c.stats == nil {
+			c.stats = new(counters)
+		}
+		return nil
+	})
+}
+
+// publishConflict returns an error when the name specified by PublishExpvar is already published.
+func (c *config) publishConflict() error {
+	if c.expvarName != "" && expvar.Get(c.expvarName) != nil {
+		return ErrOptionConflict(fmt.Sprintf("PublishExpvar name %q is already published", c.expvarName))
+	}
+	return nil
+}
+
+// publish publishes the counts returned by Stats under the name specified by PublishExpvar. It
+// does nothing unless PublishExpvar was specified.
+func (c *config) publish() {
+	if c.expvarName != "" {
+		expvar.Publish(c.expvarName, expvar.Func(func() interface{} { return c.statistics() }))
+	}
+}
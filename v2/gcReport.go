@@ -0,0 +1,34 @@
+package congomap
+
+import "time"
+
+// GCReport describes what the most recent GC of a Congomap reclaimed, so operators may tune the TTL
+// and how often to invoke GC.
+type GCReport struct {
+	// Started is when the GC started, or the zero time when GC has not yet run.
+	Started time.Time
+
+	// Duration is how long the GC took to sweep the data store, not including invoking the
+	// reaper callback function on the values it removed.
+	Duration time.Duration
+
+	// Evicted is the number of values the GC removed from the data store.
+	Evicted int
+
+	// Remaining is the number of values the GC left in the data store.
+	Remaining int
+}
+
+// GCStats returns the report of the most recent GC of the Congomap, whether invoked by its GC
+// method or periodically. It returns ErrUnsupportedOption for a Congomap not provided by this
+// library.
+func GCStats(cgm Congomap) (GCReport, error) {
+	c, ok := cgm.(configurable)
+	if !ok {
+		return GCReport{}, ErrUnsupportedOption{}
+	}
+	cfg := c.getConfig()
+	cfg.ttlLock.Lock()
+	defer cfg.ttlLock.Unlock()
+	return cfg.gcReport, nil
+}
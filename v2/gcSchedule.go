@@ -0,0 +1,98 @@
+package congomap
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+func (c *config) PauseGC() {
+	atomic.StoreInt32(&c.gcPaused, 1)
+}
+
+func (c *config) ResumeGC() {
+	atomic.StoreInt32(&c.gcPaused, 0)
+}
+
+// gcDue returns true when periodic GC has not been paused by PauseGC.
+func (c *config) gcDue() bool {
+	return atomic.LoadInt32(&c.gcPaused) == 0
+}
+
+// gcTicker returns a channel that receives each time periodic GC is due, and a function that stops
+// it, which must be invoked once the channel is no longer read. The channel is nil when GC is only
+// invoked by the application.
+func (c *config) gcTicker() (<-chan time.Time, func()) {
+	if c.manual {
+		return nil, func() {}
+	}
+	if c.expiries != nil {
+		return c.expiryTicker()
+	}
+	if c.clock == nil {
+		ticker := time.NewTicker(c.gcInterval())
+		return ticker.C, ticker.Stop
+	}
+
+	// a TimeSource has no ticker, so its After method is consulted for each period
+	ticks := make(chan time.Time)
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case now := <-c.clock.After(c.gcInterval()):
+				select {
+				case ticks <- now:
+				case <-stop:
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return ticks, func() { close(stop) }
+}
+
+// expiryTicker returns a channel that receives when the value that expires soonest becomes evictable,
+// or when the interval between periodic GCs elapses, whichever is sooner, and a function that stops
+// it, as used when ExpiryHeap was specified. While periodic GC is paused it receives only once each
+// interval.
+func (c *config) expiryTicker() (<-chan time.Time, func()) {
+	ticks := make(chan time.Time)
+	stop := make(chan struct{})
+	go func() {
+		for {
+			d := c.gcInterval()
+			if c.gcDue() {
+				d = c.untilExpiry()
+			}
+			var timer *time.Timer
+			var fired <-chan time.Time
+			if c.clock == nil {
+				timer = time.NewTimer(d)
+				fired = timer.C
+			} else {
+				fired = c.clock.After(d)
+			}
+			select {
+			case now := <-fired:
+				select {
+				case ticks <- now:
+				case <-stop:
+					return
+				}
+			case <-c.expiries.wake:
+				// a value expires sooner than the one waited for
+			case <-stop:
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+	}()
+	return ticks, func() { close(stop) }
+}
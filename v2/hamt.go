@@ -0,0 +1,279 @@
+package congomap
+
+import (
+	"math/bits"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	hamtBits = 5 // bits of the hash consumed by each level of the trie
+	hamtMask = 1<<hamtBits - 1
+)
+
+// hamt is a persistent hash array mapped trie of the values held by a SyncAtomicMap. It is never
+// modified once built: the with and without methods return a new trie sharing every node but those
+// on the path to the key, so writes take O(log n) time and space, and readers may consult a trie
+// without holding any lock.
+type hamt struct {
+	root  *hamtNode
+	count int
+}
+
+// hamtNode holds a slot for each bit set in its bitmap, in order, so that only slots in use take
+// space.
+type hamtNode struct {
+	bitmap uint32
+	slots  []hamtSlot
+}
+
+// hamtSlot is either a child node, or the keys whose hashes are identical, which is nearly always a
+// single key.
+type hamtSlot struct {
+	child *hamtNode
+	hash  uint64
+	pairs []hamtPair
+}
+
+type hamtPair struct {
+	key string
+	ev  *entry
+}
+
+// newHAMT returns an empty trie.
+func newHAMT() *hamt {
+	return &hamt{root: &hamtNode{}}
+}
+
+// hashKey returns the 64-bit FNV-1a hash of key.
+func hashKey(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// index returns the bit of the bitmap for the hash at the specified shift, and the position of its
+// slot.
+func (n *hamtNode) index(hash uint64, shift uint) (uint32, int) {
+	bit := uint32(1) << ((hash >> shift) & hamtMask)
+	return bit, bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+// get returns the value of key, and false when key is not present.
+func (h *hamt) get(key string) (*entry, bool) {
+	hash := hashKey(key)
+	n := h.root
+	for shift := uint(0); ; shift += hamtBits {
+		bit, i := n.index(hash, shift)
+		if n.bitmap&bit == 0 {
+			return nil, false
+		}
+		s := &n.slots[i]
+		if s.child != nil {
+			n = s.child
+			continue
+		}
+		if s.hash == hash {
+			for _, p := range s.pairs {
+				if p.key == key {
+					return p.ev, true
+				}
+			}
+		}
+		return nil, false
+	}
+}
+
+// with returns a trie in which key holds the specified value.
+func (h *hamt) with(key string, ev *entry) *hamt {
+	root, added := h.root.with(hashKey(key), 0, key, ev)
+	count := h.count
+	if added {
+		count++
+	}
+	return &hamt{root: root, count: count}
+}
+
+// with returns a copy of the node in which key holds the specified value, and whether key was added
+// rather than replaced.
+func (n *hamtNode) with(hash uint64, shift uint, key string, ev *entry) (*hamtNode, bool) {
+	bit, i := n.index(hash, shift)
+	if n.bitmap&bit == 0 {
+		slots := make([]hamtSlot, len(n.slots)+1)
+		copy(slots, n.slots[:i])
+		slots[i] = hamtSlot{hash: hash, pairs: []hamtPair{{key, ev}}}
+		copy(slots[i+1:], n.slots[i:])
+		return &hamtNode{bitmap: n.bitmap | bit, slots: slots}, true
+	}
+
+	s := n.slots[i]
+	var added bool
+	switch {
+	case s.child != nil:
+		s.child, added = s.child.with(hash, shift+hamtBits, key, ev)
+	case s.hash == hash:
+		s.pairs, added = withPair(s.pairs, key, ev)
+	default:
+		// the hashes differ at a later level, so the slot becomes a child holding both
+		child := &hamtNode{bitmap: uint32(1) << ((s.hash >> (shift + hamtBits)) & hamtMask), slots: []hamtSlot{s}}
+		child, added = child.with(hash, shift+hamtBits, key, ev)
+		s = hamtSlot{child: child}
+	}
+	slots := append([]hamtSlot(nil), n.slots...)
+	slots[i] = s
+	return &hamtNode{bitmap: n.bitmap, slots: slots}, added
+}
+
+// withPair returns a copy of pairs in which key holds the specified value, and whether key was
+// added rather than replaced.
+func withPair(pairs []hamtPair, key string, ev *entry) ([]hamtPair, bool) {
+	for i, p := range pairs {
+		if p.key == key {
+			pairs = append([]hamtPair(nil), pairs...)
+			pairs[i].ev = ev
+			return pairs, false
+		}
+	}
+	return append(pairs[:len(pairs):len(pairs)], hamtPair{key, ev}), true
+}
+
+// without returns a trie in which key is not present.
+func (h *hamt) without(key string) *hamt {
+	root, removed := h.root.without(hashKey(key), 0, key)
+	if !removed {
+		return h
+	}
+	if root == nil {
+		root = &hamtNode{}
+	}
+	return &hamt{root: root, count: h.count - 1}
+}
+
+// without returns a copy of the node in which key is not present, or nil when that leaves it empty,
+// and whether key was removed.
+func (n *hamtNode) without(hash uint64, shift uint, key string) (*hamtNode, bool) {
+	bit, i := n.index(hash, shift)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+
+	s := n.slots[i]
+	if s.child != nil {
+		child, removed := s.child.without(hash, shift+hamtBits, key)
+		switch {
+		case !removed:
+			return n, false
+		case child == nil:
+			return n.withoutSlot(bit, i), true
+		case len(child.slots) == 1 && child.slots[0].child == nil:
+			s = child.slots[0] // a child holding only the keys of a single hash is collapsed
+		default:
+			s.child = child
+		}
+	} else {
+		if s.hash != hash {
+			return n, false
+		}
+		j := -1
+		for k, p := range s.pairs {
+			if p.key == key {
+				j = k
+				break
+			}
+		}
+		if j < 0 {
+			return n, false
+		}
+		if len(s.pairs) == 1 {
+			return n.withoutSlot(bit, i), true
+		}
+		pairs := make([]hamtPair, 0, len(s.pairs)-1)
+		pairs = append(pairs, s.pairs[:j]...)
+		s.pairs = append(pairs, s.pairs[j+1:]...)
+	}
+	slots := append([]hamtSlot(nil), n.slots...)
+	slots[i] = s
+	return &hamtNode{bitmap: n.bitmap, slots: slots}, true
+}
+
+// withoutSlot returns a copy of the node without the slot at position i, or nil when that leaves it
+// empty.
+func (n *hamtNode) withoutSlot(bit uint32, i int) *hamtNode {
+	if len(n.slots) == 1 {
+		return nil
+	}
+	slots := make([]hamtSlot, 0, len(n.slots)-1)
+	slots = append(slots, n.slots[:i]...)
+	slots = append(slots, n.slots[i+1:]...)
+	return &hamtNode{bitmap: n.bitmap &^ bit, slots: slots}
+}
+
+// each invokes fn with each key and its value until fn returns false.
+func (h *hamt) each(fn func(string, *entry) bool) {
+	h.root.each(fn)
+}
+
+// sample visits the key, expiry, and time of most recent use of values drawn at random, for choosing
+// keys to evict, until fn returns false. Because, unlike a map, a trie is always iterated in the same
+// order, each value is drawn independently, by descending through a random slot at each level, so a
+// value may be visited more than once. After drawing as many values as the trie holds, sample visits
+// every value in order, so that fn is offered each key before sample returns.
+func (h *hamt) sample(fn func(string, time.Time, int64) bool) {
+	for i := 0; i < h.count; i++ {
+		n := h.root
+		for {
+			s := &n.slots[rand.Intn(len(n.slots))]
+			if s.child != nil {
+				n = s.child
+				continue
+			}
+			p := s.pairs[rand.Intn(len(s.pairs))]
+			if !fn(p.key, p.ev.Expiry, atomic.LoadInt64(&p.ev.used)) {
+				return
+			}
+			break
+		}
+	}
+	h.visit(fn)
+}
+
+// visit visits the key, expiry, and time of most recent use of every value, in order.
+func (h *hamt) visit(fn func(string, time.Time, int64) bool) {
+	h.each(func(key string, ev *entry) bool {
+		return fn(key, ev.Expiry, atomic.LoadInt64(&ev.used))
+	})
+}
+
+// each invokes fn with each key and its value below the node until fn returns false, and returns
+// false when fn did.
+func (n *hamtNode) each(fn func(string, *entry) bool) bool {
+	for i := range n.slots {
+		s := &n.slots[i]
+		if s.child != nil {
+			if !s.child.each(fn) {
+				return false
+			}
+			continue
+		}
+		for _, p := range s.pairs {
+			if !fn(p.key, p.ev) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pairs returns the keys and values of the trie, such as to reap them all.
+func (h *hamt) pairs() []Pair {
+	ps := make([]Pair, 0, h.count)
+	h.each(func(key string, ev *entry) bool {
+		ps = append(ps, Pair{key, ev.Value})
+		return true
+	})
+	return ps
+}
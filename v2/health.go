@@ -0,0 +1,56 @@
+package congomap
+
+import "time"
+
+// Health is the status of a Congomap, as returned by Ping, suitable for readiness probes.
+type Health struct {
+	// Running is true while the go routine that maintains the Congomap is running, which it
+	// does until the Congomap is closed.
+	Running bool
+
+	// LastGC is when the Congomap was most recently garbage collected, or the zero time when it
+	// has not yet been.
+	LastGC time.Time
+
+	// FailingLookups is the number of keys for which an error returned by the lookup callback
+	// function is memoized, as specified by BadExpiryDuration, so LoadStore returns that error
+	// rather than invoking the lookup callback function again.
+	FailingLookups int
+}
+
+// Healthy returns true when the Congomap is running.
+func (h Health) Healthy() bool {
+	return h.Running
+}
+
+// alive is implemented by the Congomap types provided by this library.
+type alive interface {
+	// running returns true until the go routine that maintains the Congomap returns.
+	running() bool
+}
+
+// Ping returns the health of the Congomap. It returns ErrUnsupportedOption for a Congomap not
+// provided by this library.
+func Ping(cgm Congomap) (Health, error) {
+	a, ok := cgm.(alive)
+	if !ok {
+		return Health{}, ErrUnsupportedOption{}
+	}
+	c := cgm.(configurable).getConfig()
+	h := Health{Running: a.running()}
+
+	c.ttlLock.Lock()
+	h.LastGC = c.gcReport.Started
+	c.ttlLock.Unlock()
+
+	now := c.now()
+	c.bad.lock.Lock()
+	for _, bl := range c.bad.db {
+		if now.Before(bl.expiry) {
+			h.FailingLookups++
+		}
+	}
+	c.bad.lock.Unlock()
+
+	return h, nil
+}
@@ -0,0 +1,39 @@
+package congomap
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// ErrInvalidFraction is returned by TTLJitter when a fraction that is not greater than 0 and less
+// than 1 is specified.
+type ErrInvalidFraction float64
+
+func (e ErrInvalidFraction) Error() string {
+	return fmt.Sprintf("congomap: fraction must be greater than 0 and less than 1: %v", float64(e))
+}
+
+// TTLJitter is used to specify that the default time-to-live of each value, as specified by TTL,
+// StoreTTL, or LookupTTL, is randomized by up to the specified fraction of it in either direction
+// when the value is stored. With a TTL of an hour and a fraction of 0.1, values expire between 54
+// and 66 minutes after being stored, so keys populated together, such as by Warmup, do not all
+// expire together, and stampede the backend when looked up again.
+func TTLJitter(fraction float64) Setter {
+	return configure(func(c *config) error {
+		if !(fraction > 0 && fraction < 1) {
+			return ErrInvalidFraction(fraction)
+		}
+		c.jitter = fraction
+		return nil
+	})
+}
+
+// jittered returns the duration randomized by up to the fraction specified by TTLJitter in either
+// direction.
+func (c *config) jittered(d time.Duration) time.Duration {
+	if c.jitter == 0 {
+		return d
+	}
+	return d + time.Duration(float64(d)*c.jitter*(2*rand.Float64()-1))
+}
@@ -0,0 +1,80 @@
+package congomap
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// ErrInvalidKey is returned by LoadStore when a key is rejected by a validator specified by
+// KeyValidator. It records the rejected key, and wraps the error returned by the validator.
+type ErrInvalidKey struct {
+	Key string
+	Err error
+}
+
+func (e ErrInvalidKey) Error() string {
+	return fmt.Sprintf("congomap: invalid key %q: %s", e.Key, e.Err)
+}
+
+// Unwrap returns the error returned by the validator.
+func (e ErrInvalidKey) Unwrap() error {
+	return e.Err
+}
+
+// checkKey returns ErrInvalidKey when any validator rejects the key.
+func (c *config) checkKey(key string) error {
+	for _, validate := range c.validators {
+		if err := validate(key); err != nil {
+			return ErrInvalidKey{Key: key, Err: err}
+		}
+	}
+	return nil
+}
+
+// KeyValidator is used to specify a function that validates each key passed to the Store, Do, and
+// LoadStore methods, so that garbage keys from untrusted input cannot bloat the Congomap. LoadStore
+// returns ErrInvalidKey for a key the validator rejects, without invoking the lookup callback
+// function. Store and Do cannot return an error, and ignore a key the validator rejects. When
+// KeyValidator is specified more than once, every validator must accept a key.
+//
+//	cgm, err := congomap.NewSyncMutexMap(
+//	    congomap.KeyValidator(congomap.MaxKeyLength(64)),
+//	    congomap.KeyValidator(congomap.KeyCharset(func(r rune) bool {
+//	        return r < utf8.RuneSelf && r > ' '
+//	    })),
+//	)
+func KeyValidator(validate func(key string) error) Setter {
+	return configure(func(c *config) error {
+		c.validators = append(c.validators, validate)
+		return nil
+	})
+}
+
+// MaxKeyLength returns a validator for KeyValidator that rejects keys longer than the specified
+// number of bytes.
+func MaxKeyLength(max int) func(string) error {
+	return func(key string) error {
+		if len(key) > max {
+			return fmt.Errorf("length %d exceeds %d bytes", len(key), max)
+		}
+		return nil
+	}
+}
+
+// KeyCharset returns a validator for KeyValidator that rejects keys that are not valid UTF-8, or
+// that contain a rune for which allowed returns false.
+func KeyCharset(allowed func(rune) bool) func(string) error {
+	return func(key string) error {
+		for i, r := range key {
+			if r == utf8.RuneError {
+				if _, size := utf8.DecodeRuneInString(key[i:]); size == 1 {
+					return fmt.Errorf("invalid UTF-8 at byte %d", i)
+				}
+			}
+			if !allowed(r) {
+				return fmt.Errorf("rune %q at byte %d not allowed", r, i)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,75 @@
+package congomap
+
+import "sync"
+
+// KeyedCall deduplicates concurrent invocations of functions by key: while a function invoked for
+// a key is running, further invocations for the same key wait for it and receive its result rather
+// than invoking their own function. It offers the guarantee LoadStore gives about the lookup
+// callback function, one invocation per key while everyone else waits, for results that ought not
+// be stored in a Congomap.
+//
+// The zero value is ready for use. A KeyedCall must not be copied after first use.
+type KeyedCall struct {
+	lock  sync.Mutex
+	calls map[string]*keyedCall
+}
+
+// keyedCall is an invocation in progress, or completed, for a key.
+type keyedCall struct {
+	wg     sync.WaitGroup
+	value  interface{}
+	err    error
+	shared bool
+}
+
+// Do invokes fn and returns its results, unless an invocation for the key is already in progress,
+// in which case it waits for that invocation and returns its results instead. The shared result is
+// true when the results were returned to more than one caller. A panic in fn is recovered and
+// returned to every caller as ErrCallbackPanic.
+func (kc *KeyedCall) Do(key string, fn func() (interface{}, error)) (interface{}, error, bool) {
+	return kc.do(key, "KeyedCall", func() (value interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				value, err = nil, ErrCallbackPanic{Callback: "KeyedCall", Value: r}
+			}
+		}()
+		return fn()
+	})
+}
+
+// do is Do without recovering a panic in fn: the panic propagates to the caller that invoked fn,
+// while the callers waiting for it receive ErrCallbackPanic naming the callback.
+func (kc *KeyedCall) do(key, callback string, fn func() (interface{}, error)) (value interface{}, err error, shared bool) {
+	kc.lock.Lock()
+	if kc.calls == nil {
+		kc.calls = make(map[string]*keyedCall)
+	}
+	if c, ok := kc.calls[key]; ok {
+		c.shared = true
+		kc.lock.Unlock()
+		c.wg.Wait()
+		return c.value, c.err, true
+	}
+	c := new(keyedCall)
+	c.wg.Add(1)
+	kc.calls[key] = c
+	kc.lock.Unlock()
+
+	defer func() {
+		r := recover()
+		if r != nil {
+			c.value, c.err = nil, ErrCallbackPanic{Callback: callback, Value: r}
+		}
+		kc.lock.Lock()
+		delete(kc.calls, key)
+		shared = c.shared
+		kc.lock.Unlock()
+		c.wg.Done()
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	c.value, c.err = fn()
+	return c.value, c.err, shared // shared is set by the deferred function
+}
@@ -0,0 +1,62 @@
+package congomap
+
+import (
+	"container/heap"
+	"sort"
+	"strings"
+)
+
+// hasPrefix returns a function for KeysMatching that matches the keys beginning with prefix.
+func hasPrefix(prefix string) func(string) bool {
+	return func(key string) bool { return strings.HasPrefix(key, prefix) }
+}
+
+// keyPager selects a page of keys from the keys it is shown, in any order, while holding no more
+// than one page of them, by retaining the smallest keys after the cursor in a max-heap.
+type keyPager struct {
+	cursor string
+	limit  int
+	keys   []string // max-heap of the smallest keys seen after cursor
+	more   bool     // true when a key after cursor was excluded from the page
+}
+
+func newKeyPager(cursor string, limit int) *keyPager {
+	return &keyPager{cursor: cursor, limit: limit}
+}
+
+func (p *keyPager) Len() int           { return len(p.keys) }
+func (p *keyPager) Less(i, j int) bool { return p.keys[i] > p.keys[j] }
+func (p *keyPager) Swap(i, j int)      { p.keys[i], p.keys[j] = p.keys[j], p.keys[i] }
+func (p *keyPager) Push(x interface{}) { p.keys = append(p.keys, x.(string)) }
+
+func (p *keyPager) Pop() interface{} {
+	x := p.keys[len(p.keys)-1]
+	p.keys = p.keys[:len(p.keys)-1]
+	return x
+}
+
+// add considers the key for the page.
+func (p *keyPager) add(key string) {
+	if p.cursor != "" && key <= p.cursor {
+		return
+	}
+	if len(p.keys) < p.limit {
+		heap.Push(p, key)
+		return
+	}
+	p.more = true
+	if p.limit > 0 && key < p.keys[0] {
+		p.keys[0] = key
+		heap.Fix(p, 0)
+	}
+}
+
+// page returns the sorted keys of the page, and the cursor of the next page, which is empty when
+// this is the last page.
+func (p *keyPager) page() ([]string, string) {
+	sort.Strings(p.keys)
+	if !p.more || len(p.keys) == 0 {
+		return p.keys, ""
+	}
+	return p.keys, p.keys[len(p.keys)-1]
+}
@@ -0,0 +1,125 @@
+package congomap
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// lockHolds records which operation holds each per-key lock, and since when, so that holds lasting
+// longer than a threshold may be logged. It is only used when debugging lock holds.
+type lockHolds struct {
+	threshold time.Duration
+	logf      func(string, ...interface{})
+
+	lock sync.Mutex
+	db   map[*sync.RWMutex]*lockHold
+}
+
+type lockHold struct {
+	op    string
+	since time.Time
+	stack []byte
+}
+
+func callerStack() []byte {
+	buf := make([]byte, 4096)
+	return buf[:runtime.Stack(buf, false)]
+}
+
+// acquire locks l on behalf of the named operation on key, logging a warning when it waits longer
+// than the threshold for the lock.
+func (h *lockHolds) acquire(l *sync.RWMutex, key, op string) {
+	waiter := callerStack()
+	timer := h.await(l, key, op, waiter)
+	l.Lock()
+	timer.Stop()
+
+	h.lock.Lock()
+	h.db[l] = &lockHold{op: op, since: time.Now(), stack: waiter}
+	h.lock.Unlock()
+}
+
+// acquireRead locks l as a reader on behalf of the named operation on key, logging a warning when
+// it waits longer than the threshold for the lock. How long readers hold the lock is not recorded,
+// because several readers may hold it at once.
+func (h *lockHolds) acquireRead(l *sync.RWMutex, key, op string) {
+	timer := h.await(l, key, op, callerStack())
+	l.RLock()
+	timer.Stop()
+}
+
+// await returns a timer that logs a warning unless it is stopped within the threshold, naming the
+// operation holding l, if any.
+func (h *lockHolds) await(l *sync.RWMutex, key, op string, waiter []byte) *time.Timer {
+	return time.AfterFunc(h.threshold, func() {
+		h.lock.Lock()
+		hold, ok := h.db[l]
+		h.lock.Unlock()
+		if !ok {
+			h.logf("congomap: %s %q waiting longer than %s for lock:\n%s", op, key, h.threshold, waiter)
+			return
+		}
+		h.logf("congomap: %s %q waiting longer than %s for lock held %s by %s from:\n%s\nwaiting from:\n%s",
+			op, key, h.threshold, time.Since(hold.since), hold.op, hold.stack, waiter)
+	})
+}
+
+// release unlocks l, logging a warning when it was held longer than the threshold.
+func (h *lockHolds) release(l *sync.RWMutex, key string) {
+	h.lock.Lock()
+	hold := h.db[l]
+	delete(h.db, l)
+	h.lock.Unlock()
+	l.Unlock()
+
+	if held := time.Since(hold.since); held > h.threshold {
+		h.logf("congomap: %s %q held lock for %s from:\n%s", hold.op, key, held, hold.stack)
+	}
+}
+
+// lockKey locks the per-key lock l on behalf of the named operation on key.
+func (c *config) lockKey(l *sync.RWMutex, key, op string) {
+	if c.holds == nil {
+		l.Lock()
+		return
+	}
+	c.holds.acquire(l, key, op)
+}
+
+// rlockKey locks the per-key lock l as a reader on behalf of the named operation on key. It is
+// released by invoking the RUnlock method of l.
+func (c *config) rlockKey(l *sync.RWMutex, key, op string) {
+	if c.holds == nil {
+		l.RLock()
+		return
+	}
+	c.holds.acquireRead(l, key, op)
+}
+
+// unlockKey unlocks the per-key lock l for key.
+func (c *config) unlockKey(l *sync.RWMutex, key string) {
+	if c.holds == nil {
+		l.Unlock()
+		return
+	}
+	c.holds.release(l, key)
+}
+
+// DebugLockHolds is used to debug operations that hold per-key locks for too long, such as when a
+// lookup callback function accidentally invokes a method of the same Congomap for the same key,
+// which deadlocks. Every acquisition of a per-key lock records which operation acquired it, along
+// with a stack trace, and the specified log function is invoked with a warning including the stack
+// traces of both the holder and the waiter whenever an operation waits longer than the threshold
+// for a per-key lock, or holds one longer than the threshold. Recording stack traces is expensive,
+// so this option ought not be used in production. Only a Congomap created by NewTwoLevelMap has
+// per-key locks; other Congomaps ignore this option.
+func DebugLockHolds(threshold time.Duration, logf func(format string, args ...interface{})) Setter {
+	return configure(func(c *config) error {
+		if threshold <= 0 {
+			return ErrInvalidDuration(threshold)
+		}
+		c.holds = &lockHolds{threshold: threshold, logf: logf, db: make(map[*sync.RWMutex]*lockHold)}
+		return nil
+	})
+}
@@ -0,0 +1,35 @@
+package congomap
+
+// LeveledLogger is the interface of a logger to which a Congomap reports what happens in its
+// background go routines, which would otherwise go unnoticed. It is satisfied by many popular
+// logging libraries, and SlogLogger adapts a slog.Handler to it.
+type LeveledLogger interface {
+	Debugf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// Logger is used to specify a logger to which the Congomap reports failures of the lookup callback
+// function, and failures and panics of the reaper callback function, at the error level, and each
+// GC, and each eviction of values by MaxEntries, MaxCost, or Prune, at the debug level. Because
+// reporting evictions requires gathering the evicted values as a reaper does, specifying a logger
+// has a cost similar to specifying a reaper.
+func Logger(logger LeveledLogger) Setter {
+	return configure(func(c *config) error {
+		c.logger = logger
+		return nil
+	})
+}
+
+// debugf reports to the logger at the debug level. It does nothing unless Logger was specified.
+func (c *config) debugf(format string, args ...interface{}) {
+	if c.logger != nil {
+		c.logger.Debugf(format, args...)
+	}
+}
+
+// errorf reports to the logger at the error level. It does nothing unless Logger was specified.
+func (c *config) errorf(format string, args ...interface{}) {
+	if c.logger != nil {
+		c.logger.Errorf(format, args...)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build go1.21
+
+package congomap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// SlogLogger returns a LeveledLogger that reports to the slog.Handler, at slog.LevelDebug and
+// slog.LevelError, for specifying with Logger.
+func SlogLogger(handler slog.Handler) LeveledLogger {
+	return slogLogger{handler}
+}
+
+type slogLogger struct {
+	handler slog.Handler
+}
+
+func (l slogLogger) Debugf(format string, args ...interface{}) {
+	l.log(slog.LevelDebug, format, args)
+}
+
+func (l slogLogger) Errorf(format string, args ...interface{}) {
+	l.log(slog.LevelError, format, args)
+}
+
+func (l slogLogger) log(level slog.Level, format string, args []interface{}) {
+	logger := slog.New(l.handler)
+	if logger.Enabled(context.Background(), level) {
+		logger.Log(context.Background(), level, fmt.Sprintf(format, args...))
+	}
+}
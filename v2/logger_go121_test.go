@@ -0,0 +1,40 @@
+//go:build go1.21
+
+package congomap_test
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	congomap "github.com/karrick/congomap/v2"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelError,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{} // omitted so the output is predictable
+			}
+			return a
+		},
+	})
+	cgm, err := congomap.NewSyncMutexMap(
+		congomap.Logger(congomap.SlogLogger(handler)),
+		congomap.Lookup(failingLookup),
+		congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	_, _ = cgm.LoadStore("bad")
+	cgm.GC() // logged at the debug level, which the handler does not enable
+
+	expected := `level=ERROR msg="congomap: lookup \"bad\": lookup failed"` + "\n"
+	if actual := buf.String(); actual != expected {
+		t.Errorf("Actual: %q; Expected: %q", actual, expected)
+	}
+}
@@ -0,0 +1,105 @@
+package congomap
+
+import (
+	"context"
+	"sync"
+)
+
+// ErrKeyNotReturned is returned by LoadStore, wrapped in ErrLookupFailed, when the batch lookup
+// callback function specified by LookupMany returns no value for a key it was asked to look up.
+type ErrKeyNotReturned struct{}
+
+func (e ErrKeyNotReturned) Error() string {
+	return "congomap: key not returned by batch lookup"
+}
+
+// LookupMany is used to specify a lookup callback function that looks up many keys at once, as an
+// alternative to Lookup, such as with a single SQL query. When LoadStore misses a key while a batch
+// lookup is already in progress, that key waits to be looked up along with every other key missed
+// in the meantime, in the next batch, so concurrent misses cost one call rather than one call per
+// key. Only Congomap implementations that look up different keys concurrently, such as the one
+// created by NewTwoLevelMap, coalesce misses. The function returns a value for each key it finds;
+// keys it omits fail with ErrKeyNotReturned, and an error it returns fails every key in the batch.
+// Specifying LookupMany replaces any lookup specified by Lookup or LookupContext, and vice versa.
+func LookupMany(lookup func([]string) (map[string]interface{}, error)) Setter {
+	return configure(func(c *config) error {
+		b := &lookupBatcher{c: c, lookup: lookup}
+		c.lookup = b.lookupOne
+		return nil
+	})
+}
+
+// batchResult is the outcome of looking up one key as part of a batch.
+type batchResult struct {
+	value interface{}
+	err   error
+}
+
+// lookupBatcher coalesces lookups for different keys into batches.
+type lookupBatcher struct {
+	c      *config
+	lookup func([]string) (map[string]interface{}, error)
+
+	lock    sync.Mutex
+	pending map[string][]chan batchResult // keys waiting for the next batch
+	busy    bool                          // true while a go routine is looking up batches
+}
+
+// lookupOne adds key to the next batch, and waits for the result of looking it up.
+func (b *lookupBatcher) lookupOne(ctx context.Context, key string) (interface{}, error) {
+	rc := make(chan batchResult, 1)
+	b.lock.Lock()
+	if b.pending == nil {
+		b.pending = make(map[string][]chan batchResult)
+	}
+	b.pending[key] = append(b.pending[key], rc)
+	if !b.busy {
+		b.busy = true
+		go b.run()
+	}
+	b.lock.Unlock()
+
+	select {
+	case r := <-rc:
+		return r.value, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run looks up batches of pending keys until none remain.
+func (b *lookupBatcher) run() {
+	for {
+		b.lock.Lock()
+		batch := b.pending
+		b.pending = nil
+		if len(batch) == 0 {
+			b.busy = false
+			b.lock.Unlock()
+			return
+		}
+		b.lock.Unlock()
+
+		keys := make([]string, 0, len(batch))
+		for key := range batch {
+			keys = append(keys, key)
+		}
+		var values map[string]interface{}
+		var err error
+		if perr := b.c.protect("lookup", func() { values, err = b.lookup(keys) }); perr != nil {
+			err = perr
+		}
+		for key, rcs := range batch {
+			r := batchResult{err: err}
+			if err == nil {
+				var ok bool
+				if r.value, ok = values[key]; !ok {
+					r.err = ErrKeyNotReturned{}
+				}
+			}
+			for _, rc := range rcs {
+				rc <- r
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package congomap
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// Sizer is used to specify the function that MemoryUsage invokes to estimate how many bytes each
+// value held by the Congomap occupies, including any memory it references.
+func Sizer(sizer func(value interface{}) int64) Setter {
+	return configure(func(c *config) error {
+		c.sizer = sizer
+		return nil
+	})
+}
+
+// MemoryUsage returns an estimate of how many bytes the keys and values held by the Congomap occupy,
+// including the data store entries and wrappers that hold them, so operators can alert before the
+// Congomap exhausts the memory of the process. Expired values not yet collected as garbage are
+// counted, because they still occupy memory. Values are measured by the function specified by
+// Sizer. Without it, strings and byte slices are measured by their length, and other values by the
+// size of their type; approx is true when any value references memory that was not counted. It
+// returns ErrUnsupportedOption for a Congomap not provided by this library.
+func MemoryUsage(cgm Congomap) (bytes int64, approx bool, err error) {
+	c, ok := cgm.(configurable)
+	if !ok {
+		return 0, false, ErrUnsupportedOption{}
+	}
+	it, ok := cgm.(iterable)
+	if !ok {
+		return 0, false, ErrUnsupportedOption{}
+	}
+	sizer := c.getConfig().sizer
+
+	// each map entry holds the key's string header and a pointer to its wrapper
+	overhead := int64(unsafe.Sizeof("") + unsafe.Sizeof(uintptr(0)) + unsafe.Sizeof(entry{}))
+	if _, ok := cgm.(*twoLevelMap); ok {
+		overhead += int64(unsafe.Sizeof(lockingValue{}))
+	}
+
+	it.each(func(key string, ev *entry) bool {
+		bytes += overhead + int64(len(key))
+		if sizer != nil {
+			bytes += sizer(ev.Value)
+		} else {
+			size, exact := sizeOf(ev.Value)
+			bytes += size
+			approx = approx || !exact
+		}
+		return true
+	})
+	return bytes, approx, nil
+}
+
+// sizeOf returns how many bytes the value occupies, and whether that includes all the memory it
+// references.
+func sizeOf(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case nil:
+		return 0, true
+	case string:
+		return int64(len(v)), true
+	case []byte:
+		return int64(cap(v)), true
+	}
+	t := reflect.TypeOf(value)
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return int64(t.Size()), true
+	}
+	return int64(t.Size()), false
+}
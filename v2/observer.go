@@ -0,0 +1,80 @@
+package congomap
+
+import "time"
+
+// Observer is the interface of a type whose methods are invoked as a Congomap performs each
+// operation, so applications may feed their own metrics or telemetry. Its methods are invoked by
+// many go routines at once, sometimes while the Congomap holds a lock, so they ought to return
+// quickly, and must not invoke methods of the Congomap.
+type Observer interface {
+	// OnLoad is invoked by Load and LoadMany for each key read, with whether a live value was
+	// found.
+	OnLoad(key string, hit bool)
+
+	// OnStore is invoked for each value written by Store, StoreMany, Do, Update, LoadOrStore, and
+	// StoreReturning.
+	OnStore(key string)
+
+	// OnLoadStore is invoked by LoadStore, LoadStoreContext, LoadStoreInfo, and LoadStoreWith, with
+	// whether the lookup callback function was invoked, and the error returned, if any.
+	OnLoadStore(key string, looked bool, err error)
+
+	// OnDelete is invoked for each value removed by Delete.
+	OnDelete(key string)
+
+	// OnEvict is invoked for each value removed for any other reason, such as expiry or capacity.
+	OnEvict(key string, reason EvictionReason)
+
+	// OnLookup is invoked for each invocation of the lookup callback function, including retries,
+	// with how long it took, and the error it returned, if any.
+	OnLookup(key string, duration time.Duration, err error)
+}
+
+// Observe is used to specify an Observer whose methods are invoked as the Congomap performs each
+// operation. Because observing values as they are removed requires gathering them as a reaper
+// does, specifying an Observer has a cost similar to specifying a reaper.
+func Observe(observer Observer) Setter {
+	return configure(func(c *config) error {
+		c.observer = observer
+		return nil
+	})
+}
+
+// loaded records a read of the key by Load or LoadMany.
+func (c *config) loaded(key string, hit bool) {
+	c.stats.read(hit)
+	if c.observer != nil {
+		c.observer.OnLoad(key, hit)
+	}
+}
+
+// loadStored records a read of the key by LoadStore.
+func (c *config) loadStored(key string, looked bool, err error) {
+	c.stats.read(err == nil && !looked)
+	if c.observer != nil {
+		c.observer.OnLoadStore(key, looked, err)
+	}
+}
+
+// stored records a value written by the application.
+func (c *config) stored(key string) {
+	c.stats.add(countStores, 1)
+	if c.observer != nil {
+		c.observer.OnStore(key)
+	}
+}
+
+// removed records the values removed from the data store for the specified reason.
+func (c *config) removed(reason EvictionReason, pairs []Pair) {
+	c.stats.removed(reason, pairs)
+	if c.observer == nil {
+		return
+	}
+	for _, p := range pairs {
+		if reason == ReasonDeleted {
+			c.observer.OnDelete(p.Key)
+		} else {
+			c.observer.OnEvict(p.Key, reason)
+		}
+	}
+}
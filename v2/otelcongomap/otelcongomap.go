@@ -0,0 +1,46 @@
+// Package otelcongomap records the LoadStore operations of a Congomap, and the invocations of the
+// lookup callback function they make, as OpenTelemetry spans, so slow cache fills show up in
+// distributed traces. It is a separate module so the congomap package need not depend upon
+// OpenTelemetry.
+package otelcongomap
+
+import (
+	"context"
+
+	congomap "github.com/karrick/congomap/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of the tracer used when Tracing is passed nil.
+const ScopeName = "github.com/karrick/congomap/v2/otelcongomap"
+
+// KeyAttribute is the attribute recording the key of each span.
+const KeyAttribute = attribute.Key("congomap.key")
+
+// Tracing is used to specify that each LoadStore of the Congomap, and each invocation of the lookup
+// callback function it makes, is recorded as a span named "congomap.LoadStore" or "congomap.Lookup"
+// with the key as an attribute, and its error, if any, as the span's status. Spans are started by
+// the specified tracer, or by the tracer of the global TracerProvider when nil, and so are sampled
+// by the sampler of that provider.
+//
+//	cgm, err := congomap.NewSyncMutexMap(congomap.Lookup(lookup), otelcongomap.Tracing(nil))
+func Tracing(tracer trace.Tracer) congomap.Setter {
+	if tracer == nil {
+		tracer = otel.Tracer(ScopeName)
+	}
+	return congomap.Trace(func(ctx context.Context, operation, key string) (context.Context, func(error)) {
+		ctx, span := tracer.Start(ctx, "congomap."+operation,
+			trace.WithSpanKind(trace.SpanKindInternal),
+			trace.WithAttributes(KeyAttribute.String(key)))
+		return ctx, func(err error) {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package otelcongomap_test
+
+import (
+	"errors"
+	"testing"
+
+	congomap "github.com/karrick/congomap/v2"
+	"github.com/karrick/congomap/v2/otelcongomap"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var errLookupFailed = errors.New("lookup failed")
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	cgm, err := congomap.NewSyncMutexMap(
+		congomap.Lookup(func(key string) (interface{}, error) {
+			if key == "bad" {
+				return nil, errLookupFailed
+			}
+			return 42, nil
+		}),
+		otelcongomap.Tracing(provider.Tracer("test")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	_, _ = cgm.LoadStore("a")
+	_, _ = cgm.LoadStore("bad")
+
+	spans := recorder.Ended()
+	type span struct {
+		name, key string
+		status    codes.Code
+		child     bool
+	}
+	var actual []span
+	for _, s := range spans {
+		var key string
+		for _, kv := range s.Attributes() {
+			if kv.Key == otelcongomap.KeyAttribute {
+				key = kv.Value.AsString()
+			}
+		}
+		actual = append(actual, span{s.Name(), key, s.Status().Code, s.Parent().IsValid()})
+	}
+	expected := []span{
+		{"congomap.Lookup", "a", codes.Unset, true},
+		{"congomap.LoadStore", "a", codes.Unset, false},
+		{"congomap.Lookup", "bad", codes.Error, true},
+		{"congomap.LoadStore", "bad", codes.Error, false},
+	}
+	if len(actual) != len(expected) {
+		t.Fatalf("Actual: %v; Expected: %v", actual, expected)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("Span: %d; Actual: %v; Expected: %v", i, actual[i], expected[i])
+		}
+	}
+	if lookup, loadStore := spans[0], spans[1]; lookup.Parent().SpanID() != loadStore.SpanContext().SpanID() {
+		t.Errorf("Actual: %v; Expected: %v", lookup.Parent().SpanID(), loadStore.SpanContext().SpanID())
+	}
+}
@@ -0,0 +1,143 @@
+package persist
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	congomap "github.com/karrick/congomap/v2"
+)
+
+// BlobStore is the storage to which snapshots are written, and from which they are read. Implement
+// it to keep snapshots somewhere other than the local file system, such as in an object store.
+type BlobStore interface {
+	// Put stores the contents read from r as the named blob, replacing any existing blob of that
+	// name. A blob must not be visible to Get or List until all of r has been stored.
+	Put(name string, r io.Reader) error
+
+	// Get returns the contents of the named blob, or ErrBlobNotFound when there is no such blob.
+	Get(name string) (io.ReadCloser, error)
+
+	// List returns the names of the stored blobs that begin with prefix, in lexical order.
+	List(prefix string) ([]string, error)
+}
+
+// ErrBlobNotFound is returned by BlobStore.Get when there is no blob of the specified name.
+type ErrBlobNotFound string
+
+func (e ErrBlobNotFound) Error() string {
+	return "persist: blob not found: " + string(e)
+}
+
+// ErrInvalidBlobName is returned by FileStore when a blob name is empty, begins with a period, or
+// contains a path separator.
+type ErrInvalidBlobName string
+
+func (e ErrInvalidBlobName) Error() string {
+	return "persist: invalid blob name: " + string(e)
+}
+
+// FileStore is a BlobStore that keeps each blob as a file in a directory.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore that keeps blobs in the specified directory, creating the
+// directory when it does not exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (fs *FileStore) pathname(name string) (string, error) {
+	if name == "" || name[0] == '.' || strings.ContainsAny(name, `/\`) {
+		return "", ErrInvalidBlobName(name)
+	}
+	return filepath.Join(fs.dir, name), nil
+}
+
+// Put writes the blob to a temporary file, flushes it to stable storage, then renames it into place.
+func (fs *FileStore) Put(name string, r io.Reader) error {
+	pathname, err := fs.pathname(name)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(fs.dir, ".put-*") // hidden from List
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, r)
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), pathname)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+	}
+	return err
+}
+
+func (fs *FileStore) Get(name string) (io.ReadCloser, error) {
+	pathname, err := fs.pathname(name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(pathname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrBlobNotFound(name)
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+func (fs *FileStore) List(prefix string) ([]string, error) {
+	entries, err := os.ReadDir(fs.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && name[0] != '.' && strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SaveSnapshot writes a snapshot of the Congomap to the named blob.
+func SaveSnapshot(store BlobStore, name string, cgm congomap.Congomap) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(WriteSnapshot(pw, cgm))
+	}()
+	err := store.Put(name, pr)
+	pr.CloseWithError(err) // unblocks the writer when Put returns early
+	return err
+}
+
+// LoadSnapshot restores the Congomap from the snapshot in the named blob. When the snapshot is
+// corrupt, nothing is stored.
+func LoadSnapshot(store BlobStore, name string, cgm congomap.Congomap) error {
+	rc, err := store.Get(name)
+	if err != nil {
+		return err
+	}
+	err = RestoreSnapshot(rc, cgm)
+	if cerr := rc.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package persist_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/karrick/congomap/v2/persist"
+)
+
+// memStore is a BlobStore that keeps blobs in memory.
+type memStore struct {
+	lock  sync.Mutex
+	blobs map[string][]byte
+}
+
+func (ms *memStore) Put(name string, r io.Reader) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	ms.lock.Lock()
+	ms.blobs[name] = b
+	ms.lock.Unlock()
+	return nil
+}
+
+func (ms *memStore) Get(name string) (io.ReadCloser, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	b, ok := ms.blobs[name]
+	if !ok {
+		return nil, persist.ErrBlobNotFound(name)
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (ms *memStore) List(prefix string) ([]string, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	var names []string
+	for name := range ms.blobs {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func TestFileStore(t *testing.T) {
+	fs, err := persist.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = fs.Get("missing"); err != persist.ErrBlobNotFound("missing") {
+		t.Errorf("Actual: %#v; Expected: %#v", err, persist.ErrBlobNotFound("missing"))
+	}
+	for _, name := range []string{"", ".hidden", "../escape", `a\b`} {
+		if err = fs.Put(name, strings.NewReader("x")); err != persist.ErrInvalidBlobName(name) {
+			t.Errorf("Name: %q; Actual: %#v; Expected: %#v", name, err, persist.ErrInvalidBlobName(name))
+		}
+	}
+
+	for _, name := range []string{"snap-2", "snap-1", "other"} {
+		if err = fs.Put(name, strings.NewReader(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, err := fs.List("snap-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"snap-1", "snap-2"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Actual: %v; Expected: %v", names, expected)
+	}
+
+	rc, err := fs.Get("snap-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rc.Close() }()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "snap-2" {
+		t.Errorf("Actual: %q; Expected: %q", b, "snap-2")
+	}
+}
+
+func TestWALSnapshotStore(t *testing.T) {
+	dir := t.TempDir()
+	store := &memStore{blobs: make(map[string][]byte)}
+
+	wal := openWAL(t, dir, persist.SnapshotStore(store))
+	wal.Store("abc", 1)
+	if err := wal.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := store.List(""); !reflect.DeepEqual(names, []string{"snapshot"}) {
+		t.Errorf("Actual: %v; Expected: %v", names, []string{"snapshot"})
+	}
+
+	wal = openWAL(t, dir, persist.SnapshotStore(store))
+	defer func() { _ = wal.Close() }()
+	loadValue(t, wal, "abc", 1)
+}
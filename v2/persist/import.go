@@ -0,0 +1,162 @@
+package persist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+)
+
+// ImportSetter declares the type of function used when importing records to change the import's
+// behavior.
+type ImportSetter func(*importer) error
+
+// ImportConcurrency is used to specify how many records are stored in the Congomap concurrently.
+// The default is one.
+func ImportConcurrency(n int) ImportSetter {
+	return func(im *importer) error {
+		if n <= 0 {
+			return fmt.Errorf("persist: import concurrency must be greater than 0: %d", n)
+		}
+		im.concurrency = n
+		return nil
+	}
+}
+
+// ImportDecoder is used to specify how the JSON value of each record is converted to the value
+// stored in the Congomap. When not specified, each value is stored as a json.RawMessage.
+func ImportDecoder(decode func(json.RawMessage) (interface{}, error)) ImportSetter {
+	return func(im *importer) error {
+		im.decode = decode
+		return nil
+	}
+}
+
+// ImportProgress is used to specify a function invoked with the number of records stored so far,
+// after every so many records are stored.
+func ImportProgress(every int, progress func(int)) ImportSetter {
+	return func(im *importer) error {
+		if every <= 0 {
+			return fmt.Errorf("persist: import progress interval must be greater than 0: %d", every)
+		}
+		im.every = every
+		im.progress = progress
+		return nil
+	}
+}
+
+type importer struct {
+	concurrency int
+	decode      func(json.RawMessage) (interface{}, error)
+	every       int
+	progress    func(int)
+
+	lock  sync.Mutex // guards all fields below
+	count int
+	err   error // first error storing a record
+}
+
+// jsonRecord is a single key-value pair of JSON-lines input.
+type jsonRecord struct {
+	Key    string          `json:"key"`
+	Value  json.RawMessage `json:"value"`
+	Expiry time.Time       `json:"expiry"` // RFC 3339; omitted means no expiry
+}
+
+func (im *importer) fail(err error) {
+	im.lock.Lock()
+	if im.err == nil {
+		im.err = err
+	}
+	im.lock.Unlock()
+}
+
+func (im *importer) failed() bool {
+	im.lock.Lock()
+	defer im.lock.Unlock()
+	return im.err != nil
+}
+
+func (im *importer) store(cgm congomap.Congomap, line int, rec *jsonRecord) {
+	var value interface{} = rec.Value
+	if im.decode != nil {
+		var err error
+		if value, err = im.decode(rec.Value); err != nil {
+			im.fail(fmt.Errorf("persist: cannot decode value of line %d: %s", line, err))
+			return
+		}
+	}
+	if !rec.Expiry.IsZero() {
+		value = &congomap.ExpiringValue{Value: value, Expiry: rec.Expiry}
+	}
+	cgm.Store(rec.Key, value)
+
+	im.lock.Lock()
+	im.count++
+	if im.progress != nil && im.count%im.every == 0 {
+		im.progress(im.count)
+	}
+	im.lock.Unlock()
+}
+
+// ImportJSONLines stores the records read from r in the Congomap, and returns how many records
+// were stored. Each line of input is a JSON object with a "key" string, a "value" of any JSON type,
+// and optionally an "expiry" time in RFC 3339 format. Blank lines are ignored. Import stops at the
+// first line that cannot be parsed, but records read before that line may already be stored.
+//
+//	n, err := persist.ImportJSONLines(os.Stdin, cgm, persist.ImportConcurrency(8),
+//	    persist.ImportProgress(10000, func(n int) { log.Printf("imported %d records", n) }))
+func ImportJSONLines(r io.Reader, cgm congomap.Congomap, setters ...ImportSetter) (int, error) {
+	im := &importer{concurrency: 1}
+	for _, setter := range setters {
+		if err := setter(im); err != nil {
+			return 0, err
+		}
+	}
+
+	type job struct {
+		line int
+		rec  *jsonRecord
+	}
+	jobs := make(chan job, im.concurrency)
+
+	var wg sync.WaitGroup
+	wg.Add(im.concurrency)
+	for i := 0; i < im.concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				im.store(cgm, j.line, j.rec)
+			}
+		}()
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	var line int
+	for scanner.Scan() && !im.failed() {
+		line++
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		rec := new(jsonRecord)
+		if err := json.Unmarshal(b, rec); err != nil {
+			im.fail(fmt.Errorf("persist: cannot parse line %d: %s", line, err))
+			break
+		}
+		jobs <- job{line, rec}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := scanner.Err(); err != nil {
+		im.fail(err)
+	}
+	return im.count, im.err
+}
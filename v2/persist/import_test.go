@@ -0,0 +1,72 @@
+package persist_test
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+	"github.com/karrick/congomap/v2/persist"
+)
+
+func TestImportJSONLines(t *testing.T) {
+	cgm, err := congomap.NewSyncMutexMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	var input strings.Builder
+	for i := 0; i < 100; i++ {
+		input.WriteString(`{"key":"k` + strconv.Itoa(i) + `","value":` + strconv.Itoa(i) + "}\n")
+	}
+	input.WriteString("\n")
+	input.WriteString(`{"key":"expired","value":"x","expiry":"2001-02-03T04:05:06Z"}` + "\n")
+
+	var lock sync.Mutex
+	var reports []int
+	n, err := persist.ImportJSONLines(strings.NewReader(input.String()), cgm,
+		persist.ImportConcurrency(4),
+		persist.ImportDecoder(func(raw json.RawMessage) (interface{}, error) {
+			var v interface{}
+			err := json.Unmarshal(raw, &v)
+			return v, err
+		}),
+		persist.ImportProgress(25, func(n int) {
+			lock.Lock()
+			reports = append(reports, n)
+			lock.Unlock()
+		}))
+	if n != 101 || err != nil {
+		t.Fatalf("Actual: %d, %#v; Expected: %d, %#v", n, err, 101, nil)
+	}
+	if len(reports) != 4 || reports[3] != 100 {
+		t.Errorf("Actual: %v; Expected: %v", reports, []int{25, 50, 75, 100})
+	}
+	loadValue(t, cgm, "k42", float64(42))
+	loadValue(t, cgm, "expired", nil)
+	if next, ok := cgm.NextExpiry(); !ok || !next.Equal(time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)) {
+		t.Errorf("Actual: %v, %v; Expected: 2001-02-03T04:05:06Z, true", next, ok)
+	}
+}
+
+func TestImportJSONLinesMalformed(t *testing.T) {
+	cgm, err := congomap.NewSyncMutexMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	input := `{"key":"abc","value":1}` + "\n" + `{"key":` + "\n" + `{"key":"def","value":2}` + "\n"
+	n, err := persist.ImportJSONLines(strings.NewReader(input), cgm)
+	if n != 1 || err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Actual: %d, %v; Expected: %d, error about line 2", n, err, 1)
+	}
+	if value, ok := cgm.Load("abc"); !ok || string(value.(json.RawMessage)) != "1" {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, ok, json.RawMessage("1"), true)
+	}
+	loadValue(t, cgm, "def", nil)
+}
@@ -0,0 +1,70 @@
+package persist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	opStore   byte = 's'
+	opDelete  byte = 'd'
+	opClear   byte = 'c'
+	opPersist byte = 'p'
+)
+
+// record is a single mutation of a Congomap, as written to the write-ahead log.
+type record struct {
+	Op     byte
+	Key    string
+	Value  interface{}
+	Expiry time.Time
+}
+
+// errTruncated is returned when the final record of a file was only partially written, as happens
+// when the process crashes while appending to it.
+var errTruncated = errors.New("persist: truncated record")
+
+// writeRecord writes the record prefixed by its length. Each record is encoded independently, so
+// records may be appended to a file written by a previous process.
+func writeRecord(w io.Writer, r *record) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(r); err != nil {
+		return err
+	}
+	var prefix [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(prefix[:], uint64(buf.Len()))
+	if _, err := w.Write(prefix[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// readRecord reads the next record, returning io.EOF when there are no more records, and
+// errTruncated when the final record is incomplete.
+func readRecord(r *bufio.Reader) (*record, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errTruncated
+		}
+		return nil, err
+	}
+	buf := make([]byte, size)
+	if _, err = io.ReadFull(r, buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errTruncated
+		}
+		return nil, err
+	}
+	rec := new(record)
+	if err = gob.NewDecoder(bytes.NewReader(buf)).Decode(rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
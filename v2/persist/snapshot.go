@@ -0,0 +1,266 @@
+package persist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+)
+
+// A snapshot file is laid out as follows, with all fixed size integers in big-endian byte order:
+//
+//	header:  8 byte magic "congomap", 2 byte format version, 1 byte codec id
+//	records: uvarint length of payload (never zero), payload, 4 byte CRC-32C of payload
+//	trailer: uvarint zero, uvarint count of records, 4 byte CRC-32C of all preceding bytes
+//
+// A file lacking its trailer was truncated.
+
+const (
+	snapshotMagic   = "congomap"
+	snapshotVersion = 1
+	codecGob        = 1
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Record is a single key-value pair read from a snapshot.
+type Record struct {
+	Key    string
+	Value  interface{}
+	Expiry time.Time // zero time means no expiry
+}
+
+// ErrCorruptSnapshot is returned when reading a snapshot that is truncated, fails a checksum, or is
+// otherwise malformed.
+type ErrCorruptSnapshot string
+
+func (e ErrCorruptSnapshot) Error() string {
+	return "persist: corrupt snapshot: " + string(e)
+}
+
+// ErrUnsupportedSnapshot is returned when reading a snapshot written with a format version or codec
+// this package does not support.
+type ErrUnsupportedSnapshot struct {
+	Version uint16
+	Codec   byte
+}
+
+func (e ErrUnsupportedSnapshot) Error() string {
+	return fmt.Sprintf("persist: unsupported snapshot format version %d codec %d", e.Version, e.Codec)
+}
+
+// snapshotWriter writes a snapshot, tracking the checksum of everything written.
+type snapshotWriter struct {
+	w     io.Writer
+	crc   uint32
+	count uint64
+	err   error
+}
+
+func (sw *snapshotWriter) write(b []byte) {
+	if sw.err != nil {
+		return
+	}
+	sw.crc = crc32.Update(sw.crc, castagnoli, b)
+	_, sw.err = sw.w.Write(b)
+}
+
+func (sw *snapshotWriter) writeUvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	sw.write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+func (sw *snapshotWriter) writeUint32(v uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	sw.write(buf[:])
+}
+
+func (sw *snapshotWriter) header() {
+	sw.write([]byte(snapshotMagic))
+	var buf [3]byte
+	binary.BigEndian.PutUint16(buf[:2], snapshotVersion)
+	buf[2] = codecGob
+	sw.write(buf[:])
+}
+
+func (sw *snapshotWriter) record(r *Record) {
+	if sw.err != nil {
+		return
+	}
+	var payload bytes.Buffer
+	if sw.err = gob.NewEncoder(&payload).Encode(r); sw.err != nil {
+		return
+	}
+	sw.writeUvarint(uint64(payload.Len()))
+	sw.write(payload.Bytes())
+	sw.writeUint32(crc32.Checksum(payload.Bytes(), castagnoli))
+	sw.count++
+}
+
+func (sw *snapshotWriter) trailer() {
+	sw.writeUvarint(0)
+	sw.writeUvarint(sw.count)
+	sw.writeUint32(sw.crc)
+}
+
+// WriteSnapshot writes the key-value pairs of the Congomap to w in the snapshot format, each with
+// its expiry, derived from its remaining time-to-live. Values are encoded with encoding/gob.
+func WriteSnapshot(w io.Writer, cgm congomap.Congomap) error {
+	bw := bufio.NewWriter(w)
+	sw := &snapshotWriter{w: bw}
+	sw.header()
+	now := congomap.Now(cgm)
+	for pair := range cgm.Pairs() {
+		ttl, ok := cgm.TTLRemaining(pair.Key)
+		if !ok {
+			continue // expired or deleted since it was sent
+		}
+		rec := &Record{Key: pair.Key, Value: pair.Value}
+		if ttl > 0 {
+			rec.Expiry = now.Add(ttl)
+		}
+		sw.record(rec) // drain channel even after an error
+	}
+	sw.trailer()
+	if sw.err != nil {
+		return sw.err
+	}
+	return bw.Flush()
+}
+
+// snapshotReader reads a snapshot, tracking the checksum of everything read.
+type snapshotReader struct {
+	r   *bufio.Reader
+	crc uint32
+}
+
+func (sr *snapshotReader) read(b []byte) error {
+	if _, err := io.ReadFull(sr.r, b); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrCorruptSnapshot("truncated")
+		}
+		return err
+	}
+	sr.crc = crc32.Update(sr.crc, castagnoli, b)
+	return nil
+}
+
+func (sr *snapshotReader) readUvarint() (uint64, error) {
+	var buf [binary.MaxVarintLen64]byte
+	for i := range buf {
+		if err := sr.read(buf[i : i+1]); err != nil {
+			return 0, err
+		}
+		if buf[i] < 0x80 {
+			v, n := binary.Uvarint(buf[:i+1])
+			if n <= 0 {
+				break
+			}
+			return v, nil
+		}
+	}
+	return 0, ErrCorruptSnapshot("malformed length")
+}
+
+func (sr *snapshotReader) readUint32() (uint32, error) {
+	var buf [4]byte
+	if err := sr.read(buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(buf[:]), nil
+}
+
+// ReadSnapshot reads every record of the snapshot from r, verifying its header, the checksum of
+// each record, and its trailer, then invokes fn with each record in the order written. When the
+// snapshot is corrupt, ReadSnapshot returns ErrCorruptSnapshot without invoking fn.
+func ReadSnapshot(r io.Reader, fn func(*Record) error) error {
+	sr := &snapshotReader{r: bufio.NewReader(r)}
+
+	var header [len(snapshotMagic) + 3]byte
+	if err := sr.read(header[:]); err != nil {
+		return err
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return ErrCorruptSnapshot("not a snapshot")
+	}
+	version := binary.BigEndian.Uint16(header[len(snapshotMagic):])
+	codec := header[len(snapshotMagic)+2]
+	if version != snapshotVersion || codec != codecGob {
+		return ErrUnsupportedSnapshot{Version: version, Codec: codec}
+	}
+
+	var records []*Record
+	for {
+		size, err := sr.readUvarint()
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			break // trailer
+		}
+		payload := make([]byte, size)
+		if err = sr.read(payload); err != nil {
+			return err
+		}
+		checksum, err := sr.readUint32()
+		if err != nil {
+			return err
+		}
+		if checksum != crc32.Checksum(payload, castagnoli) {
+			return ErrCorruptSnapshot(fmt.Sprintf("checksum mismatch for record %d", len(records)))
+		}
+		rec := new(Record)
+		if err = gob.NewDecoder(bytes.NewReader(payload)).Decode(rec); err != nil {
+			return ErrCorruptSnapshot(fmt.Sprintf("cannot decode record %d: %s", len(records), err))
+		}
+		records = append(records, rec)
+	}
+
+	count, err := sr.readUvarint()
+	if err != nil {
+		return err
+	}
+	if count != uint64(len(records)) {
+		return ErrCorruptSnapshot(fmt.Sprintf("trailer expects %d records; found %d", count, len(records)))
+	}
+	expected := sr.crc
+	checksum, err := sr.readUint32()
+	if err != nil {
+		return err
+	}
+	if checksum != expected {
+		return ErrCorruptSnapshot("file checksum mismatch")
+	}
+
+	for _, rec := range records {
+		if err = fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreSnapshot stores every record of the snapshot read from r in the Congomap, other than those
+// whose values have expired since the snapshot was written. When the snapshot is corrupt, nothing
+// is stored.
+func RestoreSnapshot(r io.Reader, cgm congomap.Congomap) error {
+	now := congomap.Now(cgm)
+	return ReadSnapshot(r, func(rec *Record) error {
+		if !rec.Expiry.IsZero() && !rec.Expiry.After(now) {
+			return nil
+		}
+		if rec.Expiry.IsZero() {
+			cgm.Store(rec.Key, rec.Value)
+		} else {
+			cgm.Store(rec.Key, &congomap.ExpiringValue{Value: rec.Value, Expiry: rec.Expiry})
+		}
+		return nil
+	})
+}
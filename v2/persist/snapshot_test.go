@@ -0,0 +1,132 @@
+package persist_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+	"github.com/karrick/congomap/v2/persist"
+)
+
+func writeSnapshot(t *testing.T) []byte {
+	cgm, err := congomap.NewSyncMutexMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+	cgm.Store("abc", 1)
+	cgm.Store("def", 2)
+
+	var buf bytes.Buffer
+	if err = persist.WriteSnapshot(&buf, cgm); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func restoreSnapshot(t *testing.T, b []byte) (congomap.Congomap, error) {
+	cgm, err := congomap.NewSyncMutexMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cgm, persist.RestoreSnapshot(bytes.NewReader(b), cgm)
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	cgm, err := restoreSnapshot(t, writeSnapshot(t))
+	defer func() { _ = cgm.Close() }()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadValue(t, cgm, "abc", 1)
+	loadValue(t, cgm, "def", 2)
+}
+
+// fixedClock is a TimeSource whose time never passes.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time                       { return time.Time(c) }
+func (c fixedClock) After(time.Duration) <-chan time.Time { return nil }
+
+func TestSnapshotExpiry(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cgm, err := congomap.NewSyncMutexMap(congomap.Clock(fixedClock(now)), congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+	cgm.Store("forever", 1)
+	cgm.Store("hour", &congomap.ExpiringValue{Value: 2, Expiry: now.Add(time.Hour)})
+
+	var buf bytes.Buffer
+	if err = persist.WriteSnapshot(&buf, cgm); err != nil {
+		t.Fatal(err)
+	}
+
+	expiries := make(map[string]time.Time)
+	err = persist.ReadSnapshot(bytes.NewReader(buf.Bytes()), func(rec *persist.Record) error {
+		expiries[rec.Key] = rec.Expiry
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := expiries, map[string]time.Time{"forever": {}, "hour": now.Add(time.Hour)}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Actual: %v; Expected: %v", actual, expected)
+	}
+
+	// values that expired since the snapshot was written are not restored
+	later, err := congomap.NewSyncMutexMap(congomap.Clock(fixedClock(now.Add(2*time.Hour))), congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = later.Close() }()
+	if err = persist.RestoreSnapshot(bytes.NewReader(buf.Bytes()), later); err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := later.Keys(), []string{"forever"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Actual: %v; Expected: %v", actual, expected)
+	}
+}
+
+func TestSnapshotCorruption(t *testing.T) {
+	good := writeSnapshot(t)
+
+	flipped := append([]byte(nil), good...)
+	flipped[len(good)/2] ^= 0xff
+
+	cases := map[string][]byte{
+		"empty":          nil,
+		"truncated":      good[:len(good)-1],
+		"no trailer":     good[:len(good)-6],
+		"flipped":        flipped,
+		"not a snapshot": []byte("this is not a snapshot file"),
+	}
+	for name, b := range cases {
+		t.Run(name, func(t *testing.T) {
+			cgm, err := restoreSnapshot(t, b)
+			defer func() { _ = cgm.Close() }()
+			var corrupt persist.ErrCorruptSnapshot
+			if !errors.As(err, &corrupt) {
+				t.Errorf("Actual: %#v; Expected: %T", err, corrupt)
+			}
+			if keys := cgm.Keys(); len(keys) != 0 {
+				t.Errorf("Actual: %v; Expected: no keys restored", keys)
+			}
+		})
+	}
+}
+
+func TestSnapshotUnsupportedVersion(t *testing.T) {
+	b := writeSnapshot(t)
+	b[9] = 99 // low byte of format version
+
+	cgm, err := restoreSnapshot(t, b)
+	defer func() { _ = cgm.Close() }()
+	if _, ok := err.(persist.ErrUnsupportedSnapshot); !ok {
+		t.Errorf("Actual: %#v; Expected: %T", err, persist.ErrUnsupportedSnapshot{})
+	}
+}
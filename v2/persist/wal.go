@@ -0,0 +1,463 @@
+// Package persist provides durability for Congomaps, by recording their mutations to files from
+// which they may be restored after the process restarts.
+//
+// Values are encoded with encoding/gob, so the concrete type of every value stored in a persisted
+// Congomap must be registered with gob.Register.
+package persist
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+)
+
+const (
+	walName      = "wal"
+	snapshotName = "snapshot"
+)
+
+// WALSetter declares the type of function used when creating a WAL to change the instance's
+// behavior.
+type WALSetter func(*WAL) error
+
+// FsyncInterval is used to specify how often the write-ahead log is flushed to stable storage. A
+// crash loses at most the mutations recorded during the last interval. The default is one second.
+func FsyncInterval(duration time.Duration) WALSetter {
+	return func(w *WAL) error {
+		if duration <= 0 {
+			return congomap.ErrInvalidDuration(duration)
+		}
+		w.interval = duration
+		return nil
+	}
+}
+
+// CompactAfter is used to specify how many mutations are recorded in the write-ahead log before it
+// is automatically compacted into a snapshot. When not specified, the write-ahead log is only
+// compacted when the Compact method is invoked.
+func CompactAfter(mutations int) WALSetter {
+	return func(w *WAL) error {
+		w.compactAfter = mutations
+		return nil
+	}
+}
+
+// SnapshotStore is used to specify where snapshots of the Congomap are kept. The default is a
+// FileStore in the same directory as the write-ahead log.
+func SnapshotStore(store BlobStore) WALSetter {
+	return func(w *WAL) error {
+		w.store = store
+		return nil
+	}
+}
+
+// WAL is a Congomap that records every Store and Delete, and every replacement made by Do, to an
+// append-only write-ahead log before applying it to the Congomap it wraps. The log is periodically
+// compacted into a snapshot of the Congomap's contents. Values obtained by the lookup callback
+// function are not recorded, because they may be looked up again.
+//
+// All mutations made through a WAL are serialized by the log, and mutations made to the wrapped
+// Congomap other than through the WAL are not recorded. Expiries postponed by Touch, or by reads
+// when AccessTTL was specified, are not recorded either, nor are keys evicted by Prune.
+type WAL struct {
+	congomap.Congomap
+
+	dir          string
+	store        BlobStore
+	interval     time.Duration
+	compactAfter int
+
+	lock      sync.Mutex // guards all fields below
+	file      *os.File
+	w         *bufio.Writer
+	mutations int // recorded since last compaction
+	dirty     bool
+	err       error // first error writing the log
+
+	halt chan struct{}
+	done chan struct{}
+}
+
+// NewWAL restores the Congomap from the snapshot and write-ahead log in the specified directory,
+// creating the directory when it does not exist, and returns a WAL that records subsequent
+// mutations there.
+//
+//	cgm, err := congomap.NewSyncMutexMap()
+//	if err != nil {
+//	    panic(err)
+//	}
+//	wal, err := persist.NewWAL(cgm, "/var/cache/myapp")
+//	if err != nil {
+//	    panic(err)
+//	}
+//	defer func() { _ = wal.Close() }() // also closes cgm
+func NewWAL(cgm congomap.Congomap, dir string, setters ...WALSetter) (*WAL, error) {
+	w := &WAL{
+		Congomap: cgm,
+		dir:      dir,
+		interval: time.Second,
+		halt:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, setter := range setters {
+		if err := setter(w); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if w.store == nil {
+		store, err := NewFileStore(dir)
+		if err != nil {
+			return nil, err
+		}
+		w.store = store
+	}
+	if err := LoadSnapshot(w.store, snapshotName, cgm); err != nil {
+		if _, ok := err.(ErrBlobNotFound); !ok {
+			return nil, err
+		}
+	}
+	if err := replay(cgm, filepath.Join(dir, walName)); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, walName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	w.file = file
+	w.w = bufio.NewWriter(file)
+
+	go w.run()
+	return w, nil
+}
+
+// replay applies the records in the named file to the Congomap. A missing file has no records, and
+// a partially written final record is ignored.
+func replay(cgm congomap.Congomap, name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	r := bufio.NewReader(file)
+	for {
+		rec, err := readRecord(r)
+		if err != nil {
+			if err == io.EOF || err == errTruncated {
+				return nil
+			}
+			return err
+		}
+		apply(cgm, rec)
+	}
+}
+
+func apply(cgm congomap.Congomap, rec *record) {
+	switch rec.Op {
+	case opStore:
+		if !rec.Expiry.IsZero() && !rec.Expiry.After(congomap.Now(cgm)) {
+			cgm.Delete(rec.Key) // the value stored has since expired, along with any it replaced
+		} else if rec.Expiry.IsZero() {
+			cgm.Store(rec.Key, rec.Value)
+		} else {
+			cgm.Store(rec.Key, &congomap.ExpiringValue{Value: rec.Value, Expiry: rec.Expiry})
+		}
+	case opDelete:
+		cgm.Delete(rec.Key)
+	case opClear:
+		cgm.Clear()
+	case opPersist:
+		// the value is stored again because its original expiry may have passed during replay
+		cgm.Store(rec.Key, rec.Value)
+		cgm.Persist(rec.Key)
+	}
+}
+
+// storeRecord returns the record of storing the value, which may be an ExpiringValue.
+func storeRecord(key string, value interface{}) *record {
+	if ev, ok := value.(*congomap.ExpiringValue); ok {
+		return &record{Op: opStore, Key: key, Value: ev.Value, Expiry: ev.Expiry}
+	}
+	return &record{Op: opStore, Key: key, Value: value}
+}
+
+// append records a mutation. It must be called while holding the lock.
+func (w *WAL) append(rec *record) {
+	if w.err != nil {
+		return
+	}
+	if w.err = writeRecord(w.w, rec); w.err != nil {
+		return
+	}
+	w.dirty = true
+	w.mutations++
+}
+
+// maybeCompact compacts the log once enough mutations have been recorded. It must be called while
+// holding the lock, but not while the mutation is being applied, because compaction reads the
+// wrapped Congomap.
+func (w *WAL) maybeCompact() {
+	if w.err == nil && w.compactAfter > 0 && w.mutations >= w.compactAfter {
+		w.err = w.compact()
+	}
+}
+
+func (w *WAL) Clear() {
+	w.lock.Lock()
+	w.append(&record{Op: opClear})
+	w.Congomap.Clear()
+	w.maybeCompact()
+	w.lock.Unlock()
+}
+
+func (w *WAL) Delete(key string) {
+	w.lock.Lock()
+	w.append(&record{Op: opDelete, Key: key})
+	w.Congomap.Delete(key)
+	w.maybeCompact()
+	w.lock.Unlock()
+}
+
+func (w *WAL) DeleteIf(key string, fn func(interface{}) bool) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	deleted := w.Congomap.DeleteIf(key, fn)
+	if deleted {
+		w.append(&record{Op: opDelete, Key: key})
+		w.maybeCompact()
+	}
+	return deleted
+}
+
+func (w *WAL) DeleteMany(keys []string) {
+	w.lock.Lock()
+	for _, key := range keys {
+		w.append(&record{Op: opDelete, Key: key})
+	}
+	w.Congomap.DeleteMany(keys)
+	w.maybeCompact()
+	w.lock.Unlock()
+}
+
+func (w *WAL) Do(key string, fn func(interface{}, bool) (interface{}, bool)) {
+	w.lock.Lock()
+	w.Congomap.Do(key, func(value interface{}, ok bool) (interface{}, bool) {
+		replacement, replace := fn(value, ok)
+		if replace {
+			w.append(storeRecord(key, replacement))
+		}
+		return replacement, replace
+	})
+	w.maybeCompact()
+	w.lock.Unlock()
+}
+
+func (w *WAL) Store(key string, value interface{}) {
+	w.lock.Lock()
+	w.append(storeRecord(key, value))
+	w.Congomap.Store(key, value)
+	w.maybeCompact()
+	w.lock.Unlock()
+}
+
+func (w *WAL) StoreMany(pairs map[string]interface{}) {
+	w.lock.Lock()
+	for key, value := range pairs {
+		w.append(storeRecord(key, value))
+	}
+	w.Congomap.StoreMany(pairs)
+	w.maybeCompact()
+	w.lock.Unlock()
+}
+
+func (w *WAL) StoreWithTTL(key string, value interface{}, ttl time.Duration) {
+	if ev, ok := value.(*congomap.ExpiringValue); ok {
+		value = ev.Value
+	}
+	if ttl > 0 {
+		value = &congomap.ExpiringValue{Value: value, Expiry: congomap.Now(w.Congomap).Add(ttl)}
+	}
+	w.Store(key, value)
+}
+
+func (w *WAL) Update(key string, fn func(interface{}, bool) (interface{}, bool)) {
+	w.lock.Lock()
+	w.Congomap.Update(key, func(value interface{}, ok bool) (interface{}, bool) {
+		replacement, keep := fn(value, ok)
+		if keep {
+			w.append(storeRecord(key, replacement))
+		} else if ok {
+			w.append(&record{Op: opDelete, Key: key})
+		}
+		return replacement, keep
+	})
+	w.maybeCompact()
+	w.lock.Unlock()
+}
+
+func (w *WAL) Expire(key string, duration time.Duration) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	found := w.Congomap.Expire(key, duration)
+	if found {
+		// recorded as a store of the value with its new expiry, or as its deletion when the
+		// value has already expired
+		if value, ok := w.Congomap.Load(key); ok {
+			w.append(&record{Op: opStore, Key: key, Value: value, Expiry: congomap.Now(w.Congomap).Add(duration)})
+		} else {
+			w.append(&record{Op: opDelete, Key: key})
+		}
+		w.maybeCompact()
+	}
+	return found
+}
+
+func (w *WAL) Persist(key string) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	found := w.Congomap.Persist(key)
+	if found {
+		if value, ok := w.Congomap.Load(key); ok {
+			w.append(&record{Op: opPersist, Key: key, Value: value})
+			w.maybeCompact()
+		}
+	}
+	return found
+}
+
+func (w *WAL) LoadAndDelete(key string) (interface{}, bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	value, loaded := w.Congomap.LoadAndDelete(key)
+	if loaded {
+		w.append(&record{Op: opDelete, Key: key})
+		w.maybeCompact()
+	}
+	return value, loaded
+}
+
+func (w *WAL) LoadOrStore(key string, value interface{}) (interface{}, bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	actual, loaded := w.Congomap.LoadOrStore(key, value)
+	if !loaded {
+		w.append(storeRecord(key, value))
+		w.maybeCompact()
+	}
+	return actual, loaded
+}
+
+func (w *WAL) StoreReturning(key string, value interface{}) (interface{}, bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.append(storeRecord(key, value))
+	prev, existed := w.Congomap.StoreReturning(key, value)
+	w.maybeCompact()
+	return prev, existed
+}
+
+func (w *WAL) Swap(key string, value interface{}) (interface{}, bool) {
+	return w.StoreReturning(key, value)
+}
+
+// Compact writes a snapshot of the Congomap's contents, with the expiry of each value, then empties
+// the write-ahead log.
+func (w *WAL) Compact() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.compact()
+	return w.err
+}
+
+// compact must be called while holding the lock, which prevents mutations from being recorded
+// between taking the snapshot and emptying the log.
+func (w *WAL) compact() error {
+	if err := SaveSnapshot(w.store, snapshotName, w.Congomap); err != nil {
+		return err
+	}
+
+	// The snapshot now holds every recorded mutation, so the log may be emptied.
+	w.w.Reset(w.file)
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	w.mutations = 0
+	w.dirty = false
+	return nil
+}
+
+// Sync flushes recorded mutations to stable storage.
+func (w *WAL) Sync() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.sync()
+}
+
+func (w *WAL) sync() error {
+	if w.err != nil || !w.dirty {
+		return w.err
+	}
+	if w.err = w.w.Flush(); w.err != nil {
+		return w.err
+	}
+	w.err = w.file.Sync()
+	w.dirty = false
+	return w.err
+}
+
+// Err returns the first error encountered while recording mutations. Once an error is encountered,
+// no further mutations are recorded.
+func (w *WAL) Err() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.err
+}
+
+func (w *WAL) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = w.Sync()
+		case <-w.halt:
+			close(w.done)
+			return
+		}
+	}
+}
+
+// Close flushes recorded mutations to stable storage, closes the write-ahead log, then closes the
+// wrapped Congomap. It returns the first error encountered while recording mutations.
+func (w *WAL) Close() error {
+	close(w.halt)
+	<-w.done
+
+	w.lock.Lock()
+	err := w.sync()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.lock.Unlock()
+
+	if cerr := w.Congomap.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
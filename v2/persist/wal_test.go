@@ -0,0 +1,158 @@
+package persist_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+	"github.com/karrick/congomap/v2/persist"
+)
+
+func openWAL(t *testing.T, dir string, setters ...persist.WALSetter) *persist.WAL {
+	cgm, err := congomap.NewSyncMutexMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal, err := persist.NewWAL(cgm, dir, setters...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wal
+}
+
+func loadValue(t *testing.T, cgm congomap.Congomap, key string, expected interface{}) {
+	value, ok := cgm.Load(key)
+	if expected == nil {
+		if ok {
+			t.Errorf("Key: %q; Actual: %#v; Expected: %#v", key, value, nil)
+		}
+		return
+	}
+	if !ok || value != expected {
+		t.Errorf("Key: %q; Actual: %#v; Expected: %#v", key, value, expected)
+	}
+}
+
+func TestWALRestoresMutations(t *testing.T) {
+	dir := t.TempDir()
+
+	wal := openWAL(t, dir)
+	wal.Store("abc", 1)
+	wal.Store("def", 2)
+	wal.Store("expired", &congomap.ExpiringValue{Value: 3, Expiry: time.Now().Add(-time.Second)})
+	wal.Delete("abc")
+	wal.Do("def", func(value interface{}, ok bool) (interface{}, bool) {
+		return value.(int) + 40, true
+	})
+	if prev, existed := wal.StoreReturning("ghi", 5); prev != nil || existed {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", prev, existed, nil, false)
+	}
+	if actual, loaded := wal.LoadOrStore("ghi", 6); actual != 5 || !loaded {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", actual, loaded, 5, true)
+	}
+	if actual, loaded := wal.LoadOrStore("jkl", 7); actual != 7 || loaded {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", actual, loaded, 7, false)
+	}
+	wal.Store("mno", 8)
+	wal.StoreWithTTL("stu", 10, time.Hour)
+	wal.Update("pqr", func(interface{}, bool) (interface{}, bool) { return 9, true })
+	wal.Update("def", func(value interface{}, ok bool) (interface{}, bool) { return value.(int) + 1, true })
+	wal.Update("def", func(value interface{}, ok bool) (interface{}, bool) { return value.(int) - 1, true })
+	wal.Update("ghi", func(interface{}, bool) (interface{}, bool) { return nil, false })
+	wal.StoreMany(map[string]interface{}{"many1": 13, "many2": 14, "many3": 15})
+	wal.DeleteMany([]string{"many2", "many3"})
+	wal.Store("vwx", 11)
+	wal.Expire("vwx", -time.Second)
+	wal.Store("yz", &congomap.ExpiringValue{Value: 12, Expiry: time.Now().Add(time.Millisecond)})
+	wal.Persist("yz")
+	if value, ok := wal.LoadAndDelete("mno"); value != 8 || !ok {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, ok, 8, true)
+	}
+	if err := wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	wal = openWAL(t, dir)
+	defer func() { _ = wal.Close() }()
+	loadValue(t, wal, "abc", nil)
+	loadValue(t, wal, "def", 42)
+	loadValue(t, wal, "expired", nil)
+	loadValue(t, wal, "ghi", nil)
+	loadValue(t, wal, "jkl", 7)
+	loadValue(t, wal, "mno", nil)
+	loadValue(t, wal, "pqr", 9)
+	loadValue(t, wal, "stu", 10)
+	loadValue(t, wal, "vwx", nil)
+	loadValue(t, wal, "many1", 13)
+	loadValue(t, wal, "many2", nil)
+	time.Sleep(2 * time.Millisecond)
+	loadValue(t, wal, "yz", 12)
+}
+
+func TestWALRestoresClear(t *testing.T) {
+	dir := t.TempDir()
+
+	wal := openWAL(t, dir)
+	wal.Store("abc", 1)
+	wal.Clear()
+	wal.Store("def", 2)
+	if err := wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	wal = openWAL(t, dir)
+	defer func() { _ = wal.Close() }()
+	loadValue(t, wal, "abc", nil)
+	loadValue(t, wal, "def", 2)
+}
+
+func TestWALCompaction(t *testing.T) {
+	dir := t.TempDir()
+
+	wal := openWAL(t, dir, persist.CompactAfter(3))
+	wal.Store("abc", 1)
+	wal.Store("def", 2)
+	wal.Store("abc", 13) // triggers compaction
+	wal.Store("ghi", 42)
+	if err := wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "snapshot")); err != nil {
+		t.Fatal(err)
+	}
+
+	wal = openWAL(t, dir)
+	defer func() { _ = wal.Close() }()
+	loadValue(t, wal, "abc", 13)
+	loadValue(t, wal, "def", 2)
+	loadValue(t, wal, "ghi", 42)
+}
+
+func TestWALIgnoresTruncatedRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	wal := openWAL(t, dir)
+	wal.Store("abc", 1)
+	wal.Store("def", 2)
+	if err := wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a crash while appending the final record
+	name := filepath.Join(dir, "wal")
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Truncate(name, info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	wal = openWAL(t, dir)
+	defer func() { _ = wal.Close() }()
+	loadValue(t, wal, "abc", 1)
+	loadValue(t, wal, "def", nil)
+}
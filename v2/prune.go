@@ -0,0 +1,52 @@
+package congomap
+
+import (
+	"sort"
+	"time"
+)
+
+// oldest returns up to n of the keys visited by each, in the order they ought to be evicted: least
+// recently used first when EvictLRU was specified, otherwise soonest to expire first, with values
+// that never expire last.
+func (c *config) oldest(n int, each func(func(key string, expiry time.Time, used int64) bool)) []string {
+	if n <= 0 {
+		return nil
+	}
+	type candidate struct {
+		key    string
+		expiry time.Time
+		used   int64
+	}
+	var candidates []candidate
+	each(func(key string, expiry time.Time, used int64) bool {
+		candidates = append(candidates, candidate{key, expiry, used})
+		return true
+	})
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if c.lru {
+			return a.used < b.used
+		}
+		return !a.expiry.IsZero() && (b.expiry.IsZero() || a.expiry.Before(b.expiry))
+	})
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = candidates[i].key
+	}
+	return keys
+}
+
+// pruneLocked removes up to n keys chosen by the oldest method from the data store, and returns
+// their pairs to be reaped. The caller must serialize access to the data store.
+func (c *config) pruneLocked(db map[string]*entry, n int) []Pair {
+	keys := c.oldest(n, eachOf(db))
+	pruned := make([]Pair, len(keys))
+	for i, key := range keys {
+		pruned[i] = Pair{key, db[key].Value}
+		c.remove(db, key)
+	}
+	return pruned
+}
@@ -0,0 +1,103 @@
+package congomap
+
+import (
+	"strings"
+	"time"
+)
+
+// iterable is implemented by the Congomap types provided by this library, which can iterate over
+// their values under the appropriate locks.
+type iterable interface {
+	// each invokes fn with every key and its value, whether or not the value has expired, until
+	// fn returns false.
+	each(fn func(string, *entry) bool)
+
+	// now returns the current time according to the Congomap's TimeSource.
+	now() time.Time
+}
+
+// Query selects the values of a Congomap by filters applied while iterating over the Congomap
+// itself, rather than by streaming every pair through Pairs and filtering them afterwards. A Query
+// is built by chaining its methods:
+//
+//	err := congomap.Where(cgm, "session/").WithMinRemaining(time.Minute).Limit(100).Each(func(key string, value interface{}) bool {
+//	    fmt.Println(key, value)
+//	    return true
+//	})
+type Query struct {
+	cgm          Congomap
+	prefix       string
+	minRemaining time.Duration
+	limit        int
+}
+
+// Where returns a Query of the values in the Congomap whose keys begin with the specified prefix.
+// The prefix is canonicalized as the Congomap canonicalizes its keys, when CanonicalKey was
+// specified. The empty prefix selects every value.
+func Where(cgm Congomap, prefix string) *Query {
+	return &Query{cgm: cgm, prefix: prefix}
+}
+
+// WithMinRemaining restricts the Query to values with at least the specified duration remaining
+// before they expire. Values that never expire always qualify.
+func (q *Query) WithMinRemaining(duration time.Duration) *Query {
+	q.minRemaining = duration
+	return q
+}
+
+// Limit restricts the Query to at most n values. Zero means no limit.
+func (q *Query) Limit(n int) *Query {
+	q.limit = n
+	return q
+}
+
+// Each invokes fn with the key and value of each selected value, in no particular order, until fn
+// returns false or the limit is reached. Expired values are never selected. Because fn is invoked
+// while the Congomap is locked, it must not invoke any methods on the same Congomap. Each returns
+// ErrUnsupportedOption for a Congomap not provided by this library.
+func (q *Query) Each(fn func(string, interface{}) bool) error {
+	it, ok := q.cgm.(iterable)
+	if !ok {
+		return ErrUnsupportedOption{}
+	}
+	prefix := q.prefix
+	if c, ok := q.cgm.(configurable); ok {
+		prefix = c.getConfig().canonical(prefix)
+	}
+	now := it.now()
+	var n int
+	it.each(func(key string, ev *entry) bool {
+		if !strings.HasPrefix(key, prefix) || !ev.live(now) {
+			return true
+		}
+		if q.minRemaining > 0 && !ev.Expiry.IsZero() && ev.Expiry.Sub(now) < q.minRemaining {
+			return true
+		}
+		n++
+		return fn(key, ev.Value) && (q.limit <= 0 || n < q.limit)
+	})
+	return nil
+}
+
+// Reduce folds fn over the key and value of each selected value, in no particular order, starting
+// with init, and returns the final accumulator. Like Each, fn is invoked while the Congomap is
+// locked, and Reduce returns ErrUnsupportedOption for a Congomap not provided by this library.
+func (q *Query) Reduce(fn func(acc interface{}, p Pair) interface{}, init interface{}) (interface{}, error) {
+	acc := init
+	err := q.Each(func(key string, value interface{}) bool {
+		acc = fn(acc, Pair{Key: key, Value: value})
+		return true
+	})
+	return acc, err
+}
+
+// Reduce folds fn over every non-expired key and value of the Congomap, in no particular order,
+// starting with init, and returns the final accumulator. It is shorthand for
+// Where(cgm, "").Reduce(fn, init).
+//
+//	total, err := congomap.Reduce(cgm, func(acc interface{}, p congomap.Pair) interface{} {
+//	    return acc.(int) + p.Value.(int)
+//	}, 0)
+func Reduce(cgm Congomap, fn func(acc interface{}, p Pair) interface{}, init interface{}) (interface{}, error) {
+	return Where(cgm, "").Reduce(fn, init)
+}
@@ -0,0 +1,85 @@
+package congomap
+
+import (
+	"strconv"
+	"time"
+)
+
+// ErrInvalidBurst is returned by NewRateLimiter when a burst of less than or equal to zero is
+// specified.
+type ErrInvalidBurst int
+
+func (e ErrInvalidBurst) Error() string {
+	return "congomap: burst must be greater than 0: " + strconv.Itoa(int(e))
+}
+
+// RateLimiter is a view of a Congomap that limits the rate of events for each key, such as a user
+// or an IP address, with a token bucket per key. Each bucket holds up to burst tokens, and gains one
+// token per interval. A bucket expires from the Congomap once it would have refilled, because a
+// full bucket is no different from a new one, so idle keys cost nothing.
+type RateLimiter struct {
+	cgm      Congomap
+	interval time.Duration
+	burst    float64
+}
+
+// bucket is the state of a token bucket. Buckets are replaced rather than modified, because Load
+// and Pairs may read them concurrently.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a RateLimiter that keeps the token bucket of each key in the Congomap,
+// allowing bursts of up to burst events, and one further event per interval. The Congomap ought not
+// be used for anything else.
+//
+//	limiter, err := congomap.NewRateLimiter(cgm, time.Second, 10) // 1 event/s, bursts of 10
+//	if err != nil {
+//	    panic(err)
+//	}
+//	if !limiter.Allow(remoteAddr) {
+//	    http.Error(w, "slow down", http.StatusTooManyRequests)
+//	    return
+//	}
+func NewRateLimiter(cgm Congomap, interval time.Duration, burst int) (*RateLimiter, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidDuration(interval)
+	}
+	if burst <= 0 {
+		return nil, ErrInvalidBurst(burst)
+	}
+	return &RateLimiter{cgm: cgm, interval: interval, burst: float64(burst)}, nil
+}
+
+// Allow is shorthand for AllowN(key, 1).
+func (rl *RateLimiter) Allow(key string) bool {
+	return rl.AllowN(key, 1)
+}
+
+// AllowN returns true and takes n tokens from the key's bucket when it holds at least n tokens.
+// Otherwise it returns false and takes none. It returns false when n is not positive.
+func (rl *RateLimiter) AllowN(key string, n int) bool {
+	if n <= 0 {
+		return false
+	}
+	var allowed bool
+	rl.cgm.Do(key, func(value interface{}, ok bool) (interface{}, bool) {
+		now := Now(rl.cgm)
+		tokens := rl.burst
+		if ok {
+			b := value.(*bucket)
+			tokens = b.tokens + float64(now.Sub(b.last))/float64(rl.interval)
+			if tokens > rl.burst {
+				tokens = rl.burst
+			}
+		}
+		if float64(n) <= tokens {
+			tokens -= float64(n)
+			allowed = true
+		}
+		full := now.Add(time.Duration((rl.burst - tokens) * float64(rl.interval)))
+		return &ExpiringValue{Value: &bucket{tokens: tokens, last: now}, Expiry: full}, true
+	})
+	return allowed
+}
@@ -0,0 +1,80 @@
+package congomap
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// refreshes are the keys whose values are being refreshed in the background.
+type refreshes struct {
+	lock sync.Mutex
+	keys map[string]struct{}
+}
+
+// Stale is used to specify how long after a value is obtained by the lookup callback function it
+// goes stale. When LoadStore finds a stale value that has not yet expired, it returns that value
+// immediately, but also invokes the lookup callback function in the background, storing the value
+// it returns should it succeed, so frequently loaded keys are refreshed before they expire rather
+// than making a caller wait for the lookup. At most one background lookup runs for each key. This
+// option requires a lookup callback function, and ought to be shorter than the time-to-live of
+// values it returns.
+func Stale(duration time.Duration) Setter {
+	return configure(func(c *config) error {
+		if duration <= 0 {
+			return ErrInvalidDuration(duration)
+		}
+		c.stale = duration
+		return nil
+	})
+}
+
+// refreshAhead invokes the lookup function for key in the background when the live value returned
+// by LoadStore has gone stale, unless a refresh of key is already running. The value it returns
+// replaces the stale one using the Congomap's mutate method, only when the stale value is still
+// held, so that a value stored, updated, or deleted while the lookup runs is left alone.
+func (c *config) refreshAhead(key string, ev *entry, lookup lookupFunc, mutate func(string, mutator)) {
+	if ev.stale.IsZero() || c.now().Before(ev.stale) {
+		return
+	}
+
+	c.refreshing.lock.Lock()
+	if _, ok := c.refreshing.keys[key]; ok {
+		c.refreshing.lock.Unlock()
+		return
+	}
+	if c.refreshing.keys == nil {
+		c.refreshing.keys = make(map[string]struct{})
+	}
+	c.refreshing.keys[key] = struct{}{}
+	c.refreshing.lock.Unlock()
+
+	c.goBackground(func() {
+		defer func() {
+			c.refreshing.lock.Lock()
+			delete(c.refreshing.keys, key)
+			c.refreshing.lock.Unlock()
+		}()
+		if c.admit() != nil {
+			return // no new lookups while draining
+		}
+		defer c.release()
+		var value interface{}
+		var err error
+		if c.badExpiry == 0 {
+			value, err = c.retryLookup(context.Background(), lookup, key)
+		} else {
+			value, err = c.lookupBad(context.Background(), lookup, key)
+		}
+		if err != nil {
+			return
+		}
+		fresh := c.lookupValue(value)
+		mutate(key, func(stored *entry) (*entry, bool) {
+			if stored != ev {
+				return stored, false
+			}
+			return fresh, true
+		})
+	})
+}
@@ -0,0 +1,743 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+	"google.golang.org/grpc"
+)
+
+// ValueCodec converts the values a Client stores in, and loads from, a remote Congomap to and from
+// the bytes exchanged with the server.
+type ValueCodec interface {
+	Marshal(interface{}) ([]byte, error)
+	Unmarshal([]byte) (interface{}, error)
+}
+
+// bytesCodec is the default ValueCodec, which only accepts []byte values.
+type bytesCodec struct{}
+
+func (bytesCodec) Marshal(value interface{}) ([]byte, error) {
+	b, ok := value.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("remote: cannot marshal %T without a ValueCodec", value)
+	}
+	return b, nil
+}
+
+func (bytesCodec) Unmarshal(b []byte) (interface{}, error) {
+	return b, nil
+}
+
+// ClientSetter declares the type of function used when creating a Client to change the instance's
+// behavior.
+type ClientSetter func(*Client) error
+
+// WithValueCodec is used to specify how the values a Client stores and loads are converted to and
+// from bytes. When not specified, only []byte values may be stored.
+func WithValueCodec(vc ValueCodec) ClientSetter {
+	return func(c *Client) error {
+		c.values = vc
+		return nil
+	}
+}
+
+// WithErrorHandler is used to specify the function invoked with errors encountered by the methods
+// of the Congomap interface that cannot return an error, such as Store and Delete. When not
+// specified, those errors are discarded.
+func WithErrorHandler(handler func(error)) ClientSetter {
+	return func(c *Client) error {
+		c.onError = handler
+		return nil
+	}
+}
+
+// WithTimeout is used to specify how long each remote call may take. When not specified, remote
+// calls do not time out.
+func WithTimeout(duration time.Duration) ClientSetter {
+	return func(c *Client) error {
+		if duration <= 0 {
+			return congomap.ErrInvalidDuration(duration)
+		}
+		c.timeout = duration
+		return nil
+	}
+}
+
+// Client is a Congomap whose data store is a Congomap served by a remote process.
+type Client struct {
+	cc      *grpc.ClientConn
+	values  ValueCodec
+	onError func(error)
+	timeout time.Duration
+}
+
+// NewClient returns a Congomap that invokes the methods of the Congomap served by Register over the
+// specified connection. The Client's Close method closes the connection.
+//
+// The lookup callback function, reaper callback function, and default TTL are those of the served
+// Congomap, so the Lookup, Reaper, and TTL methods of a Client return an error.
+//
+//	cc, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+//	if err != nil {
+//	    panic(err)
+//	}
+//	cgm, err := remote.NewClient(cc)
+//	if err != nil {
+//	    panic(err)
+//	}
+//	defer func() { _ = cgm.Close() }()
+func NewClient(cc *grpc.ClientConn, setters ...ClientSetter) (*Client, error) {
+	c := &Client{cc: cc, values: bytesCodec{}}
+	for _, setter := range setters {
+		if err := setter(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *Client) invoke(method string, rq, rs message) error {
+	return c.invokeContext(context.Background(), method, rq, rs)
+}
+
+// invokeContext invokes the method with ctx, bounded by the timeout of the Client.
+func (c *Client) invokeContext(ctx context.Context, method string, rq, rs message) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	return c.cc.Invoke(ctx, "/"+serviceName+"/"+method, rq, rs, grpc.ForceCodec(codec{}))
+}
+
+func (c *Client) report(err error) {
+	if err != nil && c.onError != nil {
+		c.onError(err)
+	}
+}
+
+// encode marshals a value to be stored, returning the expiry of ExpiringValue values in
+// nanoseconds since the Unix epoch.
+func (c *Client) encode(value interface{}) ([]byte, int64, error) {
+	var expiry int64
+	if ev, ok := value.(*congomap.ExpiringValue); ok {
+		value = ev.Value
+		if !ev.Expiry.IsZero() {
+			expiry = ev.Expiry.UnixNano()
+		}
+	}
+	b, err := c.values.Marshal(value)
+	return b, expiry, err
+}
+
+// All iterates over the pairs streamed by Pairs.
+func (c *Client) All() func(yield func(string, interface{}) bool) {
+	return func(yield func(string, interface{}) bool) {
+		pairs := c.Pairs()
+		for p := range pairs {
+			if !yield(p.Key, p.Value) {
+				for range pairs {
+					// drain the channel so its goroutine returns
+				}
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) Clear() {
+	c.report(c.invoke("Clear", &empty{}, &empty{}))
+}
+
+func (c *Client) Close() error {
+	return c.cc.Close()
+}
+
+func (c *Client) Delete(key string) {
+	c.report(c.invoke("Delete", &keyRequest{Key: key}, &empty{}))
+}
+
+// DeleteIf loads the value associated with the key, invokes fn, and atomically removes the value
+// only when the server still holds the value that was loaded, by way of the server's
+// CompareAndSwap method, retrying otherwise.
+func (c *Client) DeleteIf(key string, fn func(interface{}) bool) bool {
+	for {
+		rs := &valueResponse{}
+		if err := c.invoke("Load", &keyRequest{Key: key}, rs); err != nil {
+			c.report(err)
+			return false
+		}
+		if !rs.OK {
+			return false
+		}
+		value, err := c.values.Unmarshal(rs.Value)
+		if err != nil {
+			c.report(err)
+			return false
+		}
+		if !fn(value) {
+			return false
+		}
+
+		cas := &compareAndSwapResponse{}
+		rq := &compareAndSwapRequest{Key: key, Old: rs.Value, OldOK: true, Delete: true}
+		if err := c.invoke("CompareAndSwap", rq, cas); err != nil {
+			c.report(err)
+			return false
+		}
+		if cas.Swapped {
+			return true
+		}
+	}
+}
+
+func (c *Client) DeleteMany(keys []string) {
+	c.report(c.invoke("DeleteMany", &keysRequest{Keys: keys}, &empty{}))
+}
+
+// Do loads the value associated with the key, invokes fn, and atomically replaces the value only
+// when the server still holds the value that was loaded. Because another client may change the
+// value between those steps, fn may be invoked more than once.
+func (c *Client) Do(key string, fn func(interface{}, bool) (interface{}, bool)) {
+	for {
+		rs := &valueResponse{}
+		if err := c.invoke("Load", &keyRequest{Key: key}, rs); err != nil {
+			c.report(err)
+			return
+		}
+
+		var value interface{}
+		if rs.OK {
+			var err error
+			if value, err = c.values.Unmarshal(rs.Value); err != nil {
+				c.report(err)
+				return
+			}
+		}
+
+		replacement, ok := fn(value, rs.OK)
+		if !ok {
+			return
+		}
+
+		b, expiry, err := c.encode(replacement)
+		if err != nil {
+			c.report(err)
+			return
+		}
+
+		cas := &compareAndSwapResponse{}
+		rq := &compareAndSwapRequest{Key: key, Old: rs.Value, OldOK: rs.OK, New: b, Expiry: expiry}
+		if err = c.invoke("CompareAndSwap", rq, cas); err != nil {
+			c.report(err)
+			return
+		}
+		if cas.Swapped {
+			return
+		}
+	}
+}
+
+func (c *Client) GC() {
+	c.report(c.invoke("GC", &empty{}, &empty{}))
+}
+
+func (c *Client) PauseGC() {
+	c.report(c.invoke("PauseGC", &empty{}, &empty{}))
+}
+
+func (c *Client) ResumeGC() {
+	c.report(c.invoke("ResumeGC", &empty{}, &empty{}))
+}
+
+func (c *Client) Prune(n int) int {
+	rs := &pruneResponse{}
+	if err := c.invoke("Prune", &pruneRequest{Count: int64(n)}, rs); err != nil {
+		c.report(err)
+		return 0
+	}
+	return int(rs.Pruned)
+}
+
+func (c *Client) Keys() []string {
+	rs := &keysResponse{}
+	if err := c.invoke("Keys", &empty{}, rs); err != nil {
+		c.report(err)
+		return nil
+	}
+	return rs.Keys
+}
+
+// AppendKeys appends the keys returned by Keys to the given slice. The keys are still decoded into
+// a new slice first, so it only spares the caller from having to append them itself.
+func (c *Client) AppendKeys(buf []string) []string {
+	return append(buf, c.Keys()...)
+}
+
+// KeysPage returns a page of the keys returned by Keys, so unlike the Congomaps provided by package
+// congomap, it transfers every key from the server for each page.
+func (c *Client) KeysPage(cursor string, limit int) ([]string, string) {
+	keys := c.Keys()
+	sort.Strings(keys)
+	i := sort.SearchStrings(keys, cursor)
+	if i < len(keys) && keys[i] == cursor && cursor != "" {
+		i++
+	}
+	keys = keys[i:]
+	if limit < 0 {
+		limit = 0
+	}
+	if len(keys) <= limit {
+		return keys, ""
+	}
+	keys = keys[:limit]
+	if limit == 0 {
+		return keys, ""
+	}
+	return keys, keys[limit-1]
+}
+
+// KeysMatching filters the keys returned by Keys, because the function cannot be sent to the
+// server. Prefer KeysWithPrefix when it suffices, which the server filters.
+func (c *Client) KeysMatching(match func(string) bool) []string {
+	var keys []string
+	for _, key := range c.Keys() {
+		if match(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// KeysWithPrefix returns the keys that begin with the specified prefix, which the server filters,
+// so only those keys are transferred.
+func (c *Client) KeysWithPrefix(prefix string) []string {
+	rs := &keysResponse{}
+	if err := c.invoke("KeysWithPrefix", &keyRequest{Key: prefix}, rs); err != nil {
+		c.report(err)
+		return nil
+	}
+	return rs.Keys
+}
+
+func (c *Client) Expire(key string, duration time.Duration) bool {
+	return c.expire(&expireRequest{Key: key, Duration: int64(duration)})
+}
+
+func (c *Client) Persist(key string) bool {
+	return c.expire(&expireRequest{Key: key, Persist: true})
+}
+
+func (c *Client) Touch(key string) bool {
+	rs := &expireResponse{}
+	if err := c.invoke("Touch", &keyRequest{Key: key}, rs); err != nil {
+		c.report(err)
+		return false
+	}
+	return rs.Found
+}
+
+func (c *Client) TTLRemaining(key string) (time.Duration, bool) {
+	rs := &ttlRemainingResponse{}
+	if err := c.invoke("TTLRemaining", &keyRequest{Key: key}, rs); err != nil {
+		c.report(err)
+		return 0, false
+	}
+	return time.Duration(rs.Remaining), rs.Found
+}
+
+func (c *Client) expire(rq *expireRequest) bool {
+	rs := &expireResponse{}
+	if err := c.invoke("Expire", rq, rs); err != nil {
+		c.report(err)
+		return false
+	}
+	return rs.Found
+}
+
+func (c *Client) Len() int {
+	rs := &lenResponse{}
+	if err := c.invoke("Len", &empty{}, rs); err != nil {
+		c.report(err)
+		return 0
+	}
+	return int(rs.Len)
+}
+
+func (c *Client) Load(key string) (interface{}, bool) {
+	rs := &valueResponse{}
+	if err := c.invoke("Load", &keyRequest{Key: key}, rs); err != nil {
+		c.report(err)
+		return nil, false
+	}
+	if !rs.OK {
+		return nil, false
+	}
+	value, err := c.values.Unmarshal(rs.Value)
+	if err != nil {
+		c.report(err)
+		return nil, false
+	}
+	return value, true
+}
+
+func (c *Client) LoadAndDelete(key string) (interface{}, bool) {
+	rs := &valueResponse{}
+	if err := c.invoke("LoadAndDelete", &keyRequest{Key: key}, rs); err != nil {
+		c.report(err)
+		return nil, false
+	}
+	if !rs.OK {
+		return nil, false
+	}
+	value, err := c.values.Unmarshal(rs.Value)
+	if err != nil {
+		c.report(err)
+		return nil, false
+	}
+	return value, true
+}
+
+func (c *Client) LoadStore(key string) (interface{}, error) {
+	value, _, err := c.LoadStoreInfo(key)
+	return value, err
+}
+
+func (c *Client) LoadStoreInfo(key string) (interface{}, bool, error) {
+	return c.loadStoreContext(context.Background(), key)
+}
+
+// LoadStoreContext behaves like LoadStore, and returns an error when ctx is done before the server
+// responds. The lookup callback function of the served Congomap is not passed ctx.
+func (c *Client) LoadStoreContext(ctx context.Context, key string) (interface{}, error) {
+	value, _, err := c.loadStoreContext(ctx, key)
+	return value, err
+}
+
+// LoadStoreWith returns the live value the server holds for the key, or invokes the lookup function
+// and stores the value it returns, as LoadOrStore would. The function cannot be sent to the server,
+// so unlike LoadStore, concurrent lookups for the same key are not serialized, though only one value
+// is stored.
+func (c *Client) LoadStoreWith(key string, lookup func(string) (interface{}, error)) (interface{}, error) {
+	if value, ok := c.Load(key); ok {
+		return value, nil
+	}
+	value, err := lookup(key)
+	if err != nil {
+		return nil, congomap.ErrLookupFailed{Key: key, Err: err}
+	}
+	if actual, loaded := c.LoadOrStore(key, value); loaded {
+		return actual, nil
+	}
+	return value, nil
+}
+
+func (c *Client) loadStoreContext(ctx context.Context, key string) (interface{}, bool, error) {
+	rs := &valueResponse{}
+	if err := c.invokeContext(ctx, "LoadStore", &keyRequest{Key: key}, rs); err != nil {
+		return nil, false, err
+	}
+	value, err := c.values.Unmarshal(rs.Value)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, rs.Looked, nil
+}
+
+// LoadMany returns the values the server holds for the keys, omitting values that cannot be
+// decoded, each of which is reported to the error handler.
+func (c *Client) LoadMany(keys []string) map[string]interface{} {
+	rs := &pairsResponse{}
+	if err := c.invoke("LoadMany", &keysRequest{Keys: keys}, rs); err != nil {
+		c.report(err)
+		return map[string]interface{}{}
+	}
+	values := make(map[string]interface{}, len(rs.Pairs))
+	for _, p := range rs.Pairs {
+		value, err := c.values.Unmarshal(p.Value)
+		if err != nil {
+			c.report(err)
+			continue
+		}
+		values[p.Key] = value
+	}
+	return values
+}
+
+// maxLoadStoreAllConcurrency is the most keys LoadStoreAll resolves at a time, matching the
+// Congomaps provided by the congomap package.
+const maxLoadStoreAllConcurrency = 64
+
+func (c *Client) LoadStoreAll(keys []string) map[string]congomap.LoadStoreResult {
+	unique := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		unique[key] = struct{}{}
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(len(unique))
+
+	results := make(map[string]congomap.LoadStoreResult, len(unique))
+	limit := make(chan struct{}, maxLoadStoreAllConcurrency)
+
+	for key := range unique {
+		limit <- struct{}{}
+		go func(key string) {
+			value, err := c.LoadStore(key)
+			lock.Lock()
+			results[key] = congomap.LoadStoreResult{Value: value, Err: err}
+			lock.Unlock()
+			<-limit
+			wg.Done()
+		}(key)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// MaintainOnce asks the server to garbage collect its Congomap, which is all the maintenance a
+// Client can request.
+func (c *Client) MaintainOnce() {
+	c.GC()
+}
+
+func (c *Client) NextExpiry() (time.Time, bool) {
+	rs := &nextExpiryResponse{}
+	if err := c.invoke("NextExpiry", &empty{}, rs); err != nil {
+		c.report(err)
+		return time.Time{}, false
+	}
+	if !rs.OK {
+		return time.Time{}, false
+	}
+	return time.Unix(0, rs.Expiry), true
+}
+
+func (c *Client) Pairs() <-chan congomap.Pair {
+	return c.pairs(&serviceDesc.Streams[0])
+}
+
+func (c *Client) PairsByExpiry() <-chan congomap.Pair {
+	return c.pairs(&serviceDesc.Streams[1])
+}
+
+// pairs returns a channel through which the pairs streamed by the described method are read.
+func (c *Client) pairs(desc *grpc.StreamDesc) <-chan congomap.Pair {
+	pairs := make(chan congomap.Pair)
+
+	go func(pairs chan<- congomap.Pair) {
+		defer close(pairs)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		stream, err := c.cc.NewStream(ctx, desc, "/"+serviceName+"/"+desc.StreamName, grpc.ForceCodec(codec{}))
+		if err == nil {
+			err = stream.SendMsg(&empty{})
+		}
+		if err == nil {
+			err = stream.CloseSend()
+		}
+		for err == nil {
+			p := &pair{}
+			if err = stream.RecvMsg(p); err != nil {
+				break
+			}
+			var value interface{}
+			if value, err = c.values.Unmarshal(p.Value); err != nil {
+				break
+			}
+			pairs <- congomap.Pair{Key: p.Key, Value: value}
+		}
+		if !errors.Is(err, io.EOF) {
+			c.report(err)
+		}
+	}(pairs)
+
+	return pairs
+}
+
+// Snapshot gathers the pairs streamed by Pairs.
+func (c *Client) Snapshot() map[string]interface{} {
+	m := make(map[string]interface{})
+	for p := range c.Pairs() {
+		m[p.Key] = p.Value
+	}
+	return m
+}
+
+func (c *Client) Store(key string, value interface{}) {
+	b, expiry, err := c.encode(value)
+	if err != nil {
+		c.report(err)
+		return
+	}
+	c.report(c.invoke("Store", &storeRequest{Key: key, Value: b, Expiry: expiry}, &empty{}))
+}
+
+// StoreMany sends every value in a single request, omitting values that cannot be encoded, each of
+// which is reported to the error handler.
+func (c *Client) StoreMany(pairs map[string]interface{}) {
+	rq := &storeManyRequest{Entries: make([]*storeRequest, 0, len(pairs))}
+	for key, value := range pairs {
+		b, expiry, err := c.encode(value)
+		if err != nil {
+			c.report(err)
+			continue
+		}
+		rq.Entries = append(rq.Entries, &storeRequest{Key: key, Value: b, Expiry: expiry})
+	}
+	c.report(c.invoke("StoreMany", rq, &empty{}))
+}
+
+// Update loads the value associated with the key, invokes fn, and atomically replaces or removes
+// the value only when it has not changed in the meantime, by way of the server's CompareAndSwap
+// method, retrying otherwise.
+func (c *Client) Update(key string, fn func(interface{}, bool) (interface{}, bool)) {
+	for {
+		rs := &valueResponse{}
+		if err := c.invoke("Load", &keyRequest{Key: key}, rs); err != nil {
+			c.report(err)
+			return
+		}
+
+		var value interface{}
+		if rs.OK {
+			var err error
+			if value, err = c.values.Unmarshal(rs.Value); err != nil {
+				c.report(err)
+				return
+			}
+		}
+
+		replacement, keep := fn(value, rs.OK)
+		if !keep && !rs.OK {
+			return // nothing to remove
+		}
+
+		rq := &compareAndSwapRequest{Key: key, Old: rs.Value, OldOK: rs.OK, Delete: !keep}
+		if keep {
+			var err error
+			if rq.New, rq.Expiry, err = c.encode(replacement); err != nil {
+				c.report(err)
+				return
+			}
+		}
+
+		cas := &compareAndSwapResponse{}
+		if err := c.invoke("CompareAndSwap", rq, cas); err != nil {
+			c.report(err)
+			return
+		}
+		if cas.Swapped {
+			return
+		}
+	}
+}
+
+// LoadOrStore stores the value only when the key has no value, by way of the server's
+// CompareAndSwap method, and otherwise loads the value the key has, retrying when the key is stored
+// or deleted in between.
+func (c *Client) LoadOrStore(key string, value interface{}) (interface{}, bool) {
+	b, expiry, err := c.encode(value)
+	if err != nil {
+		c.report(err)
+		return nil, false
+	}
+	if ev, ok := value.(*congomap.ExpiringValue); ok {
+		value = ev.Value
+	}
+	for {
+		cas := &compareAndSwapResponse{}
+		rq := &compareAndSwapRequest{Key: key, New: b, Expiry: expiry}
+		if err = c.invoke("CompareAndSwap", rq, cas); err != nil {
+			c.report(err)
+			return nil, false
+		}
+		if cas.Swapped {
+			return value, false
+		}
+		if actual, ok := c.Load(key); ok {
+			return actual, true
+		}
+	}
+}
+
+func (c *Client) StoreWithTTL(key string, value interface{}, ttl time.Duration) {
+	if ev, ok := value.(*congomap.ExpiringValue); ok {
+		value = ev.Value
+	}
+	if ttl > 0 {
+		value = &congomap.ExpiringValue{Value: value, Expiry: time.Now().Add(ttl)}
+	}
+	c.Store(key, value)
+}
+
+// Swap is StoreReturning.
+func (c *Client) Swap(key string, value interface{}) (interface{}, bool) {
+	return c.StoreReturning(key, value)
+}
+
+// StoreReturning loads the value associated with the key, and atomically replaces it only when the
+// server still holds the value that was loaded, retrying when another client changed it first.
+func (c *Client) StoreReturning(key string, value interface{}) (interface{}, bool) {
+	b, expiry, err := c.encode(value)
+	if err != nil {
+		c.report(err)
+		return nil, false
+	}
+	for {
+		rs := &valueResponse{}
+		if err = c.invoke("Load", &keyRequest{Key: key}, rs); err != nil {
+			c.report(err)
+			return nil, false
+		}
+
+		cas := &compareAndSwapResponse{}
+		rq := &compareAndSwapRequest{Key: key, Old: rs.Value, OldOK: rs.OK, New: b, Expiry: expiry}
+		if err = c.invoke("CompareAndSwap", rq, cas); err != nil {
+			c.report(err)
+			return nil, false
+		}
+		if !cas.Swapped {
+			continue
+		}
+		if !rs.OK {
+			return nil, false
+		}
+		prev, err := c.values.Unmarshal(rs.Value)
+		if err != nil {
+			c.report(err)
+			return nil, false
+		}
+		return prev, true
+	}
+}
+
+// Lookup returns ErrUnsupportedOption, because the lookup callback function is that of the served
+// Congomap.
+func (c *Client) Lookup(func(string) (interface{}, error)) error {
+	return congomap.ErrUnsupportedOption{}
+}
+
+// Reaper returns ErrUnsupportedOption, because the reaper callback function is that of the served
+// Congomap.
+func (c *Client) Reaper(func(interface{})) error {
+	return congomap.ErrUnsupportedOption{}
+}
+
+// TTL returns ErrUnsupportedOption, because the default TTL is that of the served Congomap.
+func (c *Client) TTL(time.Duration) error {
+	return congomap.ErrUnsupportedOption{}
+}
+
+var _ congomap.Congomap = (*Client)(nil)
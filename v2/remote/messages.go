@@ -0,0 +1,538 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The message types below correspond to the messages declared in congomap.proto. They encode
+// themselves in the protocol buffer wire format, so clients in other languages may be generated
+// from congomap.proto.
+
+type message interface {
+	marshal() []byte
+	unmarshal([]byte) error
+}
+
+// codec is the gRPC codec used by both Client and Server, which encodes the message types of this
+// package. It is not named "proto", because its messages are encoded by hand rather than generated
+// from congomap.proto, so it is not mistaken for the standard protocol buffer codec.
+type codec struct{}
+
+func (codec) Name() string { return "congomap" }
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("remote: cannot marshal %T", v)
+	}
+	return m.marshal(), nil
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("remote: cannot unmarshal %T", v)
+	}
+	return m.unmarshal(data)
+}
+
+var errMalformed = errors.New("remote: malformed message")
+
+// fields invokes fn with each field of a protocol buffer encoded message. fn returns the number of
+// bytes it consumed, or a negative number when the field is malformed. Fields fn does not consume
+// are skipped.
+func fields(b []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) int) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errMalformed
+		}
+		b = b[n:]
+		n = fn(num, typ, b)
+		if n == 0 {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return errMalformed
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	return appendVarint(b, num, protowire.EncodeBool(v))
+}
+
+// appendMessage appends the message as an embedded field, even when it is empty, so that repeated
+// fields keep every element.
+func appendMessage(b []byte, num protowire.Number, m message) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m.marshal())
+}
+
+func consumeString(typ protowire.Type, b []byte, s *string) int {
+	if typ != protowire.BytesType {
+		return 0
+	}
+	v, n := protowire.ConsumeString(b)
+	*s = v
+	return n
+}
+
+func consumeBytes(typ protowire.Type, b []byte, v *[]byte) int {
+	if typ != protowire.BytesType {
+		return 0
+	}
+	raw, n := protowire.ConsumeBytes(b)
+	*v = append([]byte(nil), raw...)
+	return n
+}
+
+func consumeVarint(typ protowire.Type, b []byte, v *uint64) int {
+	if typ != protowire.VarintType {
+		return 0
+	}
+	x, n := protowire.ConsumeVarint(b)
+	*v = x
+	return n
+}
+
+func consumeBool(typ protowire.Type, b []byte, v *bool) int {
+	var x uint64
+	n := consumeVarint(typ, b, &x)
+	*v = protowire.DecodeBool(x)
+	return n
+}
+
+func consumeInt64(typ protowire.Type, b []byte, v *int64) int {
+	var x uint64
+	n := consumeVarint(typ, b, &x)
+	*v = int64(x)
+	return n
+}
+
+// consumeMessage unmarshals an embedded field into the message.
+func consumeMessage(typ protowire.Type, b []byte, m message) int {
+	if typ != protowire.BytesType {
+		return 0
+	}
+	raw, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return n
+	}
+	if err := m.unmarshal(raw); err != nil {
+		return -1
+	}
+	return n
+}
+
+type empty struct{}
+
+func (*empty) marshal() []byte { return nil }
+
+func (*empty) unmarshal(b []byte) error {
+	return fields(b, func(protowire.Number, protowire.Type, []byte) int { return 0 })
+}
+
+type keyRequest struct {
+	Key string
+}
+
+func (m *keyRequest) marshal() []byte {
+	return appendString(nil, 1, m.Key)
+}
+
+func (m *keyRequest) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if num == 1 {
+			return consumeString(typ, b, &m.Key)
+		}
+		return 0
+	})
+}
+
+type keysRequest struct {
+	Keys []string
+}
+
+func (m *keysRequest) marshal() []byte {
+	return (*keysResponse)(m).marshal()
+}
+
+func (m *keysRequest) unmarshal(b []byte) error {
+	return (*keysResponse)(m).unmarshal(b)
+}
+
+type keysResponse struct {
+	Keys []string
+}
+
+func (m *keysResponse) marshal() []byte {
+	var b []byte
+	for _, key := range m.Keys {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, key)
+	}
+	return b
+}
+
+func (m *keysResponse) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if num == 1 {
+			var key string
+			n := consumeString(typ, b, &key)
+			if n > 0 {
+				m.Keys = append(m.Keys, key)
+			}
+			return n
+		}
+		return 0
+	})
+}
+
+type valueResponse struct {
+	Value  []byte
+	OK     bool
+	Looked bool
+}
+
+func (m *valueResponse) marshal() []byte {
+	b := appendBytes(nil, 1, m.Value)
+	b = appendBool(b, 2, m.OK)
+	return appendBool(b, 3, m.Looked)
+}
+
+func (m *valueResponse) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return consumeBytes(typ, b, &m.Value)
+		case 2:
+			return consumeBool(typ, b, &m.OK)
+		case 3:
+			return consumeBool(typ, b, &m.Looked)
+		}
+		return 0
+	})
+}
+
+type nextExpiryResponse struct {
+	Expiry int64
+	OK     bool
+}
+
+func (m *nextExpiryResponse) marshal() []byte {
+	b := appendVarint(nil, 1, uint64(m.Expiry))
+	return appendBool(b, 2, m.OK)
+}
+
+func (m *nextExpiryResponse) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return consumeInt64(typ, b, &m.Expiry)
+		case 2:
+			return consumeBool(typ, b, &m.OK)
+		}
+		return 0
+	})
+}
+
+type lenResponse struct {
+	Len int64
+}
+
+func (m *lenResponse) marshal() []byte {
+	return appendVarint(nil, 1, uint64(m.Len))
+}
+
+func (m *lenResponse) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if num == 1 {
+			return consumeInt64(typ, b, &m.Len)
+		}
+		return 0
+	})
+}
+
+type pruneRequest struct {
+	Count int64
+}
+
+func (m *pruneRequest) marshal() []byte {
+	return appendVarint(nil, 1, uint64(m.Count))
+}
+
+func (m *pruneRequest) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if num == 1 {
+			return consumeInt64(typ, b, &m.Count)
+		}
+		return 0
+	})
+}
+
+type pruneResponse struct {
+	Pruned int64
+}
+
+func (m *pruneResponse) marshal() []byte {
+	return appendVarint(nil, 1, uint64(m.Pruned))
+}
+
+func (m *pruneResponse) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if num == 1 {
+			return consumeInt64(typ, b, &m.Pruned)
+		}
+		return 0
+	})
+}
+
+type storeRequest struct {
+	Key    string
+	Value  []byte
+	Expiry int64
+}
+
+func (m *storeRequest) marshal() []byte {
+	b := appendString(nil, 1, m.Key)
+	b = appendBytes(b, 2, m.Value)
+	return appendVarint(b, 3, uint64(m.Expiry))
+}
+
+func (m *storeRequest) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return consumeString(typ, b, &m.Key)
+		case 2:
+			return consumeBytes(typ, b, &m.Value)
+		case 3:
+			return consumeInt64(typ, b, &m.Expiry)
+		}
+		return 0
+	})
+}
+
+type storeManyRequest struct {
+	Entries []*storeRequest
+}
+
+func (m *storeManyRequest) marshal() []byte {
+	var b []byte
+	for _, entry := range m.Entries {
+		b = appendMessage(b, 1, entry)
+	}
+	return b
+}
+
+func (m *storeManyRequest) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if num == 1 {
+			entry := &storeRequest{}
+			n := consumeMessage(typ, b, entry)
+			if n > 0 {
+				m.Entries = append(m.Entries, entry)
+			}
+			return n
+		}
+		return 0
+	})
+}
+
+type compareAndSwapRequest struct {
+	Key    string
+	Old    []byte
+	OldOK  bool
+	New    []byte
+	Expiry int64
+	Delete bool
+}
+
+func (m *compareAndSwapRequest) marshal() []byte {
+	b := appendString(nil, 1, m.Key)
+	b = appendBytes(b, 2, m.Old)
+	b = appendBool(b, 3, m.OldOK)
+	b = appendBytes(b, 4, m.New)
+	b = appendVarint(b, 5, uint64(m.Expiry))
+	return appendBool(b, 6, m.Delete)
+}
+
+func (m *compareAndSwapRequest) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return consumeString(typ, b, &m.Key)
+		case 2:
+			return consumeBytes(typ, b, &m.Old)
+		case 3:
+			return consumeBool(typ, b, &m.OldOK)
+		case 4:
+			return consumeBytes(typ, b, &m.New)
+		case 5:
+			return consumeInt64(typ, b, &m.Expiry)
+		case 6:
+			return consumeBool(typ, b, &m.Delete)
+		}
+		return 0
+	})
+}
+
+type expireRequest struct {
+	Key      string
+	Duration int64
+	Persist  bool
+}
+
+func (m *expireRequest) marshal() []byte {
+	b := appendString(nil, 1, m.Key)
+	b = appendVarint(b, 2, uint64(m.Duration))
+	return appendBool(b, 3, m.Persist)
+}
+
+func (m *expireRequest) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return consumeString(typ, b, &m.Key)
+		case 2:
+			return consumeInt64(typ, b, &m.Duration)
+		case 3:
+			return consumeBool(typ, b, &m.Persist)
+		}
+		return 0
+	})
+}
+
+type expireResponse struct {
+	Found bool
+}
+
+func (m *expireResponse) marshal() []byte {
+	return appendBool(nil, 1, m.Found)
+}
+
+func (m *expireResponse) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if num == 1 {
+			return consumeBool(typ, b, &m.Found)
+		}
+		return 0
+	})
+}
+
+type ttlRemainingResponse struct {
+	Remaining int64
+	Found     bool
+}
+
+func (m *ttlRemainingResponse) marshal() []byte {
+	b := appendVarint(nil, 1, uint64(m.Remaining))
+	return appendBool(b, 2, m.Found)
+}
+
+func (m *ttlRemainingResponse) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return consumeInt64(typ, b, &m.Remaining)
+		case 2:
+			return consumeBool(typ, b, &m.Found)
+		}
+		return 0
+	})
+}
+
+type compareAndSwapResponse struct {
+	Swapped bool
+}
+
+func (m *compareAndSwapResponse) marshal() []byte {
+	return appendBool(nil, 1, m.Swapped)
+}
+
+func (m *compareAndSwapResponse) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if num == 1 {
+			return consumeBool(typ, b, &m.Swapped)
+		}
+		return 0
+	})
+}
+
+type pair struct {
+	Key   string
+	Value []byte
+}
+
+func (m *pair) marshal() []byte {
+	b := appendString(nil, 1, m.Key)
+	return appendBytes(b, 2, m.Value)
+}
+
+func (m *pair) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return consumeString(typ, b, &m.Key)
+		case 2:
+			return consumeBytes(typ, b, &m.Value)
+		}
+		return 0
+	})
+}
+
+type pairsResponse struct {
+	Pairs []*pair
+}
+
+func (m *pairsResponse) marshal() []byte {
+	var b []byte
+	for _, p := range m.Pairs {
+		b = appendMessage(b, 1, p)
+	}
+	return b
+}
+
+func (m *pairsResponse) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if num == 1 {
+			p := &pair{}
+			n := consumeMessage(typ, b, p)
+			if n > 0 {
+				m.Pairs = append(m.Pairs, p)
+			}
+			return n
+		}
+		return 0
+	})
+}
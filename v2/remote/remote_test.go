@@ -0,0 +1,378 @@
+package remote_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+	"github.com/karrick/congomap/v2/remote"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var errLookupFailed = errors.New("lookup failed")
+
+type intCodec struct{}
+
+func (intCodec) Marshal(value interface{}) ([]byte, error) {
+	return []byte(strconv.Itoa(value.(int))), nil
+}
+
+func (intCodec) Unmarshal(b []byte) (interface{}, error) {
+	return strconv.Atoi(string(b))
+}
+
+// newClient returns a Client connected to a server for the specified Congomap.
+func newClient(t *testing.T, served congomap.Congomap) *remote.Client {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(remote.ServerCodec())
+	remote.Register(server, served)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	cc, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cgm, err := remote.NewClient(cc, remote.WithValueCodec(intCodec{}), remote.WithErrorHandler(func(err error) { t.Error(err) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cgm.Close() })
+	return cgm
+}
+
+func TestClient(t *testing.T) {
+	served, err := congomap.NewSyncMutexMap(congomap.Lookup(func(key string) (interface{}, error) {
+		if key == "fail" {
+			return nil, errLookupFailed
+		}
+		return []byte("42"), nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = served.Close() }()
+
+	cgm := newClient(t, served)
+
+	if value, ok := cgm.Load("miss"); ok || value != nil {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, ok, nil, false)
+	}
+
+	cgm.Store("hit", 13)
+	if value, ok := cgm.Load("hit"); !ok || value != 13 {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, ok, 13, true)
+	}
+
+	if value, err := cgm.LoadStore("lookup"); err != nil || value != 42 {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, err, 42, nil)
+	}
+	if value, looked, err := cgm.LoadStoreInfo("hit"); err != nil || looked || value != 13 {
+		t.Errorf("Actual: %#v, %#v, %#v; Expected: %#v, %#v, %#v", value, looked, err, 13, false, nil)
+	}
+	if value, looked, err := cgm.LoadStoreInfo("lookup2"); err != nil || !looked || value != 42 {
+		t.Errorf("Actual: %#v, %#v, %#v; Expected: %#v, %#v, %#v", value, looked, err, 42, true, nil)
+	}
+	cgm.Delete("lookup2")
+
+	if value, err := cgm.LoadStoreContext(context.Background(), "hit"); err != nil || value != 13 {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, err, 13, nil)
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cgm.LoadStoreContext(canceled, "hit"); err == nil {
+		t.Errorf("Actual: %#v; Expected: error", err)
+	}
+
+	fill := func(key string) (interface{}, error) { return len(key), nil }
+	if value, err := cgm.LoadStoreWith("hit", fill); err != nil || value != 13 {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, err, 13, nil)
+	}
+	if value, err := cgm.LoadStoreWith("filled", fill); err != nil || value != 6 {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, err, 6, nil)
+	}
+	if value, ok := cgm.Load("filled"); !ok || value != 6 {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, ok, 6, true)
+	}
+	cgm.Delete("filled")
+
+	if prev, existed := cgm.StoreReturning("hit", 14); prev != 13 || !existed {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", prev, existed, 13, true)
+	}
+	if prev, existed := cgm.StoreReturning("hit", 13); prev != 14 || !existed {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", prev, existed, 14, true)
+	}
+	if actual, loaded := cgm.LoadOrStore("hit", 15); actual != 13 || !loaded {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", actual, loaded, 13, true)
+	}
+	if actual, loaded := cgm.LoadOrStore("fresh", 7); actual != 7 || loaded {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", actual, loaded, 7, false)
+	}
+	if value, ok := cgm.LoadAndDelete("fresh"); value != 7 || !ok {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, ok, 7, true)
+	}
+	if value, ok := cgm.LoadAndDelete("fresh"); value != nil || ok {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, ok, nil, false)
+	}
+	increment := func(value interface{}, ok bool) (interface{}, bool) {
+		if !ok {
+			return 1, true
+		}
+		return value.(int) + 1, true
+	}
+	cgm.Update("counter", increment)
+	cgm.Update("counter", increment)
+	if value, ok := cgm.Load("counter"); value != 2 || !ok {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, ok, 2, true)
+	}
+	cgm.Update("counter", func(interface{}, bool) (interface{}, bool) { return nil, false })
+	if value, ok := cgm.Load("counter"); value != nil || ok {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, ok, nil, false)
+	}
+	if actual := cgm.Len(); actual != 2 {
+		t.Errorf("Actual: %d; Expected: %d", actual, 2)
+	}
+	if keys, next := cgm.KeysPage("", 1); len(keys) != 1 || keys[0] != "hit" || next != "hit" {
+		t.Errorf("Actual: %v, %q; Expected: %v, %q", keys, next, []string{"hit"}, "hit")
+	}
+	if keys, next := cgm.KeysPage("hit", 1); len(keys) != 1 || keys[0] != "lookup" || next != "" {
+		t.Errorf("Actual: %v, %q; Expected: %v, %q", keys, next, []string{"lookup"}, "")
+	}
+	if value, err := cgm.LoadStore("fail"); err == nil || value != nil {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, err, nil, errLookupFailed)
+	}
+
+	expiry := time.Now().Add(-time.Second)
+	cgm.Store("expired", &congomap.ExpiringValue{Value: 1, Expiry: expiry})
+	if next, ok := cgm.NextExpiry(); !ok || !next.Equal(expiry) {
+		t.Errorf("Actual: %v, %v; Expected: %v, %v", next, ok, expiry, true)
+	}
+	if value, ok := cgm.Load("expired"); ok {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, ok, nil, false)
+	}
+	cgm.GC()
+
+	cgm.Delete("lookup")
+
+	keys := cgm.Keys()
+	sort.Strings(keys)
+	if actual, expected := len(keys), 1; actual != expected || keys[0] != "hit" {
+		t.Errorf("Actual: %#v; Expected: %#v", keys, []string{"hit"})
+	}
+
+	var pairs []congomap.Pair
+	for p := range cgm.Pairs() {
+		pairs = append(pairs, p)
+	}
+	if len(pairs) != 1 || pairs[0] != (congomap.Pair{Key: "hit", Value: 13}) {
+		t.Errorf("Actual: %#v; Expected: %#v", pairs, []congomap.Pair{{Key: "hit", Value: 13}})
+	}
+
+	pairs = pairs[:0]
+	for p := range cgm.PairsByExpiry() {
+		pairs = append(pairs, p)
+	}
+	if len(pairs) != 1 || pairs[0] != (congomap.Pair{Key: "hit", Value: 13}) {
+		t.Errorf("Actual: %#v; Expected: %#v", pairs, []congomap.Pair{{Key: "hit", Value: 13}})
+	}
+
+	if err := cgm.TTL(time.Minute); err == nil {
+		t.Errorf("Actual: %#v; Expected: %#v", err, congomap.ErrUnsupportedOption{})
+	}
+
+	var yielded int
+	cgm.All()(func(key string, value interface{}) bool {
+		if key != "hit" || value != 13 {
+			t.Errorf("Actual: %q, %#v; Expected: %q, %#v", key, value, "hit", 13)
+		}
+		yielded++
+		return true
+	})
+	if yielded != 1 {
+		t.Errorf("Actual: %d; Expected: %d", yielded, 1)
+	}
+
+	cgm.StoreWithTTL("short", 1, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if value, ok := cgm.Load("short"); ok {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, ok, nil, false)
+	}
+
+	cgm.Store("expiring", 2)
+	if !cgm.Expire("expiring", -time.Second) {
+		t.Errorf("Actual: %#v; Expected: %#v", false, true)
+	}
+	if value, ok := cgm.Load("expiring"); ok {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, ok, nil, false)
+	}
+	if !cgm.Persist("hit") || cgm.Persist("expiring") {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", false, true, true, false)
+	}
+	if left, ok := cgm.TTLRemaining("hit"); left != 0 || !ok {
+		t.Errorf("Actual: %v, %#v; Expected: %v, %#v", left, ok, time.Duration(0), true)
+	}
+	if left, ok := cgm.TTLRemaining("expiring"); left != 0 || ok {
+		t.Errorf("Actual: %v, %#v; Expected: %v, %#v", left, ok, time.Duration(0), false)
+	}
+	if !cgm.Touch("hit") || cgm.Touch("expiring") {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", false, true, true, false)
+	}
+
+	cgm.StoreMany(map[string]interface{}{"one": 1, "two": 2})
+	if values := cgm.LoadMany([]string{"one", "two", "missing"}); len(values) != 2 || values["one"] != 1 || values["two"] != 2 {
+		t.Errorf("Actual: %v; Expected: %v", values, map[string]interface{}{"one": 1, "two": 2})
+	}
+	if keys := cgm.KeysWithPrefix("on"); len(keys) != 1 || keys[0] != "one" {
+		t.Errorf("Actual: %v; Expected: %v", keys, []string{"one"})
+	}
+	if keys := cgm.KeysMatching(func(key string) bool { return key == "two" }); len(keys) != 1 || keys[0] != "two" {
+		t.Errorf("Actual: %v; Expected: %v", keys, []string{"two"})
+	}
+	if snapshot := cgm.Snapshot(); len(snapshot) != 3 || snapshot["hit"] != 13 || snapshot["one"] != 1 || snapshot["two"] != 2 {
+		t.Errorf("Actual: %v; Expected: %v", snapshot, map[string]interface{}{"hit": 13, "one": 1, "two": 2})
+	}
+	cgm.DeleteMany([]string{"one", "two"})
+	if values := cgm.LoadMany([]string{"one", "two"}); len(values) != 0 {
+		t.Errorf("Actual: %v; Expected: %v", values, map[string]interface{}{})
+	}
+
+	cgm.Clear()
+	if actual := served.Len(); actual != 0 {
+		t.Errorf("Actual: %d; Expected: %d", actual, 0)
+	}
+}
+
+func TestClientUpdateLeavesValueChangedByAnotherClient(t *testing.T) {
+	var reaped int32
+	served, err := congomap.NewSyncMutexMap(congomap.TTL(time.Minute), congomap.Reaper(func(interface{}) { atomic.AddInt32(&reaped, 1) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = served.Close() }()
+
+	cgm := newClient(t, served)
+	served.Store("k", []byte("1"))
+
+	var calls int
+	cgm.Update("k", func(value interface{}, ok bool) (interface{}, bool) {
+		calls++
+		if calls == 1 {
+			// another client stores a value before this one is removed, so the removal fails
+			served.Store("k", &congomap.ExpiringValue{Value: []byte("2"), Expiry: time.Now().Add(time.Hour)})
+			return nil, false
+		}
+		if value != 2 {
+			t.Errorf("Actual: %#v; Expected: %#v", value, 2)
+		}
+		if remaining, ok := served.TTLRemaining("k"); !ok || remaining < 59*time.Minute {
+			t.Errorf("Actual: %v, %#v; Expected: %v, %#v", remaining, ok, time.Hour, true)
+		}
+		if actual := atomic.LoadInt32(&reaped); actual != 1 {
+			t.Errorf("Actual: %#v; Expected: %#v", actual, 1) // only the value replaced by the other client
+		}
+		return nil, false
+	})
+
+	if calls != 2 {
+		t.Errorf("Actual: %#v; Expected: %#v", calls, 2)
+	}
+	if _, ok := served.Load("k"); ok {
+		t.Errorf("Actual: %#v; Expected: %#v", ok, false)
+	}
+}
+
+func TestClientDeleteIf(t *testing.T) {
+	served, err := congomap.NewSyncMutexMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = served.Close() }()
+
+	cgm := newClient(t, served)
+	cgm.Store("k", 1)
+
+	if cgm.DeleteIf("k", func(value interface{}) bool { return value == 2 }) {
+		t.Errorf("Actual: %#v; Expected: %#v", true, false)
+	}
+	if !cgm.DeleteIf("k", func(value interface{}) bool { return value == 1 }) {
+		t.Errorf("Actual: %#v; Expected: %#v", false, true)
+	}
+	if _, ok := served.Load("k"); ok {
+		t.Errorf("Actual: %#v; Expected: %#v", ok, false)
+	}
+}
+
+func TestClientPrune(t *testing.T) {
+	served, err := congomap.NewSyncMutexMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = served.Close() }()
+
+	cgm := newClient(t, served)
+	cgm.Store("a", 1)
+	cgm.Store("b", 2)
+	cgm.Store("c", 3)
+
+	if n := cgm.Prune(2); n != 2 {
+		t.Errorf("Actual: %#v; Expected: %#v", n, 2)
+	}
+	if actual := served.Len(); actual != 1 {
+		t.Errorf("Actual: %#v; Expected: %#v", actual, 1)
+	}
+}
+
+func TestClientPauseGC(t *testing.T) {
+	served, err := congomap.NewSyncMutexMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = served.Close() }()
+
+	// the client reports any error from the server to the test
+	cgm := newClient(t, served)
+	cgm.PauseGC()
+	cgm.ResumeGC()
+}
+
+func TestClientDo(t *testing.T) {
+	served, err := congomap.NewSyncMutexMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = served.Close() }()
+
+	cgm := newClient(t, served)
+
+	const tasks = 20
+
+	var wg sync.WaitGroup
+	wg.Add(tasks)
+	for i := 0; i < tasks; i++ {
+		go func() {
+			cgm.Do("counter", func(value interface{}, ok bool) (interface{}, bool) {
+				if !ok {
+					return 1, true
+				}
+				return value.(int) + 1, true
+			})
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	if value, ok := cgm.Load("counter"); !ok || value != tasks {
+		t.Errorf("Actual: %#v; Expected: %#v", value, tasks)
+	}
+}
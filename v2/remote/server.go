@@ -0,0 +1,347 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ServerCodec returns the grpc.ServerOption a grpc.Server must be created with in order to serve
+// Congomaps with Register.
+func ServerCodec() grpc.ServerOption {
+	return grpc.ForceServerCodec(codec{})
+}
+
+// Register registers the Congomap service on the grpc.Server, serving the specified Congomap. The
+// grpc.Server must be created with ServerCodec.
+//
+// Values are exchanged as opaque bytes, so every value stored in the served Congomap, including
+// those returned by its Lookup callback function, ought to be a []byte.
+//
+//	cgm, err := congomap.NewTwoLevelMap()
+//	if err != nil {
+//	    panic(err)
+//	}
+//	defer func() { _ = cgm.Close() }()
+//
+//	server := grpc.NewServer(remote.ServerCodec())
+//	remote.Register(server, cgm)
+//	err = server.Serve(listener)
+func Register(server *grpc.Server, cgm congomap.Congomap) {
+	server.RegisterService(&serviceDesc, &service{cgm: cgm})
+}
+
+type service struct {
+	cgm congomap.Congomap
+}
+
+func (s *service) clear(context.Context, *empty) (*empty, error) {
+	s.cgm.Clear()
+	return &empty{}, nil
+}
+
+func (s *service) compareAndSwap(_ context.Context, rq *compareAndSwapRequest) (*compareAndSwapResponse, error) {
+	if rq.Delete {
+		if !rq.OldOK {
+			return &compareAndSwapResponse{}, nil // nothing to remove
+		}
+		swapped := s.cgm.DeleteIf(rq.Key, func(value interface{}) bool {
+			old, isBytes := value.([]byte)
+			return isBytes && bytes.Equal(old, rq.Old)
+		})
+		return &compareAndSwapResponse{Swapped: swapped}, nil
+	}
+	var swapped bool
+	s.cgm.Do(rq.Key, func(value interface{}, ok bool) (interface{}, bool) {
+		if ok != rq.OldOK {
+			return nil, false
+		}
+		if ok {
+			if old, isBytes := value.([]byte); !isBytes || !bytes.Equal(old, rq.Old) {
+				return nil, false
+			}
+		}
+		swapped = true
+		return expiringValue(rq.New, rq.Expiry), true
+	})
+	return &compareAndSwapResponse{Swapped: swapped}, nil
+}
+
+func (s *service) delete(_ context.Context, rq *keyRequest) (*empty, error) {
+	s.cgm.Delete(rq.Key)
+	return &empty{}, nil
+}
+
+func (s *service) deleteMany(_ context.Context, rq *keysRequest) (*empty, error) {
+	s.cgm.DeleteMany(rq.Keys)
+	return &empty{}, nil
+}
+
+func (s *service) expire(_ context.Context, rq *expireRequest) (*expireResponse, error) {
+	if rq.Persist {
+		return &expireResponse{Found: s.cgm.Persist(rq.Key)}, nil
+	}
+	return &expireResponse{Found: s.cgm.Expire(rq.Key, time.Duration(rq.Duration))}, nil
+}
+
+func (s *service) gc(context.Context, *empty) (*empty, error) {
+	s.cgm.GC()
+	return &empty{}, nil
+}
+
+func (s *service) keys(context.Context, *empty) (*keysResponse, error) {
+	return &keysResponse{Keys: s.cgm.Keys()}, nil
+}
+
+func (s *service) keysWithPrefix(_ context.Context, rq *keyRequest) (*keysResponse, error) {
+	return &keysResponse{Keys: s.cgm.KeysWithPrefix(rq.Key)}, nil
+}
+
+func (s *service) len(context.Context, *empty) (*lenResponse, error) {
+	return &lenResponse{Len: int64(s.cgm.Len())}, nil
+}
+
+func (s *service) load(_ context.Context, rq *keyRequest) (*valueResponse, error) {
+	value, ok := s.cgm.Load(rq.Key)
+	if !ok {
+		return &valueResponse{}, nil
+	}
+	return valueBytes(value)
+}
+
+func (s *service) loadMany(_ context.Context, rq *keysRequest) (*pairsResponse, error) {
+	rs := &pairsResponse{}
+	for key, value := range s.cgm.LoadMany(rq.Keys) {
+		b, ok := value.([]byte)
+		if !ok {
+			return nil, status.Errorf(codes.Internal, "value of key %q is %T rather than []byte", key, value)
+		}
+		rs.Pairs = append(rs.Pairs, &pair{Key: key, Value: b})
+	}
+	return rs, nil
+}
+
+func (s *service) loadAndDelete(_ context.Context, rq *keyRequest) (*valueResponse, error) {
+	value, ok := s.cgm.LoadAndDelete(rq.Key)
+	if !ok {
+		return &valueResponse{}, nil
+	}
+	return valueBytes(value)
+}
+
+func (s *service) loadStore(_ context.Context, rq *keyRequest) (*valueResponse, error) {
+	value, looked, err := s.cgm.LoadStoreInfo(rq.Key)
+	if err != nil {
+		return nil, status.Error(codes.Unknown, err.Error())
+	}
+	rs, err := valueBytes(value)
+	if err != nil {
+		return nil, err
+	}
+	rs.Looked = looked
+	return rs, nil
+}
+
+func (s *service) nextExpiry(_ context.Context, _ *empty) (*nextExpiryResponse, error) {
+	next, ok := s.cgm.NextExpiry()
+	if !ok {
+		return &nextExpiryResponse{}, nil
+	}
+	return &nextExpiryResponse{Expiry: next.UnixNano(), OK: true}, nil
+}
+
+func (s *service) pauseGC(context.Context, *empty) (*empty, error) {
+	s.cgm.PauseGC()
+	return &empty{}, nil
+}
+
+func (s *service) resumeGC(context.Context, *empty) (*empty, error) {
+	s.cgm.ResumeGC()
+	return &empty{}, nil
+}
+
+func (s *service) prune(_ context.Context, rq *pruneRequest) (*pruneResponse, error) {
+	return &pruneResponse{Pruned: int64(s.cgm.Prune(int(rq.Count)))}, nil
+}
+
+func (s *service) pairs(_ *empty, stream grpc.ServerStream) error {
+	return sendPairs(s.cgm.Pairs(), stream)
+}
+
+func (s *service) pairsByExpiry(_ *empty, stream grpc.ServerStream) error {
+	return sendPairs(s.cgm.PairsByExpiry(), stream)
+}
+
+func sendPairs(pairs <-chan congomap.Pair, stream grpc.ServerStream) error {
+	var err error
+	for p := range pairs {
+		if err != nil {
+			continue // drain channel so the Congomap is released
+		}
+		value, ok := p.Value.([]byte)
+		if !ok {
+			err = status.Errorf(codes.Internal, "value of key %q is %T rather than []byte", p.Key, p.Value)
+			continue
+		}
+		err = stream.SendMsg(&pair{Key: p.Key, Value: value})
+	}
+	return err
+}
+
+func (s *service) store(_ context.Context, rq *storeRequest) (*empty, error) {
+	s.cgm.Store(rq.Key, expiringValue(rq.Value, rq.Expiry))
+	return &empty{}, nil
+}
+
+func (s *service) touch(_ context.Context, rq *keyRequest) (*expireResponse, error) {
+	return &expireResponse{Found: s.cgm.Touch(rq.Key)}, nil
+}
+
+func (s *service) ttlRemaining(_ context.Context, rq *keyRequest) (*ttlRemainingResponse, error) {
+	left, found := s.cgm.TTLRemaining(rq.Key)
+	return &ttlRemainingResponse{Remaining: int64(left), Found: found}, nil
+}
+
+func (s *service) storeMany(_ context.Context, rq *storeManyRequest) (*empty, error) {
+	pairs := make(map[string]interface{}, len(rq.Entries))
+	for _, entry := range rq.Entries {
+		pairs[entry.Key] = expiringValue(entry.Value, entry.Expiry)
+	}
+	s.cgm.StoreMany(pairs)
+	return &empty{}, nil
+}
+
+// expiringValue returns the value to store for a request, which is wrapped in an ExpiringValue when
+// the request specifies an expiry.
+func expiringValue(value []byte, expiry int64) interface{} {
+	if expiry == 0 {
+		return value
+	}
+	return &congomap.ExpiringValue{Value: value, Expiry: time.Unix(0, expiry)}
+}
+
+func valueBytes(value interface{}) (*valueResponse, error) {
+	b, ok := value.([]byte)
+	if !ok {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("value is %T rather than []byte", value))
+	}
+	return &valueResponse{Value: b, OK: true}, nil
+}
+
+const serviceName = "congomap.remote.Congomap"
+
+// unaryHandler adapts a method of service to a grpc.MethodHandler.
+func unaryHandler(name string, newRequest func() message, fn func(*service, context.Context, message) (message, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			rq := newRequest()
+			if err := dec(rq); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return fn(srv.(*service), ctx, rq)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + name}
+			return interceptor(ctx, rq, info, func(ctx context.Context, rq interface{}) (interface{}, error) {
+				return fn(srv.(*service), ctx, rq.(message))
+			})
+		},
+	}
+}
+
+// pairsHandler adapts a method of service to a server streaming grpc.StreamDesc.
+func pairsHandler(name string, fn func(*service, *empty, grpc.ServerStream) error) grpc.StreamDesc {
+	return grpc.StreamDesc{
+		StreamName:    name,
+		ServerStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			rq := &empty{}
+			if err := stream.RecvMsg(rq); err != nil {
+				return err
+			}
+			return fn(srv.(*service), rq, stream)
+		},
+	}
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		unaryHandler("Clear", func() message { return &empty{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.clear(ctx, rq.(*empty))
+		}),
+		unaryHandler("CompareAndSwap", func() message { return &compareAndSwapRequest{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.compareAndSwap(ctx, rq.(*compareAndSwapRequest))
+		}),
+		unaryHandler("Delete", func() message { return &keyRequest{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.delete(ctx, rq.(*keyRequest))
+		}),
+		unaryHandler("DeleteMany", func() message { return &keysRequest{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.deleteMany(ctx, rq.(*keysRequest))
+		}),
+		unaryHandler("Expire", func() message { return &expireRequest{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.expire(ctx, rq.(*expireRequest))
+		}),
+		unaryHandler("GC", func() message { return &empty{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.gc(ctx, rq.(*empty))
+		}),
+		unaryHandler("Keys", func() message { return &empty{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.keys(ctx, rq.(*empty))
+		}),
+		unaryHandler("KeysWithPrefix", func() message { return &keyRequest{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.keysWithPrefix(ctx, rq.(*keyRequest))
+		}),
+		unaryHandler("Len", func() message { return &empty{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.len(ctx, rq.(*empty))
+		}),
+		unaryHandler("Load", func() message { return &keyRequest{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.load(ctx, rq.(*keyRequest))
+		}),
+		unaryHandler("LoadAndDelete", func() message { return &keyRequest{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.loadAndDelete(ctx, rq.(*keyRequest))
+		}),
+		unaryHandler("LoadMany", func() message { return &keysRequest{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.loadMany(ctx, rq.(*keysRequest))
+		}),
+		unaryHandler("LoadStore", func() message { return &keyRequest{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.loadStore(ctx, rq.(*keyRequest))
+		}),
+		unaryHandler("NextExpiry", func() message { return &empty{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.nextExpiry(ctx, rq.(*empty))
+		}),
+		unaryHandler("PauseGC", func() message { return &empty{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.pauseGC(ctx, rq.(*empty))
+		}),
+		unaryHandler("Prune", func() message { return &pruneRequest{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.prune(ctx, rq.(*pruneRequest))
+		}),
+		unaryHandler("ResumeGC", func() message { return &empty{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.resumeGC(ctx, rq.(*empty))
+		}),
+		unaryHandler("Store", func() message { return &storeRequest{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.store(ctx, rq.(*storeRequest))
+		}),
+		unaryHandler("StoreMany", func() message { return &storeManyRequest{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.storeMany(ctx, rq.(*storeManyRequest))
+		}),
+		unaryHandler("Touch", func() message { return &keyRequest{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.touch(ctx, rq.(*keyRequest))
+		}),
+		unaryHandler("TTLRemaining", func() message { return &keyRequest{} }, func(s *service, ctx context.Context, rq message) (message, error) {
+			return s.ttlRemaining(ctx, rq.(*keyRequest))
+		}),
+	},
+	Streams: []grpc.StreamDesc{
+		pairsHandler("Pairs", (*service).pairs),
+		pairsHandler("PairsByExpiry", (*service).pairsByExpiry),
+	},
+	Metadata: "congomap.proto",
+}
@@ -0,0 +1,70 @@
+package congomap
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// LookupRetry is used to specify how many times the lookup callback function is invoked for a key
+// before LoadStore gives up and returns its error, so transient failures are retried without every
+// caller writing its own retry loop. Before each retry, LoadStore waits for the duration backoff
+// returns for the attempt that failed, numbered from 1, or until the context passed to
+// LoadStoreContext is done. When backoff is nil, retries are made without waiting. Panics in the
+// lookup callback function are not retried. Note that some Congomap implementations hold a lock
+// while looking up a value, which retries prolong.
+func LookupRetry(attempts int, backoff func(attempt int) time.Duration) Setter {
+	return configure(func(c *config) error {
+		if attempts <= 0 {
+			return ErrInvalidCount{Option: "LookupRetry", Count: attempts}
+		}
+		c.lookupAttempts = attempts
+		c.lookupBackoff = backoff
+		return nil
+	})
+}
+
+// ExponentialBackoff returns a backoff function for LookupRetry that waits a random duration of up
+// to base for the first attempt, doubling the limit with each attempt after it, without exceeding
+// max.
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		limit := base
+		for i := 1; i < attempt && limit < max; i++ {
+			limit *= 2
+		}
+		if limit > max {
+			limit = max
+		}
+		if limit <= 0 {
+			return 0
+		}
+		return time.Duration(rand.Int63n(int64(limit)))
+	}
+}
+
+// retryLookup invokes the lookup function as many times as LookupRetry allows, until it succeeds,
+// panics, or ctx is done.
+func (c *config) retryLookup(ctx context.Context, lookup lookupFunc, key string) (interface{}, error) {
+	for attempt := 1; ; attempt++ {
+		value, err := c.callLookup(ctx, lookup, key)
+		if err == nil || attempt >= c.lookupAttempts {
+			return value, err
+		}
+		if _, ok := err.(ErrCallbackPanic); ok {
+			return nil, err
+		}
+		if _, ok := err.(ErrNoLookupDefined); ok {
+			return nil, err
+		}
+		if c.lookupBackoff != nil {
+			timer := time.NewTimer(c.lookupBackoff(attempt))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
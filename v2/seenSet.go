@@ -0,0 +1,42 @@
+package congomap
+
+import "time"
+
+// SeenSet is a view of a Congomap that remembers which keys it has seen within a window of time,
+// such as for discarding duplicate events. Each key is forgotten once the window that began when it
+// was first seen elapses, after which it is reported as unseen again.
+type SeenSet struct {
+	cgm    Congomap
+	window time.Duration
+}
+
+// NewSeenSet returns a SeenSet that records the keys it sees in the Congomap, remembering each of
+// them for the specified window. The Congomap ought not be used for anything else.
+//
+//	seen, err := congomap.NewSeenSet(cgm, 10*time.Minute)
+//	if err != nil {
+//	    panic(err)
+//	}
+//	for event := range events {
+//	    if seen.Seen(event.ID) {
+//	        continue // duplicate
+//	    }
+//	    process(event)
+//	}
+func NewSeenSet(cgm Congomap, window time.Duration) (*SeenSet, error) {
+	if window <= 0 {
+		return nil, ErrInvalidDuration(window)
+	}
+	return &SeenSet{cgm: cgm, window: window}, nil
+}
+
+// Seen returns false the first time it is invoked with a key within the window, and true every
+// other time. Concurrent invocations with the same unseen key return false for exactly one of them.
+func (s *SeenSet) Seen(key string) bool {
+	var seen bool
+	s.cgm.Do(key, func(_ interface{}, ok bool) (interface{}, bool) {
+		seen = ok
+		return &ExpiringValue{Value: struct{}{}, Expiry: Now(s.cgm).Add(s.window)}, !ok
+	})
+	return seen
+}
@@ -0,0 +1,62 @@
+// Package session provides a store of short-lived web session data backed by a Congomap.
+//
+// Session data is removed from the Congomap when it expires or is deleted, at which point the
+// reaper callback function of the Congomap, when one is specified, is invoked with it, so
+// resources attached to a session, such as open files or subscriptions, may be released there.
+//
+//	cgm, err := congomap.NewTwoLevelMap(congomap.Reaper(func(data interface{}) {
+//	    _ = data.(*Session).Close()
+//	}))
+//	if err != nil {
+//	    panic(err)
+//	}
+//	sessions := session.NewStore(cgm)
+//	defer func() { _ = sessions.Close() }()
+package session
+
+import (
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+)
+
+// Store keeps the data of each session by its ID until the session expires or is deleted.
+type Store struct {
+	cgm congomap.Congomap
+}
+
+// NewStore returns a Store that keeps session data in the specified Congomap.
+func NewStore(cgm congomap.Congomap) *Store {
+	return &Store{cgm: cgm}
+}
+
+// Close closes the Congomap the Store keeps session data in, reaping the data of every session.
+func (s *Store) Close() error {
+	return s.cgm.Close()
+}
+
+// Get returns the data of the session with the specified ID and true, or nil and false when there
+// is no such session or it has expired.
+func (s *Store) Get(id string) (interface{}, bool) {
+	return s.cgm.Load(id)
+}
+
+// Save associates the data with the session ID until the expiry, replacing any data previously
+// saved for the session. The zero expiry means the session expires after the TTL of the Congomap,
+// or never when it has none. Saving with an expiry in the past deletes the session.
+func (s *Store) Save(id string, data interface{}, expiry time.Time) {
+	if !expiry.IsZero() && !expiry.After(time.Now()) {
+		s.cgm.Delete(id)
+		return
+	}
+	if expiry.IsZero() {
+		s.cgm.Store(id, data)
+		return
+	}
+	s.cgm.Store(id, &congomap.ExpiringValue{Value: data, Expiry: expiry})
+}
+
+// Delete removes the session with the specified ID.
+func (s *Store) Delete(id string) {
+	s.cgm.Delete(id)
+}
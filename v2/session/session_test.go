@@ -0,0 +1,74 @@
+package session_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+	"github.com/karrick/congomap/v2/session"
+)
+
+func newTestStore(t *testing.T) (*session.Store, func() []interface{}) {
+	var lock sync.Mutex
+	var reaped []interface{}
+	cgm, err := congomap.NewSyncMutexMap(congomap.Reaper(func(data interface{}) {
+		lock.Lock()
+		reaped = append(reaped, data)
+		lock.Unlock()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := session.NewStore(cgm)
+	t.Cleanup(func() { _ = s.Close() })
+	return s, func() []interface{} {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]interface{}(nil), reaped...)
+	}
+}
+
+func TestSaveGet(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	s.Save("abc", "alice", time.Time{})
+	if data, ok := s.Get("abc"); data != "alice" || !ok {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", data, ok, "alice", true)
+	}
+	if data, ok := s.Get("xyz"); data != nil || ok {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", data, ok, nil, false)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	s.Save("abc", "alice", time.Now().Add(10*time.Millisecond))
+	if _, ok := s.Get("abc"); !ok {
+		t.Errorf("Actual: %#v; Expected: %#v", ok, true)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if data, ok := s.Get("abc"); data != nil || ok {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", data, ok, nil, false)
+	}
+
+	s.Save("def", "bob", time.Now().Add(-time.Second))
+	if data, ok := s.Get("def"); data != nil || ok {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", data, ok, nil, false)
+	}
+}
+
+func TestDeleteReaps(t *testing.T) {
+	s, reaped := newTestStore(t)
+
+	s.Save("abc", "alice", time.Time{})
+	s.Delete("abc")
+	if _, ok := s.Get("abc"); ok {
+		t.Errorf("Actual: %#v; Expected: %#v", ok, false)
+	}
+	if actual := reaped(); !reflect.DeepEqual(actual, []interface{}{"alice"}) {
+		t.Errorf("Actual: %v; Expected: %v", actual, []interface{}{"alice"})
+	}
+}
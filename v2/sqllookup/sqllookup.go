@@ -0,0 +1,94 @@
+// Package sqllookup provides read-through Congomaps whose lookup callback function queries a
+// database with a prepared statement.
+//
+//	stmt, err := db.Prepare("SELECT name, email FROM users WHERE id = ?")
+//	if err != nil {
+//	    panic(err)
+//	}
+//	cgm, err := sqllookup.New(congomap.NewTwoLevelMap, stmt, func(row *sql.Row) (interface{}, error) {
+//	    u := new(User)
+//	    err := row.Scan(&u.Name, &u.Email)
+//	    return u, err
+//	}, []sqllookup.Setter{sqllookup.Timeout(time.Second)}, congomap.TTL(time.Minute))
+//	if err != nil {
+//	    panic(err)
+//	}
+//	defer func() { _ = cgm.Close() }()
+package sqllookup
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+)
+
+// Factory creates the value associated with a key from the row the statement selected for it.
+type Factory func(*sql.Row) (interface{}, error)
+
+// Setter declares the type of function used when creating a lookup callback function to change
+// its behavior.
+type Setter func(*lookup) error
+
+// Context is used to specify the context from which the context of every query is derived, so
+// cancelling it makes lookups fail rather than wait on the database. The default is
+// context.Background().
+func Context(ctx context.Context) Setter {
+	return func(l *lookup) error {
+		l.ctx = ctx
+		return nil
+	}
+}
+
+// Timeout is used to specify how long each query may take. When not specified, queries do not time
+// out.
+func Timeout(duration time.Duration) Setter {
+	return func(l *lookup) error {
+		if duration <= 0 {
+			return congomap.ErrInvalidDuration(duration)
+		}
+		l.timeout = duration
+		return nil
+	}
+}
+
+type lookup struct {
+	stmt    *sql.Stmt
+	factory Factory
+	ctx     context.Context
+	timeout time.Duration
+}
+
+func (l *lookup) query(key string) (interface{}, error) {
+	ctx := l.ctx
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+	return l.factory(l.stmt.QueryRowContext(ctx, key))
+}
+
+// Lookup returns a congomap.Setter that makes the lookup callback function of a Congomap execute
+// the prepared statement with the key as its only argument, and create the value from the selected
+// row with the factory. When the statement selects no row, the lookup returns sql.ErrNoRows, unless
+// the factory handles it.
+func Lookup(stmt *sql.Stmt, factory Factory, setters ...Setter) congomap.Setter {
+	l := &lookup{stmt: stmt, factory: factory, ctx: context.Background()}
+	return func(cgm congomap.Congomap) error {
+		for _, setter := range setters {
+			if err := setter(l); err != nil {
+				return err
+			}
+		}
+		return cgm.Lookup(l.query)
+	}
+}
+
+// New returns a Congomap created by newCongomap, such as congomap.NewTwoLevelMap, whose lookup
+// callback function is the prepared statement, as described by Lookup. The remaining setters are
+// passed to newCongomap.
+func New(newCongomap func(...congomap.Setter) (congomap.Congomap, error), stmt *sql.Stmt, factory Factory, lookupSetters []Setter, setters ...congomap.Setter) (congomap.Congomap, error) {
+	return newCongomap(append([]congomap.Setter{Lookup(stmt, factory, lookupSetters...)}, setters...)...)
+}
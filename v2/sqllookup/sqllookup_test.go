@@ -0,0 +1,136 @@
+package sqllookup_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	congomap "github.com/karrick/congomap/v2"
+	"github.com/karrick/congomap/v2/sqllookup"
+)
+
+// fakeDriver serves a single table of names by id, and blocks queries for the id "slow" until
+// their context is done.
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return fakeStmt{}, nil }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+type fakeStmt struct{}
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return 1 }
+
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	id := args[0].Value.(string)
+	if id == "slow" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	rows := &fakeRows{}
+	if name, ok := map[string]string{"1": "alice", "2": "bob"}[id]; ok {
+		rows.names = []string{name}
+	}
+	return rows, nil
+}
+
+type fakeRows struct{ names []string }
+
+func (*fakeRows) Columns() []string { return []string{"name"} }
+func (*fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.names) == 0 {
+		return io.EOF
+	}
+	dest[0], r.names = r.names[0], r.names[1:]
+	return nil
+}
+
+func init() {
+	sql.Register("sqllookup-fake", fakeDriver{})
+}
+
+func scanName(row *sql.Row) (interface{}, error) {
+	var name string
+	err := row.Scan(&name)
+	return name, err
+}
+
+func newTestMap(t *testing.T, setters ...sqllookup.Setter) congomap.Congomap {
+	db, err := sql.Open("sqllookup-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	stmt, err := db.Prepare("SELECT name FROM users WHERE id = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = stmt.Close() })
+
+	cgm, err := sqllookup.New(congomap.NewSyncMutexMap, stmt, scanName, setters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cgm.Close() })
+	return cgm
+}
+
+func TestLookup(t *testing.T) {
+	cgm := newTestMap(t)
+
+	value, err := cgm.LoadStore("1")
+	if value != "alice" || err != nil {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, err, "alice", nil)
+	}
+	if value, ok := cgm.Load("1"); value != "alice" || !ok {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, ok, "alice", true)
+	}
+
+	if _, err = cgm.LoadStore("3"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Actual: %#v; Expected: %#v", err, sql.ErrNoRows)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	cgm := newTestMap(t, sqllookup.Timeout(10*time.Millisecond))
+
+	if _, err := cgm.LoadStore("slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Actual: %#v; Expected: %#v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cgm := newTestMap(t, sqllookup.Context(ctx))
+	cancel()
+
+	if _, err := cgm.LoadStore("slow"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Actual: %#v; Expected: %#v", err, context.Canceled)
+	}
+}
+
+func TestInvalidTimeout(t *testing.T) {
+	if _, err := sqllookup.New(congomap.NewSyncMutexMap, nil, scanName, []sqllookup.Setter{sqllookup.Timeout(0)}); err == nil {
+		t.Errorf("Actual: %#v; Expected: error", err)
+	}
+}
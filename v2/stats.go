@@ -0,0 +1,114 @@
+package congomap
+
+import "sync/atomic"
+
+// CacheStats are the counts of operations performed by a Congomap since it was created, as returned
+// by Stats, from which operators may compute its hit ratio and the load it spares the backend.
+type CacheStats struct {
+	Hits         uint64 // reads by Load, LoadMany, or LoadStore that found a live value
+	Misses       uint64 // reads by Load, LoadMany, or LoadStore that did not
+	Lookups      uint64 // invocations of the lookup callback function, including retries
+	LookupErrors uint64 // invocations of the lookup callback function that failed
+	Stores       uint64 // values written by the application
+	Deletes      uint64 // values removed by the application
+	Expirations  uint64 // expired values removed
+	Evictions    uint64 // values evicted by MaxEntries, MaxCost, or Prune
+}
+
+// HitRatio returns the fraction of reads that found a live value, or zero when there have been no
+// reads.
+func (s CacheStats) HitRatio() float64 {
+	if reads := s.Hits + s.Misses; reads > 0 {
+		return float64(s.Hits) / float64(reads)
+	}
+	return 0
+}
+
+// counter identifies one of the counts maintained for CacheStats.
+type counter int
+
+const (
+	countHits counter = iota
+	countMisses
+	countLookups
+	countLookupErrors
+	countStores
+	countDeletes
+	countExpirations
+	countEvictions
+	numCounters
+)
+
+// counters are the counts maintained for CacheStats. They are allocated separately from the config,
+// so they are 64-bit aligned for atomic access on 32-bit platforms.
+type counters [numCounters]uint64
+
+// add increments the specified count by n. It does nothing unless CollectStats was specified.
+func (s *counters) add(which counter, n int) {
+	if s != nil && n > 0 {
+		atomic.AddUint64(&s[which], uint64(n))
+	}
+}
+
+// read increments the count of hits or misses according to whether a read found a live value.
+func (s *counters) read(hit bool) {
+	if hit {
+		s.add(countHits, 1)
+	} else {
+		s.add(countMisses, 1)
+	}
+}
+
+// removed increments the count for the reason the pairs were removed from the data store.
+func (s *counters) removed(reason EvictionReason, pairs []Pair) {
+	switch reason {
+	case ReasonDeleted:
+		s.add(countDeletes, len(pairs))
+	case ReasonExpired:
+		s.add(countExpirations, len(pairs))
+	case ReasonEvicted:
+		s.add(countEvictions, len(pairs))
+	}
+}
+
+// CollectStats is used to specify that the Congomap counts the operations it performs, which are
+// returned by Stats. Because counting values as they are removed requires gathering them as a reaper
+// does, collecting statistics has a cost similar to specifying a reaper.
+func CollectStats() Setter {
+	return configure(func(c *config) error {
+		if c.stats == nil {
+			c.stats = new(counters)
+		}
+		return nil
+	})
+}
+
+// Stats returns the counts of operations performed by the Congomap since it was created, all of
+// which are zero unless CollectStats was specified. It returns ErrUnsupportedOption for a Congomap
+// not provided by this library.
+func Stats(cgm Congomap) (CacheStats, error) {
+	c, ok := cgm.(configurable)
+	if !ok {
+		return CacheStats{}, ErrUnsupportedOption{}
+	}
+	return c.getConfig().statistics(), nil
+}
+
+// statistics returns the counts of operations performed, all of which are zero unless CollectStats
+// was specified.
+func (c *config) statistics() CacheStats {
+	s := c.stats
+	if s == nil {
+		return CacheStats{}
+	}
+	return CacheStats{
+		Hits:         atomic.LoadUint64(&s[countHits]),
+		Misses:       atomic.LoadUint64(&s[countMisses]),
+		Lookups:      atomic.LoadUint64(&s[countLookups]),
+		LookupErrors: atomic.LoadUint64(&s[countLookupErrors]),
+		Stores:       atomic.LoadUint64(&s[countStores]),
+		Deletes:      atomic.LoadUint64(&s[countDeletes]),
+		Expirations:  atomic.LoadUint64(&s[countExpirations]),
+		Evictions:    atomic.LoadUint64(&s[countEvictions]),
+	}
+}
@@ -1,28 +1,32 @@
 package congomap
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
 type syncAtomicMap struct {
-	db     atomic.Value
-	dbLock sync.Mutex // used only by writers
+	db     atomic.Value // *hamt, replaced by each write
+	dbLock sync.Mutex   // used only by writers
 
-	halt   chan struct{}
-	lookup func(string) (interface{}, error)
-	reaper func(interface{})
-	ttl    time.Duration
+	loading KeyedCall   // dedupes concurrent lookups of the same key
+	buffer  writeBuffer // of values written by Store, when WriteBuffer was specified
+
+	halt chan struct{}
+	done chan struct{} // closed when run returns
+
+	config
 }
 
-// NewSyncAtomicMap returns a map that uses atomic.Value to serialize access, using a copy-on-write
-// method of atomically updating the data store.
+// NewSyncAtomicMap returns a map that uses atomic.Value to serialize access, atomically replacing
+// the data store, a persistent hash array mapped trie, with a new version on each write.
 //
-// Because write speeds are O(n) based on the size of the keys in this Congomap, this type of
-// Congomap is particularly well suited for scenarios with a very large read to write ratio, and a
-// small corpus of keys in the Congomap. This type of Congomap also uses a mutex to guard all
-// mutations to the data store.
+// Because each write copies only the O(log n) nodes of the trie on the path to its key, while reads
+// load the current version without acquiring any lock, this type of Congomap is particularly well
+// suited for scenarios with a very large read to write ratio. This type of Congomap also uses a
+// mutex to guard all mutations to the data store.
 //
 // Note that it is important to call the Close method on the returned data structure when it's no
 // longer needed to free CPU and channel resources back to the runtime.
@@ -33,209 +37,632 @@ type syncAtomicMap struct {
 //	}
 //	defer func() { _ = cgm.Close() }()
 func NewSyncAtomicMap(setters ...Setter) (Congomap, error) {
-	cgm := &syncAtomicMap{halt: make(chan struct{})}
-	cgm.db.Store(make(map[string]*ExpiringValue))
-	for _, setter := range setters {
-		if err := setter(cgm); err != nil {
-			return nil, err
-		}
+	cgm := &syncAtomicMap{halt: make(chan struct{}), done: make(chan struct{})}
+	cgm.db.Store(newHAMT())
+	if err := applySetters(cgm, setters); err != nil {
+		return nil, err
 	}
 	if cgm.lookup == nil {
-		cgm.lookup = func(_ string) (interface{}, error) {
+		cgm.lookup = func(context.Context, string) (interface{}, error) {
 			return nil, ErrNoLookupDefined{}
 		}
 	}
-	go cgm.run()
+	if !cgm.noGoroutine {
+		go cgm.run()
+	}
 	return cgm, nil
 }
 
-func (cgm *syncAtomicMap) Lookup(lookup func(string) (interface{}, error)) error {
-	cgm.lookup = lookup
-	return nil
+func (cgm *syncAtomicMap) Delete(key string) {
+	key = cgm.canonical(key)
+	cgm.dbLock.Lock()
+	defer cgm.dbLock.Unlock()
+	h := cgm.flushLocked()
+	ev, ok := h.get(key)
+	cgm.db.Store(cgm.without(h, key))
+	if ok && cgm.reaping() {
+		cgm.reap(key, ev.Value, ReasonDeleted)
+	}
 }
 
-func (cgm *syncAtomicMap) Reaper(reaper func(interface{})) error {
-	cgm.reaper = reaper
-	return nil
+func (cgm *syncAtomicMap) Prune(n int) int {
+	cgm.dbLock.Lock()
+	h := cgm.flushLocked()
+	keys := cgm.oldest(n, h.visit)
+	pruned := make([]Pair, len(keys))
+	for i, key := range keys {
+		ev, _ := h.get(key)
+		pruned[i] = Pair{key, ev.Value}
+		h = cgm.without(h, key)
+	}
+	cgm.db.Store(h)
+	cgm.dbLock.Unlock()
+	cgm.reapAll(pruned, ReasonEvicted)
+	return len(pruned)
 }
 
-func (cgm *syncAtomicMap) TTL(duration time.Duration) error {
-	if duration <= 0 {
-		return ErrInvalidDuration(duration)
-	}
-	cgm.ttl = duration
-	return nil
+func (cgm *syncAtomicMap) DeleteIf(key string, fn func(interface{}) bool) bool {
+	return deleteIf(cgm.mutate, cgm.canonical(key), fn)
 }
 
-func (cgm *syncAtomicMap) Delete(key string) {
+func (cgm *syncAtomicMap) DeleteMany(keys []string) {
+	keys = cgm.canonicalKeys(keys)
+	var removed []Pair
 	cgm.dbLock.Lock()
-	m := cgm.copyNonExpiredData(nil)
-	if cgm.reaper != nil {
-		if ev, ok := m[key]; ok {
-			cgm.reaper(ev.Value)
+	h := cgm.flushLocked()
+	for _, key := range keys {
+		if ev, ok := h.get(key); ok {
+			if cgm.reaping() {
+				removed = append(removed, Pair{key, ev.Value})
+			}
+			h = cgm.without(h, key)
 		}
 	}
-	delete(m, key)
-	cgm.db.Store(m)
+	cgm.db.Store(h)
+	cgm.dbLock.Unlock()
+	cgm.reapAll(removed, ReasonDeleted)
+}
+
+func (cgm *syncAtomicMap) All() func(yield func(string, interface{}) bool) {
+	return all(cgm)
+}
+
+func (cgm *syncAtomicMap) Clear() {
+	cgm.dbLock.Lock()
+	h := cgm.flushLocked()
+	cgm.db.Store(newHAMT())
+	cgm.resetCost()
 	cgm.dbLock.Unlock()
+	cgm.reapAll(h.pairs(), ReasonCleared)
+}
+
+func (cgm *syncAtomicMap) Do(key string, fn func(interface{}, bool) (interface{}, bool)) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return
+	}
+	defer cgm.release()
+	cgm.mutate(key, doMutator(fn, cgm.storeFunc(key)))
+}
+
+func (cgm *syncAtomicMap) Update(key string, fn func(interface{}, bool) (interface{}, bool)) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return
+	}
+	defer cgm.release()
+	cgm.mutate(key, updateMutator(fn, cgm.storeFunc(key)))
+}
+
+// mutate invokes fn with the live value for key while holding the writer lock, and replaces that
+// value with what fn returns.
+func (cgm *syncAtomicMap) mutate(key string, fn mutator) {
+	// expired values being discarded are always reaped
+	if stored, reason := cgm.mutateLocked(key, fn); stored != nil && cgm.reaping() {
+		cgm.reap(key, stored.Value, reason)
+	}
+}
+
+// mutateLocked does the work of mutate while holding the writer lock, and returns the replaced
+// value when it ought to be reaped, along with why.
+func (cgm *syncAtomicMap) mutateLocked(key string, fn mutator) (*entry, EvictionReason) {
+	cgm.dbLock.Lock()
+	defer cgm.dbLock.Unlock()
+
+	h := cgm.flushLocked()
+
+	stored, ok := h.get(key)
+	ev := stored
+	if ok && !stored.live(cgm.now()) {
+		ev = nil // expired values are never shown to fn
+	}
+
+	next, reap := fn(ev)
+	if next == ev {
+		return nil, 0
+	}
+
+	if next == nil {
+		h = cgm.without(h, key)
+	} else {
+		var evicted []Pair
+		h, evicted = cgm.evictFrom(cgm.with(h, key, next), key)
+		cgm.reapAll(evicted, ReasonEvicted)
+	}
+	cgm.db.Store(h)
+
+	if ok && (reap || ev == nil) {
+		return stored, mutated(ev, next)
+	}
+	return nil, 0
+}
+
+func (cgm *syncAtomicMap) each(fn func(string, *entry) bool) {
+	cgm.data().each(fn)
+}
+
+func (cgm *syncAtomicMap) Expire(key string, duration time.Duration) bool {
+	key = cgm.canonical(key)
+	if cgm.admit() != nil {
+		return false
+	}
+	defer cgm.release()
+	return cgm.setExpiry(cgm.mutate, key, cgm.now().Add(duration))
+}
+
+func (cgm *syncAtomicMap) Persist(key string) bool {
+	key = cgm.canonical(key)
+	if cgm.admit() != nil {
+		return false
+	}
+	defer cgm.release()
+	return cgm.setExpiry(cgm.mutate, key, time.Time{})
+}
+
+func (cgm *syncAtomicMap) TTLRemaining(key string) (time.Duration, bool) {
+	key = cgm.canonical(key)
+	ev, _ := cgm.data().get(key)
+	return remaining(ev, cgm.now())
+}
+
+func (cgm *syncAtomicMap) Touch(key string) bool {
+	key = cgm.canonical(key)
+	if cgm.admit() != nil {
+		return false
+	}
+	defer cgm.release()
+	return cgm.touch(cgm.mutate, key, cgm.touchDuration())
 }
 
 func (cgm *syncAtomicMap) GC() {
+	now := cgm.now()
+	cgm.gcBadLookups(now)
 	cgm.dbLock.Lock()
-	m := cgm.copyNonExpiredData(nil)
-	cgm.db.Store(m)
+	h := cgm.flushLocked()
+
+	if cgm.expiries != nil {
+		var reaped []Pair
+		var evicted int
+		for _, key := range cgm.popExpired(now) {
+			if ev, ok := h.get(key); ok && cgm.evictable(ev, now) {
+				h = cgm.without(h, key)
+				evicted++
+				if cgm.reaping() {
+					reaped = append(reaped, Pair{key, ev.Value})
+				}
+			}
+		}
+		cgm.db.Store(h)
+		cgm.dbLock.Unlock()
+		cgm.recordSweep(now, evicted, h.count)
+		cgm.reapAll(reaped, ReasonExpired)
+		return
+	}
+
+	sampler := newTTLSampler(now)
+	var expired []Pair
+	h.each(func(key string, ev *entry) bool {
+		if cgm.evictable(ev, now) {
+			expired = append(expired, Pair{key, ev.Value})
+		} else {
+			sampler.add(ev.Expiry)
+		}
+		return true
+	})
+	for _, p := range expired {
+		h = cgm.without(h, p.Key)
+		sampler.evict()
+	}
+	cgm.db.Store(h)
 	cgm.dbLock.Unlock()
+
+	cgm.recordTTLs(sampler)
+	if cgm.reaping() {
+		cgm.reapAll(expired, ReasonExpired)
+	}
+}
+
+func (cgm *syncAtomicMap) MaintainOnce() {
+	cgm.GC()
 }
 
 func (cgm *syncAtomicMap) Load(key string) (interface{}, bool) {
-	ev, ok := cgm.db.Load().(map[string]*ExpiringValue)[key]
-	if ok && (ev.Expiry.IsZero() || ev.Expiry.After(time.Now())) {
-		return ev.Value, true
+	key = cgm.canonical(key)
+	if cgm.isClosed() {
+		return nil, false
 	}
+	ev, ok := cgm.data().get(key)
+	if ok && (ev.Expiry.IsZero() || ev.Expiry.After(cgm.now())) {
+		cgm.loaded(key, true)
+		cgm.use(&ev.used)
+		cgm.accessed(cgm.mutate, key)
+		return cgm.copied(ev.Value), true
+	}
+	cgm.loaded(key, false)
 	return nil, false
 }
 
+func (cgm *syncAtomicMap) LoadMany(keys []string) map[string]interface{} {
+	return cgm.loadMany(cgm.mutate, keys, func(keys []string) []*entry {
+		h := cgm.data()
+		evs := make([]*entry, len(keys))
+		for i, key := range keys {
+			evs[i], _ = h.get(key)
+		}
+		return evs
+	})
+}
+
 func (cgm *syncAtomicMap) LoadStore(key string) (interface{}, error) {
-	cgm.dbLock.Lock() // synchronize with other potential writers
+	value, _, err := cgm.LoadStoreInfo(key)
+	return value, err
+}
 
-	m1 := cgm.db.Load().(map[string]*ExpiringValue) // load current value of the data structure
+func (cgm *syncAtomicMap) LoadStoreInfo(key string) (interface{}, bool, error) {
+	return cgm.loadStoreWith(context.Background(), key, cgm.lookup)
+}
 
-	ev, ok := m1[key]
-	if ok && (ev.Expiry.IsZero() || ev.Expiry.After(time.Now())) {
-		cgm.dbLock.Unlock()
-		return ev.Value, nil
-	}
+func (cgm *syncAtomicMap) LoadStoreContext(ctx context.Context, key string) (interface{}, error) {
+	value, _, err := cgm.loadStoreWith(ctx, key, cgm.lookup)
+	return value, err
+}
 
-	var wg sync.WaitGroup
-	defer wg.Wait()
+func (cgm *syncAtomicMap) LoadStoreWith(key string, lookup func(string) (interface{}, error)) (interface{}, error) {
+	value, _, err := cgm.loadStoreWith(context.Background(), key, withoutContext(lookup))
+	return value, err
+}
 
-	if ok && cgm.reaper != nil {
-		wg.Add(1)
-		go func(value interface{}) {
-			cgm.reaper(value)
-			wg.Done()
-		}(ev.Value)
+// loadStoreWith does the work of LoadStoreInfo, LoadStoreContext, and LoadStoreWith, passing ctx to
+// the lookup function.
+func (cgm *syncAtomicMap) loadStoreWith(ctx context.Context, key string, lookup lookupFunc) (interface{}, bool, error) {
+	key = cgm.canonical(key)
+	if err := cgm.checkKey(key); err != nil {
+		return nil, false, err
+	}
+	if err := cgm.admit(); err != nil {
+		return nil, false, err
+	}
+	defer cgm.release()
+	ctx, end := cgm.trace(ctx, "LoadStore", key)
+	value, looked, stale, err := cgm.loadStoreOnce(ctx, key, lookup)
+	end(err)
+	cgm.loadStored(key, looked, err)
+	if stale != nil && cgm.reaping() {
+		reason := ReasonExpired
+		if stale.live(cgm.now()) {
+			reason = ReasonReplaced // stored by another writer while the lookup ran
+		}
+		cgm.reap(key, stale.Value, reason)
 	}
-
-	value, err := cgm.lookup(key)
 	if err != nil {
-		cgm.dbLock.Unlock()
-		return nil, err
+		return nil, false, err
+	}
+	if !looked {
+		cgm.accessed(cgm.mutate, key)
 	}
+	return cgm.copied(value), looked, nil
+}
 
-	m2 := cgm.copyNonExpiredData(m1)
-	m2[key] = newExpiringValue(value, cgm.ttl)
-	cgm.db.Store(m2)
-	cgm.dbLock.Unlock()
+// loadStoreOnce does the work of loadStoreWith, invoking the lookup function at most once for
+// concurrent misses of the same key. The writer lock is not held while the lookup runs, so writes
+// to other keys may proceed. It returns the value replaced by the fresh one, which ought to be
+// reaped.
+func (cgm *syncAtomicMap) loadStoreOnce(ctx context.Context, key string, lookup lookupFunc) (interface{}, bool, *entry, error) {
+	if ev, ok := cgm.data().get(key); ok && ev.live(cgm.now()) {
+		cgm.use(&ev.used)
+		cgm.refreshAhead(key, ev, lookup, cgm.mutate)
+		return ev.Value, false, nil, nil
+	}
+
+	var looked bool
+	var stale *entry
+	value, err, _ := cgm.loading.do(key, "Lookup", func() (interface{}, error) {
+		// another caller might have stored a fresh value after the check above
+		cgm.flush()
+		if ev, ok := cgm.data().get(key); ok && ev.live(cgm.now()) {
+			cgm.use(&ev.used)
+			return ev.Value, nil
+		}
+
+		value, err := cgm.fetch(ctx, lookup, key, cgm.Store)
 
-	return value, nil
+		cgm.dbLock.Lock()
+		defer cgm.dbLock.Unlock()
+
+		h := cgm.flushLocked() // other writers may have run during lookup
+		if err != nil {
+			if ev, ok := h.get(key); ok && cgm.servesStale(ev, cgm.now()) {
+				return ev.Value, nil
+			}
+			return nil, err
+		}
+
+		stale, _ = h.get(key) // expired value might have been retained so it could be served stale
+		h, evicted := cgm.evictFrom(cgm.with(h, key, cgm.lookupValue(value)), key)
+		cgm.reapAll(evicted, ReasonEvicted)
+		cgm.db.Store(h)
+		looked = true
+		return value, nil
+	})
+	return value, looked, stale, err
+}
+
+func (cgm *syncAtomicMap) LoadStoreAll(keys []string) map[string]LoadStoreResult {
+	return loadStoreAll(cgm, keys)
+}
+
+func (cgm *syncAtomicMap) NextExpiry() (time.Time, bool) {
+	var next time.Time
+	cgm.data().each(func(_ string, ev *entry) bool {
+		next = earliest(next, ev)
+		return true
+	})
+	return next, !next.IsZero()
 }
 
 func (cgm *syncAtomicMap) Store(key string, value interface{}) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return
+	}
+	defer cgm.release()
+	if cgm.buffer.buffered() {
+		prev, full := cgm.buffer.add(key, cgm.storeValue(key, value))
+		if prev != nil && cgm.reaping() {
+			cgm.reap(key, prev.Value, ReasonReplaced)
+		}
+		if full {
+			cgm.flush()
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait() // after the lock is released
+
 	cgm.dbLock.Lock()
+	defer cgm.dbLock.Unlock()
 
-	m := cgm.copyNonExpiredData(nil)
+	h := cgm.flushLocked()
 
-	ev, ok := m[key]
+	ev, ok := h.get(key)
 
-	var wg sync.WaitGroup
-	if ok && cgm.reaper != nil {
+	if ok && cgm.reaping() {
 		wg.Add(1)
 		go func(value interface{}) {
-			cgm.reaper(value)
+			cgm.reap(key, value, ReasonReplaced)
 			wg.Done()
 		}(ev.Value)
 	}
 
-	m[key] = newExpiringValue(value, cgm.ttl)
-	cgm.db.Store(m)
+	h, evicted := cgm.evictFrom(cgm.with(h, key, cgm.storeValue(key, value)), key)
+	cgm.reapAll(evicted, ReasonEvicted)
+	cgm.db.Store(h)
+}
+
+func (cgm *syncAtomicMap) StoreMany(pairs map[string]interface{}) {
+	if cgm.admit() != nil {
+		return
+	}
+	defer cgm.release()
+	evs := cgm.storable(pairs)
+	var replaced, evicted []Pair
+	cgm.dbLock.Lock()
+	h := cgm.flushLocked()
+	for key, ev := range evs {
+		if prev, ok := h.get(key); ok && cgm.reaping() {
+			replaced = append(replaced, Pair{key, prev.Value})
+		}
+		var pairs []Pair
+		h, pairs = cgm.evictFrom(cgm.with(h, key, ev), key)
+		evicted = append(evicted, pairs...)
+	}
+	cgm.db.Store(h)
 	cgm.dbLock.Unlock()
-	wg.Wait()
+	cgm.reapAll(replaced, ReasonReplaced)
+	cgm.reapAll(evicted, ReasonEvicted)
+}
+
+func (cgm *syncAtomicMap) Snapshot() map[string]interface{} {
+	return cgm.snapshot(cgm)
+}
+
+func (cgm *syncAtomicMap) Len() int {
+	return liveCount(cgm)
+}
+
+func (cgm *syncAtomicMap) LoadAndDelete(key string) (interface{}, bool) {
+	return loadAndDelete(cgm.mutate, cgm.canonical(key))
+}
+
+func (cgm *syncAtomicMap) LoadOrStore(key string, value interface{}) (interface{}, bool) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return nil, false
+	}
+	defer cgm.release()
+	return loadOrStore(cgm.mutate, key, cgm.storeValue(key, value))
+}
+
+func (cgm *syncAtomicMap) StoreWithTTL(key string, value interface{}, ttl time.Duration) {
+	cgm.Store(key, cgm.withTTL(value, ttl))
+}
+
+func (cgm *syncAtomicMap) StoreReturning(key string, value interface{}) (interface{}, bool) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return nil, false
+	}
+	defer cgm.release()
+	return storeReturning(cgm.mutate, key, cgm.storeValue(key, value))
+}
+
+func (cgm *syncAtomicMap) Swap(key string, value interface{}) (interface{}, bool) {
+	return cgm.StoreReturning(key, value)
 }
 
 func (cgm *syncAtomicMap) Keys() []string {
+	return cgm.AppendKeys(nil)
+}
+
+func (cgm *syncAtomicMap) AppendKeys(buf []string) []string {
+	cgm.data().each(func(k string, _ *entry) bool {
+		buf = append(buf, k)
+		return true
+	})
+	return buf
+}
+
+func (cgm *syncAtomicMap) KeysMatching(match func(string) bool) []string {
 	var keys []string
-	m1 := cgm.db.Load().(map[string]*ExpiringValue) // load current value of the data structure
-	for k := range m1 {
-		keys = append(keys, k)
-	}
+	cgm.data().each(func(k string, _ *entry) bool {
+		if match(k) {
+			keys = append(keys, k)
+		}
+		return true
+	})
 	return keys
 }
 
-func (cgm *syncAtomicMap) Pairs() <-chan *Pair {
-	pairs := make(chan *Pair)
-	go func(pairs chan<- *Pair) {
-		cgm.dbLock.Lock()
-		defer cgm.dbLock.Unlock()
+func (cgm *syncAtomicMap) KeysWithPrefix(prefix string) []string {
+	return cgm.KeysMatching(hasPrefix(prefix))
+}
+
+func (cgm *syncAtomicMap) KeysPage(cursor string, limit int) ([]string, string) {
+	p := newKeyPager(cursor, limit)
+	cgm.data().each(func(k string, _ *entry) bool {
+		p.add(k)
+		return true
+	})
+	return p.page()
+}
 
-		m1 := cgm.db.Load().(map[string]*ExpiringValue) // load current value of the data structure
-		now := time.Now()
-		for k, v := range m1 {
+func (cgm *syncAtomicMap) Pairs() <-chan Pair {
+	if cgm.snapshotPairs {
+		return snapshotPairs(cgm)
+	}
+	h := cgm.data() // writers never modify this version of the data store
+	pairs := newPairs(h.count)
+	go func(pairs chan<- Pair) {
+		now := cgm.now()
+		h.each(func(k string, v *entry) bool {
 			if v.Expiry.IsZero() || v.Expiry.After(now) {
-				pairs <- &Pair{k, v.Value}
+				pairs <- Pair{k, v.Value}
 			}
-		}
+			return true
+		})
 		close(pairs)
 	}(pairs)
 	return pairs
 }
 
-func (cgm *syncAtomicMap) Close() error {
-	close(cgm.halt)
-	return nil
+func (cgm *syncAtomicMap) PairsByExpiry() <-chan Pair {
+	h := cgm.data()
+	eps := make([]expiringPair, 0, h.count)
+	h.each(func(key string, ev *entry) bool {
+		eps = append(eps, expiringPair{key, ev})
+		return true
+	})
+	return pairsByExpiry(eps, cgm.now())
 }
 
-func (cgm *syncAtomicMap) copyNonExpiredData(m1 map[string]*ExpiringValue) map[string]*ExpiringValue {
-	now := time.Now()
-	if m1 == nil {
-		m1 = cgm.db.Load().(map[string]*ExpiringValue) // load current value of the data structure
+func (cgm *syncAtomicMap) Close() error {
+	if cgm.closing() {
+		close(cgm.halt)
+		if cgm.noGoroutine {
+			cgm.shutdown()
+		}
 	}
-	m2 := make(map[string]*ExpiringValue) // create a new value
+	<-cgm.done
+	cgm.closeEvents()
+	return cgm.closeErr()
+}
 
-	var wg sync.WaitGroup
+// data returns the current version of the data store, which is never modified.
+func (cgm *syncAtomicMap) data() *hamt {
+	return cgm.db.Load().(*hamt)
+}
+
+// with returns a version of the data store in which key holds the specified value, accounting for
+// its cost and indexing its expiry. The caller must hold the writer lock.
+func (cgm *syncAtomicMap) with(h *hamt, key string, ev *entry) *hamt {
+	prev, _ := h.get(key)
+	cgm.charge(key, prev, ev)
+	cgm.indexExpiry(key, ev)
+	return h.with(key, ev)
+}
 
-	for k, v := range m1 {
-		if v.Expiry.IsZero() || v.Expiry.After(now) {
-			m2[k] = v // copy non-expired data from the current object to the new one
-		} else if cgm.reaper != nil {
-			wg.Add(1)
-			go func(value interface{}) {
-				cgm.reaper(value)
-				wg.Done()
-			}(v.Value)
+// without returns a version of the data store without key, accounting for the cost of its value.
+// The caller must hold the writer lock.
+func (cgm *syncAtomicMap) without(h *hamt, key string) *hamt {
+	if prev, ok := h.get(key); ok {
+		cgm.charge(key, prev, nil)
+		return h.without(key)
+	}
+	return h
+}
+
+// evictFrom returns a version of the data store without the keys sampled for eviction while it
+// holds more than MaxEntries keys, or values costing more than MaxCost, and their pairs to be
+// reaped. The caller must hold the writer lock.
+func (cgm *syncAtomicMap) evictFrom(h *hamt, written string) (*hamt, []Pair) {
+	var evicted []Pair
+	for cgm.full(h.count) {
+		key, ok := cgm.victim(written, h.sample)
+		if !ok {
+			break
 		}
+		if cgm.reaping() {
+			ev, _ := h.get(key)
+			evicted = append(evicted, Pair{key, ev.Value})
+		}
+		h = cgm.without(h, key)
 	}
+	return h, evicted
+}
 
-	wg.Wait()
-	return m2
+func (cgm *syncAtomicMap) running() bool {
+	select {
+	case <-cgm.done:
+		return false
+	default:
+		return true
+	}
 }
 
 func (cgm *syncAtomicMap) run() {
-	gcPeriodicity := 15 * time.Minute
-	if cgm.ttl > 0 && cgm.ttl <= time.Second {
-		gcPeriodicity = time.Minute
+	ticks, stop := cgm.gcTicker()
+	defer stop()
+
+	var flushes <-chan time.Time
+	if cgm.buffer.buffered() {
+		ticker := time.NewTicker(cgm.buffer.every)
+		defer ticker.Stop()
+		flushes = ticker.C
 	}
 
 	active := true
 	for active {
 		select {
-		case <-time.After(gcPeriodicity):
-			cgm.GC()
+		case <-ticks:
+			if cgm.gcDue() {
+				cgm.GC()
+			}
+		case <-flushes:
+			cgm.flush()
 		case <-cgm.halt:
 			active = false
 		}
 	}
+	cgm.shutdown()
+}
 
-	if cgm.reaper != nil {
-		m1 := cgm.db.Load().(map[string]*ExpiringValue) // load current value of the data structure
-		var wg sync.WaitGroup
-		wg.Add(len(m1))
-		for _, ev := range m1 {
-			go func(value interface{}) {
-				cgm.reaper(value)
-				wg.Done()
-			}(ev.Value)
-		}
-		wg.Wait()
+// shutdown reaps the values remaining once the Congomap is closed, then signals that it is done. It
+// is invoked by the run go routine, or by Close when NoGoroutine was specified.
+func (cgm *syncAtomicMap) shutdown() {
+	defer close(cgm.done)
+
+	cgm.flush()
+	if cgm.reaping() {
+		cgm.reapAll(cgm.data().pairs(), ReasonClosed)
 	}
 }
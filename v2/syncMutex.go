@@ -1,18 +1,19 @@
 package congomap
 
 import (
+	"context"
 	"sync"
 	"time"
 )
 
 type syncMutexMap struct {
-	db     map[string]*ExpiringValue
+	db     map[string]*entry
 	dbLock sync.RWMutex
 
-	halt   chan struct{}
-	lookup func(string) (interface{}, error)
-	reaper func(interface{})
-	ttl    time.Duration
+	halt chan struct{}
+	done chan struct{} // closed when run returns
+
+	config
 }
 
 // NewSyncMutexMap returns a map that uses sync.RWMutex to serialize access to the data store.
@@ -27,135 +28,422 @@ type syncMutexMap struct {
 //	defer func() { _ = cgm.Close() }()
 func NewSyncMutexMap(setters ...Setter) (Congomap, error) {
 	cgm := &syncMutexMap{
-		db:   make(map[string]*ExpiringValue),
+		db:   make(map[string]*entry),
 		halt: make(chan struct{}),
+		done: make(chan struct{}),
 	}
-	for _, setter := range setters {
-		if err := setter(cgm); err != nil {
-			return nil, err
-		}
+	if err := applySetters(cgm, setters); err != nil {
+		return nil, err
 	}
 	if cgm.lookup == nil {
-		cgm.lookup = func(_ string) (interface{}, error) {
+		cgm.lookup = func(context.Context, string) (interface{}, error) {
 			return nil, ErrNoLookupDefined{}
 		}
 	}
-	go cgm.run()
+	if !cgm.noGoroutine {
+		go cgm.run()
+	}
 	return cgm, nil
 }
 
-func (cgm *syncMutexMap) Lookup(lookup func(string) (interface{}, error)) error {
-	cgm.lookup = lookup
-	return nil
+func (cgm *syncMutexMap) All() func(yield func(string, interface{}) bool) {
+	return all(cgm)
 }
 
-func (cgm *syncMutexMap) Reaper(reaper func(interface{})) error {
-	cgm.reaper = reaper
-	return nil
+func (cgm *syncMutexMap) Clear() {
+	cgm.dbLock.Lock()
+	db := cgm.db
+	cgm.db = make(map[string]*entry)
+	cgm.resetCost()
+	cgm.dbLock.Unlock()
+	cgm.reapAll(pairsOf(db), ReasonCleared)
 }
 
-func (cgm *syncMutexMap) TTL(duration time.Duration) error {
-	if duration <= 0 {
-		return ErrInvalidDuration(duration)
-	}
-	cgm.ttl = duration
-	return nil
+func (cgm *syncMutexMap) Prune(n int) int {
+	cgm.dbLock.Lock()
+	pruned := cgm.pruneLocked(cgm.db, n)
+	cgm.dbLock.Unlock()
+	cgm.reapAll(pruned, ReasonEvicted)
+	return len(pruned)
 }
 
 func (cgm *syncMutexMap) Delete(key string) {
+	key = cgm.canonical(key)
 	cgm.dbLock.Lock()
 	ev, ok := cgm.db[key]
-	delete(cgm.db, key)
+	cgm.remove(cgm.db, key)
+	cgm.dbLock.Unlock()
+
+	if ok && cgm.reaping() {
+		cgm.reap(key, ev.Value, ReasonDeleted)
+	}
+}
+
+func (cgm *syncMutexMap) DeleteIf(key string, fn func(interface{}) bool) bool {
+	return deleteIf(cgm.mutate, cgm.canonical(key), fn)
+}
+
+func (cgm *syncMutexMap) DeleteMany(keys []string) {
+	keys = cgm.canonicalKeys(keys)
+	var removed []Pair
+	cgm.dbLock.Lock()
+	for _, key := range keys {
+		if ev, ok := cgm.db[key]; ok {
+			if cgm.reaping() {
+				removed = append(removed, Pair{key, ev.Value})
+			}
+			cgm.remove(cgm.db, key)
+		}
+	}
+	cgm.dbLock.Unlock()
+	cgm.reapAll(removed, ReasonDeleted)
+}
+
+func (cgm *syncMutexMap) Do(key string, fn func(interface{}, bool) (interface{}, bool)) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return
+	}
+	defer cgm.release()
+	cgm.mutate(key, doMutator(fn, cgm.storeFunc(key)))
+}
+
+func (cgm *syncMutexMap) Update(key string, fn func(interface{}, bool) (interface{}, bool)) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return
+	}
+	defer cgm.release()
+	cgm.mutate(key, updateMutator(fn, cgm.storeFunc(key)))
+}
+
+// mutate invokes fn with the live value for key while holding the lock, and replaces that value
+// with what fn returns.
+func (cgm *syncMutexMap) mutate(key string, fn mutator) {
+	cgm.dbLock.Lock()
+
+	stored, ok := cgm.db[key]
+	ev := stored
+	if ok && !stored.live(cgm.now()) {
+		ev = nil // expired values are never shown to fn
+	}
+
+	next, reap := fn(ev)
+	if next == ev {
+		cgm.dbLock.Unlock()
+		return
+	}
+
+	var evicted []Pair
+	if next == nil {
+		cgm.remove(cgm.db, key)
+	} else {
+		cgm.put(cgm.db, key, next)
+		evicted = cgm.evict(cgm.db, key)
+	}
 	cgm.dbLock.Unlock()
 
-	if ok && cgm.reaper != nil {
-		cgm.reaper(ev.Value)
+	// expired values being discarded are always reaped
+	if ok && cgm.reaping() && (reap || ev == nil) {
+		cgm.reap(key, stored.Value, mutated(ev, next))
 	}
+	cgm.reapAll(evicted, ReasonEvicted)
+}
+
+func (cgm *syncMutexMap) each(fn func(string, *entry) bool) {
+	cgm.dbLock.RLock()
+	defer cgm.dbLock.RUnlock()
+	for k, ev := range cgm.db {
+		if !fn(k, ev) {
+			return
+		}
+	}
+}
+
+func (cgm *syncMutexMap) Expire(key string, duration time.Duration) bool {
+	key = cgm.canonical(key)
+	if cgm.admit() != nil {
+		return false
+	}
+	defer cgm.release()
+	return cgm.setExpiry(cgm.mutate, key, cgm.now().Add(duration))
+}
+
+func (cgm *syncMutexMap) Persist(key string) bool {
+	key = cgm.canonical(key)
+	if cgm.admit() != nil {
+		return false
+	}
+	defer cgm.release()
+	return cgm.setExpiry(cgm.mutate, key, time.Time{})
+}
+
+func (cgm *syncMutexMap) TTLRemaining(key string) (time.Duration, bool) {
+	key = cgm.canonical(key)
+	cgm.dbLock.RLock()
+	ev := cgm.db[key]
+	cgm.dbLock.RUnlock()
+	return remaining(ev, cgm.now())
+}
+
+func (cgm *syncMutexMap) Touch(key string) bool {
+	key = cgm.canonical(key)
+	if cgm.admit() != nil {
+		return false
+	}
+	defer cgm.release()
+	return cgm.touch(cgm.mutate, key, cgm.touchDuration())
 }
 
 func (cgm *syncMutexMap) GC() {
-	var wg sync.WaitGroup
+	var reaped []Pair
 
 	cgm.dbLock.Lock()
-	now := time.Now()
+	now := cgm.now()
+	cgm.gcBadLookups(now)
+
+	if cgm.expiries != nil {
+		evicted, reaped := cgm.sweepExpired(cgm.db, now)
+		remaining := len(cgm.db)
+		cgm.dbLock.Unlock()
+		cgm.recordSweep(now, evicted, remaining)
+		cgm.reapAll(reaped, ReasonExpired)
+		return
+	}
 
+	sampler := newTTLSampler(now)
 	for key, ev := range cgm.db {
-		if !ev.Expiry.IsZero() && now.After(ev.Expiry) {
-			delete(cgm.db, key)
-			if cgm.reaper != nil {
-				wg.Add(1)
-				go func(value interface{}) {
-					cgm.reaper(value)
-					wg.Done()
-				}(ev.Value)
+		if cgm.evictable(ev, now) {
+			cgm.remove(cgm.db, key)
+			sampler.evict()
+			if cgm.reaping() {
+				reaped = append(reaped, Pair{key, ev.Value})
 			}
+		} else {
+			sampler.add(ev.Expiry)
 		}
 	}
 
 	cgm.dbLock.Unlock()
-	wg.Wait()
+	cgm.recordTTLs(sampler)
+	cgm.reapAll(reaped, ReasonExpired)
+}
+
+func (cgm *syncMutexMap) MaintainOnce() {
+	cgm.GC()
 }
 
 func (cgm *syncMutexMap) Load(key string) (interface{}, bool) {
+	key = cgm.canonical(key)
+	if cgm.isClosed() {
+		return nil, false
+	}
 	cgm.dbLock.RLock()
 	ev, ok := cgm.db[key]
 	cgm.dbLock.RUnlock()
 
-	if ok && (ev.Expiry.IsZero() || ev.Expiry.After(time.Now())) {
-		return ev.Value, true
+	if ok && (ev.Expiry.IsZero() || ev.Expiry.After(cgm.now())) {
+		cgm.loaded(key, true)
+		cgm.use(&ev.used)
+		cgm.accessed(cgm.mutate, key)
+		return cgm.copied(ev.Value), true
 	}
 
+	cgm.loaded(key, false)
 	return nil, false
 }
 
+func (cgm *syncMutexMap) LoadMany(keys []string) map[string]interface{} {
+	return cgm.loadMany(cgm.mutate, keys, func(keys []string) []*entry {
+		evs := make([]*entry, len(keys))
+		cgm.dbLock.RLock()
+		for i, key := range keys {
+			evs[i] = cgm.db[key]
+		}
+		cgm.dbLock.RUnlock()
+		return evs
+	})
+}
+
 func (cgm *syncMutexMap) LoadStore(key string) (interface{}, error) {
+	value, _, err := cgm.LoadStoreInfo(key)
+	return value, err
+}
+
+func (cgm *syncMutexMap) LoadStoreInfo(key string) (interface{}, bool, error) {
+	return cgm.loadStoreWith(context.Background(), key, cgm.lookup)
+}
+
+func (cgm *syncMutexMap) LoadStoreContext(ctx context.Context, key string) (interface{}, error) {
+	value, _, err := cgm.loadStoreWith(ctx, key, cgm.lookup)
+	return value, err
+}
+
+func (cgm *syncMutexMap) LoadStoreWith(key string, lookup func(string) (interface{}, error)) (interface{}, error) {
+	value, _, err := cgm.loadStoreWith(context.Background(), key, withoutContext(lookup))
+	return value, err
+}
+
+// loadStoreWith does the work of LoadStoreInfo, LoadStoreContext, and LoadStoreWith, passing ctx to
+// the lookup function.
+func (cgm *syncMutexMap) loadStoreWith(ctx context.Context, key string, lookup lookupFunc) (interface{}, bool, error) {
+	ctx, end := cgm.trace(ctx, "LoadStore", key)
+	value, looked, err := cgm.loadStoreInfo(ctx, key, lookup)
+	end(err)
+	key = cgm.canonical(key)
+	cgm.loadStored(key, looked, err)
+	if err != nil {
+		return nil, false, err
+	}
+	if !looked {
+		cgm.accessed(cgm.mutate, key)
+	}
+	return cgm.copied(value), looked, nil
+}
+
+// loadStoreInfo does the work of loadStoreWith, returning the value held by the Congomap.
+func (cgm *syncMutexMap) loadStoreInfo(ctx context.Context, key string, lookup lookupFunc) (interface{}, bool, error) {
+	key = cgm.canonical(key)
+	if err := cgm.checkKey(key); err != nil {
+		return nil, false, err
+	}
+	if err := cgm.admit(); err != nil {
+		return nil, false, err
+	}
+	defer cgm.release()
 	cgm.dbLock.Lock()
 	defer cgm.dbLock.Unlock()
 
 	ev, ok := cgm.db[key]
-	if ok && (ev.Expiry.IsZero() || ev.Expiry.After(time.Now())) {
-		return ev.Value, nil
+	if ok && (ev.Expiry.IsZero() || ev.Expiry.After(cgm.now())) {
+		cgm.use(&ev.used)
+		cgm.refreshAhead(key, ev, lookup, cgm.mutate)
+		return ev.Value, false, nil
+	}
+
+	value, err := cgm.fetch(ctx, lookup, key, cgm.Store)
+	if err != nil && ok && cgm.servesStale(ev, cgm.now()) {
+		return ev.Value, false, nil
 	}
 
 	var wg sync.WaitGroup
 	defer wg.Wait()
-	if ok && cgm.reaper != nil {
+	if ok && cgm.reaping() {
 		wg.Add(1)
 		go func(value interface{}) {
-			cgm.reaper(value)
+			cgm.reap(key, value, ReasonExpired)
 			wg.Done()
 		}(ev.Value)
 	}
 
-	value, err := cgm.lookup(key)
 	if err != nil {
-		delete(cgm.db, key)
-		return nil, err
+		cgm.remove(cgm.db, key)
+		return nil, false, err
 	}
 
-	cgm.db[key] = newExpiringValue(value, cgm.ttl)
-	return value, nil
+	cgm.put(cgm.db, key, cgm.lookupValue(value))
+	cgm.reapAll(cgm.evict(cgm.db, key), ReasonEvicted)
+	return value, true, nil
+}
+
+func (cgm *syncMutexMap) LoadStoreAll(keys []string) map[string]LoadStoreResult {
+	return loadStoreAll(cgm, keys)
+}
+
+func (cgm *syncMutexMap) NextExpiry() (time.Time, bool) {
+	var next time.Time
+	cgm.dbLock.RLock()
+	for _, ev := range cgm.db {
+		next = earliest(next, ev)
+	}
+	cgm.dbLock.RUnlock()
+	return next, !next.IsZero()
 }
 
 func (cgm *syncMutexMap) Store(key string, value interface{}) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return
+	}
+	defer cgm.release()
 	cgm.dbLock.Lock()
 
 	ev, ok := cgm.db[key]
 
 	var wg sync.WaitGroup
-	if ok && cgm.reaper != nil {
+	if ok && cgm.reaping() {
 		wg.Add(1)
 		go func(value interface{}) {
-			cgm.reaper(value)
+			cgm.reap(key, value, ReasonReplaced)
 			wg.Done()
 		}(ev.Value)
 	}
 
-	cgm.db[key] = newExpiringValue(value, cgm.ttl)
+	cgm.put(cgm.db, key, cgm.storeValue(key, value))
+	evicted := cgm.evict(cgm.db, key)
 	cgm.dbLock.Unlock()
+	cgm.reapAll(evicted, ReasonEvicted)
 	wg.Wait()
 }
 
+func (cgm *syncMutexMap) StoreMany(pairs map[string]interface{}) {
+	if cgm.admit() != nil {
+		return
+	}
+	defer cgm.release()
+	evs := cgm.storable(pairs)
+	var replaced, evicted []Pair
+	cgm.dbLock.Lock()
+	for key, ev := range evs {
+		if prev, ok := cgm.db[key]; ok && cgm.reaping() {
+			replaced = append(replaced, Pair{key, prev.Value})
+		}
+		cgm.put(cgm.db, key, ev)
+		evicted = append(evicted, cgm.evict(cgm.db, key)...)
+	}
+	cgm.dbLock.Unlock()
+	cgm.reapAll(replaced, ReasonReplaced)
+	cgm.reapAll(evicted, ReasonEvicted)
+}
+
+func (cgm *syncMutexMap) Snapshot() map[string]interface{} {
+	return cgm.snapshot(cgm)
+}
+
+func (cgm *syncMutexMap) Len() int {
+	return liveCount(cgm)
+}
+
+func (cgm *syncMutexMap) LoadAndDelete(key string) (interface{}, bool) {
+	return loadAndDelete(cgm.mutate, cgm.canonical(key))
+}
+
+func (cgm *syncMutexMap) LoadOrStore(key string, value interface{}) (interface{}, bool) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return nil, false
+	}
+	defer cgm.release()
+	return loadOrStore(cgm.mutate, key, cgm.storeValue(key, value))
+}
+
+func (cgm *syncMutexMap) StoreWithTTL(key string, value interface{}, ttl time.Duration) {
+	cgm.Store(key, cgm.withTTL(value, ttl))
+}
+
+func (cgm *syncMutexMap) StoreReturning(key string, value interface{}) (interface{}, bool) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return nil, false
+	}
+	defer cgm.release()
+	return storeReturning(cgm.mutate, key, cgm.storeValue(key, value))
+}
+
+func (cgm *syncMutexMap) Swap(key string, value interface{}) (interface{}, bool) {
+	return cgm.StoreReturning(key, value)
+}
+
 func (cgm *syncMutexMap) Keys() (keys []string) {
 	cgm.dbLock.RLock()
 	defer cgm.dbLock.RUnlock()
@@ -166,9 +454,47 @@ func (cgm *syncMutexMap) Keys() (keys []string) {
 	return
 }
 
-func (cgm *syncMutexMap) Pairs() <-chan *Pair {
+func (cgm *syncMutexMap) AppendKeys(buf []string) []string {
+	cgm.dbLock.RLock()
+	defer cgm.dbLock.RUnlock()
+	for k := range cgm.db {
+		buf = append(buf, k)
+	}
+	return buf
+}
+
+func (cgm *syncMutexMap) KeysMatching(match func(string) bool) []string {
+	var keys []string
+	cgm.dbLock.RLock()
+	for k := range cgm.db {
+		if match(k) {
+			keys = append(keys, k)
+		}
+	}
+	cgm.dbLock.RUnlock()
+	return keys
+}
+
+func (cgm *syncMutexMap) KeysWithPrefix(prefix string) []string {
+	return cgm.KeysMatching(hasPrefix(prefix))
+}
+
+func (cgm *syncMutexMap) KeysPage(cursor string, limit int) ([]string, string) {
+	p := newKeyPager(cursor, limit)
+	cgm.dbLock.RLock()
+	for k := range cgm.db {
+		p.add(k)
+	}
+	cgm.dbLock.RUnlock()
+	return p.page()
+}
+
+func (cgm *syncMutexMap) Pairs() <-chan Pair {
+	if cgm.snapshotPairs {
+		return snapshotPairs(cgm)
+	}
 	keys := make([]string, 0, len(cgm.db))
-	evs := make([]*ExpiringValue, 0, len(cgm.db))
+	evs := make([]*entry, 0, len(cgm.db))
 
 	cgm.dbLock.RLock()
 	for k, v := range cgm.db {
@@ -177,18 +503,18 @@ func (cgm *syncMutexMap) Pairs() <-chan *Pair {
 	}
 	cgm.dbLock.RUnlock()
 
-	pairs := make(chan *Pair)
+	pairs := newPairs(len(keys))
 
-	go func(pairs chan<- *Pair) {
-		now := time.Now()
+	go func(pairs chan<- Pair) {
+		now := cgm.now()
 
 		var wg sync.WaitGroup
 		wg.Add(len(keys))
 
 		for i, key := range keys {
-			go func(key string, ev *ExpiringValue) {
+			go func(key string, ev *entry) {
 				if ev.Expiry.IsZero() || ev.Expiry.After(now) {
-					pairs <- &Pair{key, ev.Value}
+					pairs <- Pair{key, ev.Value}
 				}
 				wg.Done()
 			}(key, evs[i])
@@ -201,39 +527,68 @@ func (cgm *syncMutexMap) Pairs() <-chan *Pair {
 	return pairs
 }
 
+func (cgm *syncMutexMap) PairsByExpiry() <-chan Pair {
+	cgm.dbLock.RLock()
+	eps := make([]expiringPair, 0, len(cgm.db))
+	for key, ev := range cgm.db {
+		eps = append(eps, expiringPair{key, ev})
+	}
+	cgm.dbLock.RUnlock()
+	return pairsByExpiry(eps, cgm.now())
+}
+
 func (cgm *syncMutexMap) Close() error {
-	close(cgm.halt)
-	return nil
+	if cgm.closing() {
+		close(cgm.halt)
+		if cgm.noGoroutine {
+			cgm.shutdown()
+		}
+	}
+	<-cgm.done
+	cgm.closeEvents()
+	return cgm.closeErr()
 }
 
-func (cgm *syncMutexMap) run() {
-	gcPeriodicity := 15 * time.Minute
-	if cgm.ttl > 0 && cgm.ttl <= time.Second {
-		gcPeriodicity = time.Minute
+func (cgm *syncMutexMap) running() bool {
+	select {
+	case <-cgm.done:
+		return false
+	default:
+		return true
 	}
+}
+
+func (cgm *syncMutexMap) run() {
+	ticks, stop := cgm.gcTicker()
+	defer stop()
 
 	active := true
 	for active {
 		select {
-		case <-time.After(gcPeriodicity):
-			cgm.GC()
+		case <-ticks:
+			if cgm.gcDue() {
+				cgm.GC()
+			}
 		case <-cgm.halt:
 			active = false
 		}
 	}
+	cgm.shutdown()
+}
 
-	if cgm.reaper != nil {
+// shutdown reaps the values remaining once the Congomap is closed, then signals that it is done. It
+// is invoked by the run go routine, or by Close when NoGoroutine was specified.
+func (cgm *syncMutexMap) shutdown() {
+	defer close(cgm.done)
+
+	if cgm.reaping() {
 		cgm.dbLock.Lock()
-		var wg sync.WaitGroup
-		wg.Add(len(cgm.db))
+		reaped := make([]Pair, 0, len(cgm.db))
 		for key, ev := range cgm.db {
-			delete(cgm.db, key)
-			go func(ev *ExpiringValue) {
-				cgm.reaper(ev.Value)
-				wg.Done()
-			}(ev)
+			cgm.remove(cgm.db, key)
+			reaped = append(reaped, Pair{key, ev.Value})
 		}
-		wg.Wait()
+		cgm.reapAll(reaped, ReasonClosed)
 		cgm.dbLock.Unlock()
 	}
 }
@@ -55,9 +55,9 @@ func (cgm *Template) LoadStore(key string) (interface{}, error) {
 	return nil, errors.New("TODO")
 }
 
-func (cgm *Template) Pairs() <-chan *Pair {
-	ch := make(chan *Pair)
-	go func(ch chan<- *Pair) {
+func (cgm *Template) Pairs() <-chan Pair {
+	ch := make(chan Pair)
+	go func(ch chan<- Pair) {
 		close(ch)
 	}(ch)
 	return ch
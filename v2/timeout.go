@@ -0,0 +1,65 @@
+package congomap
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ErrLookupTimeout is returned by LoadStore when the lookup callback function does not return
+// within the duration specified by LookupTimeout.
+type ErrLookupTimeout struct {
+	Key     string
+	Timeout time.Duration
+}
+
+func (e ErrLookupTimeout) Error() string {
+	return fmt.Sprintf("congomap: lookup %q: timed out after %s", e.Key, e.Timeout)
+}
+
+// LookupTimeout is used to specify the longest LoadStore waits for the lookup callback function to
+// return, so a hung lookup cannot hold the lock of its key, or of the entire Congomap, forever. When
+// it does not return in time, LoadStore returns ErrLookupTimeout, and no value is stored for the
+// key, even should the lookup callback function eventually return one. A lookup callback function
+// specified by LookupContext is passed a context that is done when the lookup times out, so it may
+// stop early. Because the lookup callback function is invoked by another go routine, it terminates
+// the program should it panic when RecoverPanics(false) is specified.
+func LookupTimeout(duration time.Duration) Setter {
+	return configure(func(c *config) error {
+		if duration <= 0 {
+			return ErrInvalidDuration(duration)
+		}
+		c.lookupTimeout = duration
+		return nil
+	})
+}
+
+// callLookupTimeout does the work of callLookup when LookupTimeout was specified, invoking the
+// lookup function from another go routine, and abandoning it once it times out.
+func (c *config) callLookupTimeout(parent context.Context, lookup lookupFunc, key string) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(parent, c.lookupTimeout)
+	defer cancel()
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+	done := make(chan result, 1) // buffered so an abandoned lookup does not block forever
+	go func() {
+		var r result
+		if err := c.protect("lookup", func() { r.value, r.err = lookup(ctx, key) }); err != nil {
+			r.err = err
+		}
+		done <- r
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		if err := parent.Err(); err != nil {
+			return nil, err // the caller gave up first
+		}
+		return nil, ErrLookupTimeout{Key: key, Timeout: c.lookupTimeout}
+	}
+}
@@ -0,0 +1,33 @@
+package congomap
+
+import "context"
+
+// Tracer is invoked as an operation of a Congomap begins, with the name of the operation and the
+// key it concerns, and returns the context passed to the rest of the operation, and a function the
+// Congomap invokes with the error the operation returns once it finishes.
+type Tracer func(ctx context.Context, operation, key string) (context.Context, func(error))
+
+// Trace is used to specify a Tracer invoked as each LoadStore, LoadStoreContext, LoadStoreInfo, and
+// LoadStoreWith, and each invocation of the lookup callback function, begins. The operations are
+// named "LoadStore" and "Lookup", and because the context returned for a LoadStore is passed to the
+// lookup it invokes, a Tracer that starts a span for each makes slow cache fills show up in
+// distributed traces. The otelcongomap package provides a Tracer for OpenTelemetry, so this
+// package itself need not depend upon it.
+func Trace(tracer Tracer) Setter {
+	return configure(func(c *config) error {
+		c.tracer = tracer
+		return nil
+	})
+}
+
+// untraced is returned by trace when no Tracer was specified.
+func untraced(error) {}
+
+// trace invokes the Tracer as the operation begins, returning the context for the rest of the
+// operation, and the function to invoke with its error once it finishes.
+func (c *config) trace(ctx context.Context, operation, key string) (context.Context, func(error)) {
+	if c.tracer == nil {
+		return ctx, untraced
+	}
+	return c.tracer(ctx, operation, key)
+}
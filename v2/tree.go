@@ -0,0 +1,96 @@
+package congomap
+
+import (
+	"sort"
+	"strings"
+)
+
+// Tree is a view of a Congomap that interprets its keys as paths of names joined by a separator,
+// such as "a/b/c", so that the keys under a common path may be listed or deleted together.
+//
+// A Tree holds no state of its own; it queries the Congomap it views, so it sees every key stored
+// in the Congomap, however it was stored.
+type Tree struct {
+	cgm       Congomap
+	separator string
+}
+
+// NewTree returns a Tree view of the Congomap, using the specified separator between the names of
+// each key's path. The separator must not be empty.
+//
+//	tree := congomap.NewTree(cgm, "/")
+//	cgm.Store("db/primary/host", "10.0.0.1")
+//	cgm.Store("db/primary/port", 5432)
+//	cgm.Store("db/replica/host", "10.0.0.2")
+//	fmt.Println(tree.Children("db"))      // [primary replica]
+//	fmt.Println(tree.Keys("db/primary")) // [db/primary/host db/primary/port]
+func NewTree(cgm Congomap, separator string) *Tree {
+	return &Tree{cgm: cgm, separator: separator}
+}
+
+// canonical returns the canonical form of the path, as the Congomap stores its keys, when the
+// Congomap was provided by this library.
+func (t *Tree) canonical(path string) string {
+	if c, ok := t.cgm.(configurable); ok {
+		return c.getConfig().canonical(path)
+	}
+	return path
+}
+
+// inSubtree returns true when the key is the path itself or lies beneath it. Every key lies beneath
+// the empty path.
+func (t *Tree) inSubtree(key, path string) bool {
+	return path == "" || key == path || strings.HasPrefix(key, path+t.separator)
+}
+
+// Keys returns the sorted list of keys at or beneath the specified path.
+func (t *Tree) Keys(path string) []string {
+	path = t.canonical(path)
+	var keys []string
+	for _, key := range t.cgm.KeysWithPrefix(path) {
+		if t.inSubtree(key, path) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Children returns the sorted list of distinct names immediately beneath the specified path, whether
+// each name is itself a key or only the path to other keys.
+func (t *Tree) Children(path string) []string {
+	prefix := t.canonical(path)
+	if path != "" {
+		prefix += t.separator
+	}
+	seen := make(map[string]struct{})
+	var names []string
+	for _, key := range t.cgm.KeysWithPrefix(prefix) {
+		if len(key) == len(prefix) {
+			continue
+		}
+		name := key[len(prefix):]
+		if i := strings.Index(name, t.separator); i >= 0 {
+			name = name[:i]
+		}
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Delete removes every key at or beneath the specified path, and returns the number of keys
+// removed. Keys stored beneath the path while Delete is running may not be removed, and keys whose
+// values have expired are left for GC, and not counted.
+func (t *Tree) Delete(path string) int {
+	var n int
+	for _, key := range t.Keys(path) {
+		if t.cgm.DeleteIf(key, func(interface{}) bool { return true }) {
+			n++
+		}
+	}
+	return n
+}
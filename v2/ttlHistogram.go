@@ -0,0 +1,107 @@
+package congomap
+
+import (
+	"sync"
+	"time"
+)
+
+// TTLBounds are the upper bounds of the buckets of a TTLHistogram. It must not be modified.
+var TTLBounds = []time.Duration{time.Second, 10 * time.Second, time.Minute, 10 * time.Minute, time.Hour, 24 * time.Hour}
+
+// TTLHistogram counts the values resident in a Congomap by their remaining time-to-live, as sampled
+// by its most recent GC.
+type TTLHistogram struct {
+	// Sampled is when the histogram was sampled, or the zero time when GC has not yet run.
+	Sampled time.Time
+
+	// Counts holds the number of values whose remaining time-to-live is no longer than the
+	// corresponding element of TTLBounds, but longer than the previous element. Its final element
+	// counts the values whose remaining time-to-live is longer than every bound.
+	Counts []int
+
+	// Expired is the number of values that had expired, but were retained to be served stale.
+	Expired int
+
+	// Never is the number of values that never expire.
+	Never int
+}
+
+// ttlSampler accumulates a TTLHistogram during GC. Its add method may be invoked concurrently.
+type ttlSampler struct {
+	lock    sync.Mutex
+	h       TTLHistogram
+	evicted int
+}
+
+func newTTLSampler(now time.Time) *ttlSampler {
+	return &ttlSampler{h: TTLHistogram{Sampled: now, Counts: make([]int, len(TTLBounds)+1)}}
+}
+
+// add counts a value with the specified expiry that GC retained.
+func (s *ttlSampler) add(expiry time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if expiry.IsZero() {
+		s.h.Never++
+		return
+	}
+	remaining := expiry.Sub(s.h.Sampled)
+	if remaining <= 0 {
+		s.h.Expired++
+		return
+	}
+	i := 0
+	for i < len(TTLBounds) && remaining > TTLBounds[i] {
+		i++
+	}
+	s.h.Counts[i]++
+}
+
+// evict counts a value that GC removed.
+func (s *ttlSampler) evict() {
+	s.lock.Lock()
+	s.evicted++
+	s.lock.Unlock()
+}
+
+// recordTTLs retains the histogram accumulated by the sampler as the most recent one, along with a
+// report of the GC that sampled it.
+func (c *config) recordTTLs(s *ttlSampler) {
+	remaining := s.h.Expired + s.h.Never
+	for _, count := range s.h.Counts {
+		remaining += count
+	}
+	c.ttlLock.Lock()
+	c.ttls = s.h
+	c.ttlLock.Unlock()
+	c.recordSweep(s.h.Sampled, s.evicted, remaining)
+}
+
+// recordSweep retains the report of a GC that started at the specified time.
+func (c *config) recordSweep(started time.Time, evicted, remaining int) {
+	r := GCReport{Started: started, Duration: c.now().Sub(started), Evicted: evicted, Remaining: remaining}
+	c.ttlLock.Lock()
+	c.gcReport = r
+	c.ttlLock.Unlock()
+	c.debugf("congomap: GC evicted %d values and retained %d in %s", r.Evicted, r.Remaining, r.Duration)
+}
+
+// TTLDistribution returns the histogram of remaining time-to-live of the values resident in the
+// Congomap, as sampled by its most recent GC, so operators may see whether a cache is mostly fresh
+// or mostly about to expire. It returns ErrUnsupportedOption for a Congomap not provided by this
+// library, and ErrOptionConflict for one created with ExpiryHeap, whose GC does not sample it.
+func TTLDistribution(cgm Congomap) (TTLHistogram, error) {
+	c, ok := cgm.(configurable)
+	if !ok {
+		return TTLHistogram{}, ErrUnsupportedOption{}
+	}
+	cfg := c.getConfig()
+	if cfg.expiries != nil {
+		return TTLHistogram{}, ErrOptionConflict("TTLDistribution is not sampled with ExpiryHeap")
+	}
+	cfg.ttlLock.Lock()
+	defer cfg.ttlLock.Unlock()
+	h := cfg.ttls
+	h.Counts = append([]int(nil), h.Counts...)
+	return h, nil
+}
@@ -1,30 +1,88 @@
 package congomap
 
 import (
+	"context"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type twoLevelMap struct {
-	db     map[string]*lockingValue
-	dbLock sync.RWMutex
+	count  int64 // keys held across every shard; first for 64-bit alignment
+	shards []twoLevelShard
 
-	halt   chan struct{}
-	lookup func(string) (interface{}, error)
-	reaper func(interface{})
-	ttl    time.Duration
+	halt chan struct{}
+	done chan struct{} // closed when run returns
+
+	config
 }
 
 // lockingValue is a pointer to a value and the lock that protects it. All access to the
 // ExpiringValue ought to be protected by use of the lock.
 type lockingValue struct {
-	l  sync.RWMutex
-	ev *ExpiringValue // nil means not present
+	expires int64 // UnixNano of ev.Expiry, or 0, read without the lock; first for 64-bit alignment
+	used    int64 // UnixNano of most recent use, when EvictLRU was specified
+	charged int64 // cost of ev counted against MaxCost, or -1 once removed from the data store
+	refs    int32 // operations that obtained it from slot and have not yet vacated it
+	l       sync.RWMutex
+	ev      *entry // nil means not present
+}
+
+// set replaces the value, and must be invoked while holding the lock.
+func (lv *lockingValue) set(ev *entry) {
+	lv.ev = ev
+	var expires int64
+	if ev != nil && !ev.Expiry.IsZero() {
+		expires = ev.Expiry.UnixNano()
+	}
+	atomic.StoreInt64(&lv.expires, expires)
+}
+
+// set replaces the value of key, accounting for its cost and indexing its expiry, and must be
+// invoked while holding the key's lock. The cost of a value set after its key was removed from the
+// data store is not counted.
+func (cgm *twoLevelMap) set(key string, lv *lockingValue, ev *entry) {
+	lv.set(ev)
+	cgm.indexExpiry(key, ev)
+	if cgm.maxCost == 0 {
+		return
+	}
+	cost := cgm.costOf(key, ev)
+	for {
+		charged := atomic.LoadInt64(&lv.charged)
+		if charged < 0 {
+			return // removed
+		}
+		if atomic.CompareAndSwapInt64(&lv.charged, charged, cost) {
+			atomic.AddInt64(&cgm.spent, cost-charged)
+			return
+		}
+	}
+}
+
+// discharge stops counting the cost of the value of a key removed from the data store.
+func (cgm *twoLevelMap) discharge(lv *lockingValue) {
+	if cgm.maxCost > 0 {
+		if charged := atomic.SwapInt64(&lv.charged, -1); charged > 0 {
+			atomic.AddInt64(&cgm.spent, -charged)
+		}
+	}
+}
+
+// expiry returns the expiry of the value without acquiring the lock. The zero time means the value
+// never expires, or that it is a placeholder.
+func (lv *lockingValue) expiry() time.Time {
+	if expires := atomic.LoadInt64(&lv.expires); expires != 0 {
+		return time.Unix(0, expires)
+	}
+	return time.Time{}
 }
 
 // NewTwoLevelMap returns a map that uses two levels of locks to serialize access to a key-value
-// map. The top-level lock guards insertion and removal of keys in the map. The values of those keys
-// are locks that guard each individual datum value for that key.
+// map. The top-level lock guards insertion and removal of keys in the map, which may be split into
+// shards by ShardCount. The values of those keys are locks that guard each individual datum value
+// for that key.
 //
 // Note that it is important to call the Close method on the returned data structure when it's no
 // longer needed to free CPU and channel resources back to the runtime.
@@ -36,220 +94,739 @@ type lockingValue struct {
 //	defer func() { _ = cgm.Close() }()
 func NewTwoLevelMap(setters ...Setter) (Congomap, error) {
 	cgm := &twoLevelMap{
-		db:   make(map[string]*lockingValue),
-		halt: make(chan struct{}),
+		shards: make([]twoLevelShard, 1),
+		halt:   make(chan struct{}),
+		done:   make(chan struct{}),
 	}
-	for _, setter := range setters {
-		if err := setter(cgm); err != nil {
-			return nil, err
-		}
+	if err := applySetters(cgm, setters); err != nil {
+		return nil, err
+	}
+	for i := range cgm.shards {
+		cgm.shards[i].db = make(map[string]*lockingValue)
 	}
 	if cgm.lookup == nil {
-		cgm.lookup = func(_ string) (interface{}, error) {
+		cgm.lookup = func(context.Context, string) (interface{}, error) {
 			return nil, ErrNoLookupDefined{}
 		}
 	}
-	go cgm.run()
+	if !cgm.noGoroutine {
+		go cgm.run()
+	}
 	return cgm, nil
 }
 
-func (cgm *twoLevelMap) Lookup(lookup func(string) (interface{}, error)) error {
-	cgm.lookup = lookup
-	return nil
+func (cgm *twoLevelMap) All() func(yield func(string, interface{}) bool) {
+	return all(cgm)
 }
 
-func (cgm *twoLevelMap) Reaper(reaper func(interface{})) error {
-	cgm.reaper = reaper
-	return nil
-}
+func (cgm *twoLevelMap) Clear() {
+	cgm.lockAll()
+	dbs := make([]map[string]*lockingValue, len(cgm.shards))
+	for i := range cgm.shards {
+		dbs[i] = cgm.shards[i].db
+		cgm.shards[i].db = make(map[string]*lockingValue)
+		if cgm.maxCost > 0 {
+			for _, lv := range dbs[i] {
+				cgm.discharge(lv)
+			}
+		}
+	}
+	atomic.StoreInt64(&cgm.count, 0)
+	cgm.unlockAll()
 
-func (cgm *twoLevelMap) TTL(duration time.Duration) error {
-	if duration <= 0 {
-		return ErrInvalidDuration(duration)
+	if !cgm.reaping() {
+		return
+	}
+	var reaped []Pair
+	for _, db := range dbs {
+		for key, lv := range db {
+			cgm.lockKey(&lv.l, key, "Clear")
+			if lv.ev != nil { // placeholders have no value to reap
+				reaped = append(reaped, Pair{key, lv.ev.Value})
+			}
+			cgm.unlockKey(&lv.l, key)
+		}
 	}
-	cgm.ttl = duration
-	return nil
+	cgm.reapAll(reaped, ReasonCleared)
 }
 
 func (cgm *twoLevelMap) Delete(key string) {
-	cgm.dbLock.Lock()
-	lv, ok := cgm.db[key]
-	delete(cgm.db, key)
-	cgm.dbLock.Unlock()
+	key = cgm.canonical(key)
+	s := cgm.shard(key)
+	s.dbLock.Lock()
+	lv, ok := s.db[key]
+	if ok {
+		cgm.drop(s, key, lv)
+	}
+	s.dbLock.Unlock()
+
+	if ok && cgm.reaping() {
+		cgm.lockKey(&lv.l, key, "Delete")
+		ev := lv.ev
+		cgm.unlockKey(&lv.l, key)
+		if ev != nil { // placeholders have no value to reap
+			cgm.reap(key, ev.Value, ReasonDeleted)
+		}
+	}
+}
 
-	if ok && cgm.reaper != nil {
-		cgm.reaper(lv.ev.Value)
+func (cgm *twoLevelMap) Prune(n int) int {
+	cgm.lockAll()
+	keys := cgm.oldest(n, func(fn func(string, time.Time, int64) bool) {
+		for i := range cgm.shards {
+			cgm.shards[i].eachLockingValue(fn)
+		}
+	})
+	evicted := make(map[string]*lockingValue, len(keys))
+	for _, key := range keys {
+		s := cgm.shard(key)
+		lv := s.db[key]
+		evicted[key] = lv
+		cgm.drop(s, key, lv)
+	}
+	cgm.unlockAll()
+	if cgm.reaping() {
+		cgm.reapEvicted(evicted)
 	}
+	return len(keys)
+}
+
+func (cgm *twoLevelMap) DeleteIf(key string, fn func(interface{}) bool) bool {
+	return deleteIf(cgm.mutate, cgm.canonical(key), fn)
+}
+
+func (cgm *twoLevelMap) DeleteMany(keys []string) {
+	keys = cgm.canonicalKeys(keys)
+	removed := make(map[string]*lockingValue, len(keys))
+	for _, key := range keys {
+		s := cgm.shard(key)
+		s.dbLock.Lock()
+		if lv, ok := s.db[key]; ok {
+			cgm.drop(s, key, lv)
+			removed[key] = lv
+		}
+		s.dbLock.Unlock()
+	}
+
+	if cgm.reaping() {
+		reaped := make([]Pair, 0, len(removed))
+		for key, lv := range removed {
+			cgm.lockKey(&lv.l, key, "DeleteMany")
+			ev := lv.ev
+			cgm.unlockKey(&lv.l, key)
+			if ev != nil { // placeholders have no value to reap
+				reaped = append(reaped, Pair{key, ev.Value})
+			}
+		}
+		cgm.reapAll(reaped, ReasonDeleted)
+	}
+}
+
+// slot returns the lockingValue for key, inserting a placeholder when key is not present. When that
+// makes the data store hold more than MaxEntries keys, sampled keys are evicted. The caller must
+// invoke vacate once it no longer needs the lockingValue.
+func (cgm *twoLevelMap) slot(key string) *lockingValue {
+	s := cgm.shard(key)
+	s.dbLock.RLock()
+	lv, ok := s.db[key]
+	if ok {
+		atomic.AddInt32(&lv.refs, 1) // while holding the lock, so vacate sees it
+	}
+	s.dbLock.RUnlock()
+	if ok {
+		cgm.use(&lv.used)
+		return lv
+	}
+
+	var evicted map[string]*lockingValue
+	s.dbLock.Lock()
+	lv, ok = s.db[key]
+	if !ok {
+		lv = &lockingValue{}
+		cgm.use(&lv.used)
+		cgm.insert(s, key, lv)
+		evicted = cgm.evictLocked(s, key)
+	}
+	atomic.AddInt32(&lv.refs, 1)
+	s.dbLock.Unlock()
+	cgm.reapEvicted(evicted)
+	return lv
+}
+
+// vacate releases the lockingValue of key obtained from slot, and must be invoked while holding the
+// key's lock. When it is left a placeholder, such as after a failed lookup, and no other operation
+// has obtained it from slot to fill it, it is removed from the data store, so probing keys that
+// cannot be looked up does not grow the data store without bound.
+func (cgm *twoLevelMap) vacate(key string, lv *lockingValue) {
+	if lv.ev == nil && atomic.LoadInt32(&lv.refs) == 1 {
+		s := cgm.shard(key)
+		s.dbLock.Lock()
+		if atomic.LoadInt32(&lv.refs) == 1 && s.db[key] == lv {
+			cgm.drop(s, key, lv)
+		}
+		s.dbLock.Unlock()
+	}
+	atomic.AddInt32(&lv.refs, -1)
+}
+
+// shed evicts sampled keys, other than the key just written, while the values held cost more than
+// MaxCost. It must not be invoked while holding the lock of any key.
+func (cgm *twoLevelMap) shed(key string) {
+	if cgm.maxCost == 0 || !cgm.full(0) {
+		return
+	}
+	s := cgm.shard(key)
+	s.dbLock.Lock()
+	evicted := cgm.evictLocked(s, key)
+	s.dbLock.Unlock()
+	cgm.reapEvicted(evicted)
+}
+
+// evictLocked removes keys sampled from the shard of the key just written, other than that key,
+// while the data store holds more than MaxEntries keys, or values costing more than MaxCost, and
+// returns those to be reaped. It must be invoked while holding the write lock of the shard.
+func (cgm *twoLevelMap) evictLocked(s *twoLevelShard, key string) map[string]*lockingValue {
+	var evicted map[string]*lockingValue
+	for cgm.full(int(atomic.LoadInt64(&cgm.count))) {
+		victim, found := cgm.victim(key, s.eachLockingValue)
+		if !found {
+			break
+		}
+		lv := s.db[victim]
+		if cgm.reaping() {
+			if evicted == nil {
+				evicted = make(map[string]*lockingValue)
+			}
+			evicted[victim] = lv
+		}
+		cgm.drop(s, victim, lv)
+	}
+	return evicted
+}
+
+// reapEvicted reaps the values of evicted keys from another goroutine, because the caller might be
+// a lookup holding the lock of an evicted key.
+func (cgm *twoLevelMap) reapEvicted(evicted map[string]*lockingValue) {
+	if len(evicted) == 0 {
+		return
+	}
+	cgm.goBackground(func() {
+		for key, lv := range evicted {
+			cgm.lockKey(&lv.l, key, "evict")
+			ev := lv.ev
+			cgm.unlockKey(&lv.l, key)
+			if ev != nil { // placeholders have no value to reap
+				cgm.reap(key, ev.Value, ReasonEvicted)
+			}
+		}
+	})
+}
+
+func (cgm *twoLevelMap) Do(key string, fn func(interface{}, bool) (interface{}, bool)) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return
+	}
+	defer cgm.release()
+	cgm.mutate(key, doMutator(fn, cgm.storeFunc(key)))
+}
+
+func (cgm *twoLevelMap) Update(key string, fn func(interface{}, bool) (interface{}, bool)) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return
+	}
+	defer cgm.release()
+	cgm.mutate(key, updateMutator(fn, cgm.storeFunc(key)))
+}
+
+// mutate invokes fn with the live value for key while holding the key's lock, and replaces that
+// value with what fn returns.
+func (cgm *twoLevelMap) mutate(key string, fn mutator) {
+	lv := cgm.slot(key)
+	defer cgm.shed(key) // after the key's lock is released
+
+	cgm.lockKey(&lv.l, key, "Do")
+	defer cgm.unlockKey(&lv.l, key)
+	defer cgm.vacate(key, lv) // before the key's lock is released
+
+	stored := lv.ev
+	ev := stored
+	if stored != nil && !stored.live(cgm.now()) {
+		ev = nil // expired values are never shown to fn
+	}
+
+	next, reap := fn(ev)
+	if next == ev {
+		return
+	}
+	cgm.set(key, lv, next)
+
+	// expired values being discarded are always reaped
+	if stored != nil && cgm.reaping() && (reap || ev == nil) {
+		cgm.reap(key, stored.Value, mutated(ev, next))
+	}
+}
+
+func (cgm *twoLevelMap) each(fn func(string, *entry) bool) {
+	keys, lockedValues := cgm.lockingValues()
+
+	for i, lv := range lockedValues {
+		lv.l.RLock()
+		more := lv.ev == nil || fn(keys[i], lv.ev) // placeholders have no value
+		lv.l.RUnlock()
+		if !more {
+			return
+		}
+	}
+}
+
+func (cgm *twoLevelMap) Expire(key string, duration time.Duration) bool {
+	key = cgm.canonical(key)
+	if cgm.admit() != nil {
+		return false
+	}
+	defer cgm.release()
+	return cgm.setExpiry(cgm.mutate, key, cgm.now().Add(duration))
+}
+
+func (cgm *twoLevelMap) Persist(key string) bool {
+	key = cgm.canonical(key)
+	if cgm.admit() != nil {
+		return false
+	}
+	defer cgm.release()
+	return cgm.setExpiry(cgm.mutate, key, time.Time{})
+}
+
+func (cgm *twoLevelMap) TTLRemaining(key string) (time.Duration, bool) {
+	key = cgm.canonical(key)
+	lv, ok := cgm.find(key)
+	if !ok {
+		return 0, false
+	}
+
+	lv.l.RLock()
+	ev := lv.ev
+	lv.l.RUnlock()
+	return remaining(ev, cgm.now())
+}
+
+func (cgm *twoLevelMap) Touch(key string) bool {
+	key = cgm.canonical(key)
+	if cgm.admit() != nil {
+		return false
+	}
+	defer cgm.release()
+	return cgm.touch(cgm.mutate, key, cgm.touchDuration())
 }
 
 func (cgm *twoLevelMap) GC() {
-	// NOTE: should lock lv first, but then want to parallel so lock on a lv won't block
-	// forever, but then would have race condition around deleting keys, hence, the key killer
-	keys := make(chan string, len(cgm.db))
+	now := cgm.now()
+	sampler := newTTLSampler(now)
+	cgm.gcBadLookups(now)
+
+	if cgm.expiries != nil {
+		var expired []expiringLockingValue
+		for _, key := range cgm.popExpired(now) {
+			if lv, ok := cgm.find(key); ok && cgm.evictableAt(lv.expiry(), now) {
+				expired = append(expired, expiringLockingValue{key, lv})
+			}
+		}
 
-	cgm.dbLock.Lock()
-	now := time.Now()
+		reaped := cgm.removeExpired(expired, now, sampler)
+		cgm.recordSweep(now, sampler.evicted, int(atomic.LoadInt64(&cgm.count)))
+		cgm.reapAll(reaped, ReasonExpired)
+		return
+	}
 
+	// Values are only examined while holding the lock of each shard as readers, and only by their
+	// expiry, which is read without acquiring their locks, so GC neither stalls writers for the
+	// whole sweep, nor waits for the lock of every key.
+	var expired []expiringLockingValue
+	for i := range cgm.shards {
+		s := &cgm.shards[i]
+		s.dbLock.RLock()
+		for key, lv := range s.db {
+			expiry := lv.expiry() // placeholders are counted as never expiring
+			if cgm.evictableAt(expiry, now) {
+				expired = append(expired, expiringLockingValue{key, lv})
+				continue
+			}
+			sampler.add(expiry)
+		}
+		s.dbLock.RUnlock()
+	}
+
+	reaped := cgm.removeExpired(expired, now, sampler)
+	cgm.recordTTLs(sampler)
+	cgm.reapAll(reaped, ReasonExpired)
+}
+
+// expiringLockingValue is a key whose value GC found to have expired.
+type expiringLockingValue struct {
+	key string
+	lv  *lockingValue
+}
+
+// removeExpired removes the expired values from the data store, using no more go routines than
+// there are processors, and returns the pairs to reap. Each key's lock is held while removing its
+// value, so a value being looked up or replaced is not removed once it is fresh. This acquires
+// the lock of a shard while holding the lock of a key, which is safe because nothing acquires the
+// lock of a key while holding the lock of a shard.
+func (cgm *twoLevelMap) removeExpired(expired []expiringLockingValue, now time.Time, sampler *ttlSampler) []Pair {
+	if len(expired) == 0 {
+		return nil
+	}
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(expired) {
+		workers = len(expired)
+	}
+
+	var reapedLock sync.Mutex
+	var reaped []Pair
+	work := make(chan expiringLockingValue)
 	var wg sync.WaitGroup
-	wg.Add(len(cgm.db))
-	for key, lv := range cgm.db {
-		go func(key string, lv *lockingValue) {
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
 			defer wg.Done()
+			for e := range work {
+				cgm.lockKey(&e.lv.l, e.key, "GC")
+				ev := e.lv.ev
+				if ev == nil || !cgm.evictable(ev, now) {
+					if ev != nil {
+						sampler.add(ev.Expiry) // replaced since it was examined
+					}
+					cgm.unlockKey(&e.lv.l, e.key)
+					continue
+				}
+				s := cgm.shard(e.key)
+				s.dbLock.Lock()
+				removed := s.db[e.key] == e.lv
+				if removed {
+					cgm.drop(s, e.key, e.lv)
+				}
+				s.dbLock.Unlock()
+				cgm.unlockKey(&e.lv.l, e.key)
 
-			lv.l.Lock()
-			defer lv.l.Unlock()
-
-			if lv.ev != nil && !lv.ev.Expiry.IsZero() && now.After(lv.ev.Expiry) {
-				keys <- key
-				if cgm.reaper != nil {
-					cgm.reaper(lv.ev.Value)
+				if !removed {
+					continue // already removed by another operation, which reaps it
+				}
+				sampler.evict()
+				if cgm.reaping() {
+					reapedLock.Lock()
+					reaped = append(reaped, Pair{e.key, ev.Value})
+					reapedLock.Unlock()
 				}
 			}
-		}(key, lv)
+		}()
+	}
+	for _, e := range expired {
+		work <- e
 	}
+	close(work)
 	wg.Wait()
+	return reaped
+}
 
-	var keyKiller sync.WaitGroup
-	keyKiller.Add(1)
-	go func(keys <-chan string) {
-		for key := range keys {
-			delete(cgm.db, key)
-		}
-		keyKiller.Done()
-	}(keys)
-
-	close(keys)
-	keyKiller.Wait()
-	cgm.dbLock.Unlock()
+func (cgm *twoLevelMap) MaintainOnce() {
+	cgm.GC()
 }
 
 func (cgm *twoLevelMap) Load(key string) (interface{}, bool) {
-	cgm.dbLock.RLock()
-	lv, ok := cgm.db[key]
-	cgm.dbLock.RUnlock()
-
+	key = cgm.canonical(key)
+	if cgm.isClosed() {
+		return nil, false
+	}
+	lv, ok := cgm.find(key)
 	if !ok {
+		cgm.loaded(key, false)
 		return nil, false
 	}
 
 	lv.l.RLock()
-	defer lv.l.RUnlock()
-
-	if lv.ev != nil && (lv.ev.Expiry.IsZero() || lv.ev.Expiry.After(time.Now())) {
-		return lv.ev.Value, true
+	ev := lv.ev
+	lv.l.RUnlock()
+
+	if ev != nil && (ev.Expiry.IsZero() || ev.Expiry.After(cgm.now())) {
+		cgm.loaded(key, true)
+		cgm.use(&lv.used)
+		cgm.accessed(cgm.mutate, key)
+		return cgm.copied(ev.Value), true
 	}
 
+	cgm.loaded(key, false)
 	return nil, false
 }
 
+func (cgm *twoLevelMap) LoadMany(keys []string) map[string]interface{} {
+	return cgm.loadMany(cgm.mutate, keys, func(keys []string) []*entry {
+		evs := make([]*entry, len(keys))
+		for i, key := range keys {
+			if lv, ok := cgm.find(key); ok {
+				cgm.use(&lv.used)
+				lv.l.RLock()
+				evs[i] = lv.ev
+				lv.l.RUnlock()
+			}
+		}
+		return evs
+	})
+}
+
 func (cgm *twoLevelMap) LoadStore(key string) (interface{}, error) {
-	cgm.dbLock.RLock()
-	lv, ok := cgm.db[key]
-	cgm.dbLock.RUnlock()
-	if !ok {
-		cgm.dbLock.Lock()
-		lv, ok = cgm.db[key]
-		if !ok {
-			lv = &lockingValue{}
-			cgm.db[key] = lv
+	value, _, err := cgm.LoadStoreInfo(key)
+	return value, err
+}
+
+func (cgm *twoLevelMap) LoadStoreInfo(key string) (interface{}, bool, error) {
+	return cgm.loadStoreWith(context.Background(), key, cgm.lookup)
+}
+
+func (cgm *twoLevelMap) LoadStoreContext(ctx context.Context, key string) (interface{}, error) {
+	value, _, err := cgm.loadStoreWith(ctx, key, cgm.lookup)
+	return value, err
+}
+
+func (cgm *twoLevelMap) LoadStoreWith(key string, lookup func(string) (interface{}, error)) (interface{}, error) {
+	value, _, err := cgm.loadStoreWith(context.Background(), key, withoutContext(lookup))
+	return value, err
+}
+
+// loadStoreWith does the work of LoadStoreInfo, LoadStoreContext, and LoadStoreWith, passing ctx to
+// the lookup function.
+func (cgm *twoLevelMap) loadStoreWith(ctx context.Context, key string, lookup lookupFunc) (interface{}, bool, error) {
+	ctx, end := cgm.trace(ctx, "LoadStore", key)
+	value, looked, err := cgm.loadStoreInfo(ctx, key, lookup)
+	end(err)
+	key = cgm.canonical(key)
+	cgm.loadStored(key, looked, err)
+	if err != nil {
+		return nil, false, err
+	}
+	if !looked {
+		cgm.accessed(cgm.mutate, key)
+	}
+	return cgm.copied(value), looked, nil
+}
+
+// loadStoreInfo does the work of loadStoreWith, returning the value held by the Congomap.
+func (cgm *twoLevelMap) loadStoreInfo(ctx context.Context, key string, lookup lookupFunc) (interface{}, bool, error) {
+	key = cgm.canonical(key)
+	if err := cgm.checkKey(key); err != nil {
+		return nil, false, err
+	}
+	if err := cgm.admit(); err != nil {
+		return nil, false, err
+	}
+	defer cgm.release()
+
+	// a fresh value is found while holding the key's lock only as a reader, so concurrent readers
+	// of a hot key are not serialized; the lock is only acquired as a writer to look up a value
+	// that is missing or expired
+	if lv, ok := cgm.find(key); ok {
+		cgm.rlockKey(&lv.l, key, "LoadStore")
+		ev := lv.ev
+		lv.l.RUnlock()
+		if ev != nil && ev.live(cgm.now()) {
+			cgm.use(&lv.used)
+			cgm.refreshAhead(key, ev, lookup, cgm.mutate)
+			return ev.Value, false, nil
 		}
-		cgm.dbLock.Unlock()
 	}
 
-	lv.l.Lock()
-	defer lv.l.Unlock()
+	lv := cgm.slot(key)
+	defer cgm.shed(key) // after the key's lock is released
+
+	cgm.lockKey(&lv.l, key, "LoadStore")
+	defer cgm.unlockKey(&lv.l, key)
+	defer cgm.vacate(key, lv) // before the key's lock is released
 
 	// while waiting for lock, value might have been filled by another go-routine
-	if lv.ev != nil && (lv.ev.Expiry.IsZero() || lv.ev.Expiry.After(time.Now())) {
-		return lv.ev.Value, nil
+	if lv.ev != nil && (lv.ev.Expiry.IsZero() || lv.ev.Expiry.After(cgm.now())) {
+		cgm.use(&lv.used)
+		cgm.refreshAhead(key, lv.ev, lookup, cgm.mutate)
+		return lv.ev.Value, false, nil
+	}
+
+	value, err := cgm.fetch(ctx, lookup, key, cgm.Store)
+	if err != nil && lv.ev != nil && cgm.servesStale(lv.ev, cgm.now()) {
+		return lv.ev.Value, false, nil
 	}
 
 	var wg sync.WaitGroup
 	defer wg.Wait()
-	if ok && cgm.reaper != nil {
+	if lv.ev != nil && cgm.reaping() {
 		wg.Add(1)
 		go func(value interface{}) {
 			defer wg.Done()
-			cgm.reaper(value)
+			cgm.reap(key, value, ReasonExpired)
 		}(lv.ev.Value)
 	}
 
-	value, err := cgm.lookup(key)
 	if err != nil {
-		lv.ev = nil
-		return nil, err
+		cgm.set(key, lv, nil)
+		return nil, false, err
 	}
 
-	lv.ev = newExpiringValue(value, cgm.ttl)
-	return value, nil
+	cgm.set(key, lv, cgm.lookupValue(value))
+	return value, true, nil
 }
 
-func (cgm *twoLevelMap) Store(key string, value interface{}) {
-	cgm.dbLock.RLock()
-	lv, ok := cgm.db[key]
-	cgm.dbLock.RUnlock()
-	if !ok {
-		cgm.dbLock.Lock()
-		lv, ok = cgm.db[key]
-		if !ok {
-			lv = &lockingValue{}
-			cgm.db[key] = lv
+func (cgm *twoLevelMap) LoadStoreAll(keys []string) map[string]LoadStoreResult {
+	return loadStoreAll(cgm, keys)
+}
+
+func (cgm *twoLevelMap) NextExpiry() (time.Time, bool) {
+	keys, lockedValues := cgm.lockingValues()
+
+	var next time.Time
+	for i, lv := range lockedValues {
+		cgm.lockKey(&lv.l, keys[i], "NextExpiry")
+		if lv.ev != nil {
+			next = earliest(next, lv.ev)
 		}
-		cgm.dbLock.Unlock()
+		cgm.unlockKey(&lv.l, keys[i])
 	}
+	return next, !next.IsZero()
+}
+
+func (cgm *twoLevelMap) Store(key string, value interface{}) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return
+	}
+	defer cgm.release()
+	lv := cgm.slot(key)
+	defer cgm.shed(key) // after the key's lock is released
 
-	lv.l.Lock()
-	defer lv.l.Unlock()
+	cgm.lockKey(&lv.l, key, "Store")
+	defer cgm.unlockKey(&lv.l, key)
+	defer cgm.vacate(key, lv) // before the key's lock is released
 
 	var wg sync.WaitGroup
-	if ok && cgm.reaper != nil {
+	if lv.ev != nil && cgm.reaping() { // placeholders have no value to reap
 		wg.Add(1)
 		go func(value interface{}) {
 			defer wg.Done()
-			cgm.reaper(value)
+			cgm.reap(key, value, ReasonReplaced)
 		}(lv.ev.Value)
 	}
 
-	lv.ev = newExpiringValue(value, cgm.ttl)
+	cgm.set(key, lv, cgm.storeValue(key, value))
 	wg.Wait()
 }
 
-func (cgm *twoLevelMap) Keys() []string {
-	cgm.dbLock.RLock()
-	keys := make([]string, 0, len(cgm.db))
-	for k := range cgm.db {
-		keys = append(keys, k)
+func (cgm *twoLevelMap) StoreMany(pairs map[string]interface{}) {
+	if cgm.admit() != nil {
+		return
 	}
-	cgm.dbLock.RUnlock()
-	return keys
+	defer cgm.release()
+	var replaced []Pair
+	for key, ev := range cgm.storable(pairs) {
+		lv := cgm.slot(key)
+		cgm.lockKey(&lv.l, key, "StoreMany")
+		if lv.ev != nil && cgm.reaping() { // placeholders have no value to reap
+			replaced = append(replaced, Pair{key, lv.ev.Value})
+		}
+		cgm.set(key, lv, ev)
+		cgm.vacate(key, lv)
+		cgm.unlockKey(&lv.l, key)
+		cgm.shed(key)
+	}
+	cgm.reapAll(replaced, ReasonReplaced)
+}
+
+func (cgm *twoLevelMap) Snapshot() map[string]interface{} {
+	return cgm.snapshot(cgm)
 }
 
-func (cgm *twoLevelMap) Pairs() <-chan *Pair {
-	keys := make([]string, 0, len(cgm.db))
-	lockedValues := make([]*lockingValue, 0, len(cgm.db))
+func (cgm *twoLevelMap) Len() int {
+	return liveCount(cgm)
+}
 
-	cgm.dbLock.RLock()
-	for key, lv := range cgm.db {
-		keys = append(keys, key)
-		lockedValues = append(lockedValues, lv)
+func (cgm *twoLevelMap) LoadAndDelete(key string) (interface{}, bool) {
+	return loadAndDelete(cgm.mutate, cgm.canonical(key))
+}
+
+func (cgm *twoLevelMap) LoadOrStore(key string, value interface{}) (interface{}, bool) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return nil, false
 	}
-	cgm.dbLock.RUnlock()
+	defer cgm.release()
+	return loadOrStore(cgm.mutate, key, cgm.storeValue(key, value))
+}
 
-	pairs := make(chan *Pair, len(keys))
+func (cgm *twoLevelMap) StoreWithTTL(key string, value interface{}, ttl time.Duration) {
+	cgm.Store(key, cgm.withTTL(value, ttl))
+}
 
-	go func(pairs chan<- *Pair) {
-		now := time.Now()
+func (cgm *twoLevelMap) StoreReturning(key string, value interface{}) (interface{}, bool) {
+	key = cgm.canonical(key)
+	if cgm.checkKey(key) != nil || cgm.admit() != nil {
+		return nil, false
+	}
+	defer cgm.release()
+	return storeReturning(cgm.mutate, key, cgm.storeValue(key, value))
+}
+
+func (cgm *twoLevelMap) Swap(key string, value interface{}) (interface{}, bool) {
+	return cgm.StoreReturning(key, value)
+}
+
+func (cgm *twoLevelMap) Keys() []string {
+	return cgm.AppendKeys(make([]string, 0, atomic.LoadInt64(&cgm.count)))
+}
+
+func (cgm *twoLevelMap) AppendKeys(buf []string) []string {
+	cgm.eachKey(func(k string) {
+		buf = append(buf, k)
+	})
+	return buf
+}
+
+func (cgm *twoLevelMap) KeysMatching(match func(string) bool) []string {
+	var keys []string
+	cgm.eachKey(func(k string) {
+		if match(k) {
+			keys = append(keys, k)
+		}
+	})
+	return keys
+}
+
+func (cgm *twoLevelMap) KeysWithPrefix(prefix string) []string {
+	return cgm.KeysMatching(hasPrefix(prefix))
+}
+
+func (cgm *twoLevelMap) KeysPage(cursor string, limit int) ([]string, string) {
+	p := newKeyPager(cursor, limit)
+	cgm.eachKey(p.add)
+	return p.page()
+}
+
+func (cgm *twoLevelMap) Pairs() <-chan Pair {
+	if cgm.snapshotPairs {
+		return snapshotPairs(cgm)
+	}
+	keys, lockedValues := cgm.lockingValues()
+	pairs := newPairs(len(keys))
+
+	go func(pairs chan<- Pair) {
+		now := cgm.now()
 
 		var wg sync.WaitGroup
 		wg.Add(len(keys))
 
 		for i, key := range keys {
 			go func(key string, lv *lockingValue) {
-				lv.l.Lock()
+				cgm.lockKey(&lv.l, key, "Pairs")
 				if lv.ev != nil && (lv.ev.Expiry.IsZero() || lv.ev.Expiry.After(now)) {
-					pairs <- &Pair{key, lv.ev.Value}
+					pairs <- Pair{key, lv.ev.Value}
 				}
-				lv.l.Unlock()
+				cgm.unlockKey(&lv.l, key)
 				wg.Done()
 			}(key, lockedValues[i])
 		}
@@ -261,39 +838,77 @@ func (cgm *twoLevelMap) Pairs() <-chan *Pair {
 	return pairs
 }
 
+func (cgm *twoLevelMap) PairsByExpiry() <-chan Pair {
+	keys, lockedValues := cgm.lockingValues()
+	eps := make([]expiringPair, 0, len(keys))
+	for i, lv := range lockedValues {
+		cgm.lockKey(&lv.l, keys[i], "PairsByExpiry")
+		if lv.ev != nil {
+			eps = append(eps, expiringPair{keys[i], lv.ev})
+		}
+		cgm.unlockKey(&lv.l, keys[i])
+	}
+	return pairsByExpiry(eps, cgm.now())
+}
+
 func (cgm *twoLevelMap) Close() error {
-	close(cgm.halt)
-	return nil
+	if cgm.closing() {
+		close(cgm.halt)
+		if cgm.noGoroutine {
+			cgm.shutdown()
+		}
+	}
+	<-cgm.done
+	cgm.closeEvents()
+	return cgm.closeErr()
 }
 
-func (cgm *twoLevelMap) run() {
-	gcPeriodicity := 15 * time.Minute
-	if cgm.ttl > 0 && cgm.ttl <= time.Second {
-		gcPeriodicity = time.Minute
+func (cgm *twoLevelMap) running() bool {
+	select {
+	case <-cgm.done:
+		return false
+	default:
+		return true
 	}
+}
+
+func (cgm *twoLevelMap) run() {
+	ticks, stop := cgm.gcTicker()
+	defer stop()
 
 	active := true
 	for active {
 		select {
-		case <-time.After(gcPeriodicity):
-			cgm.GC()
+		case <-ticks:
+			if cgm.gcDue() {
+				cgm.GC()
+			}
 		case <-cgm.halt:
 			active = false
 		}
 	}
+	cgm.shutdown()
+}
 
-	if cgm.reaper != nil {
-		cgm.dbLock.Lock()
-		var wg sync.WaitGroup
-		wg.Add(len(cgm.db))
-		for key, lv := range cgm.db {
-			delete(cgm.db, key)
-			go func(value interface{}) {
-				defer wg.Done()
-				cgm.reaper(value)
-			}(lv.ev.Value)
+// shutdown reaps the values remaining once the Congomap is closed, then signals that it is done. It
+// is invoked by the run go routine, or by Close when NoGoroutine was specified.
+func (cgm *twoLevelMap) shutdown() {
+	defer close(cgm.done)
+
+	if cgm.reaping() {
+		cgm.lockAll()
+		var reaped []Pair
+		for i := range cgm.shards {
+			s := &cgm.shards[i]
+			for key, lv := range s.db {
+				cgm.drop(s, key, lv)
+				if lv.ev == nil {
+					continue // placeholders have no value to reap
+				}
+				reaped = append(reaped, Pair{key, lv.ev.Value})
+			}
 		}
-		cgm.dbLock.Unlock()
-		wg.Wait()
+		cgm.unlockAll()
+		cgm.reapAll(reaped, ReasonClosed)
 	}
 }
@@ -0,0 +1,127 @@
+package congomap
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShardCount is used to specify that a TwoLevelMap splits the index of its keys into the specified
+// number of shards, each guarded by its own lock, rather than guarding every key with a single lock,
+// so that inserting and removing keys, such as by LoadStore for keys not yet held, does not
+// serialize every go routine on one lock. Each key is assigned to a shard by its hash. When
+// MaxEntries or MaxCost is exceeded, the keys evicted are sampled from the shard of the key just
+// written. It returns ErrUnsupportedOption for any other Congomap.
+func ShardCount(count int) Setter {
+	return func(cgm Congomap) error {
+		tl, ok := cgm.(*twoLevelMap)
+		if !ok {
+			return ErrUnsupportedOption{}
+		}
+		if count <= 0 {
+			return ErrInvalidCount{Option: "ShardCount", Count: count}
+		}
+		tl.shards = make([]twoLevelShard, count)
+		return nil
+	}
+}
+
+// twoLevelShard holds the keys of a TwoLevelMap assigned to it by their hash, and the lock that
+// guards insertion and removal of those keys.
+type twoLevelShard struct {
+	db     map[string]*lockingValue
+	dbLock sync.RWMutex
+}
+
+// shard returns the shard to which key is assigned, by its FNV-1a hash.
+func (cgm *twoLevelMap) shard(key string) *twoLevelShard {
+	if len(cgm.shards) == 1 {
+		return &cgm.shards[0]
+	}
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return &cgm.shards[h%uint32(len(cgm.shards))]
+}
+
+// find returns the lockingValue of key, or false when key is not present.
+func (cgm *twoLevelMap) find(key string) (*lockingValue, bool) {
+	s := cgm.shard(key)
+	s.dbLock.RLock()
+	lv, ok := s.db[key]
+	s.dbLock.RUnlock()
+	return lv, ok
+}
+
+// insert adds the lockingValue of key to the shard. It must be invoked while holding the write lock
+// of the shard.
+func (cgm *twoLevelMap) insert(s *twoLevelShard, key string, lv *lockingValue) {
+	s.db[key] = lv
+	atomic.AddInt64(&cgm.count, 1)
+}
+
+// drop removes key from the shard, and stops counting the cost of its value. It must be invoked
+// while holding the write lock of the shard.
+func (cgm *twoLevelMap) drop(s *twoLevelShard, key string, lv *lockingValue) {
+	cgm.discharge(lv)
+	delete(s.db, key)
+	atomic.AddInt64(&cgm.count, -1)
+}
+
+// lockAll acquires the write lock of every shard, in order, so that no key may be inserted or
+// removed until unlockAll is invoked. Nothing else holds the locks of more than one shard.
+func (cgm *twoLevelMap) lockAll() {
+	for i := range cgm.shards {
+		cgm.shards[i].dbLock.Lock()
+	}
+}
+
+// unlockAll releases the locks acquired by lockAll.
+func (cgm *twoLevelMap) unlockAll() {
+	for i := range cgm.shards {
+		cgm.shards[i].dbLock.Unlock()
+	}
+}
+
+// lockingValues returns every key and its lockingValue, gathered from each shard in turn while
+// holding its lock as a reader.
+func (cgm *twoLevelMap) lockingValues() ([]string, []*lockingValue) {
+	n := int(atomic.LoadInt64(&cgm.count))
+	keys := make([]string, 0, n)
+	lockedValues := make([]*lockingValue, 0, n)
+	for i := range cgm.shards {
+		s := &cgm.shards[i]
+		s.dbLock.RLock()
+		for key, lv := range s.db {
+			keys = append(keys, key)
+			lockedValues = append(lockedValues, lv)
+		}
+		s.dbLock.RUnlock()
+	}
+	return keys, lockedValues
+}
+
+// eachKey invokes fn with each key, while holding the lock of its shard as a reader.
+func (cgm *twoLevelMap) eachKey(fn func(string)) {
+	for i := range cgm.shards {
+		s := &cgm.shards[i]
+		s.dbLock.RLock()
+		for key := range s.db {
+			fn(key)
+		}
+		s.dbLock.RUnlock()
+	}
+}
+
+// eachLockingValue visits the key, expiry, and time of most recent use of each value in the shard
+// without acquiring their locks, for choosing keys to evict. It must be invoked while holding the
+// lock of the shard.
+func (s *twoLevelShard) eachLockingValue(fn func(string, time.Time, int64) bool) {
+	for k, lv := range s.db { // map iteration order is randomized
+		if !fn(k, lv.expiry(), atomic.LoadInt64(&lv.used)) {
+			return
+		}
+	}
+}
@@ -1,12 +1,20 @@
 package congomap_test
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
 	"log"
 	"math/rand"
+	"reflect"
+	"runtime"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -264,9 +272,16 @@ func loadStoreNilErrLookupFailed(t *testing.T, cgm congomap.Congomap, which, key
 	if value != nil {
 		t.Errorf("LoadStoreMiss: Which: %s; Key: %q; Actual: %#v; Expected: %#v", which, key, value, nil)
 	}
-	if err == nil || err.Error() != "lookup failed" {
+	if !errors.Is(err, errLookupFailed) {
 		t.Errorf("LoadStoreMiss: Which: %s; Key: %q; Actual: %#v; Expected: %#v", which, key, err, errLookupFailed)
 	}
+	var lf congomap.ErrLookupFailed
+	if !errors.As(err, &lf) || lf.Key != key {
+		t.Errorf("LoadStoreMiss: Which: %s; Key: %q; Actual: %#v; Expected: %#v", which, key, err, congomap.ErrLookupFailed{Key: key, Err: errLookupFailed})
+	}
+	if actual, expected := err.Error(), fmt.Sprintf("congomap: lookup %q: lookup failed", key); actual != expected {
+		t.Errorf("LoadStoreMiss: Which: %s; Key: %q; Actual: %#v; Expected: %#v", which, key, actual, expected)
+	}
 }
 
 func loadStoreValueNil(t *testing.T, cgm congomap.Congomap, which, key string) {
@@ -629,6 +644,84 @@ func TestPairsTwoLevelMap(t *testing.T) {
 	testPairs(t, cgm, "twoLevel")
 }
 
+////////////////////////////////////////
+// NextExpiry()
+
+func testNextExpiry(t *testing.T, cgm congomap.Congomap, which string) {
+	defer func() { _ = cgm.Close() }()
+	if next, ok := cgm.NextExpiry(); ok {
+		t.Errorf("Which: %s; Actual: %v, %v; Expected: %v, %v", which, next, ok, time.Time{}, false)
+	}
+
+	soon := time.Now().Add(time.Hour)
+	cgm.Store("never", 0)
+	cgm.Store("later", &congomap.ExpiringValue{Value: 2, Expiry: soon.Add(time.Hour)})
+	cgm.Store("soon", &congomap.ExpiringValue{Value: 1, Expiry: soon})
+	if next, ok := cgm.NextExpiry(); !ok || !next.Equal(soon) {
+		t.Errorf("Which: %s; Actual: %v, %v; Expected: %v, %v", which, next, ok, soon, true)
+	}
+}
+
+func TestNextExpiryChannelMap(t *testing.T) {
+	cgm, _ := congomap.NewChannelMap()
+	testNextExpiry(t, cgm, "channel")
+}
+
+func TestNextExpirySyncAtomicMap(t *testing.T) {
+	cgm, _ := congomap.NewSyncAtomicMap()
+	testNextExpiry(t, cgm, "syncAtomic")
+}
+
+func TestNextExpirySyncMutexMap(t *testing.T) {
+	cgm, _ := congomap.NewSyncMutexMap()
+	testNextExpiry(t, cgm, "syncMutex")
+}
+
+func TestNextExpiryTwoLevelMap(t *testing.T) {
+	cgm, _ := congomap.NewTwoLevelMap()
+	testNextExpiry(t, cgm, "twoLevel")
+}
+
+////////////////////////////////////////
+// PairsByExpiry()
+
+func testPairsByExpiry(t *testing.T, cgm congomap.Congomap, which string) {
+	defer func() { _ = cgm.Close() }()
+	now := time.Now()
+	cgm.Store("never", 0)
+	cgm.Store("later", &congomap.ExpiringValue{Value: 2, Expiry: now.Add(2 * time.Hour)})
+	cgm.Store("expired", &congomap.ExpiringValue{Value: -1, Expiry: now.Add(-time.Hour)})
+	cgm.Store("soon", &congomap.ExpiringValue{Value: 1, Expiry: now.Add(time.Hour)})
+
+	var keys []string
+	for pair := range cgm.PairsByExpiry() {
+		keys = append(keys, pair.Key)
+	}
+	if expected := []string{"soon", "later", "never"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, keys, expected)
+	}
+}
+
+func TestPairsByExpiryChannelMap(t *testing.T) {
+	cgm, _ := congomap.NewChannelMap()
+	testPairsByExpiry(t, cgm, "channel")
+}
+
+func TestPairsByExpirySyncAtomicMap(t *testing.T) {
+	cgm, _ := congomap.NewSyncAtomicMap()
+	testPairsByExpiry(t, cgm, "syncAtomic")
+}
+
+func TestPairsByExpirySyncMutexMap(t *testing.T) {
+	cgm, _ := congomap.NewSyncMutexMap()
+	testPairsByExpiry(t, cgm, "syncMutex")
+}
+
+func TestPairsByExpiryTwoLevelMap(t *testing.T) {
+	cgm, _ := congomap.NewTwoLevelMap()
+	testPairsByExpiry(t, cgm, "twoLevel")
+}
+
 // ReaperInvokedDuringDelete
 
 func ExampleReaper() {
@@ -815,3 +908,4941 @@ func ExampleTwoLevelMap_Keys() {
 	fmt.Println(keys)
 	// Output: [abc def]
 }
+
+// LoadStoreAll
+
+func loadStoreAll(t *testing.T, cgm congomap.Congomap, which string) {
+	defer func() { _ = cgm.Close() }()
+	cgm.Store("hit", 13)
+	results := cgm.LoadStoreAll([]string{"hit", "miss", "miss", "fail"})
+	if actual, expected := len(results), 3; actual != expected {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, expected)
+	}
+	if actual, expected := results["hit"], (congomap.LoadStoreResult{Value: 13}); actual != expected {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, expected)
+	}
+	if actual, expected := results["miss"], (congomap.LoadStoreResult{Value: 42}); actual != expected {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, expected)
+	}
+	if actual := results["fail"]; actual.Value != nil || actual.Err == nil {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, errLookupFailed)
+	}
+}
+
+func failOnFailKeyLookup(key string) (interface{}, error) {
+	if key == "fail" {
+		return nil, errLookupFailed
+	}
+	return 42, nil
+}
+
+func TestLoadStoreAllChannelMap(t *testing.T) {
+	cgm, _ := congomap.NewChannelMap(congomap.Lookup(failOnFailKeyLookup))
+	loadStoreAll(t, cgm, "channel")
+}
+
+func TestLoadStoreAllSyncAtomicMap(t *testing.T) {
+	cgm, _ := congomap.NewSyncAtomicMap(congomap.Lookup(failOnFailKeyLookup))
+	loadStoreAll(t, cgm, "syncAtomic")
+}
+
+func TestLoadStoreAllSyncMutexMap(t *testing.T) {
+	cgm, _ := congomap.NewSyncMutexMap(congomap.Lookup(failOnFailKeyLookup))
+	loadStoreAll(t, cgm, "syncMutex")
+}
+
+func TestLoadStoreAllTwoLevelMap(t *testing.T) {
+	cgm, _ := congomap.NewTwoLevelMap(congomap.Lookup(failOnFailKeyLookup))
+	loadStoreAll(t, cgm, "twoLevel")
+}
+
+func TestLoadStoreAllConcurrency(t *testing.T) {
+	var running, most int32
+	cgm, err := congomap.NewSyncMutexMap(congomap.Lookup(func(key string) (interface{}, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&most)
+			if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return key, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	keys := make([]string, 500)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	if actual, expected := len(cgm.LoadStoreAll(keys)), len(keys); actual != expected {
+		t.Errorf("Actual: %#v; Expected: %#v", actual, expected)
+	}
+	if actual, limit := atomic.LoadInt32(&most), int32(64); actual > limit {
+		t.Errorf("Actual: %#v; Expected at most: %#v", actual, limit)
+	}
+}
+
+// Do
+
+func ExampleNewTwoLevelMap_do() {
+	cgm, err := congomap.NewTwoLevelMap()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	increment := func(value interface{}, ok bool) (interface{}, bool) {
+		if !ok {
+			return 1, true
+		}
+		return value.(int) + 1, true
+	}
+
+	cgm.Do("counter", increment)
+	cgm.Do("counter", increment)
+
+	value, _ := cgm.Load("counter")
+	fmt.Println(value)
+	// Output: 2
+}
+
+func testDo(t *testing.T, cgm congomap.Congomap, which string) {
+	defer func() { _ = cgm.Close() }()
+
+	const tasks = 100
+
+	var wg sync.WaitGroup
+	wg.Add(tasks)
+	for i := 0; i < tasks; i++ {
+		go func() {
+			cgm.Do("counter", func(value interface{}, ok bool) (interface{}, bool) {
+				if !ok {
+					return 1, true
+				}
+				return value.(int) + 1, true
+			})
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	if value, ok := cgm.Load("counter"); !ok || value != tasks {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, value, tasks)
+	}
+
+	// declining to replace leaves the value alone
+	cgm.Do("counter", func(value interface{}, ok bool) (interface{}, bool) {
+		return 13, false
+	})
+	if value, ok := cgm.Load("counter"); !ok || value != tasks {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, value, tasks)
+	}
+}
+
+func TestDoChannelMap(t *testing.T) {
+	cgm, _ := congomap.NewChannelMap()
+	testDo(t, cgm, "channel")
+}
+
+func TestDoSyncAtomicMap(t *testing.T) {
+	cgm, _ := congomap.NewSyncAtomicMap()
+	testDo(t, cgm, "syncAtomic")
+}
+
+func TestDoSyncMutexMap(t *testing.T) {
+	cgm, _ := congomap.NewSyncMutexMap()
+	testDo(t, cgm, "syncMutex")
+}
+
+func TestDoTwoLevelMap(t *testing.T) {
+	cgm, _ := congomap.NewTwoLevelMap()
+	testDo(t, cgm, "twoLevel")
+}
+
+// MaxStale
+
+func testMaxStale(t *testing.T, cgm congomap.Congomap, which string) {
+	defer func() { _ = cgm.Close() }()
+
+	now := time.Now()
+	cgm.Store("stale", &congomap.ExpiringValue{Value: 42, Expiry: now.Add(-time.Millisecond)})
+	cgm.Store("tooStale", &congomap.ExpiringValue{Value: 42, Expiry: now.Add(-2 * time.Minute)})
+
+	// stale values are never returned by Load
+	loadNilFalse(t, cgm, which, "stale")
+
+	cgm.GC() // stale values that may still be served are retained
+
+	loadStoreValueNil(t, cgm, which, "stale")
+	loadStoreNilErrLookupFailed(t, cgm, which, "tooStale")
+	loadStoreNilErrLookupFailed(t, cgm, which, "miss")
+}
+
+func TestMaxStaleChannelMap(t *testing.T) {
+	cgm, _ := congomap.NewChannelMap(congomap.Lookup(failingLookup), congomap.MaxStale(time.Minute))
+	testMaxStale(t, cgm, "channel")
+}
+
+func TestMaxStaleSyncAtomicMap(t *testing.T) {
+	cgm, _ := congomap.NewSyncAtomicMap(congomap.Lookup(failingLookup), congomap.MaxStale(time.Minute))
+	testMaxStale(t, cgm, "syncAtomic")
+}
+
+func TestMaxStaleSyncMutexMap(t *testing.T) {
+	cgm, _ := congomap.NewSyncMutexMap(congomap.Lookup(failingLookup), congomap.MaxStale(time.Minute))
+	testMaxStale(t, cgm, "syncMutex")
+}
+
+func TestMaxStaleTwoLevelMap(t *testing.T) {
+	cgm, _ := congomap.NewTwoLevelMap(congomap.Lookup(failingLookup), congomap.MaxStale(time.Minute))
+	testMaxStale(t, cgm, "twoLevel")
+}
+
+func testStaleOnError(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.Lookup(failingLookup), congomap.StaleOnError())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("stale", &congomap.ExpiringValue{Value: 42, Expiry: time.Now().Add(-24 * time.Hour)})
+	cgm.GC() // stale values are retained however old
+
+	loadNilFalse(t, cgm, which, "stale")
+	loadStoreValueNil(t, cgm, which, "stale")
+	loadStoreNilErrLookupFailed(t, cgm, which, "miss")
+}
+
+func TestStaleOnErrorChannelMap(t *testing.T) {
+	testStaleOnError(t, congomap.NewChannelMap, "channel")
+}
+
+func TestStaleOnErrorSyncAtomicMap(t *testing.T) {
+	testStaleOnError(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestStaleOnErrorSyncMutexMap(t *testing.T) {
+	testStaleOnError(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestStaleOnErrorTwoLevelMap(t *testing.T) {
+	testStaleOnError(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+func TestMaxStaleInvalidDuration(t *testing.T) {
+	_, err := congomap.NewTwoLevelMap(congomap.MaxStale(0))
+	if _, ok := err.(congomap.ErrInvalidDuration); !ok {
+		t.Errorf("Actual: %#v; Expected: %#v", err, congomap.ErrInvalidDuration(0))
+	}
+}
+
+// BadExpiryDuration and BadStaleDuration
+
+func testBadLookups(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var lookups int32
+	countingLookup := func(_ string) (interface{}, error) {
+		if atomic.AddInt32(&lookups, 1) == 1 {
+			return nil, errLookupFailed
+		}
+		return 42, nil
+	}
+
+	cgm, err := newCongomap(congomap.Lookup(countingLookup), congomap.BadStaleDuration(time.Millisecond), congomap.BadExpiryDuration(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	loadStoreNilErrLookupFailed(t, cgm, which, "key")
+	loadStoreNilErrLookupFailed(t, cgm, which, "key") // memoized error
+	if actual, expected := atomic.LoadInt32(&lookups), int32(1); actual != expected {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, expected)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	loadStoreNilErrLookupFailed(t, cgm, which, "key") // stale error refreshed in background
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if _, ok := cgm.Load("key"); ok {
+			break
+		}
+	}
+	loadValueTrue(t, cgm, which, "key")
+	if actual, expected := atomic.LoadInt32(&lookups), int32(2); actual != expected {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, expected)
+	}
+}
+
+func TestBadLookupsChannelMap(t *testing.T) {
+	testBadLookups(t, congomap.NewChannelMap, "channel")
+}
+
+func TestBadLookupsSyncAtomicMap(t *testing.T) {
+	testBadLookups(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestBadLookupsSyncMutexMap(t *testing.T) {
+	testBadLookups(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestBadLookupsTwoLevelMap(t *testing.T) {
+	testBadLookups(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+func TestErrorTTL(t *testing.T) {
+	var lookups int32
+	cgm, err := congomap.NewSyncMutexMap(congomap.ErrorTTL(time.Minute), congomap.Lookup(func(_ string) (interface{}, error) {
+		atomic.AddInt32(&lookups, 1)
+		return nil, errLookupFailed
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	loadStoreNilErrLookupFailed(t, cgm, "syncMutex", "key")
+	loadStoreNilErrLookupFailed(t, cgm, "syncMutex", "key") // memoized error
+	if actual, expected := atomic.LoadInt32(&lookups), int32(1); actual != expected {
+		t.Errorf("Actual: %#v; Expected: %#v", actual, expected)
+	}
+}
+
+// Tree
+
+func ExampleTree() {
+	cgm, err := congomap.NewSyncMutexMap()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("db/primary/host", "10.0.0.1")
+	cgm.Store("db/primary/port", 5432)
+	cgm.Store("db/replica/host", "10.0.0.2")
+	cgm.Store("dbname", "app")
+
+	tree := congomap.NewTree(cgm, "/")
+	fmt.Println(tree.Children(""))
+	fmt.Println(tree.Children("db"))
+	fmt.Println(tree.Keys("db/primary"))
+	fmt.Println(tree.Delete("db"), cgm.Keys())
+	// Output:
+	// [db dbname]
+	// [primary replica]
+	// [db/primary/host db/primary/port]
+	// 3 [dbname]
+}
+
+func testTree(t *testing.T, cgm congomap.Congomap, which string) {
+	defer func() { _ = cgm.Close() }()
+	for _, key := range []string{"a", "a/b", "a/b/c", "a/bc", "ab"} {
+		cgm.Store(key, key)
+	}
+	tree := congomap.NewTree(cgm, "/")
+
+	if actual, expected := tree.Keys("a/b"), []string{"a/b", "a/b/c"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, actual, expected)
+	}
+	if actual, expected := tree.Children("a"), []string{"b", "bc"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, actual, expected)
+	}
+	cgm.Store("a/expired", &congomap.ExpiringValue{Value: 1, Expiry: time.Now().Add(-time.Minute)})
+	if actual, expected := tree.Delete("a"), 4; actual != expected {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, actual, expected) // the expired key is not counted
+	}
+	cgm.GC()
+	if actual, expected := tree.Keys(""), []string{"ab"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, actual, expected)
+	}
+}
+
+func TestTreeCanonicalKey(t *testing.T) {
+	cgm, err := congomap.NewSyncMutexMap(congomap.CanonicalKey(strings.ToLower))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+	cgm.Store("Db/Primary/Host", 1)
+	cgm.Store("db/replica/host", 2)
+	tree := congomap.NewTree(cgm, "/")
+
+	if actual, expected := tree.Keys("DB/primary"), []string{"db/primary/host"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Actual: %v; Expected: %v", actual, expected)
+	}
+	if actual, expected := tree.Children("DB"), []string{"primary", "replica"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Actual: %v; Expected: %v", actual, expected)
+	}
+	if actual, expected := tree.Delete("DB"), 2; actual != expected {
+		t.Errorf("Actual: %v; Expected: %v", actual, expected)
+	}
+}
+
+func TestTreeChannelMap(t *testing.T) {
+	cgm, _ := congomap.NewChannelMap()
+	testTree(t, cgm, "channel")
+}
+
+func TestTreeSyncAtomicMap(t *testing.T) {
+	cgm, _ := congomap.NewSyncAtomicMap()
+	testTree(t, cgm, "syncAtomic")
+}
+
+func TestTreeSyncMutexMap(t *testing.T) {
+	cgm, _ := congomap.NewSyncMutexMap()
+	testTree(t, cgm, "syncMutex")
+}
+
+func TestTreeTwoLevelMap(t *testing.T) {
+	cgm, _ := congomap.NewTwoLevelMap()
+	testTree(t, cgm, "twoLevel")
+}
+
+// Reaper2
+
+func testReaper2(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var lock sync.Mutex
+	var reaped []string
+	cgm, err := newCongomap(congomap.Reaper2(func(key string, value interface{}) {
+		lock.Lock()
+		reaped = append(reaped, fmt.Sprintf("%s=%v", key, value))
+		lock.Unlock()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cgm.Store("replaced", 1)
+	cgm.Store("replaced", 2)
+	cgm.Store("deleted", 3)
+	cgm.Delete("deleted")
+	cgm.Store("expired", &congomap.ExpiringValue{Value: 4, Expiry: time.Now().Add(-time.Second)})
+	cgm.GC()
+	cgm.Store("closed", 5)
+	if err := cgm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	sort.Strings(reaped)
+	expected := []string{"closed=5", "deleted=3", "expired=4", "replaced=1", "replaced=2"}
+	if !reflect.DeepEqual(reaped, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, reaped, expected)
+	}
+}
+
+func TestReaper2ChannelMap(t *testing.T) {
+	testReaper2(t, congomap.NewChannelMap, "channel")
+}
+
+func TestReaper2SyncAtomicMap(t *testing.T) {
+	testReaper2(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestReaper2SyncMutexMap(t *testing.T) {
+	testReaper2(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestReaper2TwoLevelMap(t *testing.T) {
+	testReaper2(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// EvictionEvents
+
+func testEvictionEvents(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.EvictionEvents(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := congomap.Events(cgm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cgm.Store("replaced", 1)
+	cgm.Store("replaced", 2)
+	cgm.Store("deleted", 3)
+	cgm.Delete("deleted")
+	cgm.Store("expired", &congomap.ExpiringValue{Value: 4, Expiry: time.Now().Add(-time.Second)})
+	cgm.GC()
+	cgm.Store("cleared", 5)
+	cgm.Clear()
+	cgm.Store("closed", 6)
+	if err := cgm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []string
+	for e := range events { // closed by Close
+		actual = append(actual, fmt.Sprintf("%s=%v %s", e.Key, e.Value, e.Reason))
+	}
+	sort.Strings(actual)
+	expected := []string{"cleared=5 cleared", "closed=6 closed", "deleted=3 deleted", "expired=4 expired", "replaced=1 replaced", "replaced=2 cleared"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, actual, expected)
+	}
+}
+
+func testEvictionEventsDropped(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.EvictionEvents(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, _ := congomap.Events(cgm)
+
+	for _, key := range []string{"a", "b", "c"} {
+		cgm.Store(key, key)
+		cgm.Delete(key)
+	}
+	_ = cgm.Close()
+
+	var count int
+	for range events {
+		count++
+	}
+	if count != 1 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, count, 1)
+	}
+}
+
+func TestEvictionEventsChannelMap(t *testing.T) {
+	testEvictionEvents(t, congomap.NewChannelMap, "channel")
+	testEvictionEventsDropped(t, congomap.NewChannelMap, "channel")
+}
+
+func TestEvictionEventsSyncAtomicMap(t *testing.T) {
+	testEvictionEvents(t, congomap.NewSyncAtomicMap, "syncAtomic")
+	testEvictionEventsDropped(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestEvictionEventsSyncMutexMap(t *testing.T) {
+	testEvictionEvents(t, congomap.NewSyncMutexMap, "syncMutex")
+	testEvictionEventsDropped(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestEvictionEventsTwoLevelMap(t *testing.T) {
+	testEvictionEvents(t, congomap.NewTwoLevelMap, "twoLevel")
+	testEvictionEventsDropped(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// BatchReaper
+
+func testBatchReaper(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var lock sync.Mutex
+	var total, largest int
+	cgm, err := newCongomap(congomap.BatchReaper(func(values []interface{}) {
+		lock.Lock()
+		total += len(values)
+		if len(values) > largest {
+			largest = len(values)
+		}
+		lock.Unlock()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	const count = 3000
+	expired := time.Now().Add(-time.Second)
+	for i := 0; i < count; i++ {
+		cgm.Store(strconv.Itoa(i), &congomap.ExpiringValue{Value: i, Expiry: expired})
+	}
+	cgm.Store("deleted", 1)
+	cgm.Delete("deleted")
+	cgm.GC()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if expected := count + 1; total != expected {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, total, expected)
+	}
+	if largest > 1024 {
+		t.Errorf("Which: %s; Actual: %d; Expected at most: %d", which, largest, 1024)
+	}
+}
+
+func TestBatchReaperChannelMap(t *testing.T) {
+	testBatchReaper(t, congomap.NewChannelMap, "channel")
+}
+
+func TestBatchReaperSyncAtomicMap(t *testing.T) {
+	testBatchReaper(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestBatchReaperSyncMutexMap(t *testing.T) {
+	testBatchReaper(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestBatchReaperTwoLevelMap(t *testing.T) {
+	testBatchReaper(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// FallibleReaper
+
+func testFallibleReaper(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.FallibleReaper(func(value interface{}) error {
+		if value.(int) < 0 {
+			return fmt.Errorf("cannot reap %d", value)
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cgm.Store("abc", -1)
+	cgm.Store("def", 2)
+	cgm.Store("ghi", -3)
+	cgm.Delete("abc")
+
+	err = cgm.Close() // reaps remaining values
+	var rf congomap.ErrReaperFailed
+	if !errors.As(err, &rf) || rf.Count != 2 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %T with Count 2", which, err, rf)
+	}
+}
+
+func TestFallibleReaperChannelMap(t *testing.T) {
+	testFallibleReaper(t, congomap.NewChannelMap, "channel")
+}
+
+func TestFallibleReaperSyncAtomicMap(t *testing.T) {
+	testFallibleReaper(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestFallibleReaperSyncMutexMap(t *testing.T) {
+	testFallibleReaper(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestFallibleReaperTwoLevelMap(t *testing.T) {
+	testFallibleReaper(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// LookupContext
+
+type traceKey struct{}
+
+func testLookupContext(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.LookupContext(func(ctx context.Context, key string) (interface{}, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return ctx.Value(traceKey{}), nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	ctx := context.WithValue(context.Background(), traceKey{}, "trace")
+	if value, err := cgm.LoadStoreContext(ctx, "traced"); err != nil || value != "trace" {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, "trace", nil)
+	}
+	if value, err := cgm.LoadStore("untraced"); err != nil || value != nil {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, nil, nil)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := cgm.LoadStoreContext(canceled, "canceled"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, context.Canceled)
+	}
+}
+
+func TestLookupContextChannelMap(t *testing.T) {
+	testLookupContext(t, congomap.NewChannelMap, "channel")
+}
+
+func TestLookupContextSyncAtomicMap(t *testing.T) {
+	testLookupContext(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestLookupContextSyncMutexMap(t *testing.T) {
+	testLookupContext(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestLookupContextTwoLevelMap(t *testing.T) {
+	testLookupContext(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// LoadStoreWith
+
+func testLoadStoreWith(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.Lookup(succeedingLookup))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	var calls int32
+	fill := func(key string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(time.Millisecond)
+		return len(key), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, err := cgm.LoadStoreWith("filled", fill); err != nil || value != 6 {
+				t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, 6, nil)
+			}
+		}()
+	}
+	wg.Wait()
+	if actual := atomic.LoadInt32(&calls); actual != 1 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, 1)
+	}
+
+	if value, err := cgm.LoadStore("looked"); err != nil || value != 42 {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, 42, nil)
+	}
+
+	_, err = cgm.LoadStoreWith("failed", failingLookup)
+	if _, ok := err.(congomap.ErrLookupFailed); !ok {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %T", which, err, congomap.ErrLookupFailed{})
+	}
+}
+
+func TestLoadStoreWithChannelMap(t *testing.T) {
+	testLoadStoreWith(t, congomap.NewChannelMap, "channel")
+}
+
+func TestLoadStoreWithSyncAtomicMap(t *testing.T) {
+	testLoadStoreWith(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestLoadStoreWithSyncMutexMap(t *testing.T) {
+	testLoadStoreWith(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestLoadStoreWithTwoLevelMap(t *testing.T) {
+	testLoadStoreWith(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// LookupRetry
+
+func testLookupRetry(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var lookups int32
+	flakyLookup := func(_ string) (interface{}, error) {
+		if atomic.AddInt32(&lookups, 1)%3 != 0 {
+			return nil, errLookupFailed
+		}
+		return 42, nil
+	}
+	var backoffs []int
+	cgm, err := newCongomap(congomap.Lookup(flakyLookup), congomap.LookupRetry(3, func(attempt int) time.Duration {
+		backoffs = append(backoffs, attempt)
+		return time.Millisecond
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	if value, err := cgm.LoadStore("key"); err != nil || value != 42 {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, 42, nil)
+	}
+	if expected := []int{1, 2}; !reflect.DeepEqual(backoffs, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, backoffs, expected)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cgm.LoadStoreContext(canceled, "canceled"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, context.Canceled)
+	}
+}
+
+func TestLookupRetryChannelMap(t *testing.T) {
+	testLookupRetry(t, congomap.NewChannelMap, "channel")
+}
+
+func TestLookupRetrySyncAtomicMap(t *testing.T) {
+	testLookupRetry(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestLookupRetrySyncMutexMap(t *testing.T) {
+	testLookupRetry(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestLookupRetryTwoLevelMap(t *testing.T) {
+	testLookupRetry(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := congomap.ExponentialBackoff(10*time.Millisecond, 25*time.Millisecond)
+	for i, limit := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond, 25 * time.Millisecond} {
+		if d := backoff(i + 1); d < 0 || d >= limit {
+			t.Errorf("Attempt: %d; Actual: %v; Expected less than: %v", i+1, d, limit)
+		}
+	}
+}
+
+// LookupTimeout
+
+func testLookupTimeout(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	release := make(chan struct{})
+	defer close(release)
+	cgm, err := newCongomap(congomap.LookupTimeout(10*time.Millisecond), congomap.Lookup(func(key string) (interface{}, error) {
+		if key == "hung" {
+			<-release
+		}
+		return 42, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	_, err = cgm.LoadStore("hung")
+	if actual, expected := err, (congomap.ErrLookupTimeout{Key: "hung", Timeout: 10 * time.Millisecond}); actual != expected {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, expected)
+	}
+	loadNilFalse(t, cgm, which, "hung")
+
+	// the lock held while looking up the hung key was released
+	if value, err := cgm.LoadStore("quick"); err != nil || value != 42 {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, 42, nil)
+	}
+}
+
+func TestLookupTimeoutChannelMap(t *testing.T) {
+	testLookupTimeout(t, congomap.NewChannelMap, "channel")
+}
+
+func TestLookupTimeoutSyncAtomicMap(t *testing.T) {
+	testLookupTimeout(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestLookupTimeoutSyncMutexMap(t *testing.T) {
+	testLookupTimeout(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestLookupTimeoutTwoLevelMap(t *testing.T) {
+	testLookupTimeout(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// LookupMany
+
+func lengths(keys []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		if key != "missing" {
+			values[key] = len(key)
+		}
+	}
+	return values, nil
+}
+
+func testLookupMany(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.LookupMany(lengths))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	results := cgm.LoadStoreAll([]string{"a", "bb", "missing"})
+	if r := results["a"]; r.Err != nil || r.Value != 1 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, r, congomap.LoadStoreResult{Value: 1})
+	}
+	if r := results["bb"]; r.Err != nil || r.Value != 2 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, r, congomap.LoadStoreResult{Value: 2})
+	}
+	if r := results["missing"]; !errors.Is(r.Err, congomap.ErrKeyNotReturned{}) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, r.Err, congomap.ErrKeyNotReturned{})
+	}
+}
+
+func TestLookupManyChannelMap(t *testing.T) {
+	testLookupMany(t, congomap.NewChannelMap, "channel")
+}
+
+func TestLookupManySyncAtomicMap(t *testing.T) {
+	testLookupMany(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestLookupManySyncMutexMap(t *testing.T) {
+	testLookupMany(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestLookupManyTwoLevelMap(t *testing.T) {
+	testLookupMany(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+func TestLookupManyCoalescesMisses(t *testing.T) {
+	release := make(chan struct{})
+	var lock sync.Mutex
+	var batches []int
+	cgm, err := congomap.NewTwoLevelMap(congomap.LookupMany(func(keys []string) (map[string]interface{}, error) {
+		lock.Lock()
+		first := len(batches) == 0
+		batches = append(batches, len(keys))
+		lock.Unlock()
+		if first {
+			<-release // hold the first batch until the other misses are waiting
+		}
+		return lengths(keys)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	var wg sync.WaitGroup
+	loadStore := func(key string) {
+		defer wg.Done()
+		if value, err := cgm.LoadStore(key); err != nil || value != len(key) {
+			t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, err, len(key), nil)
+		}
+	}
+	wg.Add(1)
+	go loadStore("first")
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		lock.Lock()
+		started := len(batches) > 0
+		lock.Unlock()
+		if started {
+			break
+		}
+	}
+	for _, key := range []string{"a", "bb", "ccc"} {
+		wg.Add(1)
+		go loadStore(key)
+	}
+	time.Sleep(10 * time.Millisecond) // let the misses wait for the next batch
+	close(release)
+	wg.Wait()
+
+	if expected := []int{1, 3}; !reflect.DeepEqual(batches, expected) {
+		t.Errorf("Actual: %v; Expected: %v", batches, expected)
+	}
+}
+
+// SyncAtomicMap single-flight lookup
+
+func TestSyncAtomicMapLookupSingleFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var calls int32
+	cgm, err := congomap.NewSyncAtomicMap(congomap.Lookup(func(key string) (interface{}, error) {
+		if key != "k" {
+			return 7, nil
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return 42, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, err := cgm.LoadStore("k"); err != nil || value != 42 {
+				t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, err, 42, nil)
+			}
+		}()
+	}
+	<-started
+
+	stored := make(chan struct{})
+	go func() {
+		cgm.Store("other", 13) // must not wait for the lookup
+		close(stored)
+	}()
+	select {
+	case <-stored:
+	case <-time.After(time.Second):
+		t.Error("Store blocked by lookup of unrelated key")
+	}
+
+	loaded := make(chan struct{})
+	go func() {
+		if value, err := cgm.LoadStore("another"); err != nil || value != 7 { // must not wait either
+			t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, err, 7, nil)
+		}
+		close(loaded)
+	}()
+	select {
+	case <-loaded:
+	case <-time.After(time.Second):
+		t.Error("LoadStore blocked by lookup of unrelated key")
+	}
+
+	time.Sleep(10 * time.Millisecond) // let the other LoadStores join the lookup
+	close(release)
+	wg.Wait()
+
+	if actual, expected := atomic.LoadInt32(&calls), int32(1); actual != expected {
+		t.Errorf("Actual: %#v; Expected: %#v", actual, expected)
+	}
+}
+
+// Stale
+
+func testStale(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var calls int32
+	cgm, err := newCongomap(congomap.Stale(10*time.Millisecond), congomap.TTL(time.Hour), congomap.Lookup(func(string) (interface{}, error) {
+		return int(atomic.AddInt32(&calls, 1)), nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	if value, err := cgm.LoadStore("k"); err != nil || value != 1 {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, 1, nil)
+	}
+	if value, err := cgm.LoadStore("k"); err != nil || value != 1 {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, 1, nil)
+	}
+	if actual, expected := atomic.LoadInt32(&calls), int32(1); actual != expected {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, expected)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	// stale value is returned immediately, while a fresh one is looked up in the background
+	if value, err := cgm.LoadStore("k"); err != nil || value != 1 {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, 1, nil)
+	}
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if value, _ := cgm.Load("k"); value == 2 {
+			break
+		}
+	}
+	if value, ok := cgm.Load("k"); !ok || value != 2 {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, 2, true)
+	}
+	if actual, expected := atomic.LoadInt32(&calls), int32(2); actual != expected {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, expected)
+	}
+}
+
+func testStaleDeletedDuringRefresh(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var calls int32
+	started := make(chan struct{})
+	proceed := make(chan struct{})
+	var lock sync.Mutex
+	var reaped []interface{}
+	cgm, err := newCongomap(congomap.Stale(10*time.Millisecond), congomap.TTL(time.Hour),
+		congomap.Reaper(func(value interface{}) {
+			lock.Lock()
+			reaped = append(reaped, value)
+			lock.Unlock()
+		}),
+		congomap.Lookup(func(string) (interface{}, error) {
+			n := int(atomic.AddInt32(&calls, 1))
+			if n == 2 {
+				close(started)
+				<-proceed
+			}
+			return n, nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := cgm.LoadStore("k"); err != nil || value != 1 {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, 1, nil)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if value, err := cgm.LoadStore("k"); err != nil || value != 1 {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, 1, nil)
+	}
+
+	<-started
+	cgm.Delete("k") // while the refresh is looking up its fresh value
+	close(proceed)
+	if err := congomap.Shutdown(context.Background(), cgm); err != nil {
+		t.Fatal(err)
+	}
+
+	// the fresh value was not stored over the deletion, so was never reaped by Close
+	lock.Lock()
+	defer lock.Unlock()
+	if actual, expected := reaped, []interface{}{1}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, expected)
+	}
+}
+
+func TestStaleDeletedDuringRefreshChannelMap(t *testing.T) {
+	testStaleDeletedDuringRefresh(t, congomap.NewChannelMap, "channel")
+}
+
+func TestStaleDeletedDuringRefreshSyncAtomicMap(t *testing.T) {
+	testStaleDeletedDuringRefresh(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestStaleDeletedDuringRefreshSyncMutexMap(t *testing.T) {
+	testStaleDeletedDuringRefresh(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestStaleDeletedDuringRefreshTwoLevelMap(t *testing.T) {
+	testStaleDeletedDuringRefresh(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+func TestStaleChannelMap(t *testing.T) {
+	testStale(t, congomap.NewChannelMap, "channel")
+}
+
+func TestStaleSyncAtomicMap(t *testing.T) {
+	testStale(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestStaleSyncMutexMap(t *testing.T) {
+	testStale(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestStaleTwoLevelMap(t *testing.T) {
+	testStale(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+func TestStaleOptionConflicts(t *testing.T) {
+	_, err := congomap.NewSyncMutexMap(congomap.Stale(time.Minute))
+	if expected := congomap.ErrOptionConflict("Stale requires Lookup"); err != expected {
+		t.Errorf("Actual: %#v; Expected: %#v", err, expected)
+	}
+	_, err = congomap.NewSyncMutexMap(congomap.Lookup(succeedingLookup), congomap.TTL(time.Minute), congomap.Stale(time.Hour))
+	if expected := congomap.ErrOptionConflict("Stale must be shorter than the time-to-live of looked up values"); err != expected {
+		t.Errorf("Actual: %#v; Expected: %#v", err, expected)
+	}
+}
+
+// Warmup
+
+func testWarmup(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.Lookup(func(key string) (interface{}, error) {
+		if key == "bad" {
+			return nil, errLookupFailed
+		}
+		return len(key), nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	err = congomap.Warmup(cgm, []string{"a", "bb", "bad", "ccc"}, 2)
+	expected := congomap.ErrWarmupFailed{congomap.ErrLookupFailed{Key: "bad", Err: errLookupFailed}}
+	if !reflect.DeepEqual(err, expected) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, expected)
+	}
+	for _, key := range []string{"a", "bb", "ccc"} {
+		if value, ok := cgm.Load(key); !ok || value != len(key) {
+			t.Errorf("Which: %s; Key: %q; Actual: %#v, %#v; Expected: %#v, %#v", which, key, value, ok, len(key), true)
+		}
+	}
+
+	if err := congomap.Warmup(cgm, []string{"a"}, 0); err != (congomap.ErrInvalidCount{Option: "concurrency", Count: 0}) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, congomap.ErrInvalidCount{Option: "concurrency", Count: 0})
+	}
+}
+
+func TestWarmupChannelMap(t *testing.T) {
+	testWarmup(t, congomap.NewChannelMap, "channel")
+}
+
+func TestWarmupSyncAtomicMap(t *testing.T) {
+	testWarmup(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestWarmupSyncMutexMap(t *testing.T) {
+	testWarmup(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestWarmupTwoLevelMap(t *testing.T) {
+	testWarmup(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// RecoverPanics
+
+func testRecoverPanics(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.Lookup(panicLookup), congomap.Reaper(func(_ interface{}) {
+		panic("boom")
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cgm.LoadStore("key")
+	var cp congomap.ErrCallbackPanic
+	if !errors.As(err, &cp) || cp.Callback != "lookup" {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %T", which, err, cp)
+	}
+
+	cgm.Store("key", 1)
+	cgm.Delete("key")
+
+	var rf congomap.ErrReaperFailed
+	if err = cgm.Close(); !errors.As(err, &rf) || !errors.As(err, &cp) || cp.Callback != "reaper" {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %T", which, err, rf)
+	}
+}
+
+func TestRecoverPanicsChannelMap(t *testing.T) {
+	testRecoverPanics(t, congomap.NewChannelMap, "channel")
+}
+
+func TestRecoverPanicsSyncAtomicMap(t *testing.T) {
+	testRecoverPanics(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestRecoverPanicsSyncMutexMap(t *testing.T) {
+	testRecoverPanics(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestRecoverPanicsTwoLevelMap(t *testing.T) {
+	testRecoverPanics(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+func testOnReaperError(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var lock sync.Mutex
+	var failed []string
+	onReaperError := congomap.OnReaperError(func(key string, value interface{}, recovered interface{}) {
+		lock.Lock()
+		failed = append(failed, fmt.Sprintf("%s=%v: %v", key, value, recovered))
+		lock.Unlock()
+	})
+
+	cgm, err := newCongomap(onReaperError, congomap.Reaper(func(_ interface{}) {
+		panic("boom")
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cgm.Store("deleted", 1)
+	cgm.Delete("deleted")
+	cgm.Store("expired", &congomap.ExpiringValue{Value: 2, Expiry: time.Now().Add(-time.Second)})
+	cgm.GC()
+	cgm.Store("closed", 3)
+	var rf congomap.ErrReaperFailed
+	if err = cgm.Close(); !errors.As(err, &rf) || rf.Count != 3 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %T", which, err, rf)
+	}
+
+	cgm, err = newCongomap(onReaperError, congomap.BatchReaper(func(_ []interface{}) {
+		panic("batch")
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cgm.Store("batched", 4)
+	_ = cgm.Close()
+
+	lock.Lock()
+	defer lock.Unlock()
+	sort.Strings(failed)
+	expected := []string{"batched=4: batch", "closed=3: boom", "deleted=1: boom", "expired=2: boom"}
+	if !reflect.DeepEqual(failed, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, failed, expected)
+	}
+}
+
+func TestOnReaperErrorChannelMap(t *testing.T) {
+	testOnReaperError(t, congomap.NewChannelMap, "channel")
+}
+
+func TestOnReaperErrorSyncAtomicMap(t *testing.T) {
+	testOnReaperError(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestOnReaperErrorSyncMutexMap(t *testing.T) {
+	testOnReaperError(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestOnReaperErrorTwoLevelMap(t *testing.T) {
+	testOnReaperError(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+func testReraisePanics(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.Lookup(panicLookup), congomap.RecoverPanics(false), congomap.Reaper(func(value interface{}) {
+		if value == "bad" {
+			panic("boom")
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	expectPanic := func(expected interface{}, fn func()) {
+		defer func() {
+			if r := recover(); r != expected {
+				t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, r, expected)
+			}
+		}()
+		fn()
+	}
+
+	expectPanic("lookup panic", func() { _, _ = cgm.LoadStore("key") })
+	cgm.Store("bad", "bad")
+	expectPanic("boom", func() { cgm.Delete("bad") })
+
+	// no lock may be left held
+	cgm.Store("key", 42)
+	loadValueTrue(t, cgm, which, "key")
+	if actual, expected := len(cgm.Keys()), 1; actual != expected {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, expected)
+	}
+}
+
+func TestReraisePanicsSyncAtomicMap(t *testing.T) {
+	testReraisePanics(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestReraisePanicsSyncAtomicMapWaiters(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	cgm, err := congomap.NewSyncAtomicMap(congomap.RecoverPanics(false), congomap.Lookup(func(string) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+			panic("lookup panic")
+		}
+		return 42, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		_, _ = cgm.LoadStore("key")
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// only the caller that invoked the lookup panics; callers waiting for it receive an error
+	waited := make(chan error)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Errorf("Actual: %#v; Expected: %#v", r, nil)
+				waited <- nil
+			}
+		}()
+		_, err := cgm.LoadStore("key")
+		waited <- err
+	}()
+	time.Sleep(10 * time.Millisecond) // let the second caller wait for the lookup
+	close(release)
+
+	if actual, expected := <-panicked, "lookup panic"; actual != expected {
+		t.Errorf("Actual: %#v; Expected: %#v", actual, expected)
+	}
+	if err := <-waited; err != nil {
+		if _, ok := err.(congomap.ErrCallbackPanic); !ok {
+			t.Errorf("Actual: %#v; Expected: %T", err, congomap.ErrCallbackPanic{})
+		}
+	}
+}
+
+func TestReraisePanicsSyncMutexMap(t *testing.T) {
+	testReraisePanics(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestReraisePanicsTwoLevelMap(t *testing.T) {
+	testReraisePanics(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// DebugLockHolds
+
+func TestDebugLockHoldsTwoLevelMap(t *testing.T) {
+	var lock sync.Mutex
+	var warnings []string
+	logf := func(format string, args ...interface{}) {
+		lock.Lock()
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+		lock.Unlock()
+	}
+	slowLookup := func(_ string) (interface{}, error) {
+		time.Sleep(50 * time.Millisecond)
+		return 42, nil
+	}
+
+	cgm, err := congomap.NewTwoLevelMap(congomap.Lookup(slowLookup), congomap.DebugLockHolds(10*time.Millisecond, logf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, _ = cgm.LoadStore("key")
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	lock.Lock()
+	defer lock.Unlock()
+	var held, waiting bool
+	for _, warning := range warnings {
+		held = held || strings.Contains(warning, `LoadStore "key" held lock for`)
+		waiting = waiting || strings.Contains(warning, `LoadStore "key" waiting longer than 10ms for lock held`)
+	}
+	if !held || !waiting {
+		t.Errorf("Actual: %q; Expected warnings of both holding and waiting for lock", warnings)
+	}
+}
+
+// Option validation
+
+func TestInvalidOptionsAggregated(t *testing.T) {
+	_, err := congomap.NewSyncMutexMap(congomap.TTL(0), congomap.MaxStale(time.Minute), congomap.BadStaleDuration(time.Second))
+	errs, ok := err.(congomap.ErrInvalidOptions)
+	if !ok {
+		t.Fatalf("Actual: %#v; Expected: %T", err, errs)
+	}
+	expected := congomap.ErrInvalidOptions{
+		congomap.ErrInvalidDuration(0),
+		congomap.ErrOptionConflict("MaxStale requires Lookup"),
+		congomap.ErrOptionConflict("BadStaleDuration requires BadExpiryDuration"),
+	}
+	if !reflect.DeepEqual(errs, expected) {
+		t.Errorf("Actual: %v; Expected: %v", errs, expected)
+	}
+}
+
+func TestOptionConflict(t *testing.T) {
+	_, err := congomap.NewTwoLevelMap(congomap.Lookup(failingLookup), congomap.BadExpiryDuration(time.Second), congomap.BadStaleDuration(time.Minute))
+	if expected := congomap.ErrOptionConflict("BadStaleDuration must be shorter than BadExpiryDuration"); err != expected {
+		t.Errorf("Actual: %#v; Expected: %#v", err, expected)
+	}
+}
+
+// StoreTTL and LookupTTL
+
+func testSeparateTTLs(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.Lookup(succeedingLookup), congomap.TTL(time.Millisecond), congomap.StoreTTL(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("stored", 42)
+	if _, err = cgm.LoadStore("looked up"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	loadValueTrue(t, cgm, which, "stored")
+	if value, ok := cgm.Load("looked up"); ok {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, value, nil)
+	}
+}
+
+func TestSeparateTTLsChannelMap(t *testing.T) {
+	testSeparateTTLs(t, congomap.NewChannelMap, "channel")
+}
+
+func TestSeparateTTLsSyncAtomicMap(t *testing.T) {
+	testSeparateTTLs(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestSeparateTTLsSyncMutexMap(t *testing.T) {
+	testSeparateTTLs(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestSeparateTTLsTwoLevelMap(t *testing.T) {
+	testSeparateTTLs(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+func TestLookupTTLInvalidDuration(t *testing.T) {
+	_, err := congomap.NewTwoLevelMap(congomap.LookupTTL(-time.Second))
+	if _, ok := err.(congomap.ErrInvalidDuration); !ok {
+		t.Errorf("Actual: %#v; Expected: %#v", err, congomap.ErrInvalidDuration(-time.Second))
+	}
+}
+
+// MinTTL and MaxTTL
+
+func testTTLBounds(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.MinTTL(time.Minute), congomap.MaxTTL(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	expectNextExpiry := func(expected time.Time) {
+		next, ok := cgm.NextExpiry()
+		if !ok || next.Before(expected.Add(-time.Second)) || next.After(expected.Add(time.Second)) {
+			t.Errorf("Which: %s; Actual: %v, %v; Expected: %v, %v", which, next, ok, expected, true)
+		}
+	}
+
+	now := time.Now()
+	cgm.Store("distant", &congomap.ExpiringValue{Value: 42, Expiry: now.Add(365 * 24 * time.Hour)})
+	cgm.Store("never", 42)
+	expectNextExpiry(now.Add(time.Hour))
+
+	past := &congomap.ExpiringValue{Value: 42, Expiry: now.Add(-time.Hour)}
+	cgm.Store("past", past)
+	loadValueTrue(t, cgm, which, "past")
+	expectNextExpiry(now.Add(time.Minute))
+	if !past.Expiry.Equal(now.Add(-time.Hour)) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, past.Expiry, now.Add(-time.Hour))
+	}
+}
+
+func TestTTLBoundsChannelMap(t *testing.T) {
+	testTTLBounds(t, congomap.NewChannelMap, "channel")
+}
+
+func TestTTLBoundsSyncAtomicMap(t *testing.T) {
+	testTTLBounds(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestTTLBoundsSyncMutexMap(t *testing.T) {
+	testTTLBounds(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestTTLBoundsTwoLevelMap(t *testing.T) {
+	testTTLBounds(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// TTLDistribution
+
+func testTTLDistribution(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.MaxStale(time.Hour), congomap.Lookup(failingLookup))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	now := time.Now()
+	cgm.Store("never", 1)
+	cgm.Store("stale", &congomap.ExpiringValue{Value: 2, Expiry: now.Add(-time.Minute)})
+	cgm.Store("minutes", &congomap.ExpiringValue{Value: 3, Expiry: now.Add(5 * time.Minute)})
+	cgm.Store("days", &congomap.ExpiringValue{Value: 4, Expiry: now.Add(48 * time.Hour)})
+	cgm.GC()
+
+	h, err := congomap.TTLDistribution(cgm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Sampled.Before(now) {
+		t.Errorf("Which: %s; Actual: %v; Expected after: %v", which, h.Sampled, now)
+	}
+	if expected := []int{0, 0, 0, 1, 0, 0, 1}; !reflect.DeepEqual(h.Counts, expected) || h.Expired != 1 || h.Never != 1 {
+		t.Errorf("Which: %s; Actual: %v, %d, %d; Expected: %v, %d, %d", which, h.Counts, h.Expired, h.Never, expected, 1, 1)
+	}
+}
+
+func TestTTLDistributionChannelMap(t *testing.T) {
+	testTTLDistribution(t, congomap.NewChannelMap, "channel")
+}
+
+func TestTTLDistributionSyncAtomicMap(t *testing.T) {
+	testTTLDistribution(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestTTLDistributionSyncMutexMap(t *testing.T) {
+	testTTLDistribution(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestTTLDistributionTwoLevelMap(t *testing.T) {
+	testTTLDistribution(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// GCStats
+
+func testGCStats(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	if r, err := congomap.GCStats(cgm); err != nil || !r.Started.IsZero() {
+		t.Errorf("Which: %s; Actual: %v, %v; Expected zero time", which, r.Started, err)
+	}
+
+	now := time.Now()
+	cgm.Store("never", 1)
+	cgm.Store("hours", &congomap.ExpiringValue{Value: 2, Expiry: now.Add(time.Hour)})
+	cgm.Store("expired1", &congomap.ExpiringValue{Value: 3, Expiry: now.Add(-time.Minute)})
+	cgm.Store("expired2", &congomap.ExpiringValue{Value: 4, Expiry: now.Add(-time.Minute)})
+	cgm.GC()
+
+	r, err := congomap.GCStats(cgm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Started.Before(now) || r.Duration < 0 {
+		t.Errorf("Which: %s; Actual: %v, %v; Expected after: %v", which, r.Started, r.Duration, now)
+	}
+	if r.Remaining != 2 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, r.Remaining, 2)
+	}
+	// syncAtomic removes expired values whenever it stores a value
+	if which != "syncAtomic" && r.Evicted != 2 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, r.Evicted, 2)
+	}
+}
+
+func TestGCStatsChannelMap(t *testing.T) {
+	testGCStats(t, congomap.NewChannelMap, "channel")
+}
+
+func TestGCStatsSyncAtomicMap(t *testing.T) {
+	testGCStats(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestGCStatsSyncMutexMap(t *testing.T) {
+	testGCStats(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestGCStatsTwoLevelMap(t *testing.T) {
+	testGCStats(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// ManualMaintenance and MaintainOnce
+
+func testMaintainOnce(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.ManualMaintenance(), congomap.TTL(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("key", 42)
+	time.Sleep(2 * time.Millisecond)
+	if actual, expected := len(cgm.Keys()), 1; actual != expected {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, expected)
+	}
+	cgm.MaintainOnce()
+	if actual, expected := len(cgm.Keys()), 0; actual != expected {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, expected)
+	}
+}
+
+func TestMaintainOnceChannelMap(t *testing.T) {
+	testMaintainOnce(t, congomap.NewChannelMap, "channel")
+}
+
+func TestMaintainOnceSyncAtomicMap(t *testing.T) {
+	testMaintainOnce(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestMaintainOnceSyncMutexMap(t *testing.T) {
+	testMaintainOnce(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestMaintainOnceTwoLevelMap(t *testing.T) {
+	testMaintainOnce(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// KeyValidator
+
+func testKeyValidator(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.Lookup(succeedingLookup),
+		congomap.KeyValidator(congomap.MaxKeyLength(8)),
+		congomap.KeyValidator(congomap.KeyCharset(func(r rune) bool { return r >= 'a' && r <= 'z' })))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	for _, key := range []string{"toolongkey", "UPPER", "bad\xff"} {
+		cgm.Store(key, 42)
+		cgm.Do(key, func(_ interface{}, _ bool) (interface{}, bool) { return 42, true })
+		value, err := cgm.LoadStore(key)
+		var ik congomap.ErrInvalidKey
+		if value != nil || !errors.As(err, &ik) || ik.Key != key {
+			t.Errorf("Which: %s; Key: %q; Actual: %#v, %#v; Expected: %#v, %T", which, key, value, err, nil, ik)
+		}
+	}
+	if keys := cgm.Keys(); len(keys) != 0 {
+		t.Errorf("Which: %s; Actual: %v; Expected: no keys", which, keys)
+	}
+
+	cgm.Store("valid", 42)
+	loadValueTrue(t, cgm, which, "valid")
+}
+
+func TestKeyValidatorChannelMap(t *testing.T) {
+	testKeyValidator(t, congomap.NewChannelMap, "channel")
+}
+
+func TestKeyValidatorSyncAtomicMap(t *testing.T) {
+	testKeyValidator(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestKeyValidatorSyncMutexMap(t *testing.T) {
+	testKeyValidator(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestKeyValidatorTwoLevelMap(t *testing.T) {
+	testKeyValidator(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// CanonicalKey
+
+func testCanonicalKey(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var lookups []string
+	cgm, err := newCongomap(congomap.CanonicalKey(strings.ToLower), congomap.Lookup(func(key string) (interface{}, error) {
+		lookups = append(lookups, key)
+		return 42, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("Foo", 13)
+	if value, ok := cgm.Load("fOO"); value != 13 || !ok {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, 13, true)
+	}
+	cgm.Do("FOO", func(value interface{}, ok bool) (interface{}, bool) { return value.(int) + 1, true })
+	if value, ok := cgm.Load("foo"); value != 14 || !ok {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, 14, true)
+	}
+	if keys := cgm.Keys(); !reflect.DeepEqual(keys, []string{"foo"}) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, keys, []string{"foo"})
+	}
+	cgm.Delete("FoO")
+	if _, ok := cgm.Load("foo"); ok {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, ok, false)
+	}
+
+	if value, err := cgm.LoadStore("Bar"); value != 42 || err != nil {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, 42, nil)
+	}
+	if !reflect.DeepEqual(lookups, []string{"bar"}) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, lookups, []string{"bar"})
+	}
+}
+
+func TestCanonicalKeyChannelMap(t *testing.T) {
+	testCanonicalKey(t, congomap.NewChannelMap, "channel")
+}
+
+func TestCanonicalKeySyncAtomicMap(t *testing.T) {
+	testCanonicalKey(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestCanonicalKeySyncMutexMap(t *testing.T) {
+	testCanonicalKey(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestCanonicalKeyTwoLevelMap(t *testing.T) {
+	testCanonicalKey(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// AppendKeys
+
+func testAppendKeys(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("alpha", 1)
+	cgm.Store("bravo", 2)
+
+	buf := make([]string, 1, 8)
+	buf[0] = "existing"
+	keys := cgm.AppendKeys(buf)
+	if &keys[0] != &buf[0] {
+		t.Errorf("Which: %s; Actual: new slice; Expected: caller's buffer reused", which)
+	}
+	sort.Strings(keys[1:])
+	if expected := []string{"existing", "alpha", "bravo"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, keys, expected)
+	}
+}
+
+func TestAppendKeysChannelMap(t *testing.T) {
+	testAppendKeys(t, congomap.NewChannelMap, "channel")
+}
+
+func TestAppendKeysSyncAtomicMap(t *testing.T) {
+	testAppendKeys(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestAppendKeysSyncMutexMap(t *testing.T) {
+	testAppendKeys(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestAppendKeysTwoLevelMap(t *testing.T) {
+	testAppendKeys(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// SeenSet
+
+func testSeenSet(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	if _, err = congomap.NewSeenSet(cgm, 0); err == nil {
+		t.Errorf("Which: %s; Actual: %#v; Expected: error", which, err)
+	}
+	seen, err := congomap.NewSeenSet(cgm, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var unseen int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !seen.Seen("event") {
+				atomic.AddInt32(&unseen, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if unseen != 1 {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, unseen, 1)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if seen.Seen("event") {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, true, false)
+	}
+	if !seen.Seen("event") {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, false, true)
+	}
+}
+
+func TestSeenSetChannelMap(t *testing.T) {
+	testSeenSet(t, congomap.NewChannelMap, "channel")
+}
+
+func TestSeenSetSyncAtomicMap(t *testing.T) {
+	testSeenSet(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestSeenSetSyncMutexMap(t *testing.T) {
+	testSeenSet(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestSeenSetTwoLevelMap(t *testing.T) {
+	testSeenSet(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// RateLimiter
+
+func testRateLimiter(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	if _, err = congomap.NewRateLimiter(cgm, time.Second, 0); err == nil {
+		t.Errorf("Which: %s; Actual: %#v; Expected: error", which, err)
+	}
+	limiter, err := congomap.NewRateLimiter(cgm, 50*time.Millisecond, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var allowed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if limiter.Allow("alice") {
+				atomic.AddInt32(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 3 {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, allowed, 3)
+	}
+	if !limiter.Allow("bob") {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, false, true)
+	}
+
+	time.Sleep(75 * time.Millisecond)
+	if !limiter.Allow("alice") {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, false, true)
+	}
+	if limiter.AllowN("alice", 3) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, true, false)
+	}
+	if limiter.AllowN("carol", -5) || limiter.AllowN("carol", 0) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, true, false)
+	}
+	if _, ok := cgm.Load("carol"); ok {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, ok, false) // no bucket is created
+	}
+
+	time.Sleep(200 * time.Millisecond) // every bucket refills, and so expires
+	cgm.GC()
+	if keys := cgm.Keys(); len(keys) != 0 {
+		t.Errorf("Which: %s; Actual: %v; Expected: no keys", which, keys)
+	}
+}
+
+func TestRateLimiterChannelMap(t *testing.T) {
+	testRateLimiter(t, congomap.NewChannelMap, "channel")
+}
+
+func TestRateLimiterSyncAtomicMap(t *testing.T) {
+	testRateLimiter(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestRateLimiterSyncMutexMap(t *testing.T) {
+	testRateLimiter(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestRateLimiterTwoLevelMap(t *testing.T) {
+	testRateLimiter(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// KeyedCall
+
+func TestKeyedCall(t *testing.T) {
+	var kc congomap.KeyedCall
+	var calls int32
+	release := make(chan struct{})
+
+	type result struct {
+		value  interface{}
+		err    error
+		shared bool
+	}
+	results := make(chan result, 5)
+	for i := 0; i < 5; i++ {
+		go func() {
+			value, err, shared := kc.Do("key", func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return 42, nil
+			})
+			results <- result{value, err, shared}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond) // let every caller join the first invocation
+	close(release)
+
+	for i := 0; i < 5; i++ {
+		if r := <-results; r.value != 42 || r.err != nil || !r.shared {
+			t.Errorf("Actual: %#v, %#v, %#v; Expected: %#v, %#v, %#v", r.value, r.err, r.shared, 42, nil, true)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Actual: %d; Expected: %d", calls, 1)
+	}
+
+	value, err, shared := kc.Do("key", func() (interface{}, error) { return 13, nil })
+	if value != 13 || err != nil || shared {
+		t.Errorf("Actual: %#v, %#v, %#v; Expected: %#v, %#v, %#v", value, err, shared, 13, nil, false)
+	}
+
+	_, err, _ = kc.Do("key", func() (interface{}, error) { panic("boom") })
+	if _, ok := err.(congomap.ErrCallbackPanic); !ok {
+		t.Errorf("Actual: %#v; Expected: %T", err, congomap.ErrCallbackPanic{})
+	}
+}
+
+// LoadStoreInfo
+
+func testLoadStoreInfo(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.Lookup(succeedingLookup))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	if value, looked, err := cgm.LoadStoreInfo("miss"); value != 42 || !looked || err != nil {
+		t.Errorf("Which: %s; Actual: %#v, %#v, %#v; Expected: %#v, %#v, %#v", which, value, looked, err, 42, true, nil)
+	}
+	if value, looked, err := cgm.LoadStoreInfo("miss"); value != 42 || looked || err != nil {
+		t.Errorf("Which: %s; Actual: %#v, %#v, %#v; Expected: %#v, %#v, %#v", which, value, looked, err, 42, false, nil)
+	}
+	cgm.Store("hit", 13)
+	if value, looked, err := cgm.LoadStoreInfo("hit"); value != 13 || looked || err != nil {
+		t.Errorf("Which: %s; Actual: %#v, %#v, %#v; Expected: %#v, %#v, %#v", which, value, looked, err, 13, false, nil)
+	}
+}
+
+func TestLoadStoreInfoChannelMap(t *testing.T) {
+	testLoadStoreInfo(t, congomap.NewChannelMap, "channel")
+}
+
+func TestLoadStoreInfoSyncAtomicMap(t *testing.T) {
+	testLoadStoreInfo(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestLoadStoreInfoSyncMutexMap(t *testing.T) {
+	testLoadStoreInfo(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestLoadStoreInfoTwoLevelMap(t *testing.T) {
+	testLoadStoreInfo(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// StoreReturning
+
+func testStoreReturning(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var reaped int32
+	cgm, err := newCongomap(congomap.Reaper(func(interface{}) { atomic.AddInt32(&reaped, 1) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	if prev, existed := cgm.StoreReturning("key", 1); prev != nil || existed {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, prev, existed, nil, false)
+	}
+	if prev, existed := cgm.StoreReturning("key", 2); prev != 1 || !existed {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, prev, existed, 1, true)
+	}
+	if value, ok := cgm.Load("key"); value != 2 || !ok {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, 2, true)
+	}
+	if actual := atomic.LoadInt32(&reaped); actual != 0 {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, actual, 0)
+	}
+
+	cgm.Store("expired", &congomap.ExpiringValue{Value: 3, Expiry: time.Now().Add(-time.Second)})
+	if prev, existed := cgm.StoreReturning("expired", 4); prev != nil || existed {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, prev, existed, nil, false)
+	}
+
+	if prev, existed := cgm.Swap("expired", &congomap.ExpiringValue{Value: 5, Expiry: time.Now().Add(-time.Second)}); prev != 4 || !existed {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, prev, existed, 4, true)
+	}
+	if value, ok := cgm.Load("expired"); value != nil || ok { // the ExpiringValue is honored
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, nil, false)
+	}
+}
+
+func TestStoreReturningChannelMap(t *testing.T) {
+	testStoreReturning(t, congomap.NewChannelMap, "channel")
+}
+
+func TestStoreReturningSyncAtomicMap(t *testing.T) {
+	testStoreReturning(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestStoreReturningSyncMutexMap(t *testing.T) {
+	testStoreReturning(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestStoreReturningTwoLevelMap(t *testing.T) {
+	testStoreReturning(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// LoadOrStore
+
+func testLoadOrStore(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var reaped int32
+	cgm, err := newCongomap(congomap.Reaper(func(interface{}) { atomic.AddInt32(&reaped, 1) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	const racers = 16
+	var stored int32
+	actuals := make(chan interface{}, racers)
+	var wg sync.WaitGroup
+	wg.Add(racers)
+	for i := 0; i < racers; i++ {
+		go func(i int) {
+			defer wg.Done()
+			actual, loaded := cgm.LoadOrStore("race", i)
+			if !loaded {
+				atomic.AddInt32(&stored, 1)
+			}
+			actuals <- actual
+		}(i)
+	}
+	wg.Wait()
+	close(actuals)
+	if stored != 1 {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, stored, 1)
+	}
+	value, _ := cgm.Load("race")
+	for actual := range actuals {
+		if actual != value {
+			t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, value)
+		}
+	}
+
+	cgm.Store("expired", &congomap.ExpiringValue{Value: 1, Expiry: time.Now().Add(-time.Second)})
+	if actual, loaded := cgm.LoadOrStore("expired", &congomap.ExpiringValue{Value: 2}); actual != 2 || loaded {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, actual, loaded, 2, false)
+	}
+	if actual := atomic.LoadInt32(&reaped); actual != 1 {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, actual, 1)
+	}
+}
+
+func TestLoadOrStoreChannelMap(t *testing.T) {
+	testLoadOrStore(t, congomap.NewChannelMap, "channel")
+}
+
+func TestLoadOrStoreSyncAtomicMap(t *testing.T) {
+	testLoadOrStore(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestLoadOrStoreSyncMutexMap(t *testing.T) {
+	testLoadOrStore(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestLoadOrStoreTwoLevelMap(t *testing.T) {
+	testLoadOrStore(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// LoadAndDelete
+
+func testLoadAndDelete(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var reaped int32
+	cgm, err := newCongomap(congomap.Reaper(func(interface{}) { atomic.AddInt32(&reaped, 1) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	const items = 100
+	for i := 0; i < items; i++ {
+		cgm.Store(strconv.Itoa(i), i)
+	}
+
+	// several workers draining the same items each remove every item exactly once
+	removed := make(chan interface{}, items)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < items; i++ {
+				if value, ok := cgm.LoadAndDelete(strconv.Itoa(i)); ok {
+					removed <- value
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(removed)
+	seen := make(map[interface{}]bool)
+	for value := range removed {
+		if seen[value] {
+			t.Errorf("Which: %s; Actual: %#v removed more than once", which, value)
+		}
+		seen[value] = true
+	}
+	if len(seen) != items {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, len(seen), items)
+	}
+	for i := 0; i < items; i++ {
+		if value, ok := cgm.Load(strconv.Itoa(i)); ok {
+			t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, nil, false)
+		}
+	}
+
+	cgm.Store("expired", &congomap.ExpiringValue{Value: 1, Expiry: time.Now().Add(-time.Second)})
+	if value, ok := cgm.LoadAndDelete("expired"); value != nil || ok {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, nil, false)
+	}
+	if actual := atomic.LoadInt32(&reaped); actual != 0 { // removed values are not reaped
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, actual, 0)
+	}
+}
+
+func TestLoadAndDeleteChannelMap(t *testing.T) {
+	testLoadAndDelete(t, congomap.NewChannelMap, "channel")
+}
+
+func TestLoadAndDeleteSyncAtomicMap(t *testing.T) {
+	testLoadAndDelete(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestLoadAndDeleteSyncMutexMap(t *testing.T) {
+	testLoadAndDelete(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestLoadAndDeleteTwoLevelMap(t *testing.T) {
+	testLoadAndDelete(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// DeleteIf
+
+func testDeleteIf(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var reaped int32
+	cgm, err := newCongomap(congomap.Reaper(func(interface{}) { atomic.AddInt32(&reaped, 1) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	expiry := time.Now().Add(time.Hour)
+	cgm.Store("k", &congomap.ExpiringValue{Value: 1, Expiry: expiry})
+
+	if cgm.DeleteIf("k", func(value interface{}) bool { return value == 2 }) {
+		t.Errorf("Actual: %#v; Expected: %#v", true, false)
+	}
+	if value, ok := cgm.Load("k"); !ok || value != 1 {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, ok, 1, true)
+	}
+	if remaining, ok := cgm.TTLRemaining("k"); !ok || remaining < 59*time.Minute {
+		t.Errorf("Actual: %v, %#v; Expected: %v, %#v", remaining, ok, time.Hour, true) // expiry is left alone
+	}
+	if actual := atomic.LoadInt32(&reaped); actual != 0 {
+		t.Errorf("Actual: %#v; Expected: %#v", actual, 0)
+	}
+
+	if cgm.DeleteIf("absent", func(interface{}) bool { return true }) {
+		t.Errorf("Actual: %#v; Expected: %#v", true, false)
+	}
+
+	if !cgm.DeleteIf("k", func(value interface{}) bool { return value == 1 }) {
+		t.Errorf("Actual: %#v; Expected: %#v", false, true)
+	}
+	if _, ok := cgm.Load("k"); ok {
+		t.Errorf("Actual: %#v; Expected: %#v", ok, false)
+	}
+	if actual := atomic.LoadInt32(&reaped); actual != 1 {
+		t.Errorf("Actual: %#v; Expected: %#v", actual, 1)
+	}
+}
+
+func TestDeleteIfChannelMap(t *testing.T) {
+	testDeleteIf(t, congomap.NewChannelMap, "channel")
+}
+
+func TestDeleteIfSyncAtomicMap(t *testing.T) {
+	testDeleteIf(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestDeleteIfSyncMutexMap(t *testing.T) {
+	testDeleteIf(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestDeleteIfTwoLevelMap(t *testing.T) {
+	testDeleteIf(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Update
+
+func testUpdate(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var reaped int32
+	cgm, err := newCongomap(congomap.Reaper(func(interface{}) { atomic.AddInt32(&reaped, 1) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	const tasks = 100
+	var wg sync.WaitGroup
+	wg.Add(tasks)
+	for i := 0; i < tasks; i++ {
+		go func(i int) {
+			defer wg.Done()
+			cgm.Update("list", func(value interface{}, ok bool) (interface{}, bool) {
+				if !ok {
+					return []int{i}, true
+				}
+				return append(append([]int(nil), value.([]int)...), i), true
+			})
+		}(i)
+	}
+	wg.Wait()
+	if value, ok := cgm.Load("list"); !ok || len(value.([]int)) != tasks {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %d items, %#v", which, value, ok, tasks, true)
+	}
+
+	// declining to keep the value removes the key, and reaps the value
+	before := atomic.LoadInt32(&reaped)
+	cgm.Update("list", func(interface{}, bool) (interface{}, bool) { return nil, false })
+	if value, ok := cgm.Load("list"); ok {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, nil, false)
+	}
+	if actual := atomic.LoadInt32(&reaped) - before; actual != 1 {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, actual, 1)
+	}
+
+	var called bool
+	cgm.Update("missing", func(value interface{}, ok bool) (interface{}, bool) {
+		called = true
+		if value != nil || ok {
+			t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, nil, false)
+		}
+		return nil, false
+	})
+	if !called {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, called, true)
+	}
+}
+
+func TestUpdateChannelMap(t *testing.T) {
+	testUpdate(t, congomap.NewChannelMap, "channel")
+}
+
+func TestUpdateSyncAtomicMap(t *testing.T) {
+	testUpdate(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestUpdateSyncMutexMap(t *testing.T) {
+	testUpdate(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestUpdateTwoLevelMap(t *testing.T) {
+	testUpdate(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Len
+
+func testLen(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.Lookup(failingLookup))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	if actual := cgm.Len(); actual != 0 {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, actual, 0)
+	}
+	cgm.Store("abc", 1)
+	cgm.Store("def", 2)
+	cgm.Store("expired", &congomap.ExpiringValue{Value: 3, Expiry: time.Now().Add(-time.Second)})
+	_, _ = cgm.LoadStore("failed") // leaves no value
+	if actual := cgm.Len(); actual != 2 {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, actual, 2)
+	}
+	cgm.Delete("abc")
+	if actual := cgm.Len(); actual != 1 {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, actual, 1)
+	}
+}
+
+func TestLenChannelMap(t *testing.T) {
+	testLen(t, congomap.NewChannelMap, "channel")
+}
+
+func TestLenSyncAtomicMap(t *testing.T) {
+	testLen(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestLenSyncMutexMap(t *testing.T) {
+	testLen(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestLenTwoLevelMap(t *testing.T) {
+	testLen(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Clear
+
+func testClear(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var reaped int32
+	cgm, err := newCongomap(congomap.Reaper(func(interface{}) { atomic.AddInt32(&reaped, 1) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("abc", 1)
+	cgm.Store("def", 2)
+	cgm.Store("expired", &congomap.ExpiringValue{Value: 3, Expiry: time.Now().Add(-time.Second)})
+	cgm.Clear()
+	if actual := atomic.LoadInt32(&reaped); actual != 3 {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, actual, 3)
+	}
+	if keys := cgm.Keys(); len(keys) != 0 {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, keys, []string{})
+	}
+
+	// the Congomap remains usable
+	cgm.Store("abc", 4)
+	if value, ok := cgm.Load("abc"); value != 4 || !ok {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, 4, true)
+	}
+}
+
+func TestClearChannelMap(t *testing.T) {
+	testClear(t, congomap.NewChannelMap, "channel")
+}
+
+func TestClearSyncAtomicMap(t *testing.T) {
+	testClear(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestClearSyncMutexMap(t *testing.T) {
+	testClear(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestClearTwoLevelMap(t *testing.T) {
+	testClear(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// All
+
+func testAll(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("abc", 1)
+	cgm.Store("def", 2)
+	cgm.Store("expired", &congomap.ExpiringValue{Value: 3, Expiry: time.Now().Add(-time.Second)})
+
+	actual := make(map[string]interface{})
+	cgm.All()(func(key string, value interface{}) bool {
+		actual[key] = value
+		return true
+	})
+	if expected := map[string]interface{}{"abc": 1, "def": 2}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, actual, expected)
+	}
+
+	var yielded int
+	cgm.All()(func(string, interface{}) bool {
+		yielded++
+		return false
+	})
+	if yielded != 1 {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, yielded, 1)
+	}
+}
+
+func TestAllChannelMap(t *testing.T) {
+	testAll(t, congomap.NewChannelMap, "channel")
+}
+
+func TestAllSyncAtomicMap(t *testing.T) {
+	testAll(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestAllSyncMutexMap(t *testing.T) {
+	testAll(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestAllTwoLevelMap(t *testing.T) {
+	testAll(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// StoreWithTTL
+
+func testStoreWithTTL(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.TTL(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.StoreWithTTL("short", 1, 10*time.Millisecond)
+	cgm.StoreWithTTL("default", 2, 0)
+	cgm.StoreWithTTL("rewrapped", &congomap.ExpiringValue{Value: 3}, 10*time.Millisecond)
+	if value, ok := cgm.Load("short"); value != 1 || !ok {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, 1, true)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	for _, key := range []string{"short", "rewrapped"} {
+		if value, ok := cgm.Load(key); ok {
+			t.Errorf("Which: %s; Key: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, key, value, ok, nil, false)
+		}
+	}
+	if value, ok := cgm.Load("default"); value != 2 || !ok {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, 2, true)
+	}
+}
+
+func TestStoreWithTTLChannelMap(t *testing.T) {
+	testStoreWithTTL(t, congomap.NewChannelMap, "channel")
+}
+
+func TestStoreWithTTLSyncAtomicMap(t *testing.T) {
+	testStoreWithTTL(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestStoreWithTTLSyncMutexMap(t *testing.T) {
+	testStoreWithTTL(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestStoreWithTTLTwoLevelMap(t *testing.T) {
+	testStoreWithTTL(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Expire
+
+func testExpire(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.TTL(10 * time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("extended", 1)
+	cgm.Store("persisted", 2)
+	cgm.Store("shortened", &congomap.ExpiringValue{Value: 3, Expiry: time.Now().Add(time.Hour)})
+	cgm.Store("expired", 4)
+
+	if !cgm.Expire("extended", time.Hour) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, false, true)
+	}
+	if !cgm.Persist("persisted") {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, false, true)
+	}
+	if !cgm.Expire("shortened", time.Millisecond) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, false, true)
+	}
+	if !cgm.Expire("expired", 0) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, false, true)
+	}
+	if value, ok := cgm.Load("expired"); ok {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, nil, false)
+	}
+	if cgm.Expire("missing", time.Hour) || cgm.Persist("missing") {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, true, false)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if value, ok := cgm.Load("extended"); value != 1 || !ok {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, 1, true)
+	}
+	if value, ok := cgm.Load("persisted"); value != 2 || !ok {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, 2, true)
+	}
+	if value, ok := cgm.Load("shortened"); ok {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, nil, false)
+	}
+	if cgm.Expire("shortened", time.Hour) || cgm.Persist("shortened") {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, true, false)
+	}
+}
+
+func TestExpireChannelMap(t *testing.T) {
+	testExpire(t, congomap.NewChannelMap, "channel")
+}
+
+func TestExpireSyncAtomicMap(t *testing.T) {
+	testExpire(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestExpireSyncMutexMap(t *testing.T) {
+	testExpire(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestExpireTwoLevelMap(t *testing.T) {
+	testExpire(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// TTLRemaining
+
+func testTTLRemaining(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("forever", 1)
+	cgm.Store("hour", &congomap.ExpiringValue{Value: 2, Expiry: time.Now().Add(time.Hour)})
+	cgm.Store("expired", &congomap.ExpiringValue{Value: 3, Expiry: time.Now().Add(-time.Second)})
+
+	if left, ok := cgm.TTLRemaining("forever"); left != 0 || !ok {
+		t.Errorf("Which: %s; Actual: %v, %#v; Expected: %v, %#v", which, left, ok, time.Duration(0), true)
+	}
+	if left, ok := cgm.TTLRemaining("hour"); left <= 59*time.Minute || left > time.Hour || !ok {
+		t.Errorf("Which: %s; Actual: %v, %#v; Expected: %v, %#v", which, left, ok, time.Hour, true)
+	}
+	for _, key := range []string{"expired", "missing"} {
+		if left, ok := cgm.TTLRemaining(key); left != 0 || ok {
+			t.Errorf("Which: %s; Key: %s; Actual: %v, %#v; Expected: %v, %#v", which, key, left, ok, time.Duration(0), false)
+		}
+	}
+}
+
+func TestTTLRemainingChannelMap(t *testing.T) {
+	testTTLRemaining(t, congomap.NewChannelMap, "channel")
+}
+
+func TestTTLRemainingSyncAtomicMap(t *testing.T) {
+	testTTLRemaining(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestTTLRemainingSyncMutexMap(t *testing.T) {
+	testTTLRemaining(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestTTLRemainingTwoLevelMap(t *testing.T) {
+	testTTLRemaining(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// AccessTTL
+
+func testAccessTTL(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.AccessTTL(50*time.Millisecond), congomap.Lookup(succeedingLookup))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("read", 1)
+	cgm.Store("touched", 2)
+	cgm.Store("idle", 3)
+	cgm.Store("forever", &congomap.ExpiringValue{Value: 4})
+	if _, err := cgm.LoadStore("looked"); err != nil {
+		t.Fatal(err)
+	}
+
+	// each read or touch postpones expiry, so values outlive the access TTL while in use
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if value, ok := cgm.Load("read"); value != 1 || !ok {
+			t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, 1, true)
+		}
+		if !cgm.Touch("touched") {
+			t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, false, true)
+		}
+		if value, err := cgm.LoadStore("looked"); value != 42 || err != nil {
+			t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, 42, nil)
+		}
+	}
+
+	if value, ok := cgm.Load("idle"); ok {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, nil, false)
+	}
+	if cgm.Touch("idle") || cgm.Touch("missing") {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, true, false)
+	}
+	if left, ok := cgm.TTLRemaining("forever"); left != 0 || !ok {
+		t.Errorf("Which: %s; Actual: %v, %#v; Expected: %v, %#v", which, left, ok, time.Duration(0), true)
+	}
+
+	// an expiry is never brought forward
+	cgm.Store("long", &congomap.ExpiringValue{Value: 5, Expiry: time.Now().Add(time.Hour)})
+	cgm.Touch("long")
+	if left, _ := cgm.TTLRemaining("long"); left <= time.Minute {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, left, time.Hour)
+	}
+}
+
+func TestAccessTTLChannelMap(t *testing.T) {
+	testAccessTTL(t, congomap.NewChannelMap, "channel")
+}
+
+func TestAccessTTLSyncAtomicMap(t *testing.T) {
+	testAccessTTL(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestAccessTTLSyncMutexMap(t *testing.T) {
+	testAccessTTL(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestAccessTTLTwoLevelMap(t *testing.T) {
+	testAccessTTL(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+func TestTouchWithoutAccessTTL(t *testing.T) {
+	cgm, err := congomap.NewSyncMutexMap(congomap.TTL(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("abc", &congomap.ExpiringValue{Value: 1, Expiry: time.Now().Add(time.Minute)})
+	if !cgm.Touch("abc") {
+		t.Errorf("Actual: %#v; Expected: %#v", false, true)
+	}
+	if left, _ := cgm.TTLRemaining("abc"); left <= time.Minute {
+		t.Errorf("Actual: %v; Expected: %v", left, time.Hour)
+	}
+}
+
+// Batch
+
+func testBatch(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var reaped int32
+	cgm, err := newCongomap(congomap.Reaper(func(value interface{}) {
+		if value != 4 { // some implementations reap expired values whenever they write
+			atomic.AddInt32(&reaped, 1)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("abc", 1)
+	cgm.StoreMany(map[string]interface{}{
+		"abc":     11,
+		"def":     2,
+		"ghi":     3,
+		"expired": &congomap.ExpiringValue{Value: 4, Expiry: time.Now().Add(-time.Second)},
+	})
+	if actual := atomic.LoadInt32(&reaped); actual != 1 {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, actual, 1)
+	}
+
+	values := cgm.LoadMany([]string{"abc", "def", "expired", "missing"})
+	if len(values) != 2 || values["abc"] != 11 || values["def"] != 2 {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, values, map[string]interface{}{"abc": 11, "def": 2})
+	}
+
+	cgm.DeleteMany([]string{"abc", "def", "missing"})
+	if actual := atomic.LoadInt32(&reaped); actual != 3 {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, actual, 3)
+	}
+	if values := cgm.LoadMany([]string{"abc", "def", "ghi"}); len(values) != 1 || values["ghi"] != 3 {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, values, map[string]interface{}{"ghi": 3})
+	}
+}
+
+func TestBatchChannelMap(t *testing.T) {
+	testBatch(t, congomap.NewChannelMap, "channel")
+}
+
+func TestBatchSyncAtomicMap(t *testing.T) {
+	testBatch(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestBatchSyncMutexMap(t *testing.T) {
+	testBatch(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestBatchTwoLevelMap(t *testing.T) {
+	testBatch(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// KeysMatching
+
+func testKeysMatching(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	for _, key := range []string{"session/abc", "session/def", "user/abc", "sessions"} {
+		cgm.Store(key, 1)
+	}
+
+	keys := cgm.KeysWithPrefix("session/")
+	sort.Strings(keys)
+	if expected := []string{"session/abc", "session/def"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, keys, expected)
+	}
+
+	keys = cgm.KeysMatching(func(key string) bool { return strings.HasSuffix(key, "/abc") })
+	sort.Strings(keys)
+	if expected := []string{"session/abc", "user/abc"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, keys, expected)
+	}
+
+	if keys := cgm.KeysWithPrefix("missing/"); len(keys) != 0 {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, keys, []string{})
+	}
+}
+
+func TestKeysMatchingChannelMap(t *testing.T) {
+	testKeysMatching(t, congomap.NewChannelMap, "channel")
+}
+
+func TestKeysMatchingSyncAtomicMap(t *testing.T) {
+	testKeysMatching(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestKeysMatchingSyncMutexMap(t *testing.T) {
+	testKeysMatching(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestKeysMatchingTwoLevelMap(t *testing.T) {
+	testKeysMatching(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Snapshot
+
+func testSnapshot(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.Copier(func(value interface{}) interface{} {
+		return append([]int(nil), value.([]int)...)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("abc", []int{1})
+	cgm.Store("def", []int{2, 3})
+	cgm.Store("expired", &congomap.ExpiringValue{Value: []int{4}, Expiry: time.Now().Add(-time.Second)})
+
+	snapshot := cgm.Snapshot()
+	if expected := map[string]interface{}{"abc": []int{1}, "def": []int{2, 3}}; !reflect.DeepEqual(snapshot, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, snapshot, expected)
+	}
+
+	// neither the snapshot nor its values are shared with the Congomap
+	snapshot["abc"].([]int)[0] = 13
+	delete(snapshot, "def")
+	if value, ok := cgm.Load("abc"); !ok || value.([]int)[0] != 1 {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, []int{1}, true)
+	}
+	if _, ok := cgm.Load("def"); !ok {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, ok, true)
+	}
+}
+
+func TestSnapshotChannelMap(t *testing.T) {
+	testSnapshot(t, congomap.NewChannelMap, "channel")
+}
+
+func TestSnapshotSyncAtomicMap(t *testing.T) {
+	testSnapshot(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestSnapshotSyncMutexMap(t *testing.T) {
+	testSnapshot(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestSnapshotTwoLevelMap(t *testing.T) {
+	testSnapshot(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// SnapshotPairs
+
+func testSnapshotPairs(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.SnapshotPairs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("abc", 1)
+	cgm.Store("def", 2)
+	cgm.Store("expired", &congomap.ExpiringValue{Value: 3, Expiry: time.Now().Add(-time.Second)})
+
+	pairs := cgm.Pairs()
+	p := <-pairs
+
+	// writers proceed while the channel is only partly read
+	done := make(chan struct{})
+	go func() {
+		cgm.Store("ghi", 4)
+		cgm.Delete(p.Key)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Which: %s; writers blocked by unread pairs", which)
+	}
+
+	keys := []string{p.Key}
+	for p := range pairs {
+		keys = append(keys, p.Key)
+	}
+	sort.Strings(keys)
+	if expected := []string{"abc", "def"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, keys, expected)
+	}
+}
+
+func TestSnapshotPairsChannelMap(t *testing.T) {
+	testSnapshotPairs(t, congomap.NewChannelMap, "channel")
+}
+
+func TestSnapshotPairsSyncAtomicMap(t *testing.T) {
+	testSnapshotPairs(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestSnapshotPairsSyncMutexMap(t *testing.T) {
+	testSnapshotPairs(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestSnapshotPairsTwoLevelMap(t *testing.T) {
+	testSnapshotPairs(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Close
+
+func testCloseTwice(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var reaped int32
+	cgm, err := newCongomap(congomap.Lookup(succeedingLookup), congomap.Reaper(func(interface{}) { atomic.AddInt32(&reaped, 1) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cgm.Store("abc", 1)
+
+	if err := cgm.Close(); err != nil {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, nil)
+	}
+	if err := cgm.Close(); err != nil {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, nil)
+	}
+	if actual := atomic.LoadInt32(&reaped); actual != 1 {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, actual, 1)
+	}
+
+	// methods invoked after Close neither panic nor block
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cgm.Store("def", 2)
+		if value, ok := cgm.Load("def"); ok {
+			t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, nil, false)
+		}
+		if value, err := cgm.LoadStore("ghi"); value != nil || !errors.Is(err, congomap.ErrClosed{}) {
+			t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, nil, congomap.ErrClosed{})
+		}
+		cgm.Delete("abc")
+		_ = cgm.Keys()
+		for range cgm.Pairs() {
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Which: %s; methods invoked after Close blocked", which)
+	}
+}
+
+func TestCloseTwiceChannelMap(t *testing.T) {
+	testCloseTwice(t, congomap.NewChannelMap, "channel")
+}
+
+func TestCloseTwiceSyncAtomicMap(t *testing.T) {
+	testCloseTwice(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestCloseTwiceSyncMutexMap(t *testing.T) {
+	testCloseTwice(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestCloseTwiceTwoLevelMap(t *testing.T) {
+	testCloseTwice(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Describe
+
+func ExampleDescribe() {
+	cgm, err := congomap.NewSyncMutexMap(congomap.TTL(time.Minute), congomap.Reaper(func(interface{}) {}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	d, err := congomap.Describe(cgm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(d)
+	// Output: syncMutex{GCInterval: 15m0s, TTL: 1m0s, Reaper: true}
+}
+
+func testDescribe(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.TTL(time.Second), congomap.MaxTTL(time.Hour), congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	d, err := congomap.Describe(cgm)
+	expected := congomap.Description{Implementation: which, TTL: time.Second, MaxTTL: time.Hour}
+	if which == "twoLevel" {
+		expected.ShardCount = 1
+	}
+	if d != expected || err != nil {
+		t.Errorf("Which: %s; Actual: %v, %#v; Expected: %v, %#v", which, d, err, expected, nil)
+	}
+}
+
+func TestDescribeChannelMap(t *testing.T) {
+	testDescribe(t, congomap.NewChannelMap, "channel")
+}
+
+func TestDescribeSyncAtomicMap(t *testing.T) {
+	testDescribe(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestDescribeSyncMutexMap(t *testing.T) {
+	testDescribe(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestDescribeTwoLevelMap(t *testing.T) {
+	testDescribe(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// KeysPage
+
+func testKeysPage(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	for _, key := range []string{"e", "b", "a", "d", "c"} {
+		cgm.Store(key, key)
+	}
+
+	var pages [][]string
+	cursor := ""
+	for {
+		keys, next := cgm.KeysPage(cursor, 2)
+		pages = append(pages, keys)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if expected := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}; !reflect.DeepEqual(pages, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, pages, expected)
+	}
+
+	if keys, next := cgm.KeysPage("c", 2); !reflect.DeepEqual(keys, []string{"d", "e"}) || next != "" {
+		t.Errorf("Which: %s; Actual: %v, %q; Expected: %v, %q", which, keys, next, []string{"d", "e"}, "")
+	}
+}
+
+func TestKeysPageChannelMap(t *testing.T) {
+	testKeysPage(t, congomap.NewChannelMap, "channel")
+}
+
+func TestKeysPageSyncAtomicMap(t *testing.T) {
+	testKeysPage(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestKeysPageSyncMutexMap(t *testing.T) {
+	testKeysPage(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestKeysPageTwoLevelMap(t *testing.T) {
+	testKeysPage(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Where
+
+func testWhere(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	now := time.Now()
+	cgm.Store("a/forever", 1)
+	cgm.Store("a/later", &congomap.ExpiringValue{Value: 2, Expiry: now.Add(time.Hour)})
+	cgm.Store("a/soon", &congomap.ExpiringValue{Value: 3, Expiry: now.Add(time.Second)})
+	cgm.Store("a/expired", &congomap.ExpiringValue{Value: 4, Expiry: now.Add(-time.Second)})
+	cgm.Store("b/forever", 5)
+
+	collect := func(q *congomap.Query) []string {
+		var keys []string
+		if err := q.Each(func(key string, _ interface{}) bool {
+			keys = append(keys, key)
+			return true
+		}); err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	if actual, expected := collect(congomap.Where(cgm, "a/")), []string{"a/forever", "a/later", "a/soon"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, actual, expected)
+	}
+	if actual, expected := collect(congomap.Where(cgm, "a/").WithMinRemaining(time.Minute)), []string{"a/forever", "a/later"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, actual, expected)
+	}
+	if actual := collect(congomap.Where(cgm, "").Limit(2)); len(actual) != 2 {
+		t.Errorf("Which: %s; Actual: %v; Expected: %d keys", which, actual, 2)
+	}
+
+	sum := func(acc interface{}, p congomap.Pair) interface{} { return acc.(int) + p.Value.(int) }
+	if actual, err := congomap.Reduce(cgm, sum, 0); actual != 11 || err != nil {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, actual, err, 11, nil)
+	}
+	if actual, err := congomap.Where(cgm, "a/").Reduce(sum, 10); actual != 16 || err != nil {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, actual, err, 16, nil)
+	}
+}
+
+func TestWhereCanonicalKey(t *testing.T) {
+	cgm, err := congomap.NewSyncMutexMap(congomap.CanonicalKey(strings.ToLower))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+	cgm.Store("Session/Alice", 1)
+	cgm.Store("other", 2)
+
+	var keys []string
+	err = congomap.Where(cgm, "SESSION/").Each(func(key string, _ interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if actual, expected := keys, []string{"session/alice"}; err != nil || !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Actual: %v, %v; Expected: %v", actual, err, expected)
+	}
+}
+
+func TestWhereChannelMap(t *testing.T) {
+	testWhere(t, congomap.NewChannelMap, "channel")
+}
+
+func TestWhereSyncAtomicMap(t *testing.T) {
+	testWhere(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestWhereSyncMutexMap(t *testing.T) {
+	testWhere(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestWhereTwoLevelMap(t *testing.T) {
+	testWhere(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Ping
+
+func testPing(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.Lookup(failingLookup), congomap.BadExpiryDuration(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := congomap.Ping(cgm)
+	if !h.Healthy() || !h.LastGC.IsZero() || h.FailingLookups != 0 || err != nil {
+		t.Errorf("Which: %s; Actual: %+v, %#v; Expected: running, never collected, no failing lookups", which, h, err)
+	}
+
+	_, _ = cgm.LoadStore("abc")
+	_, _ = cgm.LoadStore("def")
+	cgm.GC()
+	if h, _ = congomap.Ping(cgm); h.LastGC.IsZero() || h.FailingLookups != 2 {
+		t.Errorf("Which: %s; Actual: %+v; Expected: collected, %d failing lookups", which, h, 2)
+	}
+
+	if err = cgm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if h, _ = congomap.Ping(cgm); h.Healthy() {
+		t.Errorf("Which: %s; Actual: %+v; Expected: not running", which, h)
+	}
+}
+
+func TestPingChannelMap(t *testing.T) {
+	testPing(t, congomap.NewChannelMap, "channel")
+}
+
+func TestPingSyncAtomicMap(t *testing.T) {
+	testPing(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestPingSyncMutexMap(t *testing.T) {
+	testPing(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestPingTwoLevelMap(t *testing.T) {
+	testPing(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Drain
+
+func testDrain(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var reaped int32
+	cgm, err := newCongomap(
+		congomap.Lookup(func(key string) (interface{}, error) {
+			close(started)
+			<-release
+			return 42, nil
+		}),
+		congomap.Reaper(func(interface{}) { atomic.AddInt32(&reaped, 1) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cgm.Store("abc", 13)
+
+	lookedUp := make(chan interface{})
+	go func() {
+		value, _ := cgm.LoadStore("slow")
+		lookedUp <- value
+	}()
+	<-started
+
+	drained := make(chan error)
+	go func() { drained <- congomap.Drain(cgm) }()
+
+	time.Sleep(10 * time.Millisecond) // let Drain begin
+	select {
+	case err = <-drained:
+		t.Fatalf("Which: %s; Drain returned before in-flight lookup finished: %v", which, err)
+	default:
+	}
+	if _, err = cgm.LoadStore("def"); err != (congomap.ErrDraining{}) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, congomap.ErrDraining{})
+	}
+	cgm.Store("ghi", 1)
+
+	close(release)
+	if value := <-lookedUp; value != 42 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, value, 42)
+	}
+	if err = <-drained; err != nil {
+		t.Fatal(err)
+	}
+	if actual := atomic.LoadInt32(&reaped); actual != 2 { // abc and slow, but not ghi
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, actual, 2)
+	}
+}
+
+func TestDrainChannelMap(t *testing.T) {
+	testDrain(t, congomap.NewChannelMap, "channel")
+}
+
+func TestDrainSyncAtomicMap(t *testing.T) {
+	testDrain(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestDrainSyncMutexMap(t *testing.T) {
+	testDrain(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestDrainTwoLevelMap(t *testing.T) {
+	testDrain(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Shutdown
+
+func testShutdown(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var reaped int32
+	cgm, err := newCongomap(
+		congomap.MaxEntries(1),
+		congomap.Reaper(func(interface{}) {
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&reaped, 1)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cgm.Store("abc", 13)
+	cgm.Store("def", 42) // evicts abc
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := congomap.Shutdown(ctx, cgm); err != nil {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, nil)
+	}
+	if actual, expected := atomic.LoadInt32(&reaped), int32(2); actual != expected {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, expected)
+	}
+	if err := congomap.Shutdown(ctx, cgm); err != nil {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, nil)
+	}
+}
+
+func testShutdownDeadline(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	release := make(chan struct{})
+	cgm, err := newCongomap(congomap.Reaper(func(interface{}) { <-release }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cgm.Store("abc", 13)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if actual, expected := congomap.Shutdown(ctx, cgm), context.DeadlineExceeded; actual != expected {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, expected)
+	}
+
+	close(release)
+	if err := congomap.Shutdown(context.Background(), cgm); err != nil {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, nil)
+	}
+}
+
+func TestShutdownChannelMap(t *testing.T) {
+	testShutdown(t, congomap.NewChannelMap, "channel")
+	testShutdownDeadline(t, congomap.NewChannelMap, "channel")
+}
+
+func TestShutdownSyncAtomicMap(t *testing.T) {
+	testShutdown(t, congomap.NewSyncAtomicMap, "syncAtomic")
+	testShutdownDeadline(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestShutdownSyncMutexMap(t *testing.T) {
+	testShutdown(t, congomap.NewSyncMutexMap, "syncMutex")
+	testShutdownDeadline(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestShutdownTwoLevelMap(t *testing.T) {
+	testShutdown(t, congomap.NewTwoLevelMap, "twoLevel")
+	testShutdownDeadline(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// MaxEntries
+
+func testMaxEntries(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	if _, err := newCongomap(congomap.MaxEntries(0)); err != (congomap.ErrInvalidCount{Option: "MaxEntries", Count: 0}) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, congomap.ErrInvalidCount{Option: "MaxEntries", Count: 0})
+	}
+	if _, err := newCongomap(congomap.EvictionSamples(2)); err == nil {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, "error")
+	}
+
+	reaped := make(chan interface{}, 10)
+	cgm, err := newCongomap(
+		congomap.MaxEntries(3),
+		congomap.EvictionSamples(10), // samples every key
+		congomap.Lookup(succeedingLookup),
+		congomap.Reaper(func(value interface{}) { reaped <- value }),
+		congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	now := time.Now()
+	cgm.Store("forever", 1)
+	cgm.Store("later", &congomap.ExpiringValue{Value: 2, Expiry: now.Add(2 * time.Hour)})
+	cgm.Store("sooner", &congomap.ExpiringValue{Value: 3, Expiry: now.Add(time.Hour)})
+	cgm.Store("forever", 4) // replacing a key never evicts another
+
+	expectReaped := func(expected interface{}) {
+		t.Helper()
+		select {
+		case value := <-reaped:
+			if value != expected {
+				t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, value, expected)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("Which: %s; Actual: %s; Expected: %#v", which, "no value reaped", expected)
+		}
+	}
+	expectReaped(1)
+
+	cgm.Store("new", 5)
+	expectReaped(3)
+	if _, ok := cgm.Load("sooner"); ok {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, ok, false)
+	}
+
+	if _, err = cgm.LoadStore("looked"); err != nil {
+		t.Fatal(err)
+	}
+	expectReaped(2)
+
+	cgm.Do("done", func(interface{}, bool) (interface{}, bool) { return 6, true })
+	<-reaped // every remaining value never expires, so any of them might be evicted
+
+	if _, ok := cgm.Load("done"); !ok {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, ok, true)
+	}
+	if actual := len(cgm.Keys()); actual != 3 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, 3)
+	}
+
+	d, err := congomap.Describe(cgm)
+	if d.MaxEntries != 3 || d.EvictionSamples != 10 || err != nil {
+		t.Errorf("Which: %s; Actual: %v, %#v; Expected: %s, %#v", which, d, err, "MaxEntries: 3, EvictionSamples: 10", nil)
+	}
+}
+
+func TestMaxEntriesChannelMap(t *testing.T) {
+	testMaxEntries(t, congomap.NewChannelMap, "channel")
+}
+
+func TestMaxEntriesSyncAtomicMap(t *testing.T) {
+	testMaxEntries(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestMaxEntriesSyncMutexMap(t *testing.T) {
+	testMaxEntries(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestMaxEntriesTwoLevelMap(t *testing.T) {
+	testMaxEntries(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// EvictLRU
+
+func testEvictLRU(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	if _, err := newCongomap(congomap.EvictLRU()); err != congomap.ErrOptionConflict("EvictLRU requires MaxEntries or MaxCost") {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, congomap.ErrOptionConflict("EvictLRU requires MaxEntries or MaxCost"))
+	}
+
+	reaped := make(chan string, 10)
+	cgm, err := newCongomap(
+		congomap.MaxEntries(3),
+		congomap.EvictionSamples(10), // samples every key
+		congomap.EvictLRU(),
+		congomap.Lookup(succeedingLookup),
+		congomap.Reaper2(func(key string, _ interface{}) { reaped <- key }),
+		congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	expectReaped := func(expected string) {
+		t.Helper()
+		select {
+		case key := <-reaped:
+			if key != expected {
+				t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, key, expected)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("Which: %s; Actual: %s; Expected: %#v", which, "no key reaped", expected)
+		}
+	}
+
+	for _, key := range []string{"a", "b", "c"} {
+		cgm.Store(key, &congomap.ExpiringValue{Value: key, Expiry: time.Now().Add(time.Hour)})
+		time.Sleep(time.Millisecond)
+	}
+	cgm.Load("a") // a is used more recently than b, even though it expires sooner
+	time.Sleep(time.Millisecond)
+
+	cgm.Store("d", "d")
+	expectReaped("b")
+
+	if _, err = cgm.LoadStore("c"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+
+	if _, err = cgm.LoadStore("e"); err != nil {
+		t.Fatal(err)
+	}
+	expectReaped("a")
+
+	d, err := congomap.Describe(cgm)
+	if !d.EvictLRU || err != nil {
+		t.Errorf("Which: %s; Actual: %v, %#v; Expected: %s, %#v", which, d, err, "EvictLRU: true", nil)
+	}
+}
+
+func TestEvictLRUChannelMap(t *testing.T) {
+	testEvictLRU(t, congomap.NewChannelMap, "channel")
+}
+
+func TestEvictLRUSyncAtomicMap(t *testing.T) {
+	testEvictLRU(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestEvictLRUSyncMutexMap(t *testing.T) {
+	testEvictLRU(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestEvictLRUTwoLevelMap(t *testing.T) {
+	testEvictLRU(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// MaxCost
+
+func testMaxCost(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	if _, err := newCongomap(congomap.MaxCost(0)); err != (congomap.ErrInvalidCount{Option: "MaxCost", Count: 0}) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, congomap.ErrInvalidCount{Option: "MaxCost", Count: 0})
+	}
+	length := func(_ string, value interface{}) int64 { return int64(len(value.(string))) }
+	if _, err := newCongomap(congomap.Cost(length)); err != congomap.ErrOptionConflict("Cost requires MaxCost") {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, congomap.ErrOptionConflict("Cost requires MaxCost"))
+	}
+
+	reaped := make(chan string, 10)
+	cgm, err := newCongomap(
+		congomap.MaxCost(10),
+		congomap.Cost(length),
+		congomap.EvictionSamples(10), // samples every key
+		congomap.Reaper2(func(key string, _ interface{}) { reaped <- key }),
+		congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	expectReaped := func(expected ...string) {
+		t.Helper()
+		var keys []string
+		for range expected {
+			select {
+			case key := <-reaped:
+				keys = append(keys, key)
+			case <-time.After(time.Second):
+			}
+		}
+		sort.Strings(keys) // values that never expire are evicted in any order
+		if !reflect.DeepEqual(keys, expected) {
+			t.Errorf("Which: %s; Actual: %v; Expected: %v", which, keys, expected)
+		}
+	}
+	expectKeys := func(expected ...string) {
+		t.Helper()
+		keys := cgm.Keys()
+		sort.Strings(keys)
+		if !reflect.DeepEqual(keys, expected) {
+			t.Errorf("Which: %s; Actual: %v; Expected: %v", which, keys, expected)
+		}
+	}
+
+	now := time.Now()
+	cgm.Store("a", &congomap.ExpiringValue{Value: "aaaa", Expiry: now.Add(time.Hour)})
+	cgm.Store("b", &congomap.ExpiringValue{Value: "bbbb", Expiry: now.Add(2 * time.Hour)})
+	cgm.Store("c", "cccc") // costs 12 in all
+	expectReaped("a")
+	expectKeys("b", "c")
+
+	cgm.Store("b", "bb") // costs 6 in all
+	expectReaped("b")    // replaced
+	cgm.Store("e", "eeee")
+	expectKeys("b", "c", "e")
+
+	cgm.Delete("c")
+	expectReaped("c")
+	cgm.Store("d", "dddddddddddd") // costs more than the limit by itself
+	expectReaped("b", "e")
+	expectKeys("d")
+
+	d, err := congomap.Describe(cgm)
+	if d.MaxCost != 10 || err != nil {
+		t.Errorf("Which: %s; Actual: %v, %#v; Expected: %s, %#v", which, d, err, "MaxCost: 10", nil)
+	}
+}
+
+func TestMaxCostChannelMap(t *testing.T) {
+	testMaxCost(t, congomap.NewChannelMap, "channel")
+}
+
+func TestMaxCostSyncAtomicMap(t *testing.T) {
+	testMaxCost(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestMaxCostSyncMutexMap(t *testing.T) {
+	testMaxCost(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestMaxCostTwoLevelMap(t *testing.T) {
+	testMaxCost(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// MemoryUsage
+
+func testMemoryUsage(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	empty, approx, err := congomap.MemoryUsage(cgm)
+	if empty != 0 || approx || err != nil {
+		t.Errorf("Which: %s; Actual: %#v, %#v, %#v; Expected: %#v, %#v, %#v", which, empty, approx, err, 0, false, nil)
+	}
+
+	cgm.Store("key", strings.Repeat("x", 1000))
+	bytes, approx, err := congomap.MemoryUsage(cgm)
+	if bytes < 1003 || bytes > 1200 || approx || err != nil {
+		t.Errorf("Which: %s; Actual: %#v, %#v, %#v; Expected: %s, %#v, %#v", which, bytes, approx, err, "about 1003", false, nil)
+	}
+
+	cgm.Store("pointer", &struct{ buf []byte }{make([]byte, 1000)})
+	if _, approx, _ = congomap.MemoryUsage(cgm); !approx {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, approx, true)
+	}
+
+	sized, err := newCongomap(congomap.Sizer(func(interface{}) int64 { return 1 << 20 }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sized.Close() }()
+	sized.Store("pointer", &struct{ buf []byte }{})
+	if bytes, approx, err := congomap.MemoryUsage(sized); bytes < 1<<20 || approx || err != nil {
+		t.Errorf("Which: %s; Actual: %#v, %#v, %#v; Expected: %s, %#v, %#v", which, bytes, approx, err, "over 1 MiB", false, nil)
+	}
+}
+
+func TestMemoryUsageChannelMap(t *testing.T) {
+	testMemoryUsage(t, congomap.NewChannelMap, "channel")
+}
+
+func TestMemoryUsageSyncAtomicMap(t *testing.T) {
+	testMemoryUsage(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestMemoryUsageSyncMutexMap(t *testing.T) {
+	testMemoryUsage(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestMemoryUsageTwoLevelMap(t *testing.T) {
+	testMemoryUsage(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Prune
+
+func testPrune(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	reaped := make(chan string, 10)
+	cgm, err := newCongomap(
+		congomap.Reaper2(func(key string, _ interface{}) { reaped <- key }),
+		congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	expectReaped := func(expected ...string) {
+		t.Helper()
+		var keys []string
+		for range expected {
+			select {
+			case key := <-reaped:
+				keys = append(keys, key)
+			case <-time.After(time.Second):
+			}
+		}
+		sort.Strings(keys)
+		if !reflect.DeepEqual(keys, expected) {
+			t.Errorf("Which: %s; Actual: %v; Expected: %v", which, keys, expected)
+		}
+	}
+
+	cgm.Store("z", 26)
+	if n := cgm.Prune(0); n != 0 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, n, 0)
+	}
+	if n := cgm.Prune(-1); n != 0 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, n, 0)
+	}
+	cgm.Delete("z")
+	expectReaped("z")
+
+	now := time.Now()
+	cgm.Store("a", &congomap.ExpiringValue{Value: 1, Expiry: now.Add(time.Hour)})
+	cgm.Store("b", &congomap.ExpiringValue{Value: 2, Expiry: now.Add(2 * time.Hour)})
+	cgm.Store("c", 3)
+	cgm.Store("d", &congomap.ExpiringValue{Value: 4, Expiry: now.Add(time.Minute)})
+
+	if n := cgm.Prune(2); n != 2 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, n, 2)
+	}
+	expectReaped("a", "d")
+
+	if n := cgm.Prune(5); n != 2 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, n, 2)
+	}
+	expectReaped("b", "c")
+
+	lru, err := newCongomap(congomap.MaxEntries(10), congomap.EvictLRU())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = lru.Close() }()
+	lru.Store("x", 1)
+	lru.Store("y", 2)
+	time.Sleep(time.Millisecond)
+	lru.Load("x")
+	if n := lru.Prune(1); n != 1 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, n, 1)
+	}
+	if keys := lru.Keys(); !reflect.DeepEqual(keys, []string{"x"}) {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, keys, []string{"x"})
+	}
+}
+
+func TestPruneChannelMap(t *testing.T) {
+	testPrune(t, congomap.NewChannelMap, "channel")
+}
+
+func TestPruneSyncAtomicMap(t *testing.T) {
+	testPrune(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestPruneSyncMutexMap(t *testing.T) {
+	testPrune(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestPruneTwoLevelMap(t *testing.T) {
+	testPrune(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Stats
+
+func testStats(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	plain, err := newCongomap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = plain.Close() }()
+	plain.Store("a", 1)
+	if stats, err := congomap.Stats(plain); stats != (congomap.CacheStats{}) || err != nil {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, stats, err, congomap.CacheStats{}, nil)
+	}
+
+	cgm, err := newCongomap(
+		congomap.CollectStats(),
+		congomap.TTL(time.Hour),
+		congomap.Lookup(succeedingLookup),
+		congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Load("a")
+	_, _ = cgm.LoadStore("a")
+	_, _ = cgm.LoadStore("a")
+	cgm.Load("a")
+	_, _ = cgm.LoadStoreWith("bad", failingLookup)
+	cgm.Store("b", 1)
+	cgm.Delete("b")
+	cgm.Store("c", &congomap.ExpiringValue{Value: 1, Expiry: time.Now().Add(-time.Minute)})
+	cgm.GC()
+	cgm.Store("d", 1)
+	cgm.Prune(1)
+	cgm.Store("e", 1)
+	cgm.LoadMany([]string{"e", "z"})
+
+	// waits for the eviction, which twoLevel reaps in the background
+	if err := congomap.Shutdown(context.Background(), cgm); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := congomap.CacheStats{
+		Hits:         3,
+		Misses:       4,
+		Lookups:      2,
+		LookupErrors: 1,
+		Stores:       4,
+		Deletes:      1,
+		Expirations:  1,
+		Evictions:    1,
+	}
+	stats, err := congomap.Stats(cgm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != expected {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, stats, expected)
+	}
+	if ratio := stats.HitRatio(); ratio != 3.0/7 {
+		t.Errorf("Which: %s; Actual: %v; Expected: %v", which, ratio, 3.0/7)
+	}
+}
+
+func TestStatsChannelMap(t *testing.T) {
+	testStats(t, congomap.NewChannelMap, "channel")
+}
+
+func TestStatsSyncAtomicMap(t *testing.T) {
+	testStats(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestStatsSyncMutexMap(t *testing.T) {
+	testStats(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestStatsTwoLevelMap(t *testing.T) {
+	testStats(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// PublishExpvar
+
+func testPublishExpvar(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	if _, err := newCongomap(congomap.PublishExpvar("")); err != congomap.ErrOptionConflict("PublishExpvar requires a name") {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, congomap.ErrOptionConflict("PublishExpvar requires a name"))
+	}
+
+	name := fmt.Sprintf("congomap_test_%s_%d", which, time.Now().UnixNano()) // unique when run repeatedly
+	cgm, err := newCongomap(congomap.PublishExpvar(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("a", 1)
+	cgm.Load("a")
+	cgm.Load("b")
+
+	v := expvar.Get(name)
+	if v == nil {
+		t.Fatalf("Which: %s; Actual: %v; Expected: published", which, v)
+	}
+	var stats congomap.CacheStats
+	if err := json.Unmarshal([]byte(v.String()), &stats); err != nil {
+		t.Fatal(err)
+	}
+	expected := congomap.CacheStats{Hits: 1, Misses: 1, Stores: 1}
+	if stats != expected {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, stats, expected)
+	}
+
+	conflict := congomap.ErrOptionConflict(fmt.Sprintf("PublishExpvar name %q is already published", name))
+	if _, err := newCongomap(congomap.PublishExpvar(name)); err != conflict {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, conflict)
+	}
+}
+
+func TestPublishExpvarChannelMap(t *testing.T) {
+	testPublishExpvar(t, congomap.NewChannelMap, "channel")
+}
+
+func TestPublishExpvarSyncAtomicMap(t *testing.T) {
+	testPublishExpvar(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestPublishExpvarSyncMutexMap(t *testing.T) {
+	testPublishExpvar(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestPublishExpvarTwoLevelMap(t *testing.T) {
+	testPublishExpvar(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Trace
+
+func testTrace(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	type spanKey struct{}
+	var lock sync.Mutex
+	var records []string
+	record := func(format string, args ...interface{}) {
+		lock.Lock()
+		records = append(records, fmt.Sprintf(format, args...))
+		lock.Unlock()
+	}
+
+	cgm, err := newCongomap(
+		congomap.Trace(func(ctx context.Context, operation, key string) (context.Context, func(error)) {
+			record("start %s %s within %v", operation, key, ctx.Value(spanKey{}))
+			return context.WithValue(ctx, spanKey{}, operation), func(err error) {
+				record("end %s %s: %v", operation, key, err)
+			}
+		}),
+		congomap.LookupContext(func(ctx context.Context, key string) (interface{}, error) {
+			record("lookup %s within %v", key, ctx.Value(spanKey{}))
+			return 42, nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	_, _ = cgm.LoadStore("a") // miss
+	_, _ = cgm.LoadStore("a") // hit
+	_, _ = cgm.LoadStoreWith("b", failingLookup)
+
+	expected := []string{
+		"start LoadStore a within <nil>",
+		"start Lookup a within LoadStore",
+		"lookup a within Lookup",
+		"end Lookup a: <nil>",
+		"end LoadStore a: <nil>",
+		"start LoadStore a within <nil>",
+		"end LoadStore a: <nil>",
+		"start LoadStore b within <nil>",
+		"start Lookup b within LoadStore",
+		"end Lookup b: " + errLookupFailed.Error(),
+		"end LoadStore b: " + congomap.ErrLookupFailed{Key: "b", Err: errLookupFailed}.Error(),
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Which: %s; Actual: %q; Expected: %q", which, records, expected)
+	}
+}
+
+func TestTraceChannelMap(t *testing.T) {
+	testTrace(t, congomap.NewChannelMap, "channel")
+}
+
+func TestTraceSyncAtomicMap(t *testing.T) {
+	testTrace(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestTraceSyncMutexMap(t *testing.T) {
+	testTrace(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestTraceTwoLevelMap(t *testing.T) {
+	testTrace(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Logger
+
+type recordingLogger struct {
+	lock    sync.Mutex
+	records []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.record("DEBUG "+format, args...)
+}
+
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.record("ERROR "+format, args...)
+}
+
+func (l *recordingLogger) record(format string, args ...interface{}) {
+	l.lock.Lock()
+	l.records = append(l.records, fmt.Sprintf(format, args...))
+	l.lock.Unlock()
+}
+
+func testLogger(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	logger := new(recordingLogger)
+	cgm, err := newCongomap(
+		congomap.Logger(logger),
+		congomap.MaxEntries(1),
+		congomap.FallibleReaper(func(interface{}) error { return errors.New("reaper failed") }),
+		congomap.Lookup(failingLookup),
+		congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = cgm.LoadStore("bad")
+	cgm.Store("a", 1)
+	cgm.Store("b", 2) // evicts a
+	cgm.GC()
+	// waits for the eviction, which twoLevel reaps in the background
+	_ = congomap.Shutdown(context.Background(), cgm)
+
+	logger.lock.Lock()
+	defer logger.lock.Unlock()
+	for _, expected := range []string{
+		`ERROR congomap: lookup "bad": lookup failed`,
+		"DEBUG congomap: evicted 1 values",
+		"ERROR congomap: reaper: reaper failed",
+		"DEBUG congomap: GC evicted 0 values and retained 1 in ",
+	} {
+		var found bool
+		for _, record := range logger.records {
+			if strings.HasPrefix(record, expected) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Which: %s; Actual: %q; Expected: %q", which, logger.records, expected)
+		}
+	}
+}
+
+func TestLoggerChannelMap(t *testing.T) {
+	testLogger(t, congomap.NewChannelMap, "channel")
+}
+
+func TestLoggerSyncAtomicMap(t *testing.T) {
+	testLogger(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestLoggerSyncMutexMap(t *testing.T) {
+	testLogger(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestLoggerTwoLevelMap(t *testing.T) {
+	testLogger(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Observe
+
+type recordingObserver struct {
+	lock    sync.Mutex
+	records []string
+}
+
+func (o *recordingObserver) record(format string, args ...interface{}) {
+	o.lock.Lock()
+	o.records = append(o.records, fmt.Sprintf(format, args...))
+	o.lock.Unlock()
+}
+
+func (o *recordingObserver) OnLoad(key string, hit bool) {
+	o.record("load %s %t", key, hit)
+}
+
+func (o *recordingObserver) OnStore(key string) {
+	o.record("store %s", key)
+}
+
+func (o *recordingObserver) OnLoadStore(key string, looked bool, err error) {
+	o.record("loadStore %s %t %v", key, looked, err)
+}
+
+func (o *recordingObserver) OnDelete(key string) {
+	o.record("delete %s", key)
+}
+
+func (o *recordingObserver) OnEvict(key string, reason congomap.EvictionReason) {
+	o.record("evict %s %s", key, reason)
+}
+
+func (o *recordingObserver) OnLookup(key string, duration time.Duration, err error) {
+	o.record("lookup %s %t %v", key, duration > 0, err)
+}
+
+func testObserve(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	observer := new(recordingObserver)
+	cgm, err := newCongomap(
+		congomap.Observe(observer),
+		congomap.Lookup(succeedingLookup),
+		congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Load("a")
+	_, _ = cgm.LoadStore("a")
+	_, _ = cgm.LoadStore("a")
+	cgm.Store("b", 1)
+	cgm.Do("c", func(interface{}, bool) (interface{}, bool) { return 2, true })
+	cgm.LoadMany([]string{"b"})
+	cgm.Delete("b")
+	cgm.Store("d", &congomap.ExpiringValue{Value: 3, Expiry: time.Now().Add(-time.Minute)})
+	cgm.GC()
+
+	expected := []string{
+		"load a false",
+		"lookup a true <nil>",
+		"loadStore a true <nil>",
+		"loadStore a false <nil>",
+		"store b",
+		"store c",
+		"load b true",
+		"delete b",
+		"store d",
+		"evict d " + congomap.ReasonExpired.String(),
+	}
+	observer.lock.Lock()
+	defer observer.lock.Unlock()
+	if !reflect.DeepEqual(observer.records, expected) {
+		t.Errorf("Which: %s; Actual: %q; Expected: %q", which, observer.records, expected)
+	}
+}
+
+func TestObserveChannelMap(t *testing.T) {
+	testObserve(t, congomap.NewChannelMap, "channel")
+}
+
+func TestObserveSyncAtomicMap(t *testing.T) {
+	testObserve(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestObserveSyncMutexMap(t *testing.T) {
+	testObserve(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestObserveTwoLevelMap(t *testing.T) {
+	testObserve(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Clock
+
+// fakeClock is a TimeSource whose time only passes when advanced.
+type fakeClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	when time.Time
+	ch   chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{when: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the time forward, signaling the waiters whose durations have elapsed.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.when.After(c.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = waiters
+}
+
+// waitForWaiter returns once something waits upon the clock.
+func (c *fakeClock) waitForWaiter() {
+	for {
+		c.lock.Lock()
+		n := len(c.waiters)
+		c.lock.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func testClock(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	clock := &fakeClock{now: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	reaped := make(chan string, 1)
+	cgm, err := newCongomap(
+		congomap.Clock(clock),
+		congomap.TTL(time.Minute),
+		congomap.Reaper2(func(key string, _ interface{}) { reaped <- key }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("a", 1)
+	clock.Advance(59 * time.Second)
+	if _, ok := cgm.Load("a"); !ok {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, ok, true)
+	}
+	clock.Advance(2 * time.Second)
+	if _, ok := cgm.Load("a"); ok {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, ok, false)
+	}
+
+	// the periodic GC is scheduled by the clock
+	clock.waitForWaiter()
+	clock.Advance(15 * time.Minute)
+	select {
+	case key := <-reaped:
+		if key != "a" {
+			t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, key, "a")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, "not reaped", "a")
+	}
+}
+
+func TestClockChannelMap(t *testing.T) {
+	testClock(t, congomap.NewChannelMap, "channel")
+}
+
+func TestClockSyncAtomicMap(t *testing.T) {
+	testClock(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestClockSyncMutexMap(t *testing.T) {
+	testClock(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestClockTwoLevelMap(t *testing.T) {
+	testClock(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// TTLJitter
+
+func testTTLJitter(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	if _, err := newCongomap(congomap.TTLJitter(1)); err != congomap.ErrInvalidFraction(1) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, congomap.ErrInvalidFraction(1))
+	}
+	if _, err := newCongomap(congomap.TTLJitter(0.5)); err != congomap.ErrOptionConflict("TTLJitter requires TTL, StoreTTL, or LookupTTL") {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, congomap.ErrOptionConflict("TTLJitter requires TTL, StoreTTL, or LookupTTL"))
+	}
+
+	clock := &fakeClock{now: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	cgm, err := newCongomap(
+		congomap.Clock(clock),
+		congomap.TTL(time.Hour),
+		congomap.TTLJitter(0.5),
+		congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	remaining := make(map[time.Duration]struct{})
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		cgm.Store(key, i)
+		ttl, ok := cgm.TTLRemaining(key)
+		if !ok || ttl < 30*time.Minute || ttl > 90*time.Minute {
+			t.Errorf("Which: %s; Actual: %v, %v; Expected: between %v and %v", which, ttl, ok, 30*time.Minute, 90*time.Minute)
+		}
+		remaining[ttl] = struct{}{}
+	}
+	if len(remaining) < 2 {
+		t.Errorf("Which: %s; Actual: %v; Expected: randomized", which, remaining)
+	}
+}
+
+func TestTTLJitterChannelMap(t *testing.T) {
+	testTTLJitter(t, congomap.NewChannelMap, "channel")
+}
+
+func TestTTLJitterSyncAtomicMap(t *testing.T) {
+	testTTLJitter(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestTTLJitterSyncMutexMap(t *testing.T) {
+	testTTLJitter(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestTTLJitterTwoLevelMap(t *testing.T) {
+	testTTLJitter(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// GCInterval
+
+func testGCInterval(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	if _, err := newCongomap(congomap.GCInterval(0)); err != congomap.ErrInvalidDuration(0) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, congomap.ErrInvalidDuration(0))
+	}
+	if _, err := newCongomap(congomap.GCInterval(time.Second), congomap.ManualMaintenance()); err != congomap.ErrOptionConflict("GCInterval conflicts with ManualMaintenance") {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, congomap.ErrOptionConflict("GCInterval conflicts with ManualMaintenance"))
+	}
+
+	clock := &fakeClock{now: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	reaped := make(chan string, 1)
+	cgm, err := newCongomap(
+		congomap.Clock(clock),
+		congomap.GCInterval(10*time.Second),
+		congomap.Reaper2(func(key string, _ interface{}) { reaped <- key }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	if d, err := congomap.Describe(cgm); err != nil || d.GCInterval != 10*time.Second {
+		t.Errorf("Which: %s; Actual: %v, %v; Expected: %v, %v", which, d.GCInterval, err, 10*time.Second, nil)
+	}
+
+	cgm.Store("a", &congomap.ExpiringValue{Value: 1, Expiry: clock.Now().Add(time.Second)})
+	clock.waitForWaiter()
+	clock.Advance(10 * time.Second)
+	select {
+	case key := <-reaped:
+		if key != "a" {
+			t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, key, "a")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, "not reaped", "a")
+	}
+}
+
+func TestGCIntervalChannelMap(t *testing.T) {
+	testGCInterval(t, congomap.NewChannelMap, "channel")
+}
+
+func TestGCIntervalSyncAtomicMap(t *testing.T) {
+	testGCInterval(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestGCIntervalSyncMutexMap(t *testing.T) {
+	testGCInterval(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestGCIntervalTwoLevelMap(t *testing.T) {
+	testGCInterval(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// NoGoroutine
+
+func testNoGoroutine(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	const count = 100
+	reaped := make(chan string, count)
+	before := runtime.NumGoroutine()
+	cgms := make([]congomap.Congomap, count)
+	for i := range cgms {
+		cgm, err := newCongomap(
+			congomap.NoGoroutine(),
+			congomap.Reaper2(func(key string, _ interface{}) { reaped <- key }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cgm.Store(strconv.Itoa(i), i)
+		cgms[i] = cgm
+	}
+	if after := runtime.NumGoroutine(); after-before >= count {
+		t.Errorf("Which: %s; Actual: %d; Expected: fewer than %d", which, after-before, count)
+	}
+
+	if health, err := congomap.Ping(cgms[0]); err != nil || !health.Running {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: running", which, health, err)
+	}
+	for _, cgm := range cgms {
+		if err := cgm.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(reaped) != count {
+		t.Errorf("Which: %s; Actual: %d; Expected: %d", which, len(reaped), count)
+	}
+	if health, err := congomap.Ping(cgms[0]); err != nil || health.Running {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: not running", which, health, err)
+	}
+}
+
+func TestNoGoroutineChannelMap(t *testing.T) {
+	if _, err := congomap.NewChannelMap(congomap.NoGoroutine()); err != (congomap.ErrUnsupportedOption{}) {
+		t.Errorf("Actual: %#v; Expected: %#v", err, congomap.ErrUnsupportedOption{})
+	}
+}
+
+func TestNoGoroutineSyncAtomicMap(t *testing.T) {
+	testNoGoroutine(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestNoGoroutineSyncMutexMap(t *testing.T) {
+	testNoGoroutine(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestNoGoroutineTwoLevelMap(t *testing.T) {
+	testNoGoroutine(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// PauseGC
+
+func testPauseGC(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	clock := &fakeClock{now: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	reaped := make(chan string, 1)
+	cgm, err := newCongomap(
+		congomap.Clock(clock),
+		congomap.GCInterval(10*time.Second),
+		congomap.Reaper2(func(key string, _ interface{}) { reaped <- key }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.PauseGC()
+	cgm.Store("a", &congomap.ExpiringValue{Value: 1, Expiry: clock.Now().Add(time.Second)})
+	clock.waitForWaiter()
+	clock.Advance(10 * time.Second)
+	select {
+	case key := <-reaped:
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, key, "not reaped while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cgm.ResumeGC()
+	clock.waitForWaiter()
+	clock.Advance(10 * time.Second)
+	select {
+	case key := <-reaped:
+		if key != "a" {
+			t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, key, "a")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, "not reaped", "a")
+	}
+}
+
+func TestPauseGCChannelMap(t *testing.T) {
+	testPauseGC(t, congomap.NewChannelMap, "channel")
+}
+
+func TestPauseGCSyncAtomicMap(t *testing.T) {
+	testPauseGC(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestPauseGCSyncMutexMap(t *testing.T) {
+	testPauseGC(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestPauseGCTwoLevelMap(t *testing.T) {
+	testPauseGC(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// GC concurrent with Store
+
+func testGCKeepsFreshValues(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	const count = 2000
+	cgm, err := newCongomap(congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	expired := time.Now().Add(-time.Minute)
+	for i := 0; i < count; i++ {
+		cgm.Store(strconv.Itoa(i), &congomap.ExpiringValue{Value: i, Expiry: expired})
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < count; i++ {
+			cgm.Store(strconv.Itoa(i), i)
+		}
+	}()
+	cgm.GC()
+	wg.Wait()
+
+	for i := 0; i < count; i++ {
+		if _, ok := cgm.Load(strconv.Itoa(i)); !ok {
+			t.Fatalf("Which: %s; Key: %d; Actual: %#v; Expected: %#v", which, i, ok, true)
+		}
+	}
+}
+
+func TestGCKeepsFreshValuesChannelMap(t *testing.T) {
+	testGCKeepsFreshValues(t, congomap.NewChannelMap, "channel")
+}
+
+func TestGCKeepsFreshValuesSyncAtomicMap(t *testing.T) {
+	testGCKeepsFreshValues(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestGCKeepsFreshValuesSyncMutexMap(t *testing.T) {
+	testGCKeepsFreshValues(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestGCKeepsFreshValuesTwoLevelMap(t *testing.T) {
+	testGCKeepsFreshValues(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+func testExpiryHeap(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	clock := &fakeClock{now: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	cgm, err := newCongomap(congomap.Clock(clock), congomap.ExpiryHeap(), congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cgm.Store("a", &congomap.ExpiringValue{Value: 1, Expiry: clock.Now().Add(time.Second)})
+	cgm.Store("b", &congomap.ExpiringValue{Value: 2, Expiry: clock.Now().Add(time.Hour)})
+	cgm.Store("c", &congomap.ExpiringValue{Value: 3, Expiry: clock.Now().Add(time.Second)})
+	cgm.Store("c", 4) // replaced, so its entry in the heap is ignored
+	clock.Advance(2 * time.Second)
+	cgm.GC()
+	if r, err := congomap.GCStats(cgm); err != nil || r.Evicted != 1 || r.Remaining != 2 {
+		t.Errorf("Which: %s; Actual: %d, %d, %v; Expected: %d, %d, %v", which, r.Evicted, r.Remaining, err, 1, 2, nil)
+	}
+	keys := cgm.Keys()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "b" || keys[1] != "c" {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, keys, []string{"b", "c"})
+	}
+	if _, err := congomap.TTLDistribution(cgm); err != congomap.ErrOptionConflict("TTLDistribution is not sampled with ExpiryHeap") {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, congomap.ErrOptionConflict("TTLDistribution is not sampled with ExpiryHeap"))
+	}
+	_ = cgm.Close()
+
+	// GC is invoked once the soonest value expires, long before the interval between GCs
+	clock = &fakeClock{now: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	reaped := make(chan string, 2)
+	cgm, err = newCongomap(
+		congomap.Clock(clock),
+		congomap.ExpiryHeap(),
+		congomap.Reaper2(func(key string, _ interface{}) { reaped <- key }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("a", &congomap.ExpiringValue{Value: 1, Expiry: clock.Now().Add(time.Second)})
+	cgm.Store("b", &congomap.ExpiringValue{Value: 2, Expiry: clock.Now().Add(time.Hour)})
+	for i := 0; i < 60; i++ {
+		clock.waitForWaiter()
+		clock.Advance(time.Second)
+		select {
+		case key := <-reaped:
+			if key != "a" {
+				t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, key, "a")
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, "not reaped", "a")
+}
+
+func TestExpiryHeapChannelMap(t *testing.T) {
+	testExpiryHeap(t, congomap.NewChannelMap, "channel")
+}
+
+func TestExpiryHeapSyncAtomicMap(t *testing.T) {
+	testExpiryHeap(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestExpiryHeapSyncMutexMap(t *testing.T) {
+	testExpiryHeap(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestExpiryHeapTwoLevelMap(t *testing.T) {
+	testExpiryHeap(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+func testFailedLookupLeavesNoKey(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(congomap.Lookup(failingLookup))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	for i := 0; i < 100; i++ {
+		if _, err := cgm.LoadStore(strconv.Itoa(i)); err == nil {
+			t.Fatalf("Which: %s; Actual: %#v; Expected: %#v", which, err, "error")
+		}
+	}
+	if keys := cgm.Keys(); len(keys) != 0 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, keys, []string{})
+	}
+
+	// a value stored while lookups of its key fail is retained
+	var wg sync.WaitGroup
+	wg.Add(9)
+	for i := 0; i < 8; i++ {
+		go func() {
+			defer wg.Done()
+			_, _ = cgm.LoadStore("k")
+		}()
+	}
+	go func() {
+		defer wg.Done()
+		cgm.Store("k", 13)
+	}()
+	wg.Wait()
+	if value, ok := cgm.Load("k"); !ok || value != 13 {
+		t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, 13, true)
+	}
+}
+
+func TestFailedLookupLeavesNoKeyChannelMap(t *testing.T) {
+	testFailedLookupLeavesNoKey(t, congomap.NewChannelMap, "channel")
+}
+
+func TestFailedLookupLeavesNoKeySyncAtomicMap(t *testing.T) {
+	testFailedLookupLeavesNoKey(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestFailedLookupLeavesNoKeySyncMutexMap(t *testing.T) {
+	testFailedLookupLeavesNoKey(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestFailedLookupLeavesNoKeyTwoLevelMap(t *testing.T) {
+	testFailedLookupLeavesNoKey(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+func testLoadStoreHotKey(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	var lookups int32
+	cgm, err := newCongomap(congomap.Lookup(func(string) (interface{}, error) {
+		atomic.AddInt32(&lookups, 1)
+		return -1, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("hot", 0)
+	var wg sync.WaitGroup
+	wg.Add(17)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 100; i++ {
+			cgm.Store("hot", i)
+		}
+	}()
+	for g := 0; g < 16; g++ {
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				value, err := cgm.LoadStore("hot")
+				if n, ok := value.(int); err != nil || !ok || n < 0 || n > 100 {
+					t.Errorf("Which: %s; Actual: %#v, %v; Expected: %s, %v", which, value, err, "a stored value", nil)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&lookups); n != 0 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, n, 0)
+	}
+}
+
+func TestLoadStoreHotKeyChannelMap(t *testing.T) {
+	testLoadStoreHotKey(t, congomap.NewChannelMap, "channel")
+}
+
+func TestLoadStoreHotKeySyncAtomicMap(t *testing.T) {
+	testLoadStoreHotKey(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestLoadStoreHotKeySyncMutexMap(t *testing.T) {
+	testLoadStoreHotKey(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestLoadStoreHotKeyTwoLevelMap(t *testing.T) {
+	testLoadStoreHotKey(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+func testManyKeys(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	const count = 5000
+	cgm, err := newCongomap(congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	for i := 0; i < count; i++ {
+		cgm.Store(strconv.Itoa(i), i)
+	}
+	for i := 0; i < count; i += 2 {
+		cgm.Delete(strconv.Itoa(i))
+	}
+	for i := 1; i < count; i += 4 {
+		cgm.Store(strconv.Itoa(i), -i)
+	}
+
+	for i := 0; i < count; i++ {
+		value, ok := cgm.Load(strconv.Itoa(i))
+		var expected interface{}
+		switch {
+		case i%2 == 0:
+			expected = nil
+		case i%4 == 1:
+			expected = -i
+		default:
+			expected = i
+		}
+		if value != expected || ok != (expected != nil) {
+			t.Fatalf("Which: %s; Key: %d; Actual: %#v, %#v; Expected: %#v, %#v", which, i, value, ok, expected, expected != nil)
+		}
+	}
+	if keys := cgm.Keys(); len(keys) != count/2 {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, len(keys), count/2)
+	}
+}
+
+func TestManyKeysChannelMap(t *testing.T) {
+	testManyKeys(t, congomap.NewChannelMap, "channel")
+}
+
+func TestManyKeysSyncAtomicMap(t *testing.T) {
+	testManyKeys(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestManyKeysSyncMutexMap(t *testing.T) {
+	testManyKeys(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestManyKeysTwoLevelMap(t *testing.T) {
+	testManyKeys(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// ShardCount
+
+func TestShardCount(t *testing.T) {
+	if _, err := congomap.NewSyncMutexMap(congomap.ShardCount(4)); err != (congomap.ErrUnsupportedOption{}) {
+		t.Errorf("Actual: %#v; Expected: %#v", err, congomap.ErrUnsupportedOption{})
+	}
+	if _, err := congomap.NewTwoLevelMap(congomap.ShardCount(0)); err != (congomap.ErrInvalidCount{Option: "ShardCount", Count: 0}) {
+		t.Errorf("Actual: %#v; Expected: %#v", err, congomap.ErrInvalidCount{Option: "ShardCount", Count: 0})
+	}
+
+	cgm, err := congomap.NewTwoLevelMap(congomap.ShardCount(8), congomap.Lookup(succeedingLookup), congomap.ManualMaintenance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	if d, err := congomap.Describe(cgm); err != nil || d.String() != "twoLevel{ShardCount: 8}" {
+		t.Errorf("Actual: %v, %v; Expected: %v, %v", d, err, "twoLevel{ShardCount: 8}", nil)
+	}
+
+	const count = 1000
+	var wg sync.WaitGroup
+	wg.Add(count)
+	for i := 0; i < count; i++ {
+		go func(key string) {
+			defer wg.Done()
+			_, _ = cgm.LoadStore(key)
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+	if keys := cgm.Keys(); len(keys) != count {
+		t.Errorf("Actual: %#v; Expected: %#v", len(keys), count)
+	}
+
+	cgm.Store("0", &congomap.ExpiringValue{Value: 0, Expiry: time.Now().Add(-time.Minute)})
+	cgm.Delete("1")
+	cgm.GC()
+	if r, err := congomap.GCStats(cgm); err != nil || r.Evicted != 1 || r.Remaining != count-2 {
+		t.Errorf("Actual: %d, %d, %v; Expected: %d, %d, %v", r.Evicted, r.Remaining, err, 1, count-2, nil)
+	}
+	if n := cgm.Prune(10); n != 10 {
+		t.Errorf("Actual: %#v; Expected: %#v", n, 10)
+	}
+	if keys := cgm.Keys(); len(keys) != count-12 {
+		t.Errorf("Actual: %#v; Expected: %#v", len(keys), count-12)
+	}
+	cgm.Clear()
+	if keys := cgm.Keys(); len(keys) != 0 {
+		t.Errorf("Actual: %#v; Expected: %#v", keys, []string{})
+	}
+}
+
+// WriteBuffer
+
+func TestWriteBuffer(t *testing.T) {
+	if _, err := congomap.NewSyncMutexMap(congomap.WriteBuffer(4, time.Second)); err != (congomap.ErrUnsupportedOption{}) {
+		t.Errorf("Actual: %#v; Expected: %#v", err, congomap.ErrUnsupportedOption{})
+	}
+	if _, err := congomap.NewSyncAtomicMap(congomap.WriteBuffer(0, time.Second)); err != (congomap.ErrInvalidCount{Option: "WriteBuffer", Count: 0}) {
+		t.Errorf("Actual: %#v; Expected: %#v", err, congomap.ErrInvalidCount{Option: "WriteBuffer", Count: 0})
+	}
+	if _, err := congomap.NewSyncAtomicMap(congomap.WriteBuffer(4, 0)); err != congomap.ErrInvalidDuration(0) {
+		t.Errorf("Actual: %#v; Expected: %#v", err, congomap.ErrInvalidDuration(0))
+	}
+
+	cgm, err := congomap.NewSyncAtomicMap(congomap.WriteBuffer(3, time.Hour), congomap.Lookup(failingLookup))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	if d, err := congomap.Describe(cgm); err != nil || d.String() != "syncAtomic{GCInterval: 15m0s, WriteBuffer: 3, WriteBufferInterval: 1h0m0s}" {
+		t.Errorf("Actual: %v, %v; Expected: %v, %v", d, err, "syncAtomic{GCInterval: 15m0s, WriteBuffer: 3, WriteBufferInterval: 1h0m0s}", nil)
+	}
+
+	cgm.Store("a", 1)
+	cgm.Store("b", 2)
+	if _, ok := cgm.Load("a"); ok {
+		t.Errorf("Actual: %#v; Expected: %#v", ok, false) // not yet merged
+	}
+	if value, err := cgm.LoadStore("a"); err != nil || value != 1 {
+		t.Errorf("Actual: %#v, %v; Expected: %#v, %v", value, err, 1, nil)
+	}
+
+	cgm.Store("c", 3)
+	cgm.Store("d", 4)
+	cgm.Store("e", 5) // fills the buffer
+	if value, ok := cgm.Load("e"); !ok || value != 5 {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, ok, 5, true)
+	}
+
+	cgm.Store("f", 6)
+	cgm.Delete("a") // merges before writing
+	if value, ok := cgm.Load("f"); !ok || value != 6 {
+		t.Errorf("Actual: %#v, %#v; Expected: %#v, %#v", value, ok, 6, true)
+	}
+
+	// the buffer is merged once the interval elapses
+	cgm2, err := congomap.NewSyncAtomicMap(congomap.WriteBuffer(100, 10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm2.Close() }()
+	cgm2.Store("k", 13)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if value, ok := cgm2.Load("k"); ok {
+			if value != 13 {
+				t.Errorf("Actual: %#v; Expected: %#v", value, 13)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Actual: %#v; Expected: %#v", "not merged", 13)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWriteBufferReapsReplacedValues(t *testing.T) {
+	var lock sync.Mutex
+	var reaped []interface{}
+	cgm, err := congomap.NewSyncAtomicMap(congomap.WriteBuffer(10, time.Hour), congomap.Reaper(func(value interface{}) {
+		lock.Lock()
+		reaped = append(reaped, value)
+		lock.Unlock()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cgm.Store("a", 1)
+	cgm.Store("a", 2)
+	cgm.Store("a", 3) // each replaces the value buffered before it
+	if value, err := cgm.LoadStore("a"); err != nil || value != 3 {
+		t.Errorf("Actual: %#v, %v; Expected: %#v, %v", value, err, 3, nil)
+	}
+	if err := cgm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	sort.Slice(reaped, func(i, j int) bool { return reaped[i].(int) < reaped[j].(int) })
+	if len(reaped) != 3 || reaped[0] != 1 || reaped[1] != 2 || reaped[2] != 3 {
+		t.Errorf("Actual: %#v; Expected: %#v", reaped, []interface{}{1, 2, 3})
+	}
+}
+
+// NilCallbacks
+
+func testNilCallbacks(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	// nil callback functions are the same as specifying none
+	for _, setter := range []congomap.Setter{congomap.Reaper(nil), congomap.FallibleReaper(nil), congomap.BatchReaper(nil)} {
+		cgm, err := newCongomap(congomap.Lookup(nil), setter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loadStoreNilErrNoLookupDefined(t, cgm, which, "miss")
+		cgm.Store("hit", 42)
+		cgm.Store("hit", 13)
+		cgm.Delete("hit")
+		cgm.Store("hit", 42)
+		if err := cgm.Close(); err != nil {
+			t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, nil)
+		}
+	}
+}
+
+func TestNilCallbacksChannelMap(t *testing.T) {
+	testNilCallbacks(t, congomap.NewChannelMap, "channel")
+}
+
+func TestNilCallbacksSyncAtomicMap(t *testing.T) {
+	testNilCallbacks(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestNilCallbacksSyncMutexMap(t *testing.T) {
+	testNilCallbacks(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestNilCallbacksTwoLevelMap(t *testing.T) {
+	testNilCallbacks(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Copier
+
+func testCopier(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	cgm, err := newCongomap(
+		congomap.Copier(func(value interface{}) interface{} {
+			return append([]int(nil), value.([]int)...)
+		}),
+		congomap.Lookup(func(_ string) (interface{}, error) { return []int{1, 2}, nil }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("stored", []int{3, 4})
+	value, ok := cgm.Load("stored")
+	if !ok {
+		t.Fatalf("Which: %s; Actual: %#v; Expected: %#v", which, ok, true)
+	}
+	value.([]int)[0] = 13
+	if value, _ = cgm.Load("stored"); !reflect.DeepEqual(value, []int{3, 4}) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, value, []int{3, 4})
+	}
+
+	for i := 0; i < 2; i++ { // first looked up, then loaded
+		if value, err = cgm.LoadStore("looked"); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(value, []int{1, 2}) {
+			t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, value, []int{1, 2})
+		}
+		value.([]int)[0] = 13
+	}
+}
+
+func TestCopierChannelMap(t *testing.T) {
+	testCopier(t, congomap.NewChannelMap, "channel")
+}
+
+func TestCopierSyncAtomicMap(t *testing.T) {
+	testCopier(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestCopierSyncMutexMap(t *testing.T) {
+	testCopier(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestCopierTwoLevelMap(t *testing.T) {
+	testCopier(t, congomap.NewTwoLevelMap, "twoLevel")
+}
+
+// Diff
+
+func testDiff(t *testing.T, newCongomap func(...congomap.Setter) (congomap.Congomap, error), which string) {
+	from, err := congomap.NewSyncMutexMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = from.Close() }()
+	to, err := newCongomap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = to.Close() }()
+
+	for _, cgm := range []congomap.Congomap{from, to} {
+		cgm.Store("same", []int{1})
+		cgm.Store("changed", []int{2})
+	}
+	if !congomap.Equal(from, to, nil) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, false, true)
+	}
+
+	from.Store("removed", 3)
+	to.Store("added", 4)
+	to.Store("changed", []int{5})
+	to.Store("expired", &congomap.ExpiringValue{Value: 6, Expiry: time.Now().Add(-time.Second)})
+
+	actual := congomap.Diff(from, to, nil)
+	expected := congomap.Difference{Added: []string{"added"}, Removed: []string{"removed"}, Changed: []string{"changed"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, expected)
+	}
+
+	lengths := func(a, b interface{}) bool { return len(a.([]int)) == len(b.([]int)) }
+	actual = congomap.Diff(from, to, lengths)
+	expected.Changed = nil
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, actual, expected)
+	}
+}
+
+func TestDiffChannelMap(t *testing.T) {
+	testDiff(t, congomap.NewChannelMap, "channel")
+}
+
+func TestDiffSyncAtomicMap(t *testing.T) {
+	testDiff(t, congomap.NewSyncAtomicMap, "syncAtomic")
+}
+
+func TestDiffSyncMutexMap(t *testing.T) {
+	testDiff(t, congomap.NewSyncMutexMap, "syncMutex")
+}
+
+func TestDiffTwoLevelMap(t *testing.T) {
+	testDiff(t, congomap.NewTwoLevelMap, "twoLevel")
+}
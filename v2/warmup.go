@@ -0,0 +1,70 @@
+package congomap
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrWarmupFailed is returned by Warmup when LoadStore failed for any of the keys, and lists every
+// error returned, in the order of the keys.
+type ErrWarmupFailed []error
+
+func (e ErrWarmupFailed) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("congomap: warmup failed for %d keys: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Warmup populates the Congomap before traffic arrives by invoking its LoadStore method for each of
+// the keys, at most concurrency at a time, so the lookup callback function is invoked for every key
+// not already held. It waits for every key to be loaded, then returns ErrWarmupFailed listing the
+// errors for the keys that could not be, or nil when every key was loaded.
+//
+//	if err := congomap.Warmup(cgm, popularKeys, 16); err != nil {
+//	    log.Print(err) // the Congomap is still usable; failed keys are looked up on demand
+//	}
+func Warmup(cgm Congomap, keys []string, concurrency int) error {
+	if concurrency <= 0 {
+		return ErrInvalidCount{Option: "concurrency", Count: concurrency}
+	}
+
+	errs := make([]error, len(keys))
+	concurrently(len(keys), concurrency, func(i int) {
+		_, errs[i] = cgm.LoadStore(keys[i])
+	})
+
+	var failed ErrWarmupFailed
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// concurrently invokes fn with each index from 0 to n, exclusive, from at most concurrency go
+// routines at a time, and returns once every invocation has returned.
+func concurrently(n, concurrency int, fn func(int)) {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
@@ -0,0 +1,105 @@
+package congomap
+
+import (
+	"sync"
+	"time"
+)
+
+// WriteBuffer is used to specify that a SyncAtomicMap accumulates the values written by Store in a
+// buffer, and merges them into its data store together, once the specified number of keys are
+// buffered, or once the specified interval elapses, whichever is sooner, so a burst of writes
+// replaces the data store once rather than once per write. Reads remain lock free, but Load and the
+// other methods that only read the data store do not observe a stored value until it is merged.
+// Every other method that writes the data store, and LoadStore before invoking the lookup callback
+// function, merges the buffer first, as do GC and Close. When NoGoroutine was specified, nothing
+// observes the interval. It returns ErrUnsupportedOption for any other Congomap.
+func WriteBuffer(writes int, interval time.Duration) Setter {
+	return func(cgm Congomap) error {
+		sa, ok := cgm.(*syncAtomicMap)
+		if !ok {
+			return ErrUnsupportedOption{}
+		}
+		if writes <= 0 {
+			return ErrInvalidCount{Option: "WriteBuffer", Count: writes}
+		}
+		if interval <= 0 {
+			return ErrInvalidDuration(interval)
+		}
+		sa.buffer.limit = writes
+		sa.buffer.every = interval
+		return nil
+	}
+}
+
+// writeBuffer holds the values written by Store to a SyncAtomicMap until they are merged into its
+// data store, when WriteBuffer was specified.
+type writeBuffer struct {
+	limit int           // keys buffered before merging
+	every time.Duration // interval between merges
+
+	lock    sync.Mutex
+	pending map[string]*entry
+}
+
+// buffered returns true when WriteBuffer was specified.
+func (b *writeBuffer) buffered() bool {
+	return b.limit > 0
+}
+
+// add buffers the value of key, and returns the buffered value it displaces, if any, and true once
+// the buffer ought to be merged.
+func (b *writeBuffer) add(key string, ev *entry) (*entry, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.pending == nil {
+		b.pending = make(map[string]*entry, b.limit)
+	}
+	prev := b.pending[key]
+	b.pending[key] = ev
+	return prev, len(b.pending) >= b.limit
+}
+
+// take returns the buffered values, leaving the buffer empty.
+func (b *writeBuffer) take() map[string]*entry {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	pending := b.pending
+	b.pending = nil
+	return pending
+}
+
+// flush merges the buffered values into the data store.
+func (cgm *syncAtomicMap) flush() {
+	if !cgm.buffer.buffered() {
+		return
+	}
+	cgm.dbLock.Lock()
+	cgm.flushLocked()
+	cgm.dbLock.Unlock()
+}
+
+// flushLocked merges the buffered values into the data store, reaping the values they replace or
+// evict, and returns the resulting version of the data store. The caller must hold the writer lock.
+func (cgm *syncAtomicMap) flushLocked() *hamt {
+	h := cgm.data()
+	if !cgm.buffer.buffered() {
+		return h
+	}
+	pending := cgm.buffer.take()
+	if len(pending) == 0 {
+		return h
+	}
+	var replaced, evicted []Pair
+	for key, ev := range pending {
+		if prev, ok := h.get(key); ok && cgm.reaping() {
+			replaced = append(replaced, Pair{key, prev.Value})
+		}
+		var pairs []Pair
+		h, pairs = cgm.evictFrom(cgm.with(h, key, ev), key)
+		evicted = append(evicted, pairs...)
+	}
+	cgm.db.Store(h)
+	cgm.reapAll(replaced, ReasonReplaced)
+	cgm.reapAll(evicted, ReasonEvicted)
+	return h
+}
@@ -0,0 +1,194 @@
+package congomap
+
+import "time"
+
+type channelMap[K comparable, V any] struct {
+	db    map[K]*ExpiringValue[V]
+	queue chan func()
+
+	halt chan struct{}
+	done chan struct{} // closed when run returns
+
+	config[K, V]
+}
+
+// NewChannelMap returns a map that uses channels to serialize access.
+//
+// Note that it is important to call the Close method on the returned data structure when it's no
+// longer needed to free CPU and channel resources back to the runtime.
+//
+//	cgm, err := congomap.NewChannelMap[string, int]()
+//	if err != nil {
+//	    panic(err)
+//	}
+//	defer func() { _ = cgm.Close() }()
+func NewChannelMap[K comparable, V any](setters ...Setter[K, V]) (Congomap[K, V], error) {
+	c, err := newConfig[K, V](setters)
+	if err != nil {
+		return nil, err
+	}
+	cgm := &channelMap[K, V]{
+		db:     make(map[K]*ExpiringValue[V]),
+		queue:  make(chan func()),
+		halt:   make(chan struct{}),
+		done:   make(chan struct{}),
+		config: c,
+	}
+	go cgm.run()
+	return cgm, nil
+}
+
+// serialize invokes fn from the run goroutine, and returns after fn returns.
+func (cgm *channelMap[K, V]) serialize(fn func()) {
+	done := make(chan struct{})
+	cgm.queue <- func() {
+		fn()
+		close(done)
+	}
+	<-done
+}
+
+func (cgm *channelMap[K, V]) Delete(key K) {
+	var ev *ExpiringValue[V]
+	cgm.serialize(func() {
+		ev = cgm.db[key]
+		delete(cgm.db, key)
+	})
+	if ev != nil {
+		cgm.reap(ev.Value)
+	}
+}
+
+func (cgm *channelMap[K, V]) GC() {
+	var reaped []V
+	cgm.serialize(func() { reaped = cgm.gc() })
+	cgm.reapAll(reaped)
+}
+
+// gc removes expired values from the data store and returns them to be reaped. It must be invoked
+// by the run goroutine.
+func (cgm *channelMap[K, V]) gc() []V {
+	var reaped []V
+	now := time.Now()
+	for key, ev := range cgm.db {
+		if !ev.live(now) {
+			delete(cgm.db, key)
+			reaped = append(reaped, ev.Value)
+		}
+	}
+	return reaped
+}
+
+func (cgm *channelMap[K, V]) Keys() []K {
+	var keys []K
+	cgm.serialize(func() {
+		now := time.Now()
+		keys = make([]K, 0, len(cgm.db))
+		for key, ev := range cgm.db {
+			if ev.live(now) {
+				keys = append(keys, key)
+			}
+		}
+	})
+	return keys
+}
+
+func (cgm *channelMap[K, V]) Load(key K) (V, bool) {
+	var ev *ExpiringValue[V]
+	cgm.serialize(func() { ev = cgm.db[key] })
+	if ev != nil && ev.live(time.Now()) {
+		return ev.Value, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (cgm *channelMap[K, V]) LoadStore(key K) (V, error) {
+	var value V
+	var stale *ExpiringValue[V]
+	var err error
+	cgm.serialize(func() {
+		ev, ok := cgm.db[key]
+		if ok && ev.live(time.Now()) {
+			value = ev.Value
+			return
+		}
+		// key not there or expired
+		if value, err = cgm.lookup(key); err != nil {
+			return
+		}
+		stale = ev
+		cgm.db[key] = cgm.newValue(value)
+	})
+	if stale != nil {
+		cgm.reap(stale.Value)
+	}
+	return value, err
+}
+
+func (cgm *channelMap[K, V]) Pairs() <-chan Pair[K, V] {
+	var live []Pair[K, V]
+	cgm.serialize(func() {
+		now := time.Now()
+		live = make([]Pair[K, V], 0, len(cgm.db))
+		for key, ev := range cgm.db {
+			if ev.live(now) {
+				live = append(live, Pair[K, V]{key, ev.Value})
+			}
+		}
+	})
+	return pairs(live)
+}
+
+func (cgm *channelMap[K, V]) Store(key K, value V) {
+	cgm.store(key, cgm.newValue(value))
+}
+
+func (cgm *channelMap[K, V]) StoreExpiring(key K, ev ExpiringValue[V]) {
+	cgm.store(key, &ev)
+}
+
+func (cgm *channelMap[K, V]) store(key K, ev *ExpiringValue[V]) {
+	var replaced *ExpiringValue[V]
+	cgm.serialize(func() {
+		replaced = cgm.db[key]
+		cgm.db[key] = ev
+	})
+	if replaced != nil {
+		cgm.reap(replaced.Value)
+	}
+}
+
+func (cgm *channelMap[K, V]) Close() error {
+	close(cgm.halt)
+	<-cgm.done
+	return nil
+}
+
+func (cgm *channelMap[K, V]) run() {
+	defer close(cgm.done)
+
+	ticker := time.NewTicker(cgm.gcInterval())
+	defer ticker.Stop()
+
+	active := true
+	for active {
+		select {
+		case fn := <-cgm.queue:
+			fn()
+		case <-ticker.C:
+			go cgm.reapAll(cgm.gc()) // GC would deadlock sending to the queue this goroutine reads
+		case <-cgm.halt:
+			active = false
+		}
+	}
+
+	if cgm.reaper != nil {
+		reaped := make([]V, 0, len(cgm.db))
+		for key, ev := range cgm.db {
+			delete(cgm.db, key)
+			reaped = append(reaped, ev.Value)
+		}
+		cgm.reapAll(reaped)
+	}
+}
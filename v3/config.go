@@ -0,0 +1,124 @@
+package congomap
+
+import "time"
+
+// Setter declares the type of function used when creating a Congomap to change the instance's
+// behavior. Its key and value types must match those of the Congomap, so a callback function of the
+// wrong type is rejected by the compiler.
+type Setter[K comparable, V any] func(*config[K, V]) error
+
+// Lookup is used to specify what function is to be called to retrieve the value for a key when the
+// LoadStore() method is invoked for a key not found in a Congomap. Its key and value types are
+// inferred from the function.
+//
+// Some maps are used as a lazy lookup device. When a key is not already found in the map, the
+// callback function is invoked with the specified key. If the callback function returns an error,
+// then the zero value and the error is returned from LoadStore. If the callback function returns no
+// error, then the returned value is stored in the Congomap and returned from LoadStore.
+func Lookup[K comparable, V any](lookup func(K) (V, error)) Setter[K, V] {
+	return func(c *config[K, V]) error {
+		c.lookup = nil
+		if lookup != nil {
+			c.lookup = func(key K) (V, error) {
+				value, err := lookup(key)
+				if err != nil {
+					var zero V
+					return zero, err // the value accompanying an error is never stored nor returned
+				}
+				return value, nil
+			}
+		}
+		return nil
+	}
+}
+
+// Reaper is used to specify what function is to be called when garbage collecting item from the
+// Congomap. Its value type is inferred from the function, while its key type must be given, as in
+// Reaper[string](func(value int) {}).
+func Reaper[K comparable, V any](reaper func(V)) Setter[K, V] {
+	return func(c *config[K, V]) error {
+		c.reaper = reaper
+		return nil
+	}
+}
+
+// TTL is used to specify the time-to-live for a key-value pair in the Congomap. Pairs that have
+// expired are not immediately Garbage Collected until replaced by a new value, or the GC() method
+// is invoked either manually or periodically. Its key and value types must be given, as in
+// TTL[string, int](time.Minute).
+func TTL[K comparable, V any](duration time.Duration) Setter[K, V] {
+	return func(c *config[K, V]) error {
+		if duration <= 0 {
+			return ErrInvalidDuration(duration)
+		}
+		c.ttl = duration
+		return nil
+	}
+}
+
+// config is embedded by every Congomap type, and holds the options specified by its Setters.
+type config[K comparable, V any] struct {
+	ttl    time.Duration
+	lookup func(K) (V, error)
+	reaper func(V) // nil means values are not reaped
+}
+
+// newConfig applies the setters, and returns the resulting config, or an error when a setter failed.
+func newConfig[K comparable, V any](setters []Setter[K, V]) (config[K, V], error) {
+	var c config[K, V]
+	for _, setter := range setters {
+		if err := setter(&c); err != nil {
+			return config[K, V]{}, err
+		}
+	}
+	if c.lookup == nil {
+		c.lookup = func(_ K) (V, error) {
+			var zero V
+			return zero, ErrNoLookupDefined{}
+		}
+	}
+	return c, nil
+}
+
+// newValue returns the ExpiringValue to store for a value written without an expiry.
+func (c *config[K, V]) newValue(value V) *ExpiringValue[V] {
+	ev := &ExpiringValue[V]{Value: value}
+	if c.ttl > 0 {
+		ev.Expiry = time.Now().Add(c.ttl)
+	}
+	return ev
+}
+
+// gcInterval returns how often GC is invoked.
+func (c *config[K, V]) gcInterval() time.Duration {
+	if c.ttl > 0 && c.ttl <= time.Second {
+		return time.Minute
+	}
+	return 15 * time.Minute
+}
+
+// reap sends the value to the reaper, if one was specified.
+func (c *config[K, V]) reap(value V) {
+	if c.reaper != nil {
+		c.reaper(value)
+	}
+}
+
+// reapAll sends each of the values to the reaper, if one was specified.
+func (c *config[K, V]) reapAll(values []V) {
+	for _, value := range values {
+		c.reap(value)
+	}
+}
+
+// pairs returns a channel through which the specified pairs are sent.
+func pairs[K comparable, V any](live []Pair[K, V]) <-chan Pair[K, V] {
+	ch := make(chan Pair[K, V])
+	go func() {
+		for _, pair := range live {
+			ch <- pair
+		}
+		close(ch)
+	}()
+	return ch
+}
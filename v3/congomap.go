@@ -0,0 +1,76 @@
+package congomap
+
+import "time"
+
+// Congomap is the interface implemented by an object that acts as a concurrent go map to store data
+// in a key-value data store, with keys of type K and values of type V.
+type Congomap[K comparable, V any] interface {
+	// Close releases resources used by the Congomap, after reaping its remaining values.
+	Close() error
+
+	// Delete removes a key value pair from a Congomap.
+	Delete(K)
+
+	// GC forces elimination of keys in Congomap with values that have expired.
+	GC()
+
+	// Keys returns the keys of the values stored in the map that have not expired.
+	Keys() []K
+
+	// Load gets the value associated with the given key. When the key is in the map, it returns
+	// the value associated with the key and true. Otherwise it returns the zero value and false.
+	Load(K) (V, bool)
+
+	// LoadStore gets the value associated with the given key if it's in the map. If it's not in
+	// the map, it calls the lookup function, and sets the value in the map to that returned by
+	// the lookup function.
+	LoadStore(K) (V, error)
+
+	// Pairs returns a channel through which the key value pairs that have not expired are read.
+	// The pairs are gathered before the first one is sent, so the Congomap is not locked while
+	// the channel is read.
+	Pairs() <-chan Pair[K, V]
+
+	// Store sets the value associated with the given key, which expires after the time-to-live
+	// specified by TTL, if any.
+	Store(K, V)
+
+	// StoreExpiring sets the value associated with the given key, which expires at the time
+	// specified by the ExpiringValue, or never when that is the zero time.
+	StoreExpiring(K, ExpiringValue[V])
+}
+
+// Pair objects represent a single key-value pair and are passed through the channel returned by the
+// Pairs() method while enumerating through the keys and values stored in a Congomap.
+type Pair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// ExpiringValue couples a value with an expiry time for the value. The zero value for time.Time
+// implies no expiry for this value.
+type ExpiringValue[V any] struct {
+	Value  V
+	Expiry time.Time
+}
+
+// live returns true when the value has not expired as of the specified time.
+func (ev *ExpiringValue[V]) live(now time.Time) bool {
+	return ev.Expiry.IsZero() || ev.Expiry.After(now)
+}
+
+// ErrNoLookupDefined is returned by LoadStore when the Congomap was created without a Lookup
+// callback function.
+type ErrNoLookupDefined struct{}
+
+func (e ErrNoLookupDefined) Error() string {
+	return "congomap: no lookup callback function set"
+}
+
+// ErrInvalidDuration is returned when a Setter is given a duration that is not positive.
+type ErrInvalidDuration time.Duration
+
+func (e ErrInvalidDuration) Error() string {
+	return "congomap: duration must be greater than 0: " + time.Duration(e).String()
+}
+
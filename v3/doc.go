@@ -0,0 +1,53 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2015 Karrick McDermott
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+/*
+Package congomap provides a concurrency-safe Go Map, whose keys and values have the types specified
+by its type parameters, so values need no type assertions after they are loaded.
+
+This library exposes the Congomap interface, and a few concrete types that adhere to that
+interface, each with their own performance characteristics.
+
+WARNING: To prevent resource leakage, always call the Congomap's Close method after it is no longer
+needed.
+
+	cgm, err := congomap.NewTwoLevelMap[string, int](
+	    congomap.TTL[string, int](time.Minute),
+	    congomap.Lookup(func(key string) (int, error) {
+	        return len(key), nil
+	    }),
+	)
+	if err != nil {
+	    panic(err)
+	}
+	defer func() { _ = cgm.Close() }()
+
+	cgm.Store("someKeyString", 42)
+	value, ok := cgm.Load("someKeyString") // value is an int
+
+Each Setter has the key and value types of the Congomap it configures, so a Lookup or Reaper
+callback function whose types do not match those of the Congomap does not compile.
+
+Compared to version 2, values are stored with an expiry by StoreExpiring rather than by passing an
+*ExpiringValue to Store, and Pairs sends Pair values rather than pointers to them.
+*/
+package congomap
@@ -0,0 +1,3 @@
+module github.com/karrick/congomap/v3
+
+go 1.21
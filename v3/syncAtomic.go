@@ -0,0 +1,207 @@
+package congomap
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type syncAtomicMap[K comparable, V any] struct {
+	db     atomic.Pointer[map[K]*ExpiringValue[V]]
+	dbLock sync.Mutex // synchronizes writers, each of which stores a modified copy of the data store
+
+	halt chan struct{}
+	done chan struct{} // closed when run returns
+
+	config[K, V]
+}
+
+// NewSyncAtomicMap returns a map that uses sync/atomic.Pointer to serialize access. Readers never
+// block, while each writer copies the data store, so it suits data that is read far more often than
+// it is written.
+//
+// Note that it is important to call the Close method on the returned data structure when it's no
+// longer needed to free CPU and channel resources back to the runtime.
+//
+//	cgm, err := congomap.NewSyncAtomicMap[string, int]()
+//	if err != nil {
+//	    panic(err)
+//	}
+//	defer func() { _ = cgm.Close() }()
+func NewSyncAtomicMap[K comparable, V any](setters ...Setter[K, V]) (Congomap[K, V], error) {
+	c, err := newConfig[K, V](setters)
+	if err != nil {
+		return nil, err
+	}
+	cgm := &syncAtomicMap[K, V]{
+		halt:   make(chan struct{}),
+		done:   make(chan struct{}),
+		config: c,
+	}
+	m := make(map[K]*ExpiringValue[V])
+	cgm.db.Store(&m)
+	go cgm.run()
+	return cgm, nil
+}
+
+// load returns the current data store, which must not be modified.
+func (cgm *syncAtomicMap[K, V]) load() map[K]*ExpiringValue[V] {
+	return *cgm.db.Load()
+}
+
+// copyLiveData returns a copy of the current data store without its expired values, along with
+// those expired values, which ought to be reaped. It must be invoked while holding dbLock.
+func (cgm *syncAtomicMap[K, V]) copyLiveData() (map[K]*ExpiringValue[V], []V) {
+	now := time.Now()
+	m1 := cgm.load()
+	m2 := make(map[K]*ExpiringValue[V], len(m1))
+	var reaped []V
+	for key, ev := range m1 {
+		if ev.live(now) {
+			m2[key] = ev
+		} else {
+			reaped = append(reaped, ev.Value)
+		}
+	}
+	return m2, reaped
+}
+
+func (cgm *syncAtomicMap[K, V]) Delete(key K) {
+	cgm.dbLock.Lock()
+	m, reaped := cgm.copyLiveData()
+	if ev, ok := m[key]; ok {
+		reaped = append(reaped, ev.Value)
+		delete(m, key)
+	}
+	cgm.db.Store(&m)
+	cgm.dbLock.Unlock()
+
+	cgm.reapAll(reaped)
+}
+
+func (cgm *syncAtomicMap[K, V]) GC() {
+	cgm.dbLock.Lock()
+	m, reaped := cgm.copyLiveData()
+	cgm.db.Store(&m)
+	cgm.dbLock.Unlock()
+
+	cgm.reapAll(reaped)
+}
+
+func (cgm *syncAtomicMap[K, V]) Keys() []K {
+	now := time.Now()
+	m := cgm.load()
+	keys := make([]K, 0, len(m))
+	for key, ev := range m {
+		if ev.live(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (cgm *syncAtomicMap[K, V]) Load(key K) (V, bool) {
+	ev, ok := cgm.load()[key]
+	if ok && ev.live(time.Now()) {
+		return ev.Value, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (cgm *syncAtomicMap[K, V]) LoadStore(key K) (V, error) {
+	if ev, ok := cgm.load()[key]; ok && ev.live(time.Now()) {
+		return ev.Value, nil
+	}
+
+	cgm.dbLock.Lock() // synchronize with other potential writers
+
+	// while waiting for lock, value might have been stored by another goroutine
+	if ev, ok := cgm.load()[key]; ok && ev.live(time.Now()) {
+		cgm.dbLock.Unlock()
+		return ev.Value, nil
+	}
+
+	value, err := cgm.lookup(key)
+	if err != nil {
+		cgm.dbLock.Unlock()
+		return value, err
+	}
+	m, reaped := cgm.copyLiveData() // reaps the expired value being replaced
+	m[key] = cgm.newValue(value)
+	cgm.db.Store(&m)
+	cgm.dbLock.Unlock()
+
+	cgm.reapAll(reaped)
+	return value, nil
+}
+
+func (cgm *syncAtomicMap[K, V]) Pairs() <-chan Pair[K, V] {
+	now := time.Now()
+	m := cgm.load()
+	live := make([]Pair[K, V], 0, len(m))
+	for key, ev := range m {
+		if ev.live(now) {
+			live = append(live, Pair[K, V]{key, ev.Value})
+		}
+	}
+	return pairs(live)
+}
+
+func (cgm *syncAtomicMap[K, V]) Store(key K, value V) {
+	cgm.store(key, cgm.newValue(value))
+}
+
+func (cgm *syncAtomicMap[K, V]) StoreExpiring(key K, ev ExpiringValue[V]) {
+	cgm.store(key, &ev)
+}
+
+func (cgm *syncAtomicMap[K, V]) store(key K, ev *ExpiringValue[V]) {
+	cgm.dbLock.Lock()
+	m, reaped := cgm.copyLiveData()
+	if replaced, ok := m[key]; ok {
+		reaped = append(reaped, replaced.Value)
+	}
+	m[key] = ev
+	cgm.db.Store(&m)
+	cgm.dbLock.Unlock()
+
+	cgm.reapAll(reaped)
+}
+
+func (cgm *syncAtomicMap[K, V]) Close() error {
+	close(cgm.halt)
+	<-cgm.done
+	return nil
+}
+
+func (cgm *syncAtomicMap[K, V]) run() {
+	defer close(cgm.done)
+
+	ticker := time.NewTicker(cgm.gcInterval())
+	defer ticker.Stop()
+
+	active := true
+	for active {
+		select {
+		case <-ticker.C:
+			cgm.GC()
+		case <-cgm.halt:
+			active = false
+		}
+	}
+
+	if cgm.reaper != nil {
+		cgm.dbLock.Lock()
+		m := cgm.load()
+		empty := make(map[K]*ExpiringValue[V])
+		cgm.db.Store(&empty)
+		cgm.dbLock.Unlock()
+
+		reaped := make([]V, 0, len(m))
+		for _, ev := range m {
+			reaped = append(reaped, ev.Value)
+		}
+		cgm.reapAll(reaped)
+	}
+}
@@ -0,0 +1,182 @@
+package congomap
+
+import (
+	"sync"
+	"time"
+)
+
+type syncMutexMap[K comparable, V any] struct {
+	db     map[K]*ExpiringValue[V]
+	dbLock sync.RWMutex
+
+	halt chan struct{}
+	done chan struct{} // closed when run returns
+
+	config[K, V]
+}
+
+// NewSyncMutexMap returns a map that uses sync.RWMutex to serialize access to the data store.
+//
+// Note that it is important to call the Close method on the returned data structure when it's no
+// longer needed to free CPU and channel resources back to the runtime.
+//
+//	cgm, err := congomap.NewSyncMutexMap[string, int]()
+//	if err != nil {
+//	    panic(err)
+//	}
+//	defer func() { _ = cgm.Close() }()
+func NewSyncMutexMap[K comparable, V any](setters ...Setter[K, V]) (Congomap[K, V], error) {
+	c, err := newConfig[K, V](setters)
+	if err != nil {
+		return nil, err
+	}
+	cgm := &syncMutexMap[K, V]{
+		db:     make(map[K]*ExpiringValue[V]),
+		halt:   make(chan struct{}),
+		done:   make(chan struct{}),
+		config: c,
+	}
+	go cgm.run()
+	return cgm, nil
+}
+
+func (cgm *syncMutexMap[K, V]) Delete(key K) {
+	cgm.dbLock.Lock()
+	ev, ok := cgm.db[key]
+	delete(cgm.db, key)
+	cgm.dbLock.Unlock()
+
+	if ok {
+		cgm.reap(ev.Value)
+	}
+}
+
+func (cgm *syncMutexMap[K, V]) GC() {
+	var reaped []V
+
+	cgm.dbLock.Lock()
+	now := time.Now()
+	for key, ev := range cgm.db {
+		if !ev.live(now) {
+			delete(cgm.db, key)
+			reaped = append(reaped, ev.Value)
+		}
+	}
+	cgm.dbLock.Unlock()
+
+	cgm.reapAll(reaped)
+}
+
+func (cgm *syncMutexMap[K, V]) Keys() []K {
+	cgm.dbLock.RLock()
+	defer cgm.dbLock.RUnlock()
+	now := time.Now()
+	keys := make([]K, 0, len(cgm.db))
+	for key, ev := range cgm.db {
+		if ev.live(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (cgm *syncMutexMap[K, V]) Load(key K) (V, bool) {
+	cgm.dbLock.RLock()
+	ev, ok := cgm.db[key]
+	cgm.dbLock.RUnlock()
+
+	if ok && ev.live(time.Now()) {
+		return ev.Value, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (cgm *syncMutexMap[K, V]) LoadStore(key K) (V, error) {
+	cgm.dbLock.Lock()
+
+	ev, ok := cgm.db[key]
+	if ok && ev.live(time.Now()) {
+		cgm.dbLock.Unlock()
+		return ev.Value, nil
+	}
+
+	value, err := cgm.lookup(key)
+	if err != nil {
+		cgm.dbLock.Unlock()
+		return value, err
+	}
+	cgm.db[key] = cgm.newValue(value)
+	cgm.dbLock.Unlock()
+
+	if ok {
+		cgm.reap(ev.Value)
+	}
+	return value, nil
+}
+
+func (cgm *syncMutexMap[K, V]) Pairs() <-chan Pair[K, V] {
+	cgm.dbLock.RLock()
+	now := time.Now()
+	live := make([]Pair[K, V], 0, len(cgm.db))
+	for key, ev := range cgm.db {
+		if ev.live(now) {
+			live = append(live, Pair[K, V]{key, ev.Value})
+		}
+	}
+	cgm.dbLock.RUnlock()
+	return pairs(live)
+}
+
+func (cgm *syncMutexMap[K, V]) Store(key K, value V) {
+	cgm.store(key, cgm.newValue(value))
+}
+
+func (cgm *syncMutexMap[K, V]) StoreExpiring(key K, ev ExpiringValue[V]) {
+	cgm.store(key, &ev)
+}
+
+func (cgm *syncMutexMap[K, V]) store(key K, ev *ExpiringValue[V]) {
+	cgm.dbLock.Lock()
+	replaced, ok := cgm.db[key]
+	cgm.db[key] = ev
+	cgm.dbLock.Unlock()
+
+	if ok {
+		cgm.reap(replaced.Value)
+	}
+}
+
+func (cgm *syncMutexMap[K, V]) Close() error {
+	close(cgm.halt)
+	<-cgm.done
+	return nil
+}
+
+func (cgm *syncMutexMap[K, V]) run() {
+	defer close(cgm.done)
+
+	ticker := time.NewTicker(cgm.gcInterval())
+	defer ticker.Stop()
+
+	active := true
+	for active {
+		select {
+		case <-ticker.C:
+			cgm.GC()
+		case <-cgm.halt:
+			active = false
+		}
+	}
+
+	if cgm.reaper != nil {
+		cgm.dbLock.Lock()
+		reaped := make([]V, 0, len(cgm.db))
+		for key, ev := range cgm.db {
+			delete(cgm.db, key)
+			reaped = append(reaped, ev.Value)
+		}
+		cgm.dbLock.Unlock()
+		cgm.reapAll(reaped)
+	}
+}
@@ -0,0 +1,248 @@
+package congomap
+
+import (
+	"sync"
+	"time"
+)
+
+type twoLevelMap[K comparable, V any] struct {
+	db     map[K]*lockingValue[V]
+	dbLock sync.RWMutex
+
+	halt chan struct{}
+	done chan struct{} // closed when run returns
+
+	config[K, V]
+}
+
+// lockingValue is a pointer to a value and the lock that protects it. All access to the
+// ExpiringValue ought to be protected by use of the lock.
+type lockingValue[V any] struct {
+	l  sync.RWMutex
+	ev *ExpiringValue[V] // nil means not present
+}
+
+// NewTwoLevelMap returns a map that uses two levels of locks to serialize access to a key-value
+// map. The top-level lock guards insertion and removal of keys in the map. The values of those keys
+// are locks that guard each individual datum value for that key.
+//
+// Note that it is important to call the Close method on the returned data structure when it's no
+// longer needed to free CPU and channel resources back to the runtime.
+//
+//	cgm, err := congomap.NewTwoLevelMap[string, int]()
+//	if err != nil {
+//	    panic(err)
+//	}
+//	defer func() { _ = cgm.Close() }()
+func NewTwoLevelMap[K comparable, V any](setters ...Setter[K, V]) (Congomap[K, V], error) {
+	c, err := newConfig[K, V](setters)
+	if err != nil {
+		return nil, err
+	}
+	cgm := &twoLevelMap[K, V]{
+		db:     make(map[K]*lockingValue[V]),
+		halt:   make(chan struct{}),
+		done:   make(chan struct{}),
+		config: c,
+	}
+	go cgm.run()
+	return cgm, nil
+}
+
+// slot returns the lockingValue for key, inserting a placeholder when key is not present.
+func (cgm *twoLevelMap[K, V]) slot(key K) *lockingValue[V] {
+	cgm.dbLock.RLock()
+	lv, ok := cgm.db[key]
+	cgm.dbLock.RUnlock()
+	if ok {
+		return lv
+	}
+
+	cgm.dbLock.Lock()
+	defer cgm.dbLock.Unlock()
+	lv, ok = cgm.db[key]
+	if !ok {
+		lv = &lockingValue[V]{}
+		cgm.db[key] = lv
+	}
+	return lv
+}
+
+// lockingValues returns the keys and lockingValues in the data store.
+func (cgm *twoLevelMap[K, V]) lockingValues() ([]K, []*lockingValue[V]) {
+	cgm.dbLock.RLock()
+	defer cgm.dbLock.RUnlock()
+	keys := make([]K, 0, len(cgm.db))
+	lvs := make([]*lockingValue[V], 0, len(cgm.db))
+	for key, lv := range cgm.db {
+		keys = append(keys, key)
+		lvs = append(lvs, lv)
+	}
+	return keys, lvs
+}
+
+func (cgm *twoLevelMap[K, V]) Delete(key K) {
+	cgm.dbLock.Lock()
+	lv, ok := cgm.db[key]
+	delete(cgm.db, key)
+	cgm.dbLock.Unlock()
+
+	if ok {
+		lv.l.RLock()
+		ev := lv.ev
+		lv.l.RUnlock()
+		if ev != nil { // placeholders have no value to reap
+			cgm.reap(ev.Value)
+		}
+	}
+}
+
+func (cgm *twoLevelMap[K, V]) GC() {
+	var reaped []V
+
+	cgm.dbLock.Lock()
+	now := time.Now()
+	for key, lv := range cgm.db {
+		if !lv.l.TryLock() {
+			continue // in use, such as by a lookup, so neither a placeholder nor expired for long
+		}
+		if lv.ev == nil {
+			delete(cgm.db, key) // placeholder left by a failed lookup
+		} else if !lv.ev.live(now) {
+			delete(cgm.db, key)
+			reaped = append(reaped, lv.ev.Value)
+		}
+		lv.l.Unlock()
+	}
+	cgm.dbLock.Unlock()
+
+	cgm.reapAll(reaped)
+}
+
+func (cgm *twoLevelMap[K, V]) Keys() []K {
+	keys, lvs := cgm.lockingValues()
+	now := time.Now()
+	live := keys[:0]
+	for i, lv := range lvs {
+		lv.l.RLock()
+		if lv.ev != nil && lv.ev.live(now) {
+			live = append(live, keys[i])
+		}
+		lv.l.RUnlock()
+	}
+	return live
+}
+
+func (cgm *twoLevelMap[K, V]) Load(key K) (V, bool) {
+	cgm.dbLock.RLock()
+	lv, ok := cgm.db[key]
+	cgm.dbLock.RUnlock()
+
+	if ok {
+		lv.l.RLock()
+		ev := lv.ev
+		lv.l.RUnlock()
+		if ev != nil && ev.live(time.Now()) {
+			return ev.Value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+func (cgm *twoLevelMap[K, V]) LoadStore(key K) (V, error) {
+	lv := cgm.slot(key)
+
+	lv.l.Lock()
+
+	// while waiting for lock, value might have been filled by another goroutine
+	stale := lv.ev
+	if stale != nil && stale.live(time.Now()) {
+		lv.l.Unlock()
+		return stale.Value, nil
+	}
+
+	value, err := cgm.lookup(key)
+	if err != nil {
+		lv.l.Unlock()
+		return value, err
+	}
+	lv.ev = cgm.newValue(value)
+	lv.l.Unlock()
+
+	if stale != nil {
+		cgm.reap(stale.Value)
+	}
+	return value, nil
+}
+
+func (cgm *twoLevelMap[K, V]) Pairs() <-chan Pair[K, V] {
+	keys, lvs := cgm.lockingValues()
+	now := time.Now()
+	live := make([]Pair[K, V], 0, len(keys))
+	for i, lv := range lvs {
+		lv.l.RLock()
+		if lv.ev != nil && lv.ev.live(now) {
+			live = append(live, Pair[K, V]{keys[i], lv.ev.Value})
+		}
+		lv.l.RUnlock()
+	}
+	return pairs(live)
+}
+
+func (cgm *twoLevelMap[K, V]) Store(key K, value V) {
+	cgm.store(key, cgm.newValue(value))
+}
+
+func (cgm *twoLevelMap[K, V]) StoreExpiring(key K, ev ExpiringValue[V]) {
+	cgm.store(key, &ev)
+}
+
+func (cgm *twoLevelMap[K, V]) store(key K, ev *ExpiringValue[V]) {
+	lv := cgm.slot(key)
+
+	lv.l.Lock()
+	replaced := lv.ev
+	lv.ev = ev
+	lv.l.Unlock()
+
+	if replaced != nil { // placeholders have no value to reap
+		cgm.reap(replaced.Value)
+	}
+}
+
+func (cgm *twoLevelMap[K, V]) Close() error {
+	close(cgm.halt)
+	<-cgm.done
+	return nil
+}
+
+func (cgm *twoLevelMap[K, V]) run() {
+	defer close(cgm.done)
+
+	ticker := time.NewTicker(cgm.gcInterval())
+	defer ticker.Stop()
+
+	active := true
+	for active {
+		select {
+		case <-ticker.C:
+			cgm.GC()
+		case <-cgm.halt:
+			active = false
+		}
+	}
+
+	if cgm.reaper != nil {
+		keys, lvs := cgm.lockingValues()
+		reaped := make([]V, 0, len(keys))
+		for _, lv := range lvs {
+			lv.l.RLock()
+			if lv.ev != nil { // placeholders have no value to reap
+				reaped = append(reaped, lv.ev.Value)
+			}
+			lv.l.RUnlock()
+		}
+		cgm.reapAll(reaped)
+	}
+}
@@ -0,0 +1,196 @@
+package congomap_test
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/karrick/congomap/v3"
+)
+
+type newCongomap func(...congomap.Setter[string, int]) (congomap.Congomap[string, int], error)
+
+var implementations = []struct {
+	which       string
+	newCongomap newCongomap
+}{
+	{"channel", congomap.NewChannelMap[string, int]},
+	{"syncAtomic", congomap.NewSyncAtomicMap[string, int]},
+	{"syncMutex", congomap.NewSyncMutexMap[string, int]},
+	{"twoLevel", congomap.NewTwoLevelMap[string, int]},
+}
+
+// forEach runs the test once for each Congomap implementation.
+func forEach(t *testing.T, test func(*testing.T, newCongomap, string)) {
+	for _, impl := range implementations {
+		impl := impl
+		t.Run(impl.which, func(t *testing.T) { test(t, impl.newCongomap, impl.which) })
+	}
+}
+
+// reaped returns a Reaper Setter that records the values it reaps, and a function that returns them
+// sorted.
+func reaped() (congomap.Setter[string, int], func() []int) {
+	var lock sync.Mutex
+	var values []int
+	return congomap.Reaper[string](func(value int) {
+			lock.Lock()
+			values = append(values, value)
+			lock.Unlock()
+		}), func() []int {
+			lock.Lock()
+			defer lock.Unlock()
+			sorted := append([]int(nil), values...)
+			sort.Ints(sorted)
+			return sorted
+		}
+}
+
+func TestStoreLoadDelete(t *testing.T) {
+	forEach(t, func(t *testing.T, newCongomap newCongomap, which string) {
+		reaper, reapedValues := reaped()
+		cgm, err := newCongomap(reaper)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = cgm.Close() }()
+
+		if value, ok := cgm.Load("missing"); value != 0 || ok {
+			t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, 0, false)
+		}
+		cgm.Store("abc", 1)
+		cgm.Store("abc", 2) // reaps 1
+		if value, ok := cgm.Load("abc"); value != 2 || !ok {
+			t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, 2, true)
+		}
+		cgm.Delete("abc") // reaps 2
+		if _, ok := cgm.Load("abc"); ok {
+			t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, ok, false)
+		}
+		if actual, expected := reapedValues(), []int{1, 2}; !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Which: %s; Actual: %v; Expected: %v", which, actual, expected)
+		}
+	})
+}
+
+func TestLoadStore(t *testing.T) {
+	forEach(t, func(t *testing.T, newCongomap newCongomap, which string) {
+		failure := errors.New("failure")
+		cgm, err := newCongomap(congomap.Lookup(func(key string) (int, error) {
+			if key == "bad" {
+				return 13, failure
+			}
+			return len(key), nil
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = cgm.Close() }()
+
+		if value, err := cgm.LoadStore("four"); value != 4 || err != nil {
+			t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, 4, nil)
+		}
+		if value, ok := cgm.Load("four"); value != 4 || !ok {
+			t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, ok, 4, true)
+		}
+		if value, err := cgm.LoadStore("bad"); value != 0 || err != failure {
+			t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, 0, failure)
+		}
+		if keys := cgm.Keys(); !reflect.DeepEqual(keys, []string{"four"}) {
+			t.Errorf("Which: %s; Actual: %v; Expected: %v", which, keys, []string{"four"})
+		}
+	})
+}
+
+func TestNoLookupDefined(t *testing.T) {
+	forEach(t, func(t *testing.T, newCongomap newCongomap, which string) {
+		cgm, err := newCongomap()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = cgm.Close() }()
+
+		if _, err = cgm.LoadStore("abc"); err != (congomap.ErrNoLookupDefined{}) {
+			t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, congomap.ErrNoLookupDefined{})
+		}
+	})
+}
+
+func TestInvalidDuration(t *testing.T) {
+	forEach(t, func(t *testing.T, newCongomap newCongomap, which string) {
+		if _, err := newCongomap(congomap.TTL[string, int](0)); err != congomap.ErrInvalidDuration(0) {
+			t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, err, congomap.ErrInvalidDuration(0))
+		}
+	})
+}
+
+func TestExpiry(t *testing.T) {
+	forEach(t, func(t *testing.T, newCongomap newCongomap, which string) {
+		reaper, reapedValues := reaped()
+		cgm, err := newCongomap(reaper, congomap.TTL[string, int](time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		now := time.Now()
+		cgm.Store("ttl", 1)
+		cgm.StoreExpiring("expired", congomap.ExpiringValue[int]{Value: 2, Expiry: now.Add(-time.Second)})
+		cgm.StoreExpiring("forever", congomap.ExpiringValue[int]{Value: 3})
+
+		if _, ok := cgm.Load("expired"); ok {
+			t.Errorf("Which: %s; Actual: %#v; Expected: %#v", which, ok, false)
+		}
+		var actual []congomap.Pair[string, int]
+		for pair := range cgm.Pairs() {
+			actual = append(actual, pair)
+		}
+		sort.Slice(actual, func(i, j int) bool { return actual[i].Key < actual[j].Key })
+		expected := []congomap.Pair[string, int]{{"forever", 3}, {"ttl", 1}}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Which: %s; Actual: %v; Expected: %v", which, actual, expected)
+		}
+
+		cgm.GC()
+		if actual, expected := reapedValues(), []int{2}; !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Which: %s; Actual: %v; Expected: %v", which, actual, expected)
+		}
+		if err = cgm.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if actual, expected := reapedValues(), []int{1, 2, 3}; !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Which: %s; Actual: %v; Expected: %v", which, actual, expected)
+		}
+	})
+}
+
+func TestConcurrentLoadStore(t *testing.T) {
+	forEach(t, func(t *testing.T, newCongomap newCongomap, which string) {
+		cgm, err := newCongomap(congomap.Lookup(func(key string) (int, error) { return len(key), nil }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = cgm.Close() }()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for _, key := range []string{"a", "bb", "ccc"} {
+					if value, err := cgm.LoadStore(key); value != len(key) || err != nil {
+						t.Errorf("Which: %s; Actual: %#v, %#v; Expected: %#v, %#v", which, value, err, len(key), nil)
+					}
+					cgm.Store(key, len(key))
+					if i%4 == 0 {
+						cgm.Delete(key)
+					}
+				}
+				cgm.GC()
+			}(i)
+		}
+		wg.Wait()
+	})
+}